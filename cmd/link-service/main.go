@@ -4,17 +4,19 @@ import (
 	"context"
 	"errors"
 	"flag"
+	"io"
 	stdlog "log"
 	"net/http"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"go.uber.org/zap"
 
+	"link-service/internal/clock"
 	"link-service/internal/config"
 	"link-service/internal/logger"
-	filesystem "link-service/internal/repository/file_system"
+	"link-service/internal/repository"
+	"link-service/internal/repository/cache"
 	"link-service/internal/server"
 	"link-service/internal/service"
 )
@@ -43,23 +45,51 @@ func main() {
 	}
 	defer log.Sync()
 
-	storage, err := filesystem.New(&cfg.Storage, log)
+	// Backends register themselves on import; config imports all of them for
+	// their config sections.
+	storage, err := repository.NewFromConfig(cfg, log)
 	if err != nil {
 		log.Fatal("cannot initialize storage: %v", zap.Error(err))
 		return
 	}
 
-	srv := service.New(storage, &cfg.Service, log)
-	err = srv.ProcessTempRecords()
+	if cfg.SecondaryStorageType != "" {
+		secondary, err := repository.NewFromConfig(cfg.SecondaryStorage(), log)
+		if err != nil {
+			log.Fatal("cannot initialize secondary storage", zap.Error(err))
+		}
+
+		storage = repository.NewDualWrite(storage, secondary, log)
+		log.Info("dual-write mode enabled",
+			zap.String("primary", cfg.StorageType), zap.String("secondary", cfg.SecondaryStorageType))
+		log.Warn("the storage maintenance endpoints are disabled in dual-write mode",
+			zap.Strings("endpoints", []string{"/admin/reindex", "/admin/compact", "/admin/backup", "/admin/restore", "/export/json"}))
+	}
+
+	if cfg.Cache.Size > 0 {
+		storage = cache.New(storage, &cfg.Cache, clock.Real{}, log)
+		log.Info("record cache enabled", zap.Int("size", cfg.Cache.Size), zap.Duration("ttl", cfg.Cache.TTL))
+	}
+
+	srv := service.New(storage, &cfg.Service, clock.Real{}, log)
+	err = srv.ProcessTempRecords(ctx)
 	if err != nil {
 		log.Fatal("failed to process temp records: %v", zap.Error(err))
 	}
 
+	go srv.RunHealthChecks(ctx)
+	go srv.RunRetention(ctx)
+
 	serv := server.New(ctx, srv, &cfg.Logger, &cfg.HTTPServer, log, storage)
 
+	ln, err := server.Listen(&cfg.HTTPServer)
+	if err != nil {
+		log.Fatal("failed to listen", zap.Error(err))
+	}
+
 	go func() {
-		log.Info("starting http server", zap.String("addr", serv.Addr))
-		if err := serv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Info("starting http server", zap.String("addr", ln.Addr().String()))
+		if err := serv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Error("failed to start server", zap.Error(err))
 		}
 	}()
@@ -67,7 +97,27 @@ func main() {
 	<-ctx.Done()
 	log.Info("received shutdown signal")
 
-	time.Sleep(cfg.HTTPServer.ShutdownTimeout)
+	// Shutdown closes the listener, which also removes the Unix socket file.
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
+	defer shutdownCancel()
+
+	err = serv.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Error("failed to shut down http server", zap.Error(err))
+	}
+
+	// Jobs stop with the server context; wait for them to give back their
+	// IDs before the storage is closed.
+	srv.WaitJobs()
+
+	// Closing the storage flushes what is only held in memory, e.g. the
+	// in-memory snapshot.
+	if closer, ok := storage.(io.Closer); ok {
+		err = closer.Close()
+		if err != nil {
+			log.Error("failed to close storage", zap.Error(err))
+		}
+	}
 
 	log.Info("application shutdown completed successfully")
 }
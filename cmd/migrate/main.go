@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	stdlog "log"
+	"os/signal"
+	"syscall"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/config"
+	"link-service/internal/repository"
+	filesystem "link-service/internal/repository/file_system"
+)
+
+// migrate copies all records from STORAGE_TYPE to STORAGE_SECONDARY_TYPE. Run
+// it while the service is in dual-write mode to backfill the records written
+// before the secondary storage was added; it can be rerun safely.
+//
+// With -reshard it copies the records of the filesystem storage to a new
+// storage dir with that many shards instead. Stop the service first and
+// point STORAGE_DIR_PATH to the new dir and STORAGE_SHARDS to the new count
+// once it is done.
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	cfgPath, reshard, targetDir := fetchFlags()
+	if cfgPath == "" {
+		stdlog.Fatal("config file path must be specified")
+	}
+
+	cfg, err := config.New(cfgPath)
+	if err != nil {
+		stdlog.Fatalf("cannot initialize config: %v", err)
+	}
+
+	target := cfg.SecondaryStorageType
+	var dstCfg repository.Config = cfg.SecondaryStorage()
+	switch {
+	case reshard > 0:
+		if cfg.StorageType != config.StorageTypeFilesystem {
+			stdlog.Fatal("-reshard needs STORAGE_TYPE filesystem")
+		}
+		if targetDir == "" {
+			stdlog.Fatal("-target_dir must be set to the dir of the resharded storage")
+		}
+
+		target = fmt.Sprintf("%d shards in %s", reshard, targetDir)
+		dstCfg = reshardConfig(cfg, reshard, targetDir)
+
+	case cfg.SecondaryStorageType == "":
+		stdlog.Fatal("STORAGE_SECONDARY_TYPE must be set to the migration target")
+	}
+
+	src, err := repository.NewFromConfig(cfg, zap.NewNop())
+	if err != nil {
+		stdlog.Fatalf("cannot open source storage: %v", err)
+	}
+	defer closeStorage(src)
+
+	dst, err := repository.NewFromConfig(dstCfg, zap.NewNop())
+	if err != nil {
+		stdlog.Fatalf("cannot open target storage: %v", err)
+	}
+	defer closeStorage(dst)
+
+	migrated, err := repository.MigrateAll(ctx, src, dst)
+	if err != nil {
+		stdlog.Printf("migrated %d records before failing", migrated)
+		// Fatalf skips the deferred calls, so close the target here to
+		// keep the records migrated so far.
+		closeStorage(dst)
+		stdlog.Fatalf("cannot migrate records: %v", err)
+	}
+
+	stdlog.Printf("migrated %d records from %s to %s", migrated, cfg.StorageType, target)
+}
+
+// reshardSelection selects a copy of the filesystem storage config with
+// another dir and shard count.
+type reshardSelection struct {
+	section *filesystem.Config
+}
+
+func reshardConfig(cfg *config.Config, shards int, dir string) reshardSelection {
+	section := cfg.Storage
+	section.DirPath = dir
+	section.Shards = shards
+
+	return reshardSelection{section: &section}
+}
+
+func (s reshardSelection) StorageSection() (string, any) {
+	return config.StorageTypeFilesystem, s.section
+}
+
+func closeStorage(repo repository.Repository) {
+	if closer, ok := repo.(io.Closer); ok {
+		err := closer.Close()
+		if err != nil {
+			stdlog.Printf("cannot close storage: %v", err)
+		}
+	}
+}
+
+func fetchFlags() (string, int, string) {
+	var (
+		cfgPath   string
+		reshard   int
+		targetDir string
+	)
+
+	flag.StringVar(&cfgPath, "config_path", "", "path to config file")
+	flag.IntVar(&reshard, "reshard", 0, "copy the filesystem storage to a storage with this many shards")
+	flag.StringVar(&targetDir, "target_dir", "", "storage dir of the resharded storage")
+	flag.Parse()
+
+	return cfgPath, reshard, targetDir
+}
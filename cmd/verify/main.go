@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	stdlog "log"
+	"path/filepath"
+
+	"link-service/internal/config"
+	"link-service/internal/domain"
+	filesystem "link-service/internal/repository/file_system"
+)
+
+func main() {
+	cfgPath := fetchConfigPath()
+	if cfgPath == "" {
+		stdlog.Fatal("config file path must be specified")
+	}
+
+	cfg, err := config.New(cfgPath)
+	if err != nil {
+		stdlog.Fatalf("cannot initialize config: %v", err)
+	}
+
+	paths, err := filesystem.MainFilePaths(&cfg.Storage)
+	if err != nil {
+		stdlog.Fatalf("cannot list storage files: %v", err)
+	}
+	paths = append(paths, filepath.Join(cfg.Storage.DirPath, cfg.Storage.TempFileName))
+
+	corrupted := 0
+	for _, path := range paths {
+		n, err := verifyFile(path)
+		if err != nil {
+			stdlog.Fatalf("cannot verify file %s: %v", path, err)
+		}
+
+		corrupted += n
+	}
+
+	if corrupted > 0 {
+		stdlog.Fatalf("found %d corrupted lines", corrupted)
+	}
+
+	stdlog.Print("storage files are valid")
+}
+
+// verifyFile reports every line of the file that fails its checksum or cannot
+// be decoded as a record and returns the number of such lines.
+func verifyFile(path string) (int, error) {
+	file, err := filesystem.OpenMainFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	corrupted := 0
+	lineNum := 0
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNum++
+
+		err = filesystem.VerifyLine(scanner.Bytes())
+		if err != nil {
+			stdlog.Printf("%s:%d: %v", path, lineNum, err)
+			corrupted++
+			continue
+		}
+
+		var rec domain.Record
+		err = json.Unmarshal(scanner.Bytes(), &rec)
+		if err != nil {
+			stdlog.Printf("%s:%d: %v", path, lineNum, err)
+			corrupted++
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan file: %w", err)
+	}
+
+	return corrupted, nil
+}
+
+func fetchConfigPath() string {
+	var cfgPath string
+
+	flag.StringVar(&cfgPath, "config_path", "", "path to config file")
+	flag.Parse()
+
+	return cfgPath
+}
@@ -0,0 +1,20 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2025, 11, 30, 12, 0, 0, 0, time.UTC)
+	clk := NewFake(start)
+	assert.Equal(t, start, clk.Now())
+
+	clk.Advance(time.Hour)
+	assert.Equal(t, start.Add(time.Hour), clk.Now())
+
+	clk.Set(start)
+	assert.Equal(t, start, clk.Now())
+}
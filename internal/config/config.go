@@ -1,21 +1,52 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/ilyakaznacheev/cleanenv"
 
 	"link-service/internal/logger"
+	"link-service/internal/repository"
+	"link-service/internal/repository/bolt"
+	"link-service/internal/repository/cache"
 	filesystem "link-service/internal/repository/file_system"
+	"link-service/internal/repository/inmemory"
+	"link-service/internal/repository/postgres"
+	"link-service/internal/repository/redis"
+	"link-service/internal/repository/s3"
+	"link-service/internal/repository/sqlite"
 	"link-service/internal/server"
 	"link-service/internal/service"
 )
 
+const (
+	StorageTypeFilesystem = filesystem.StorageType
+	StorageTypeBolt       = bolt.StorageType
+	StorageTypePostgres   = postgres.StorageType
+	StorageTypeSQLite     = sqlite.StorageType
+	StorageTypeRedis      = redis.StorageType
+	StorageTypeInMemory   = inmemory.StorageType
+	StorageTypeS3         = s3.StorageType
+)
+
 type Config struct {
-	HTTPServer server.Config
-	Storage    filesystem.Config
-	Service    service.Config
-	Logger     logger.Config
+	HTTPServer  server.Config
+	StorageType string `env:"STORAGE_TYPE" env-default:"filesystem"`
+	// SecondaryStorageType enables dual-write mode: every write also goes to
+	// this storage, while reads stay on STORAGE_TYPE.
+	SecondaryStorageType string `env:"STORAGE_SECONDARY_TYPE"`
+	Storage              filesystem.Config
+	Bolt                 bolt.Config
+	Postgres             postgres.Config
+	SQLite               sqlite.Config
+	Redis                redis.Config
+	InMemory             inmemory.Config
+	S3                   s3.Config
+	Cache                cache.Config
+	Service              service.Config
+	Logger               logger.Config
 }
 
 func New(path string) (*Config, error) {
@@ -25,5 +56,85 @@ func New(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
 
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+
 	return &cfg, nil
 }
+
+// Validate collects the validation errors of every config section. Only the
+// sections of the selected storage types are validated.
+func (c *Config) Validate() error {
+	return errors.Join(
+		c.HTTPServer.Validate(),
+		c.validateStorage(),
+		c.Cache.Validate(),
+		c.Service.Validate(),
+		c.Logger.Validate(),
+	)
+}
+
+// StorageSection returns the selected storage type and its config section,
+// or nil if the type is unknown. It implements repository.Config.
+func (c *Config) StorageSection() (string, any) {
+	return c.StorageType, c.section(c.StorageType)
+}
+
+// SecondaryStorage selects the dual-write storage, see SecondaryStorageType.
+func (c *Config) SecondaryStorage() repository.Config {
+	return storageSelection{storageType: c.SecondaryStorageType, section: c.section(c.SecondaryStorageType)}
+}
+
+func (c *Config) section(storageType string) any {
+	switch storageType {
+	case StorageTypeFilesystem:
+		return &c.Storage
+	case StorageTypeBolt:
+		return &c.Bolt
+	case StorageTypePostgres:
+		return &c.Postgres
+	case StorageTypeSQLite:
+		return &c.SQLite
+	case StorageTypeRedis:
+		return &c.Redis
+	case StorageTypeInMemory:
+		return &c.InMemory
+	case StorageTypeS3:
+		return &c.S3
+	default:
+		return nil
+	}
+}
+
+func (c *Config) validateStorage() error {
+	err := c.validateStorageType("STORAGE_TYPE", c.StorageType)
+	if c.SecondaryStorageType == "" {
+		return err
+	}
+
+	if c.SecondaryStorageType == c.StorageType {
+		return errors.Join(err, errors.New("STORAGE_SECONDARY_TYPE must differ from STORAGE_TYPE"))
+	}
+
+	return errors.Join(err, c.validateStorageType("STORAGE_SECONDARY_TYPE", c.SecondaryStorageType))
+}
+
+func (c *Config) validateStorageType(key, storageType string) error {
+	validator, ok := c.section(storageType).(interface{ Validate() error })
+	if !ok {
+		return fmt.Errorf("%s must be one of %s, got %q",
+			key, strings.Join(repository.StorageTypes(), ", "), storageType)
+	}
+
+	return validator.Validate()
+}
+
+type storageSelection struct {
+	storageType string
+	section     any
+}
+
+func (s storageSelection) StorageSection() (string, any) {
+	return s.storageType, s.section
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewReportsAllMissingKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "partial.env")
+	err := os.WriteFile(path, []byte("HTTP_PORT=8080\nSTORAGE_FILE_NAME=data.json\n"), 0644)
+	assert.NoError(t, err)
+
+	_, err = New(path)
+	assert.Error(t, err)
+
+	for _, key := range []string{
+		"HTTP_HOST",
+		"HTTP_OPERATION_TIMEOUT",
+		"HTTP_SHUTDOWN_TIMEOUT",
+		"STORAGE_DIR_PATH",
+		"STORAGE_TEMP_FILE_NAME",
+		"SERVICE_PING_TIMEOUT",
+		"LOGGER",
+	} {
+		assert.ErrorContains(t, err, key)
+	}
+
+	assert.NotContains(t, err.Error(), "HTTP_PORT")
+	assert.NotContains(t, err.Error(), "STORAGE_FILE_NAME")
+}
+
+func TestValidateStorageType(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bolt.env")
+	err := os.WriteFile(path, []byte("STORAGE_TYPE=bolt\n"), 0644)
+	assert.NoError(t, err)
+
+	_, err = New(path)
+	assert.ErrorContains(t, err, "BOLT_PATH")
+	assert.NotContains(t, err.Error(), "STORAGE_DIR_PATH")
+
+	err = os.WriteFile(path, []byte("STORAGE_TYPE=postgres\n"), 0644)
+	assert.NoError(t, err)
+
+	_, err = New(path)
+	assert.ErrorContains(t, err, "POSTGRES_DSN")
+	assert.NotContains(t, err.Error(), "BOLT_PATH")
+
+	err = os.WriteFile(path, []byte("STORAGE_TYPE=sqlite\n"), 0644)
+	assert.NoError(t, err)
+
+	_, err = New(path)
+	assert.ErrorContains(t, err, "SQLITE_PATH")
+
+	err = os.WriteFile(path, []byte("STORAGE_TYPE=redis\n"), 0644)
+	assert.NoError(t, err)
+
+	_, err = New(path)
+	assert.ErrorContains(t, err, "REDIS_ADDR")
+
+	err = os.WriteFile(path, []byte("STORAGE_TYPE=inmemory\nINMEMORY_SNAPSHOT_INTERVAL=-1s\n"), 0644)
+	assert.NoError(t, err)
+
+	_, err = New(path)
+	assert.ErrorContains(t, err, "INMEMORY_SNAPSHOT_INTERVAL")
+
+	err = os.WriteFile(path, []byte("STORAGE_TYPE=s3\n"), 0644)
+	assert.NoError(t, err)
+
+	_, err = New(path)
+	assert.ErrorContains(t, err, "S3_ENDPOINT")
+	assert.ErrorContains(t, err, "S3_BUCKET")
+
+	err = os.WriteFile(path, []byte("STORAGE_TYPE=filesystem\nSTORAGE_SECONDARY_TYPE=s3\n"), 0644)
+	assert.NoError(t, err)
+
+	_, err = New(path)
+	assert.ErrorContains(t, err, "S3_ENDPOINT")
+	assert.ErrorContains(t, err, "STORAGE_DIR_PATH")
+
+	err = os.WriteFile(path, []byte("STORAGE_TYPE=inmemory\nSTORAGE_SECONDARY_TYPE=inmemory\n"), 0644)
+	assert.NoError(t, err)
+
+	_, err = New(path)
+	assert.ErrorContains(t, err, "STORAGE_SECONDARY_TYPE must differ")
+
+	err = os.WriteFile(path, []byte("STORAGE_TYPE=tape\n"), 0644)
+	assert.NoError(t, err)
+
+	_, err = New(path)
+	assert.ErrorContains(t, err, "STORAGE_TYPE")
+}
@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Record event types describe a step in the lifecycle of a record.
+const (
+	EventTypeCreated = "created"
+	EventTypeUpdated = "updated"
+	EventTypeDeleted = "deleted"
+	EventTypeChecked = "checked"
+)
+
+type RecordEvent struct {
+	RecordID   int64     `json:"record_id"`
+	EventType  string    `json:"event_type"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Detail     string    `json:"detail,omitempty"`
+}
+
+// StatusesDetail formats link statuses as an event detail, ordered by link.
+func StatusesDetail(links map[string]string) string {
+	keys := make([]string, 0, len(links))
+	for link := range links {
+		keys = append(keys, link)
+	}
+	slices.Sort(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, link := range keys {
+		parts = append(parts, link+": "+links[link])
+	}
+
+	return strings.Join(parts, ", ")
+}
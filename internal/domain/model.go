@@ -1,8 +1,91 @@
 package domain
 
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Record sources describe how a record was created.
+const (
+	SourceAPI       = "api"
+	SourceImport    = "import"
+	SourceScheduler = "scheduler"
+)
+
+// Link statuses set by the checker. Reports count every status other than
+// StatusAvailable as broken.
+const (
+	StatusAvailable    = "available"
+	StatusNotAvailable = "not available"
+	StatusUnknown      = "unknown"
+)
+
 type Record struct {
-	Links map[string]string `json:"links"`
-	ID    int64             `json:"links_num"`
+	Links     map[string]string `json:"links"`
+	ID        int64             `json:"links_num"`
+	Source    string            `json:"source,omitempty"`
+	Tags      []string          `json:"tags,omitempty"`
+	CreatedAt time.Time         `json:"created_at,omitzero"`
+	UpdatedAt time.Time         `json:"updated_at,omitzero"`
+	// TTL makes the record expire that long after it is saved in storages
+	// that support expiry. Zero means the storage default.
+	TTL time.Duration `json:"-"`
+}
+
+// LinkCount returns the number of links in the record.
+func (r *Record) LinkCount() int {
+	return len(r.Links)
+}
+
+// HasTag reports whether the record is labeled with the tag.
+func (r *Record) HasTag(tag string) bool {
+	return slices.Contains(r.Tags, tag)
+}
+
+// ModifiedSince reports whether the record was created or updated at or after t.
+func (r *Record) ModifiedSince(t time.Time) bool {
+	return !r.CreatedAt.Before(t) || !r.UpdatedAt.Before(t)
+}
+
+// String summarizes the record for logs, e.g. "Record{ID:1, Links:3, Tags:[prod]}".
+func (r Record) String() string {
+	return fmt.Sprintf("Record{ID:%d, Links:%d, Tags:%v}", r.ID, len(r.Links), r.Tags)
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler so records can be logged
+// with zap.Object keeping the field types.
+func (r Record) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddInt64("id", r.ID)
+	enc.AddInt("links", len(r.Links))
+
+	if r.Source != "" {
+		enc.AddString("source", r.Source)
+	}
+
+	if len(r.Tags) > 0 {
+		err := enc.AddArray("tags", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+			for _, tag := range r.Tags {
+				arr.AppendString(tag)
+			}
+			return nil
+		}))
+		if err != nil {
+			return err
+		}
+	}
+
+	if !r.CreatedAt.IsZero() {
+		enc.AddTime("created_at", r.CreatedAt)
+	}
+
+	if !r.UpdatedAt.IsZero() {
+		enc.AddTime("updated_at", r.UpdatedAt)
+	}
+
+	return nil
 }
 
 type TempRecord struct {
@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRecordString(t *testing.T) {
+	rec := &Record{
+		ID:    1,
+		Links: map[string]string{"a.com": "available", "b.com": "available", "c.com": "not available"},
+		Tags:  []string{"prod"},
+	}
+
+	assert.Equal(t, "Record{ID:1, Links:3, Tags:[prod]}", rec.String())
+	assert.Equal(t, "Record{ID:2, Links:0, Tags:[]}", Record{ID: 2}.String())
+}
+
+func TestRecordMarshalLogObject(t *testing.T) {
+	createdAt := time.Date(2025, 11, 30, 12, 0, 0, 0, time.UTC)
+	rec := Record{
+		ID:        1,
+		Links:     map[string]string{"a.com": "available"},
+		Source:    SourceAPI,
+		Tags:      []string{"prod", "eu"},
+		CreatedAt: createdAt,
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	err := rec.MarshalLogObject(enc)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"id":         int64(1),
+		"links":      1,
+		"source":     SourceAPI,
+		"tags":       []any{"prod", "eu"},
+		"created_at": createdAt,
+	}, enc.Fields)
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/repository"
+	"link-service/internal/service"
+)
+
+// BackupRestorer writes and restores tar.gz archives of a storage.
+type BackupRestorer interface {
+	Backup(w io.Writer) error
+	Restore(r io.Reader) error
+}
+
+// Purger drops the records cached in front of a storage.
+type Purger interface {
+	Purge()
+}
+
+// Backup streams a tar.gz of the storage. A failure after the archive has
+// started can only be logged, the client sees a truncated archive.
+func Backup(backupRestorer BackupRestorer, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := fmt.Sprintf("backup-%s.tar.gz", time.Now().UTC().Format("20060102T150405Z"))
+
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+
+		err := backupRestorer.Backup(w)
+		if err != nil {
+			logger.Error("failed to back up storage", zap.Error(err))
+		}
+	}
+}
+
+// Restore replaces the storage with the tar.gz in the request body and
+// answers 204 once it is done. The records cached in front of the storage
+// are dropped if purger is not nil, and the ID counter of the service is
+// moved past the restored records, so new records do not reuse their IDs.
+func Restore(srv *service.Service, backupRestorer BackupRestorer, purger Purger, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		err := backupRestorer.Restore(r.Body)
+		if errors.Is(err, repository.ErrReadOnlyStorage) {
+			http.Error(w, "storage is read-only", http.StatusForbidden)
+			logger.Warn("failed to restore storage", zap.Error(err))
+			return
+		}
+		if errors.Is(err, repository.ErrInvalidBackup) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			logger.Warn("failed to restore storage", zap.Error(err))
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to restore storage", http.StatusInternalServerError)
+			logger.Error("failed to restore storage", zap.Error(err))
+			return
+		}
+
+		if purger != nil {
+			purger.Purge()
+		}
+		srv.SyncCounter(r.Context())
+
+		logger.Info("restore completed", zap.Duration("duration", time.Since(start)))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
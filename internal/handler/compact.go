@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/repository"
+)
+
+// Compactor drops the superseded versions of records from a storage.
+type Compactor interface {
+	Compact() (int, error)
+}
+
+type compactResponse struct {
+	Dropped int `json:"dropped"`
+}
+
+// Compact compacts the storage and answers with the number of dropped lines
+// once it is done.
+func Compact(compactor Compactor, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		dropped, err := compactor.Compact()
+		if errors.Is(err, repository.ErrReadOnlyStorage) {
+			http.Error(w, "storage is read-only", http.StatusForbidden)
+			logger.Warn("failed to compact", zap.Error(err))
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to compact", http.StatusInternalServerError)
+			logger.Error("failed to compact", zap.Error(err))
+			return
+		}
+
+		logger.Info("compaction completed", zap.Int("dropped", dropped), zap.Duration("duration", time.Since(start)))
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(compactResponse{Dropped: dropped})
+		if err != nil {
+			logger.Warn("failed to encode response", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// hasContentType reports whether the request media type is mediaType,
+// ignoring parameters such as charset.
+func hasContentType(r *http.Request, mediaType string) bool {
+	got, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+
+	return got == mediaType
+}
+
+// mediaRange is a media range of an Accept header with its quality.
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+// negotiateContentType returns the offer preferred by the Accept header of the
+// request. Each offer gets the quality of the most specific media range that
+// matches it, and ties go to the earlier offer. Without an Accept header the
+// first offer is returned. It returns "" when no offer is acceptable.
+func negotiateContentType(r *http.Request, offers []string) string {
+	header := r.Header.Get("Accept")
+	if strings.TrimSpace(header) == "" {
+		return offers[0]
+	}
+
+	ranges := parseAccept(header)
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q := acceptQuality(ranges, offer)
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
+
+// parseAccept parses the media ranges of an Accept header. Malformed ranges
+// and ranges with an invalid quality are skipped.
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+
+		ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+	}
+
+	return ranges
+}
+
+// acceptQuality returns the quality of the most specific range matching the
+// media type, or 0 if none matches.
+func acceptQuality(ranges []mediaRange, mediaType string) float64 {
+	typ, _, _ := strings.Cut(mediaType, "/")
+
+	q, specificity := 0.0, 0
+	for _, mr := range ranges {
+		s := 0
+		switch mr.mediaType {
+		case mediaType:
+			s = 3
+		case typ + "/*":
+			s = 2
+		case "*/*":
+			s = 1
+		}
+
+		if s > specificity {
+			q, specificity = mr.q, s
+		}
+	}
+
+	return q
+}
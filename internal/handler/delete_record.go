@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+
+	"link-service/internal/repository"
+	"link-service/internal/service"
+)
+
+// DeleteRecord deletes a record and answers 204. The deletion is logged with
+// the client address, user agent and request ID, since there is no user
+// identity to record.
+func DeleteRecord(srv *service.Service, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "record id must be a positive integer", http.StatusBadRequest)
+			logger.Warn("invalid record id", zap.String("id", chi.URLParam(r, "id")))
+			return
+		}
+
+		err = srv.DeleteRecord(r.Context(), id)
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			http.Error(w, "record not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, repository.ErrReadOnlyStorage) {
+			http.Error(w, "storage is read-only", http.StatusForbidden)
+			logger.Warn("failed to delete record", zap.Int64("id", id), zap.Error(err))
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to delete record", http.StatusInternalServerError)
+			logger.Error("failed to delete record", zap.Int64("id", id), zap.Error(err))
+			return
+		}
+
+		logger.Info("record deleted",
+			zap.Int64("id", id),
+			zap.String("remote_addr", r.RemoteAddr),
+			zap.String("user_agent", r.UserAgent()),
+			zap.String("request_id", middleware.GetReqID(r.Context())),
+		)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
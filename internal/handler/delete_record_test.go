@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+	"link-service/internal/repository/inmemory"
+	"link-service/internal/service"
+)
+
+func TestDeleteRecord(t *testing.T) {
+	repo, err := inmemory.New(&inmemory.Config{}, clock.Real{}, zap.NewNop())
+	assert.NoError(t, err)
+
+	err = repo.SaveRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "available"}})
+	assert.NoError(t, err)
+
+	srv := service.New(repo, &service.Config{PingTimeout: time.Second}, clock.Real{}, zap.NewNop())
+
+	router := chi.NewRouter()
+	router.Delete("/records/{id}", DeleteRecord(srv, zap.NewNop()))
+
+	updates, cancel := srv.SubscribeUpdates()
+	defer cancel()
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{name: "deleted", path: "/records/1", wantStatus: http.StatusNoContent},
+		{name: "already deleted", path: "/records/1", wantStatus: http.StatusNotFound},
+		{name: "invalid id", path: "/records/abc", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, tt.path, nil))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+
+	_, err = repo.GetRecord(context.Background(), 1)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	update := <-updates
+	assert.Equal(t, domain.EventTypeDeleted, update.Type)
+	assert.Equal(t, int64(1), update.Record.ID)
+	assert.Empty(t, updates)
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"encoding/csv"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+// csvFlushRows is the number of CSV rows written between flushes to the
+// client.
+const csvFlushRows = 500
+
+// ExportCSV streams records as CSV rows of id, url, status and checked_at,
+// one row per link. The ids query parameter, a comma-separated ID list,
+// selects records in ID order; otherwise the from and to parameters bound
+// the creation time and all matching records are streamed in storage order.
+// It is served as /records/export.csv, the URLFormat middleware strips the
+// extension before routing.
+func ExportCSV(repo repository.Repository, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format, _ := r.Context().Value(middleware.URLFormatCtxKey).(string)
+		if format != "" && format != "csv" {
+			http.NotFound(w, r)
+			return
+		}
+
+		ids, err := parseIDList(r.URL.Query().Get("ids"))
+		if err != nil {
+			http.Error(w, err.Error()+" in ids", http.StatusBadRequest)
+			logger.Warn("invalid ids", zap.Error(err))
+			return
+		}
+
+		from, to, err := parseTimeRange(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			logger.Warn("invalid time range", zap.Error(err))
+			return
+		}
+
+		query := repository.SearchQuery{From: from, To: to}
+
+		var records map[int64]*domain.Record
+		if len(ids) > 0 {
+			records, err = repo.GetRecords(r.Context(), ids)
+			if err != nil {
+				http.Error(w, "failed to get records", http.StatusInternalServerError)
+				logger.Error("failed to get records", zap.Error(err))
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", mediaTypeCSV)
+		w.Header().Set("Content-Disposition", "attachment; filename=records.csv")
+
+		cw := csv.NewWriter(w)
+		rows := 0
+		write := func(rec *domain.Record) error {
+			if !query.Match(rec) {
+				return nil
+			}
+
+			n, err := writeCSVRecord(cw, rec)
+			if err != nil {
+				return err
+			}
+
+			rows += n
+			if rows >= csvFlushRows {
+				rows = 0
+				cw.Flush()
+				return cw.Error()
+			}
+
+			return nil
+		}
+
+		err = cw.Write([]string{"id", "url", "status", "checked_at"})
+		if err != nil {
+			logger.Error("failed to write csv", zap.Error(err))
+			return
+		}
+
+		if len(ids) > 0 {
+			slices.Sort(ids)
+			for _, id := range slices.Compact(ids) {
+				rec, ok := records[id]
+				if !ok {
+					continue
+				}
+
+				err = write(rec)
+				if err != nil {
+					break
+				}
+			}
+		} else {
+			err = repo.ForEachRecord(r.Context(), write)
+		}
+		if err != nil {
+			// The header is sent, so the client gets a truncated file.
+			logger.Error("failed to export records", zap.Error(err))
+			return
+		}
+
+		cw.Flush()
+
+		err = cw.Error()
+		if err != nil {
+			logger.Error("failed to write csv", zap.Error(err))
+		}
+	}
+}
+
+// writeCSVRecord writes a row per link of the record, with the links sorted,
+// and returns the number of rows. checked_at is the last update of the
+// record, or its creation if it was never updated.
+func writeCSVRecord(cw *csv.Writer, rec *domain.Record) (int, error) {
+	id := strconv.FormatInt(rec.ID, 10)
+
+	checkedAt := rec.UpdatedAt
+	if checkedAt.IsZero() {
+		checkedAt = rec.CreatedAt
+	}
+
+	checked := ""
+	if !checkedAt.IsZero() {
+		checked = checkedAt.UTC().Format(time.RFC3339)
+	}
+
+	links := make([]string, 0, len(rec.Links))
+	for link := range rec.Links {
+		links = append(links, link)
+	}
+	slices.Sort(links)
+
+	for _, link := range links {
+		err := cw.Write([]string{id, link, rec.Links[link], checked})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(links), nil
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository/inmemory"
+)
+
+func TestExportCSV(t *testing.T) {
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(jan)
+
+	repo, err := inmemory.New(&inmemory.Config{}, clk, zap.NewNop())
+	assert.NoError(t, err)
+
+	assert.NoError(t, repo.SaveRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"b.com": "not available", "a.com": "available"}}))
+	clk.Advance(31 * 24 * time.Hour)
+	assert.NoError(t, repo.SaveRecord(context.Background(), &domain.Record{ID: 2, Links: map[string]string{"c.com": "available"}}))
+
+	router := chi.NewRouter()
+	router.Use(middleware.URLFormat)
+	router.Get("/records/export", ExportCSV(repo, zap.NewNop()))
+
+	header := "id,url,status,checked_at\n"
+	first := "1,a.com,available,2025-01-01T00:00:00Z\n1,b.com,not available,2025-01-01T00:00:00Z\n"
+	second := "2,c.com,available,2025-02-01T00:00:00Z\n"
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "all", target: "/records/export.csv", wantStatus: http.StatusOK, wantBody: header + first + second},
+		{name: "ids", target: "/records/export.csv?ids=2,3,2", wantStatus: http.StatusOK, wantBody: header + second},
+		{name: "from", target: "/records/export.csv?from=2025-01-15T00:00:00Z", wantStatus: http.StatusOK, wantBody: header + second},
+		{name: "ids and to", target: "/records/export.csv?ids=1,2&to=2025-01-15T00:00:00Z", wantStatus: http.StatusOK, wantBody: header + first},
+		{name: "invalid ids", target: "/records/export.csv?ids=1,x", wantStatus: http.StatusBadRequest},
+		{name: "invalid to", target: "/records/export.csv?to=now", wantStatus: http.StatusBadRequest},
+		{name: "other format", target: "/records/export.xml", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+func ExportNDJSON(exporter io.WriterTo, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+
+		_, err := exporter.WriteTo(w)
+		if err != nil {
+			logger.Error("failed to export records", zap.Error(err))
+		}
+	}
+}
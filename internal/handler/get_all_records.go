@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+// GetAllRecords writes the records matching the filter query parameters as a
+// JSON array. With any of the offset, limit and order parameters it writes a
+// page of all records instead, see listRecords.
+func GetAllRecords(repo repository.Repository, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if isPageRequest(r.URL.Query()) {
+			listRecords(w, r, repo, logger)
+			return
+		}
+
+		var opts []repository.FilterOption
+
+		if v := r.URL.Query().Get("min_links"); v != "" {
+			minLinks, err := strconv.Atoi(v)
+			if err != nil || minLinks < 0 {
+				http.Error(w, "invalid min_links", http.StatusBadRequest)
+				logger.Warn("invalid min_links", zap.String("min_links", v))
+				return
+			}
+
+			opts = append(opts, repository.WithMinLinks(minLinks))
+		}
+
+		if v := r.URL.Query().Get("max_links"); v != "" {
+			maxLinks, err := strconv.Atoi(v)
+			if err != nil || maxLinks < 0 {
+				http.Error(w, "invalid max_links", http.StatusBadRequest)
+				logger.Warn("invalid max_links", zap.String("max_links", v))
+				return
+			}
+
+			opts = append(opts, repository.WithMaxLinks(maxLinks))
+		}
+
+		if v := r.URL.Query().Get("source"); v != "" {
+			opts = append(opts, repository.WithSource(v))
+		}
+
+		if v := r.URL.Query().Get("tag"); v != "" {
+			opts = append(opts, repository.WithTag(v))
+		}
+
+		var (
+			records []domain.Record
+			err     error
+		)
+
+		if v := r.URL.Query().Get("since"); v != "" {
+			since, perr := time.Parse(time.RFC3339, v)
+			if perr != nil {
+				http.Error(w, "invalid since", http.StatusBadRequest)
+				logger.Warn("invalid since", zap.String("since", v))
+				return
+			}
+
+			records, err = sinceRecords(r.Context(), repo, since, opts)
+		} else {
+			records, err = repo.GetAllRecords(r.Context(), opts...)
+		}
+		if err != nil {
+			http.Error(w, "failed to get records", http.StatusInternalServerError)
+			logger.Error("failed to get records", zap.Error(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err = json.NewEncoder(w).Encode(records)
+		if err != nil {
+			logger.Warn("failed to encode response", zap.Error(err))
+		}
+	}
+}
+
+// sinceRecords returns the records modified at or after since that also match
+// the filters, ordered by UpdatedAt.
+func sinceRecords(ctx context.Context, repo repository.Repository, since time.Time, opts []repository.FilterOption) ([]domain.Record, error) {
+	records, err := repo.GetRecordsSince(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := repository.NewFilter(opts...)
+	matched := records[:0]
+	for _, rec := range records {
+		if filter.Match(&rec) {
+			matched = append(matched, rec)
+		}
+	}
+
+	return matched, nil
+}
@@ -1,56 +1,343 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"go.uber.org/zap"
 
-	"github.com/jung-kurt/gofpdf"
-
+	"link-service/internal/report"
+	"link-service/internal/report/pdf"
 	"link-service/internal/repository"
 )
 
+// maxFormMemory is the part of a multipart form kept in memory, the rest is
+// stored in temporary files.
+const maxFormMemory = 1 << 20
+
 type getLinksRequest struct {
 	LinksList []int64 `json:"links_list"`
 }
 
-func GetLinks(repo repository.Repository, logger *zap.Logger) http.HandlerFunc {
+// GetLinks renders the requested records as a PDF, CSV, JSON, XLSX or HTML
+// report. The format is negotiated from the Accept header or set with the
+// format query parameter, PDF being the default. The summary of the links is
+// sent in X-Report-* headers, and the PDF and HTML reports also start with
+// it.
+func GetLinks(repo repository.Repository, opts ReportOptions, logger *zap.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		w.Header().Set("Vary", "Accept")
+
+		mediaType := reportMediaType(r)
+		if mediaType == "" {
+			http.Error(w, "report is available as "+strings.Join(reportMediaTypes, ", "), http.StatusNotAcceptable)
+			logger.Warn("no acceptable report format",
+				zap.String("accept", r.Header.Get("Accept")),
+				zap.String("format", r.URL.Query().Get("format")),
+			)
+			return
+		}
+
 		var reqLinks getLinksRequest
-		err := json.NewDecoder(r.Body).Decode(&reqLinks)
+		switch {
+		case hasContentType(r, "application/json"):
+			err := json.NewDecoder(r.Body).Decode(&reqLinks)
+			if err != nil {
+				http.Error(w, "cannot decode body", http.StatusBadRequest)
+				logger.Warn("cannot decode body", zap.Error(err))
+				return
+			}
+
+		case hasContentType(r, "multipart/form-data"), hasContentType(r, "application/x-www-form-urlencoded"):
+			ids, err := parseLinksListForm(r)
+			if err != nil {
+				http.Error(w, "cannot parse form: "+err.Error(), http.StatusBadRequest)
+				logger.Warn("cannot parse form", zap.Error(err))
+				return
+			}
+			reqLinks.LinksList = ids
+
+		default:
+			http.Error(w, "content type must be application/json, multipart/form-data or application/x-www-form-urlencoded", http.StatusUnsupportedMediaType)
+			logger.Warn("unsupported content type", zap.String("content_type", r.Header.Get("Content-Type")))
+			return
+		}
+
+		invalidIDs := invalidRecordIDs(reqLinks.LinksList)
+		if len(invalidIDs) > 0 {
+			http.Error(w, "links_list must contain only positive ids, got: "+joinIDs(invalidIDs), http.StatusBadRequest)
+			logger.Warn("invalid record ids", zap.Int64s("ids", invalidIDs))
+			return
+		}
+
+		tag := r.URL.Query().Get("tag")
+
+		// The PDF report reads the records again batch by batch while
+		// rendering, so thousands of them are never held in memory at once.
+		scan, err := scanRecords(r.Context(), repo, reqLinks.LinksList, tag, mediaType != mediaTypePDF, logger)
 		if err != nil {
-			http.Error(w, "cannot decode body", http.StatusBadRequest)
-			logger.Warn("cannot decode body", zap.Error(err))
+			http.Error(w, "failed to get records", http.StatusInternalServerError)
+			logger.Error("failed to get records", zap.Error(err))
 			return
 		}
 
-		pdf := gofpdf.New("P", "mm", "A4", "")
-		pdf.AddPage()
-		pdf.SetFont("Arial", "", 12)
+		records := scan.records
+		pdfRecords := batchedRecords(repo, scan.matched)
 
-		for _, id := range reqLinks.LinksList {
-			rec, err := repo.GetRecord(id)
-			if err != nil {
-				logger.Warn("failed to get record", zap.Int64("id", id), zap.Error(err))
-				continue
+		if mediaType == mediaTypePDF && opts.MaxPDFRecords > 0 && len(scan.matched) > opts.MaxPDFRecords {
+			http.Error(w, fmt.Sprintf("a pdf report holds at most %d records, request fewer records or another format", opts.MaxPDFRecords), http.StatusRequestEntityTooLarge)
+			logger.Warn("too many records for a pdf report",
+				zap.Int("records_count", len(scan.matched)),
+				zap.Int("max_pdf_records", opts.MaxPDFRecords),
+			)
+			return
+		}
+
+		if opts.MultiStatus && len(scan.missing) > 0 {
+			if len(scan.found) == 0 {
+				http.Error(w, "none of the requested records exist", http.StatusNotFound)
+				logger.Warn("requested records not found", zap.Int64s("missing", scan.missing))
+				return
 			}
 
-			pdf.CellFormat(0, 8, "Record: "+strconv.FormatInt(rec.ID, 10), "", 1, "", false, 0, "")
-			for link, status := range rec.Links {
-				pdf.CellFormat(0, 6, link+": "+status, "", 1, "", false, 0, "")
+			// The 207 envelope embeds a PDF report. The other formats leave
+			// the missing records out.
+			if mediaType == mediaTypePDF {
+				writeMultiStatus(w, r, scan.found, scan.missing, pdfRecords, scan.summary, len(scan.matched), opts, logger)
+				return
 			}
+		}
+
+		lastModified := scan.lastModified
+		if notModifiedSince(r, lastModified) {
+			w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		setSummaryHeaders(w, scan.summary)
 
-			pdf.Ln(4)
+		switch mediaType {
+		case mediaTypeCSV:
+			writeCSVReport(w, records, lastModified, logger)
+			return
+		case mediaTypeJSON:
+			writeJSONReport(w, records, lastModified, logger)
+			return
+		case mediaTypeXLSX:
+			writeXLSXReport(w, records, lastModified, logger)
+			return
+		case mediaTypeHTML:
+			writeHTMLReport(w, records, scan.summary, lastModified, opts, logger)
+			return
 		}
 
-		w.Header().Set("Content-Type", "application/pdf")
+		if r.URL.Query().Get("stream") == "true" {
+			flusher, ok := w.(http.Flusher)
+			if ok {
+				streamReport(w, r, flusher, pdfRecords, scan.summary, len(scan.matched), lastModified, opts, logger)
+				return
+			}
+
+			logger.Warn("response writer does not support flushing, falling back to buffered report")
+		}
+
+		buf, err := renderPDF(r.Context(), pdfRecords, scan.summary, opts, logger)
+		if errors.Is(err, errReportTooLarge) {
+			http.Error(w, "report is too large, request fewer records", http.StatusRequestEntityTooLarge)
+			logger.Warn("report is too large",
+				zap.Int("records_count", len(scan.matched)),
+				zap.Int64("max_report_bytes", opts.MaxBytes),
+			)
+			return
+		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			logger.Info("client disconnected, pdf generation stopped", zap.Error(err))
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to generate pdf", http.StatusInternalServerError)
+			logger.Error("failed to generate pdf", zap.Error(err))
+			return
+		}
+
+		generationTime := time.Since(start)
+		logger.Debug("pdf generated",
+			zap.Int("records_count", len(scan.matched)),
+			zap.Duration("generation_time", generationTime),
+		)
+
+		w.Header().Set("Content-Type", mediaTypePDF)
 		w.Header().Set("Content-Disposition", "attachment; filename=records.pdf")
+		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		w.Header().Set("X-Generation-Time-Ms", strconv.FormatInt(generationTime.Milliseconds(), 10))
+		setLastModified(w, lastModified)
 
-		err = pdf.Output(w)
+		_, err = buf.WriteTo(w)
 		if err != nil {
 			logger.Error("failed to write pdf", zap.Error(err))
 		}
 	}
 }
+
+type multiStatusResponse struct {
+	Found   []int64 `json:"found"`
+	Missing []int64 `json:"missing"`
+	// PDF is the base64-encoded report of the found records.
+	PDF []byte `json:"pdf"`
+}
+
+// writeMultiStatus answers 207 Multi-Status with the found and missing IDs and
+// the report of the found records embedded in the JSON body. count is the
+// number of records, for the logs.
+func writeMultiStatus(w http.ResponseWriter, r *http.Request, found, missing []int64, records pdf.Records, summary report.Summary, count int, opts ReportOptions, logger *zap.Logger) {
+	buf, err := renderPDF(r.Context(), records, summary, opts, logger)
+	if errors.Is(err, errReportTooLarge) {
+		http.Error(w, "report is too large, request fewer records", http.StatusRequestEntityTooLarge)
+		logger.Warn("report is too large",
+			zap.Int("records_count", count),
+			zap.Int64("max_report_bytes", opts.MaxBytes),
+		)
+		return
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		logger.Info("client disconnected, pdf generation stopped", zap.Error(err))
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to generate pdf", http.StatusInternalServerError)
+		logger.Error("failed to generate pdf", zap.Error(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusMultiStatus)
+
+	err = json.NewEncoder(w).Encode(multiStatusResponse{
+		Found:   found,
+		Missing: missing,
+		PDF:     buf.Bytes(),
+	})
+	if err != nil {
+		logger.Warn("failed to encode response", zap.Error(err))
+	}
+}
+
+// streamReport writes the report with chunked encoding. Errors after the
+// first chunk can only be logged since the status is already sent. count is
+// the number of records, for the logs.
+func streamReport(w http.ResponseWriter, r *http.Request, flusher http.Flusher, records pdf.Records, summary report.Summary, count int, lastModified time.Time, opts ReportOptions, logger *zap.Logger) {
+	start := time.Now()
+
+	w.Header().Set("Content-Type", mediaTypePDF)
+	w.Header().Set("Content-Disposition", "attachment; filename=records.pdf")
+	setLastModified(w, lastModified)
+
+	err := streamPDF(r.Context(), w, flusher, records, summary, opts, logger)
+	switch {
+	case errors.Is(err, errReportTooLarge):
+		// The document is written in one go after rendering, so nothing has
+		// been sent yet and the status can still be reported.
+		http.Error(w, "report is too large, request fewer records", http.StatusRequestEntityTooLarge)
+		logger.Warn("report is too large",
+			zap.Int("records_count", count),
+			zap.Int64("max_report_bytes", opts.MaxBytes),
+		)
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		logger.Info("client disconnected, report streaming stopped", zap.Error(err))
+	case err != nil:
+		logger.Error("failed to stream pdf", zap.Error(err))
+	default:
+		logger.Debug("pdf streamed",
+			zap.Int("records_count", count),
+			zap.Duration("generation_time", time.Since(start)),
+		)
+	}
+}
+
+// notModifiedSince reports whether the report built from records last
+// modified at lastModified is still fresh for the request's If-Modified-Since.
+// HTTP dates have second precision, so lastModified is truncated before the
+// comparison.
+func notModifiedSince(r *http.Request, lastModified time.Time) bool {
+	if lastModified.IsZero() {
+		return false
+	}
+
+	header := r.Header.Get("If-Modified-Since")
+	if header == "" {
+		return false
+	}
+
+	since, err := http.ParseTime(header)
+	if err != nil {
+		return false
+	}
+
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// parseLinksListForm reads the record IDs of an HTML form submission, either
+// multipart or URL-encoded, from the comma-separated links_list field.
+func parseLinksListForm(r *http.Request) ([]int64, error) {
+	err := r.ParseMultipartForm(maxFormMemory)
+	if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return nil, err
+	}
+
+	ids, err := parseIDList(r.PostFormValue("links_list"))
+	if err != nil {
+		return nil, fmt.Errorf("%w in links_list", err)
+	}
+
+	return ids, nil
+}
+
+// parseIDList parses a comma-separated list of record IDs.
+func parseIDList(list string) ([]int64, error) {
+	var ids []int64
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %q", part)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// invalidRecordIDs returns the ids that cannot identify a record.
+func invalidRecordIDs(ids []int64) []int64 {
+	var invalid []int64
+	for _, id := range ids {
+		if id <= 0 {
+			invalid = append(invalid, id)
+		}
+	}
+
+	return invalid
+}
+
+func joinIDs(ids []int64) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, strconv.FormatInt(id, 10))
+	}
+
+	return strings.Join(parts, ", ")
+}
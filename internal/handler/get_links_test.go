@@ -0,0 +1,770 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/report"
+	"link-service/internal/repository"
+	filesystem "link-service/internal/repository/file_system"
+)
+
+func TestPDFGeneration(t *testing.T) {
+	buf, err := renderPDF(context.Background(), sliceRecords([]*domain.Record{
+		{Links: map[string]string{"google.com": "available"}, ID: 1},
+		{Links: map[string]string{"12dqf4wgf4.com": "not available"}, ID: 2},
+	}), report.Summary{}, ReportOptions{}, zap.NewNop())
+	assert.NoError(t, err)
+	assert.Greater(t, buf.Len(), 0)
+	assert.Equal(t, "%PDF-", buf.String()[:5])
+}
+
+func TestGetLinksContentType(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		wantStatus  int
+	}{
+		{
+			name:        "json",
+			contentType: "application/json",
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "json with charset",
+			contentType: "application/json; charset=utf-8",
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "missing",
+			contentType: "",
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "text",
+			contentType: "text/plain",
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/links", strings.NewReader(`{"links_list":[]}`))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+
+			GetLinks(filesystem.NewMockStorage(), ReportOptions{}, zap.NewNop())(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+type recordsRepository struct {
+	*filesystem.MockStorage
+	records map[int64]*domain.Record
+}
+
+func (rr *recordsRepository) GetRecord(ctx context.Context, id int64) (*domain.Record, error) {
+	rec, ok := rr.records[id]
+	if !ok {
+		return nil, fmt.Errorf("record with ID %d: %w", id, repository.ErrRecordNotFound)
+	}
+
+	return rec, nil
+}
+
+func (rr *recordsRepository) GetRecords(ctx context.Context, ids []int64) (map[int64]*domain.Record, error) {
+	records := make(map[int64]*domain.Record, len(ids))
+	for _, id := range ids {
+		if rec, ok := rr.records[id]; ok {
+			records[id] = rec
+		}
+	}
+
+	return records, nil
+}
+
+func TestGetLinksMaxReportBytes(t *testing.T) {
+	repo := &recordsRepository{
+		MockStorage: filesystem.NewMockStorage(),
+		records:     make(map[int64]*domain.Record),
+	}
+
+	ids := make([]string, 0, 200)
+	for id := int64(1); id <= 200; id++ {
+		rec := &domain.Record{Links: make(map[string]string), ID: id}
+		for i := range 20 {
+			rec.Links[fmt.Sprintf("https://example-%d-%d.com", id, i)] = "available"
+		}
+		repo.records[id] = rec
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+	body := `{"links_list":[` + strings.Join(ids, ",") + `]}`
+
+	tests := []struct {
+		name           string
+		maxReportBytes int64
+		wantStatus     int
+	}{
+		{
+			name:           "unlimited",
+			maxReportBytes: 0,
+			wantStatus:     http.StatusOK,
+		},
+		{
+			name:           "too large",
+			maxReportBytes: 10 * 1024,
+			wantStatus:     http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/links", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			GetLinks(repo, ReportOptions{MaxBytes: tt.maxReportBytes}, zap.NewNop())(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestGetLinksMaxPDFRecords(t *testing.T) {
+	repo := &recordsRepository{
+		MockStorage: filesystem.NewMockStorage(),
+		records: map[int64]*domain.Record{
+			1: {ID: 1, Links: map[string]string{"a.com": "available"}},
+			2: {ID: 2, Links: map[string]string{"b.com": "available"}},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		target     string
+		body       string
+		opts       ReportOptions
+		wantStatus int
+	}{
+		{
+			name:       "within the limit",
+			target:     "/links",
+			body:       `{"links_list":[1,2]}`,
+			opts:       ReportOptions{MaxPDFRecords: 2},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "over the limit",
+			target:     "/links",
+			body:       `{"links_list":[1,2]}`,
+			opts:       ReportOptions{MaxPDFRecords: 1},
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "over the limit in multi status",
+			target:     "/v2/links",
+			body:       `{"links_list":[1,2,3]}`,
+			opts:       ReportOptions{MaxPDFRecords: 1, MultiStatus: true},
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "other formats are not limited",
+			target:     "/links?format=csv",
+			body:       `{"links_list":[1,2]}`,
+			opts:       ReportOptions{MaxPDFRecords: 1},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			GetLinks(repo, tt.opts, zap.NewNop())(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestGetLinksInvalidIDs(t *testing.T) {
+	repo := &recordsRepository{
+		MockStorage: filesystem.NewMockStorage(),
+		records: map[int64]*domain.Record{
+			1: {Links: map[string]string{"google.com": "available"}, ID: 1},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "negative ids",
+			body:       `{"links_list":[-1,1,-20]}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "-1, -20",
+		},
+		{
+			name:       "zero id",
+			body:       `{"links_list":[0]}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "got: 0",
+		},
+		{
+			name:       "valid ids",
+			body:       `{"links_list":[1,2]}`,
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/links", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			GetLinks(repo, ReportOptions{}, zap.NewNop())(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.wantBody)
+		})
+	}
+}
+
+func TestGetLinksIfModifiedSince(t *testing.T) {
+	updatedAt := time.Date(2025, 11, 30, 12, 0, 0, 500, time.UTC)
+	repo := &recordsRepository{
+		MockStorage: filesystem.NewMockStorage(),
+		records: map[int64]*domain.Record{
+			1: {ID: 1, Links: map[string]string{"a.com": "available"}, UpdatedAt: updatedAt.Add(-time.Hour)},
+			2: {ID: 2, Links: map[string]string{"b.com": "available"}, UpdatedAt: updatedAt},
+			3: {ID: 3, Links: map[string]string{"c.com": "available"}},
+		},
+	}
+
+	tests := []struct {
+		name             string
+		body             string
+		ifModifiedSince  string
+		wantStatus       int
+		wantLastModified string
+	}{
+		{
+			name:             "no header",
+			body:             `{"links_list":[1,2]}`,
+			wantStatus:       http.StatusOK,
+			wantLastModified: updatedAt.Format(http.TimeFormat),
+		},
+		{
+			name:             "not modified",
+			body:             `{"links_list":[1,2]}`,
+			ifModifiedSince:  updatedAt.Format(http.TimeFormat),
+			wantStatus:       http.StatusNotModified,
+			wantLastModified: updatedAt.Format(http.TimeFormat),
+		},
+		{
+			name:             "modified",
+			body:             `{"links_list":[1,2]}`,
+			ifModifiedSince:  updatedAt.Add(-time.Second).Format(http.TimeFormat),
+			wantStatus:       http.StatusOK,
+			wantLastModified: updatedAt.Format(http.TimeFormat),
+		},
+		{
+			name:             "invalid header",
+			body:             `{"links_list":[1,2]}`,
+			ifModifiedSince:  "yesterday",
+			wantStatus:       http.StatusOK,
+			wantLastModified: updatedAt.Format(http.TimeFormat),
+		},
+		{
+			name:            "no timestamps",
+			body:            `{"links_list":[3]}`,
+			ifModifiedSince: updatedAt.Format(http.TimeFormat),
+			wantStatus:      http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/links", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.ifModifiedSince != "" {
+				req.Header.Set("If-Modified-Since", tt.ifModifiedSince)
+			}
+			rec := httptest.NewRecorder()
+
+			GetLinks(repo, ReportOptions{}, zap.NewNop())(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantLastModified, rec.Header().Get("Last-Modified"))
+		})
+	}
+}
+
+// flushRecorder records the body size at every flush.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushedSizes []int
+}
+
+func (fr *flushRecorder) Flush() {
+	fr.flushedSizes = append(fr.flushedSizes, fr.Body.Len())
+	fr.ResponseRecorder.Flush()
+}
+
+func TestGetLinksStream(t *testing.T) {
+	repo := &recordsRepository{
+		MockStorage: filesystem.NewMockStorage(),
+		records:     make(map[int64]*domain.Record),
+	}
+
+	ids := make([]string, 0, 200)
+	for id := int64(1); id <= 200; id++ {
+		rec := &domain.Record{Links: make(map[string]string), ID: id}
+		for i := range 20 {
+			rec.Links[fmt.Sprintf("https://example-%d-%d.com", id, i)] = "available"
+		}
+		repo.records[id] = rec
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+	body := `{"links_list":[` + strings.Join(ids, ",") + `]}`
+
+	req := httptest.NewRequest(http.MethodGet, "/links?stream=true", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+
+	GetLinks(repo, ReportOptions{}, zap.NewNop())(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Length"))
+	assert.Equal(t, "%PDF-", rec.Body.String()[:5])
+
+	assert.Greater(t, len(rec.flushedSizes), 1)
+	for i := 1; i < len(rec.flushedSizes); i++ {
+		assert.Greater(t, rec.flushedSizes[i], rec.flushedSizes[i-1])
+	}
+	assert.Equal(t, rec.Body.Len(), rec.flushedSizes[len(rec.flushedSizes)-1])
+}
+
+func TestStreamPDFStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	err := streamPDF(ctx, rec, rec, sliceRecords([]*domain.Record{
+		{Links: map[string]string{"google.com": "available"}, ID: 1},
+	}), report.Summary{}, ReportOptions{}, zap.NewNop())
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, rec.flushedSizes)
+	assert.Zero(t, rec.Body.Len())
+}
+
+func TestRenderPDFStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := renderPDF(ctx, sliceRecords([]*domain.Record{
+		{Links: map[string]string{"google.com": "available"}, ID: 1},
+	}), report.Summary{}, ReportOptions{}, zap.NewNop())
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestGetLinksMultipartForm(t *testing.T) {
+	repo := &recordsRepository{
+		MockStorage: filesystem.NewMockStorage(),
+		records: map[int64]*domain.Record{
+			1: {ID: 1, Links: map[string]string{"a.com": "available"}},
+			2: {ID: 2, Links: map[string]string{"b.com": "available"}},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		linksList  string
+		wantStatus int
+	}{
+		{
+			name:       "valid ids",
+			linksList:  "1, 2",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "not a number",
+			linksList:  "1,x",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "non-positive id",
+			linksList:  "1,0",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			err := mw.WriteField("links_list", tt.linksList)
+			assert.NoError(t, err)
+			err = mw.Close()
+			assert.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, "/links/report", &body)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			rec := httptest.NewRecorder()
+
+			GetLinks(repo, ReportOptions{}, zap.NewNop())(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+			}
+
+			form := url.Values{"links_list": {tt.linksList}}
+			req = httptest.NewRequest(http.MethodPost, "/links/report", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec = httptest.NewRecorder()
+
+			GetLinks(repo, ReportOptions{}, zap.NewNop())(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestGetLinksMultiStatus(t *testing.T) {
+	repo := &recordsRepository{
+		MockStorage: filesystem.NewMockStorage(),
+		records: map[int64]*domain.Record{
+			1: {ID: 1, Links: map[string]string{"a.com": "available"}},
+			2: {ID: 2, Links: map[string]string{"b.com": "available"}},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		multiStatus bool
+		wantStatus  int
+		wantMissing []int64
+	}{
+		{
+			name:        "all found",
+			body:        `{"links_list":[1,2]}`,
+			multiStatus: true,
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "some missing",
+			body:        `{"links_list":[1,3,2,4]}`,
+			multiStatus: true,
+			wantStatus:  http.StatusMultiStatus,
+			wantMissing: []int64{3, 4},
+		},
+		{
+			name:        "none found",
+			body:        `{"links_list":[3,4]}`,
+			multiStatus: true,
+			wantStatus:  http.StatusNotFound,
+		},
+		{
+			name:        "some missing without multi status",
+			body:        `{"links_list":[1,3]}`,
+			multiStatus: false,
+			wantStatus:  http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v2/links", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			GetLinks(repo, ReportOptions{MultiStatus: tt.multiStatus}, zap.NewNop())(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+
+			if tt.wantStatus != http.StatusMultiStatus {
+				return
+			}
+
+			var resp multiStatusResponse
+			err := json.NewDecoder(rec.Body).Decode(&resp)
+			assert.NoError(t, err)
+			assert.Equal(t, []int64{1, 2}, resp.Found)
+			assert.Equal(t, tt.wantMissing, resp.Missing)
+			assert.Equal(t, "%PDF-", string(resp.PDF[:5]))
+		})
+	}
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{
+			name:   "missing",
+			accept: "",
+			want:   mediaTypePDF,
+		},
+		{
+			name:   "any",
+			accept: "*/*",
+			want:   mediaTypePDF,
+		},
+		{
+			name:   "exact",
+			accept: "text/csv",
+			want:   mediaTypeCSV,
+		},
+		{
+			name:   "quality",
+			accept: "application/pdf;q=0.5, application/json",
+			want:   mediaTypeJSON,
+		},
+		{
+			name:   "type wildcard",
+			accept: "text/*, */*;q=0.1",
+			want:   mediaTypeCSV,
+		},
+		{
+			name:   "most specific range wins",
+			accept: "application/*;q=0.9, application/pdf;q=0",
+			want:   mediaTypeJSON,
+		},
+		{
+			name:   "invalid quality skipped",
+			accept: "text/csv;q=2, application/json;q=0.3",
+			want:   mediaTypeJSON,
+		},
+		{
+			name:   "not acceptable",
+			accept: "audio/*, image/*",
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/links", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			assert.Equal(t, tt.want, negotiateContentType(req, reportMediaTypes))
+		})
+	}
+}
+
+func TestGetLinksAccept(t *testing.T) {
+	repo := &recordsRepository{
+		MockStorage: filesystem.NewMockStorage(),
+		records: map[int64]*domain.Record{
+			1: {ID: 1, Links: map[string]string{"b.com": "not available", "a.com": "available"}},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		target          string
+		accept          string
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "pdf",
+			target:          "/links",
+			accept:          "application/pdf",
+			wantStatus:      http.StatusOK,
+			wantContentType: mediaTypePDF,
+		},
+		{
+			name:            "csv",
+			target:          "/links",
+			accept:          "text/csv",
+			wantStatus:      http.StatusOK,
+			wantContentType: mediaTypeCSV,
+			wantBody:        "links_num,link,status\n1,a.com,available\n1,b.com,not available\n",
+		},
+		{
+			name:            "json",
+			target:          "/links",
+			accept:          "application/json",
+			wantStatus:      http.StatusOK,
+			wantContentType: mediaTypeJSON,
+			wantBody:        `[{"links":{"a.com":"available","b.com":"not available"},"links_num":1}]` + "\n",
+		},
+		{
+			name:            "xlsx",
+			target:          "/links?format=xlsx",
+			wantStatus:      http.StatusOK,
+			wantContentType: mediaTypeXLSX,
+		},
+		{
+			name:            "html",
+			target:          "/links",
+			accept:          "text/html",
+			wantStatus:      http.StatusOK,
+			wantContentType: mediaTypeHTML + "; charset=utf-8",
+		},
+		{
+			name:            "format overrides accept",
+			target:          "/links?format=csv",
+			accept:          "application/json",
+			wantStatus:      http.StatusOK,
+			wantContentType: mediaTypeCSV,
+		},
+		{
+			name:       "not acceptable",
+			target:     "/links",
+			accept:     "image/png",
+			wantStatus: http.StatusNotAcceptable,
+		},
+		{
+			name:       "unknown format",
+			target:     "/links?format=xml",
+			wantStatus: http.StatusNotAcceptable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, strings.NewReader(`{"links_list":[1]}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			GetLinks(repo, ReportOptions{}, zap.NewNop())(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, "Accept", rec.Header().Get("Vary"))
+
+			if tt.wantContentType != "" {
+				assert.Equal(t, tt.wantContentType, rec.Header().Get("Content-Type"))
+			}
+
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetLinksXLSX(t *testing.T) {
+	repo := &recordsRepository{
+		MockStorage: filesystem.NewMockStorage(),
+		records: map[int64]*domain.Record{
+			1: {ID: 1, Links: map[string]string{"b.com": "not available", "a.com": "available"}},
+			2: {ID: 2, Links: map[string]string{"c.com": "available"}},
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/links", strings.NewReader(`{"links_list":[2,1]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", mediaTypeXLSX)
+	rec := httptest.NewRecorder()
+
+	GetLinks(repo, ReportOptions{}, zap.NewNop())(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, mediaTypeXLSX, rec.Header().Get("Content-Type"))
+
+	f, err := excelize.OpenReader(rec.Body)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	rows, err := f.GetRows(xlsxSheet)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"links_num", "link", "status"},
+		{"2", "c.com", "available"},
+		{"1", "a.com", "available"},
+		{"1", "b.com", "not available"},
+	}, rows)
+}
+
+func TestGetLinksSummaryHeaders(t *testing.T) {
+	repo := &recordsRepository{
+		MockStorage: filesystem.NewMockStorage(),
+		records: map[int64]*domain.Record{
+			1: {ID: 1, Links: map[string]string{"a.com": "available", "b.com/x": "not available", "b.com/y": "not available"}},
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/links?format=csv", strings.NewReader(`{"links_list":[1]}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	GetLinks(repo, ReportOptions{}, zap.NewNop())(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "3", rec.Header().Get("X-Report-Total-Links"))
+	assert.Equal(t, "1", rec.Header().Get("X-Report-OK-Links"))
+	assert.Equal(t, "2", rec.Header().Get("X-Report-Broken-Links"))
+	assert.Equal(t, "33.3", rec.Header().Get("X-Report-OK-Percent"))
+	assert.Equal(t, "66.7", rec.Header().Get("X-Report-Broken-Percent"))
+	assert.Equal(t, "b.com=2", rec.Header().Get("X-Report-Failing-Domains"))
+
+	// The body stays a plain table for CSV readers.
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"links_num", "link", "status"}, rows[0])
+	assert.Len(t, rows, 4)
+}
+
+func TestGetLinksJSONEmpty(t *testing.T) {
+	repo := &recordsRepository{
+		MockStorage: filesystem.NewMockStorage(),
+		records: map[int64]*domain.Record{
+			1: {ID: 1, Links: map[string]string{"a.com": "available"}},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+	}{
+		{
+			name:   "empty links list",
+			target: "/links?format=json",
+			body:   `{"links_list":[]}`,
+		},
+		{
+			name:   "no matching tag",
+			target: "/links?format=json&tag=missing",
+			body:   `{"links_list":[1]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			GetLinks(repo, ReportOptions{}, zap.NewNop())(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.JSONEq(t, `[]`, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"link-service/internal/repository"
+)
+
+// GetRecord returns a single record as JSON.
+func GetRecord(repo repository.Repository, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "record id must be a positive integer", http.StatusBadRequest)
+			logger.Warn("invalid record id", zap.String("id", chi.URLParam(r, "id")))
+			return
+		}
+
+		record, err := repo.GetRecord(r.Context(), id)
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			http.Error(w, "record not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to get record", http.StatusInternalServerError)
+			logger.Error("failed to get record", zap.Int64("id", id), zap.Error(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err = json.NewEncoder(w).Encode(record)
+		if err != nil {
+			logger.Warn("failed to encode response", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+// GetRecordEvents returns the lifecycle events of a record as JSON.
+func GetRecordEvents(repo repository.Repository, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "record id must be a positive integer", http.StatusBadRequest)
+			logger.Warn("invalid record id", zap.String("id", chi.URLParam(r, "id")))
+			return
+		}
+
+		events, err := repo.GetRecordEvents(r.Context(), id)
+		if err != nil {
+			http.Error(w, "failed to get record events", http.StatusInternalServerError)
+			logger.Error("failed to get record events", zap.Int64("id", id), zap.Error(err))
+			return
+		}
+
+		if len(events) == 0 {
+			_, err = repo.GetRecord(r.Context(), id)
+			if errors.Is(err, repository.ErrRecordNotFound) {
+				http.Error(w, "record not found", http.StatusNotFound)
+				return
+			}
+
+			events = []domain.RecordEvent{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err = json.NewEncoder(w).Encode(events)
+		if err != nil {
+			logger.Warn("failed to encode response", zap.Error(err))
+		}
+	}
+}
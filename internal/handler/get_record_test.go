@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository/inmemory"
+)
+
+func TestGetRecord(t *testing.T) {
+	repo, err := inmemory.New(&inmemory.Config{}, clock.Real{}, zap.NewNop())
+	assert.NoError(t, err)
+
+	err = repo.SaveRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "available"}})
+	assert.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Get("/records/{id}", GetRecord(repo, zap.NewNop()))
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{name: "found", path: "/records/1", wantStatus: http.StatusOK},
+		{name: "not found", path: "/records/2", wantStatus: http.StatusNotFound},
+		{name: "invalid id", path: "/records/abc", wantStatus: http.StatusBadRequest},
+		{name: "zero id", path: "/records/0", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+			var record domain.Record
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&record))
+			assert.Equal(t, int64(1), record.ID)
+			assert.Equal(t, map[string]string{"a.com": "available"}, record.Links)
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/service"
+)
+
+type healthResponse struct {
+	Records      int64 `json:"records"`
+	StaleRecords int64 `json:"stale_records"`
+}
+
+// Health reports the results of the last health checks.
+func Health(srv *service.Service, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		err := json.NewEncoder(w).Encode(healthResponse{
+			Records:      srv.RecordCount(),
+			StaleRecords: srv.StaleRecords(),
+		})
+		if err != nil {
+			logger.Warn("failed to encode response", zap.Error(err))
+		}
+	}
+}
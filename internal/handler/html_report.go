@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/report"
+)
+
+// reportTemplateName is the template executed to render the HTML report. A
+// template directory may hold more *.html files for it to include.
+const reportTemplateName = "report.html"
+
+//go:embed templates/report.html
+var defaultReportTemplate string
+
+var reportTemplateFuncs = template.FuncMap{
+	"join": strings.Join,
+	// statusClass turns a status into a CSS class, e.g. "not-available".
+	"statusClass": func(status string) string {
+		return strings.ReplaceAll(status, " ", "-")
+	},
+}
+
+var builtinReportTemplate = template.Must(
+	template.New(reportTemplateName).Funcs(reportTemplateFuncs).Parse(defaultReportTemplate),
+)
+
+// htmlReport is the data of the report template.
+type htmlReport struct {
+	GeneratedAt time.Time
+	Summary     report.Summary
+	Records     []htmlRecord
+}
+
+type htmlRecord struct {
+	ID        int64
+	Tags      []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// Links are ordered by URL.
+	Links []htmlLink
+}
+
+type htmlLink struct {
+	URL    string
+	Status string
+}
+
+// LoadReportTemplate parses the *.html files of dir as the HTML report
+// template. One of them must be report.html, which is executed with the
+// records; the others may define templates it includes. The functions join
+// and statusClass of the built-in template are available.
+func LoadReportTemplate(dir string) (*template.Template, error) {
+	tmpl, err := template.New(reportTemplateName).Funcs(reportTemplateFuncs).ParseGlob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse report template: %w", err)
+	}
+
+	if report := tmpl.Lookup(reportTemplateName); report == nil || report.Tree == nil {
+		return nil, fmt.Errorf("report template directory has no %s: %s", reportTemplateName, dir)
+	}
+
+	return tmpl, nil
+}
+
+// writeHTMLReport renders the summary and the records with the configured
+// template, or the built-in one.
+func writeHTMLReport(w http.ResponseWriter, records []*domain.Record, summary report.Summary, lastModified time.Time, opts ReportOptions, logger *zap.Logger) {
+	tmpl := opts.HTMLTemplate
+	if tmpl == nil {
+		tmpl = builtinReportTemplate
+	}
+
+	data := htmlReport{
+		GeneratedAt: time.Now().UTC(),
+		Summary:     summary,
+		Records:     make([]htmlRecord, 0, len(records)),
+	}
+	for _, rec := range records {
+		links := make([]htmlLink, 0, len(rec.Links))
+		for link, status := range rec.Links {
+			links = append(links, htmlLink{URL: link, Status: status})
+		}
+		slices.SortFunc(links, func(a, b htmlLink) int {
+			return strings.Compare(a.URL, b.URL)
+		})
+
+		data.Records = append(data.Records, htmlRecord{
+			ID:        rec.ID,
+			Tags:      rec.Tags,
+			CreatedAt: rec.CreatedAt,
+			UpdatedAt: rec.UpdatedAt,
+			Links:     links,
+		})
+	}
+
+	// The report is rendered before anything is sent, so a failing
+	// template still gets a proper error response.
+	var buf bytes.Buffer
+	err := tmpl.ExecuteTemplate(&buf, reportTemplateName, data)
+	if err != nil {
+		http.Error(w, "failed to render html report", http.StatusInternalServerError)
+		logger.Error("failed to render html report", zap.Error(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaTypeHTML+"; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	setLastModified(w, lastModified)
+
+	_, err = buf.WriteTo(w)
+	if err != nil {
+		logger.Warn("failed to write html report", zap.Error(err))
+	}
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	filesystem "link-service/internal/repository/file_system"
+)
+
+func TestGetLinksHTML(t *testing.T) {
+	repo := &recordsRepository{
+		MockStorage: filesystem.NewMockStorage(),
+		records: map[int64]*domain.Record{
+			1: {ID: 1, Links: map[string]string{"b.com": "not available", "a.com/<script>": "available"}, Tags: []string{"prod"}},
+		},
+	}
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "report.html"),
+		[]byte(`<h1>ACME</h1>{{range .Records}}{{template "record" .}}{{end}}`), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "record.html"),
+		[]byte(`{{define "record"}}#{{.ID}}{{range .Links}} {{.URL}}={{statusClass .Status}}{{end}}{{end}}`), 0644))
+
+	branded, err := LoadReportTemplate(dir)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		opts     ReportOptions
+		wantBody []string
+	}{
+		{
+			name:     "built-in template",
+			opts:     ReportOptions{},
+			wantBody: []string{"Record 1", "Tags: prod", `class="not-available">1 (50.0%)`, "b.com (1)", "a.com/&lt;script&gt;", `class="not-available">not available`},
+		},
+		{
+			name:     "template directory",
+			opts:     ReportOptions{HTMLTemplate: branded},
+			wantBody: []string{"<h1>ACME</h1>#1 a.com/&lt;script&gt;=available b.com=not-available"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/links?format=html", strings.NewReader(`{"links_list":[1]}`))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			GetLinks(repo, tt.opts, zap.NewNop())(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+
+			for _, want := range tt.wantBody {
+				assert.Contains(t, rec.Body.String(), want)
+			}
+		})
+	}
+}
+
+func TestLoadReportTemplate(t *testing.T) {
+	_, err := LoadReportTemplate(t.TempDir())
+	assert.Error(t, err)
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "other.html"), []byte(`{{define "x"}}x{{end}}`), 0644))
+
+	_, err = LoadReportTemplate(dir)
+	assert.ErrorContains(t, err, "has no report.html")
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "report.html"), []byte(`{{.Missing`), 0644))
+
+	_, err = LoadReportTemplate(dir)
+	assert.ErrorContains(t, err, "failed to parse report template")
+}
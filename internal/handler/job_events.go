@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"link-service/internal/service"
+)
+
+// JobEvents streams the link updates of a job as Server-Sent Events: a
+// "link" event for every checked link, the ones checked before the client
+// connected first, and a final "done" event with the finished job.
+func JobEvents(srv *service.Service, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		_, err := srv.GetJob(id)
+		if errors.Is(err, service.ErrJobNotFound) {
+			http.Error(w, "job not found", http.StatusNotFound)
+			return
+		}
+
+		rc := http.NewResponseController(w)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+
+		err = rc.Flush()
+		if err != nil {
+			logger.Warn("failed to flush event stream", zap.String("job_id", id), zap.Error(err))
+			return
+		}
+
+		job, err := srv.WatchJob(r.Context(), id, func(update service.LinkUpdate) error {
+			err := writeEvent(w, "link", update)
+			if err != nil {
+				return err
+			}
+
+			return rc.Flush()
+		})
+		if err != nil {
+			// The client went away or the job expired while it was watched.
+			logger.Info("job event stream stopped", zap.String("job_id", id), zap.Error(err))
+			return
+		}
+
+		err = writeEvent(w, "done", job)
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			logger.Warn("failed to write event", zap.String("job_id", id), zap.Error(err))
+		}
+	}
+}
+
+// writeEvent writes v as the JSON data of a Server-Sent Event.
+func writeEvent(w io.Writer, event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
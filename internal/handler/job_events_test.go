@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/repository/inmemory"
+	"link-service/internal/service"
+)
+
+func TestJobEvents(t *testing.T) {
+	okServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer okServer.Close()
+
+	repo, err := inmemory.New(&inmemory.Config{}, clock.Real{}, zap.NewNop())
+	assert.NoError(t, err)
+
+	srv := service.New(repo, &service.Config{PingTimeout: time.Second}, clock.Real{}, zap.NewNop())
+
+	router := chi.NewRouter()
+	router.Get("/jobs/{id}/events", JobEvents(srv, zap.NewNop()))
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	job, err := srv.SubmitJob(context.Background(), []string{okServer.URL + "/a", okServer.URL + "/b"}, nil)
+	assert.NoError(t, err)
+
+	resp, err := http.Get(server.URL + "/jobs/" + job.ID + "/events")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	events := strings.Split(strings.TrimSpace(string(body)), "\n\n")
+	assert.Len(t, events, 3)
+	assert.Contains(t, events[0], `event: link`+"\n"+`data: {"link":"`+okServer.URL+`/a","status":"available","checked":1,"total":2}`)
+	assert.Contains(t, events[1], `"checked":2`)
+	assert.True(t, strings.HasPrefix(events[2], "event: done\ndata: "))
+	assert.Contains(t, events[2], `"status":"done"`)
+
+	resp, err = http.Get(server.URL + "/jobs/unknown/events")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
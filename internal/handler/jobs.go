@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"link-service/internal/service"
+)
+
+// SubmitLinksAsync starts checking the posted links in the background and
+// returns the pending job, which is polled with GetJob.
+func SubmitLinksAsync(serverCtx context.Context, srv *service.Service, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var reqLinks processLinksRequest
+		err := json.NewDecoder(r.Body).Decode(&reqLinks)
+		if err != nil {
+			http.Error(w, "cannot decode body", http.StatusBadRequest)
+			logger.Warn("cannot decode body", zap.Error(err))
+			return
+		}
+
+		if len(reqLinks.Links) == 0 {
+			http.Error(w, "links must not be empty", http.StatusBadRequest)
+			logger.Warn("no links to process")
+			return
+		}
+
+		job, err := srv.SubmitJob(serverCtx, reqLinks.Links, reqLinks.Tags)
+		if errors.Is(err, service.ErrAppStopped) {
+			http.Error(w, "service is shutting down and cannot accept jobs", http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to submit job", http.StatusInternalServerError)
+			logger.Error("failed to submit job", zap.Error(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/jobs/"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+
+		err = json.NewEncoder(w).Encode(job)
+		if err != nil {
+			logger.Warn("failed to encode response", zap.Error(err))
+		}
+	}
+}
+
+// GetJob returns the status and progress of a job, and its record once it
+// is done.
+func GetJob(srv *service.Service, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, err := srv.GetJob(chi.URLParam(r, "id"))
+		if errors.Is(err, service.ErrJobNotFound) {
+			http.Error(w, "job not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to get job", http.StatusInternalServerError)
+			logger.Error("failed to get job", zap.Error(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err = json.NewEncoder(w).Encode(job)
+		if err != nil {
+			logger.Warn("failed to encode response", zap.Error(err))
+		}
+	}
+}
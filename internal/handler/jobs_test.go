@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/repository/inmemory"
+	"link-service/internal/service"
+)
+
+func TestSubmitLinksAsync(t *testing.T) {
+	okServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer okServer.Close()
+
+	repo, err := inmemory.New(&inmemory.Config{}, clock.Real{}, zap.NewNop())
+	assert.NoError(t, err)
+
+	srv := service.New(repo, &service.Config{PingTimeout: time.Second}, clock.Real{}, zap.NewNop())
+
+	router := chi.NewRouter()
+	router.Post("/links/async", SubmitLinksAsync(context.Background(), srv, zap.NewNop()))
+	router.Get("/jobs/{id}", GetJob(srv, zap.NewNop()))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/links/async", strings.NewReader(`{"links":["`+okServer.URL+`"]}`)))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	var job service.Job
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&job))
+	assert.Equal(t, "/jobs/"+job.ID, rec.Header().Get("Location"))
+
+	srv.WaitJobs()
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&job))
+	assert.Equal(t, service.JobStatusDone, job.Status)
+	assert.Equal(t, int64(1), job.Record.ID)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/links/async", strings.NewReader(`{"links":[]}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
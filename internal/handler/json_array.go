@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/repository"
+	"link-service/internal/service"
+)
+
+type importResponse struct {
+	Imported int `json:"imported"`
+}
+
+func ExportJSONArray(repo repository.Repository, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		err := repository.WriteJSONArray(r.Context(), w, repo)
+		if err != nil {
+			logger.Error("failed to export records", zap.Error(err))
+		}
+	}
+}
+
+func ImportJSONArray(srv *service.Service, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		imported, err := srv.ImportJSONArray(r.Context(), r.Body)
+		if errors.Is(err, repository.ErrReadOnlyStorage) {
+			http.Error(w, "storage is read-only", http.StatusForbidden)
+			logger.Warn("failed to import records", zap.Error(err))
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to import records", http.StatusBadRequest)
+			logger.Warn("failed to import records", zap.Int("imported", imported), zap.Error(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+
+		err = json.NewEncoder(w).Encode(importResponse{Imported: imported})
+		if err != nil {
+			logger.Warn("failed to encode response", zap.Error(err))
+		}
+	}
+}
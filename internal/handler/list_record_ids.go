@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/repository"
+)
+
+func ListRecordIDs(repo repository.Repository, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, err := repo.ListRecordIDs(r.Context())
+		if err != nil {
+			http.Error(w, "failed to list record ids", http.StatusInternalServerError)
+			logger.Error("failed to list record ids", zap.Error(err))
+			return
+		}
+
+		if ids == nil {
+			ids = []int64{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err = json.NewEncoder(w).Encode(ids)
+		if err != nil {
+			logger.Warn("failed to encode response", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/repository"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// filterParams are the query parameters of GetAllRecords that cannot be
+// combined with a page.
+var filterParams = []string{"min_links", "max_links", "source", "tag", "since"}
+
+// isPageRequest reports whether the query asks for a page of the records.
+func isPageRequest(query url.Values) bool {
+	return query.Has("offset") || query.Has("limit") || query.Has("order")
+}
+
+// listRecords writes a page of the records ordered by ID as a JSON array. The
+// page is selected with the offset, limit and order query parameters, limit
+// defaulting to defaultPageLimit and capped at maxPageLimit.
+func listRecords(w http.ResponseWriter, r *http.Request, repo repository.Repository, logger *zap.Logger) {
+	query := r.URL.Query()
+
+	for _, param := range filterParams {
+		if query.Has(param) {
+			http.Error(w, param+" cannot be combined with offset, limit or order", http.StatusBadRequest)
+			logger.Warn("filter combined with page", zap.String("param", param))
+			return
+		}
+	}
+
+	offset := 0
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			logger.Warn("invalid offset", zap.String("offset", v))
+			return
+		}
+		offset = n
+	}
+
+	limit := defaultPageLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxPageLimit {
+			http.Error(w, "limit must be between 1 and "+strconv.Itoa(maxPageLimit), http.StatusBadRequest)
+			logger.Warn("invalid limit", zap.String("limit", v))
+			return
+		}
+		limit = n
+	}
+
+	records, err := repo.ListRecords(r.Context(), offset, limit, query.Get("order"))
+	if errors.Is(err, repository.ErrInvalidPage) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		logger.Warn("invalid page", zap.Error(err))
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to list records", http.StatusInternalServerError)
+		logger.Error("failed to list records", zap.Error(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(records)
+	if err != nil {
+		logger.Warn("failed to encode response", zap.Error(err))
+	}
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository/inmemory"
+)
+
+func TestGetAllRecordsPage(t *testing.T) {
+	repo, err := inmemory.New(&inmemory.Config{}, clock.Real{}, zap.NewNop())
+	assert.NoError(t, err)
+
+	for id := int64(1); id <= 5; id++ {
+		assert.NoError(t, repo.SaveRecord(context.Background(), &domain.Record{ID: id}))
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIDs    []int64
+	}{
+		{name: "first page", query: "?limit=2", wantStatus: http.StatusOK, wantIDs: []int64{1, 2}},
+		{name: "next page", query: "?offset=2&limit=2", wantStatus: http.StatusOK, wantIDs: []int64{3, 4}},
+		{name: "descending", query: "?order=desc&limit=2", wantStatus: http.StatusOK, wantIDs: []int64{5, 4}},
+		{name: "past the end", query: "?offset=10", wantStatus: http.StatusOK, wantIDs: []int64{}},
+		{name: "invalid offset", query: "?offset=-1", wantStatus: http.StatusBadRequest},
+		{name: "invalid limit", query: "?limit=0", wantStatus: http.StatusBadRequest},
+		{name: "limit too large", query: "?limit=1001", wantStatus: http.StatusBadRequest},
+		{name: "invalid order", query: "?order=newest", wantStatus: http.StatusBadRequest},
+		{name: "with filter", query: "?limit=2&tag=prod", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/records"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			GetAllRecords(repo, zap.NewNop())(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var records []domain.Record
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&records))
+
+			ids := make([]int64, 0, len(records))
+			for _, r := range records {
+				ids = append(ids, r.ID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/report"
+	"link-service/internal/report/pdf"
+)
+
+// streamChunkSize is the amount of report data written between flushes in
+// stream mode.
+const streamChunkSize = 8 << 10
+
+var errReportTooLarge = errors.New("report is too large")
+
+// ReportOptions configures report rendering.
+type ReportOptions struct {
+	// MaxBytes limits the size of the rendered report when positive.
+	MaxBytes int64
+	// MaxPDFRecords limits the number of records of a PDF report when
+	// positive. gofpdf keeps the whole document in memory until it is
+	// written, so MaxBytes alone does not bound the memory of a report.
+	MaxPDFRecords int
+	// PDF configures the title, logo and font of the PDF report.
+	PDF pdf.Options
+	// HTMLTemplate renders the HTML report, see LoadReportTemplate. When nil,
+	// the built-in template is used.
+	HTMLTemplate *template.Template
+	// MultiStatus answers 404 when none of the requested records exist and
+	// 207 Multi-Status with a JSON envelope when only some of them do,
+	// instead of a 200 report of whatever was found.
+	MultiStatus bool
+}
+
+// renderPDF renders the summary and the records into a buffer. It fails with errReportTooLarge
+// as soon as the output exceeds a positive opts.MaxBytes.
+func renderPDF(ctx context.Context, records pdf.Records, summary report.Summary, opts ReportOptions, logger *zap.Logger) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	err := pdf.Write(ctx, &limitedWriter{w: &buf, limit: opts.MaxBytes}, records, summary, opts.PDF, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &buf, nil
+}
+
+// streamPDF renders the records and writes the report to w in chunks,
+// flushing after each one.
+func streamPDF(ctx context.Context, w io.Writer, flusher http.Flusher, records pdf.Records, summary report.Summary, opts ReportOptions, logger *zap.Logger) error {
+	fw := &flushWriter{ctx: ctx, w: w, flusher: flusher}
+	return pdf.Write(ctx, &limitedWriter{w: fw, limit: opts.MaxBytes}, records, summary, opts.PDF, logger)
+}
+
+// limitedWriter refuses writes past a positive limit.
+type limitedWriter struct {
+	w       io.Writer
+	limit   int64
+	written int64
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	if lw.limit > 0 && lw.written+int64(len(p)) > lw.limit {
+		return 0, errReportTooLarge
+	}
+
+	n, err := lw.w.Write(p)
+	lw.written += int64(n)
+	return n, err
+}
+
+// flushWriter writes in chunks of streamChunkSize and flushes after each
+// chunk until ctx is done.
+type flushWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	flusher http.Flusher
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		err := fw.ctx.Err()
+		if err != nil {
+			return written, err
+		}
+
+		chunk := p[:min(len(p), streamChunkSize)]
+		n, err := fw.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+
+		fw.flusher.Flush()
+		p = p[n:]
+	}
+
+	return written, nil
+}
@@ -10,13 +10,17 @@ import (
 	"go.uber.org/zap"
 
 	"link-service/internal/domain"
+	"link-service/internal/repository"
 	"link-service/internal/service"
 )
 
 type processLinksRequest struct {
 	Links []string `json:"links"`
+	Tags  []string `json:"tags"`
 }
 
+// ProcessLinks checks the posted links under the next record ID, saves the
+// record and returns it with the link statuses as JSON.
 func ProcessLinks(serverCtx context.Context, srv *service.Service, requestTimeout time.Duration, logger *zap.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		requestCtx, cancel := context.WithTimeout(r.Context(), requestTimeout)
@@ -30,13 +34,31 @@ func ProcessLinks(serverCtx context.Context, srv *service.Service, requestTimeou
 			return
 		}
 
-		rec, err := srv.Process(serverCtx, requestCtx, reqLinks.Links)
+		if len(reqLinks.Links) == 0 {
+			http.Error(w, "links must not be empty", http.StatusBadRequest)
+			logger.Warn("no links to process")
+			return
+		}
+
+		rec, err := srv.Process(serverCtx, requestCtx, reqLinks.Links, reqLinks.Tags)
 		if err != nil {
 			if errors.Is(err, service.ErrAppStopped) {
 				err = writeResponse(w, rec, logger)
 				return
 			}
 
+			if errors.Is(err, repository.ErrReadOnlyStorage) {
+				http.Error(w, "storage is read-only", http.StatusForbidden)
+				logger.Warn("failed to process links", zap.Error(err))
+				return
+			}
+
+			if errors.Is(err, repository.ErrTempFileFull) {
+				http.Error(w, "service is shutting down and cannot accept records", http.StatusServiceUnavailable)
+				logger.Error("failed to process links", zap.Error(err))
+				return
+			}
+
 			http.Error(w, "failed to process links", http.StatusInternalServerError)
 			logger.Error("failed to process links", zap.Error(err))
 			return
@@ -47,8 +69,8 @@ func ProcessLinks(serverCtx context.Context, srv *service.Service, requestTimeou
 }
 
 func writeResponse(w http.ResponseWriter, rec *domain.Record, logger *zap.Logger) error {
-	w.WriteHeader(http.StatusCreated)
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
 
 	err := json.NewEncoder(w).Encode(rec)
 	if err != nil {
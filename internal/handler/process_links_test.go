@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository/inmemory"
+	"link-service/internal/service"
+)
+
+func TestProcessLinks(t *testing.T) {
+	okServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer okServer.Close()
+
+	repo, err := inmemory.New(&inmemory.Config{}, clock.Real{}, zap.NewNop())
+	assert.NoError(t, err)
+
+	srv := service.New(repo, &service.Config{PingTimeout: time.Second}, clock.Real{}, zap.NewNop())
+	h := ProcessLinks(context.Background(), srv, time.Second, zap.NewNop())
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantID     int64
+	}{
+		{name: "links", body: `{"links":["` + okServer.URL + `"]}`, wantStatus: http.StatusCreated, wantID: 1},
+		{name: "next id", body: `{"links":["` + okServer.URL + `"]}`, wantStatus: http.StatusCreated, wantID: 2},
+		{name: "no links", body: `{"links":[]}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid body", body: `{"links":`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h(rec, httptest.NewRequest(http.MethodPost, "/links", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+			var record domain.Record
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&record))
+			assert.Equal(t, tt.wantID, record.ID)
+			assert.Equal(t, map[string]string{okServer.URL: "available"}, record.Links)
+
+			_, err := repo.GetRecord(context.Background(), tt.wantID)
+			assert.NoError(t, err)
+		})
+	}
+}
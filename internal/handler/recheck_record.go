@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+	"link-service/internal/service"
+)
+
+type recheckRecordResponse struct {
+	Record  *domain.Record                  `json:"record"`
+	Changed map[string]service.StatusChange `json:"changed"`
+}
+
+// RecheckRecord checks the links of a stored record again and returns the
+// updated record with the links whose status changed.
+func RecheckRecord(srv *service.Service, requestTimeout time.Duration, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "record id must be a positive integer", http.StatusBadRequest)
+			logger.Warn("invalid record id", zap.String("id", chi.URLParam(r, "id")))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+		defer cancel()
+
+		rec, changed, err := srv.RecheckRecord(ctx, id)
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			http.Error(w, "record not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, repository.ErrReadOnlyStorage) {
+			http.Error(w, "storage is read-only", http.StatusForbidden)
+			logger.Warn("failed to recheck record", zap.Int64("id", id), zap.Error(err))
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, "recheck timed out", http.StatusGatewayTimeout)
+			logger.Warn("failed to recheck record", zap.Int64("id", id), zap.Error(err))
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to recheck record", http.StatusInternalServerError)
+			logger.Error("failed to recheck record", zap.Int64("id", id), zap.Error(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err = json.NewEncoder(w).Encode(recheckRecordResponse{Record: rec, Changed: changed})
+		if err != nil {
+			logger.Warn("failed to encode response", zap.Error(err))
+		}
+	}
+}
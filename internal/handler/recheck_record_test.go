@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository/inmemory"
+	"link-service/internal/service"
+)
+
+func TestRecheckRecord(t *testing.T) {
+	okServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer okServer.Close()
+
+	repo, err := inmemory.New(&inmemory.Config{}, clock.Real{}, zap.NewNop())
+	assert.NoError(t, err)
+
+	err = repo.SaveRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{
+		okServer.URL + "/a": "not available",
+		okServer.URL + "/b": "available",
+	}})
+	assert.NoError(t, err)
+
+	srv := service.New(repo, &service.Config{PingTimeout: time.Second}, clock.Real{}, zap.NewNop())
+
+	router := chi.NewRouter()
+	router.Patch("/records/{id}", RecheckRecord(srv, time.Second, zap.NewNop()))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/records/1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var resp recheckRecordResponse
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, "available", resp.Record.Links[okServer.URL+"/a"])
+	assert.Equal(t, map[string]service.StatusChange{
+		okServer.URL + "/a": {Old: "not available", New: "available"},
+	}, resp.Changed)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/records/2", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
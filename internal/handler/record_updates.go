@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+
+	"link-service/internal/service"
+)
+
+// RecordUpdates pushes every record created, updated or deleted through the
+// service to the WebSocket client as a JSON service.RecordUpdate message.
+// The stream ends when the client closes the connection or the server
+// context is done. Messages sent by the client are ignored. Handshakes from
+// other origins than allowedOrigins are refused, see checkOrigin.
+func RecordUpdates(serverCtx context.Context, srv *service.Service, allowedOrigins []string, logger *zap.Logger) http.Handler {
+	return websocket.Server{
+		Handshake: func(_ *websocket.Config, r *http.Request) error {
+			err := checkOrigin(r, allowedOrigins)
+			if err != nil {
+				logger.Warn("record updates handshake refused",
+					zap.String("origin", r.Header.Get("Origin")),
+					zap.String("remote_addr", r.RemoteAddr),
+				)
+			}
+
+			return err
+		},
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+
+			updates, cancel := srv.SubscribeUpdates()
+			defer cancel()
+
+			// Reading is the only way to notice that the client went away.
+			closed := make(chan struct{})
+			go func() {
+				defer close(closed)
+				_, _ = io.Copy(io.Discard, ws)
+			}()
+
+			for {
+				select {
+				case <-serverCtx.Done():
+					return
+				case <-closed:
+					return
+				case update := <-updates:
+					err := websocket.JSON.Send(ws, update)
+					if err != nil {
+						logger.Info("record updates stream stopped", zap.Error(err))
+						return
+					}
+				}
+			}
+		},
+	}
+}
+
+// checkOrigin accepts a handshake without an Origin header, sent by clients
+// outside a browser, and one from the origin of the server itself or one of
+// allowed, e.g. "https://dashboard.example.com". WebSockets are not bound by
+// the same-origin policy, so without the check any page open in a browser on
+// the network could read the records.
+func checkOrigin(r *http.Request, allowed []string) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("invalid origin %q: %w", origin, err)
+	}
+
+	if strings.EqualFold(u.Host, r.Host) {
+		return nil
+	}
+
+	for _, a := range allowed {
+		if strings.EqualFold(strings.TrimSuffix(a, "/"), origin) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("origin %q is not allowed", origin)
+}
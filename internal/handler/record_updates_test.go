@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository/inmemory"
+	"link-service/internal/service"
+)
+
+func TestRecordUpdates(t *testing.T) {
+	repo, err := inmemory.New(&inmemory.Config{}, clock.Real{}, zap.NewNop())
+	assert.NoError(t, err)
+
+	srv := service.New(repo, &service.Config{PingTimeout: time.Second}, clock.Real{}, zap.NewNop())
+
+	server := httptest.NewServer(RecordUpdates(context.Background(), srv, nil, zap.NewNop()))
+	defer server.Close()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http"), "", server.URL)
+	assert.NoError(t, err)
+	defer ws.Close()
+
+	// The handler subscribes after the handshake, so import records until
+	// one of them reaches the client.
+	var update service.RecordUpdate
+	for id := 1; id <= 50; id++ {
+		body := fmt.Sprintf(`[{"links_num":%d,"links":{"a.com":"available"}}]`, id)
+		_, err = srv.ImportJSONArray(context.Background(), strings.NewReader(body))
+		assert.NoError(t, err)
+
+		assert.NoError(t, ws.SetReadDeadline(time.Now().Add(20*time.Millisecond)))
+		err = websocket.JSON.Receive(ws, &update)
+		if err == nil {
+			break
+		}
+	}
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.EventTypeCreated, update.Type)
+	assert.Equal(t, map[string]string{"a.com": "available"}, update.Record.Links)
+}
+
+func TestRecordUpdatesOrigin(t *testing.T) {
+	repo, err := inmemory.New(&inmemory.Config{}, clock.Real{}, zap.NewNop())
+	assert.NoError(t, err)
+
+	srv := service.New(repo, &service.Config{PingTimeout: time.Second}, clock.Real{}, zap.NewNop())
+
+	server := httptest.NewServer(RecordUpdates(context.Background(), srv, []string{"https://dashboard.example.com/"}, zap.NewNop()))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	tests := []struct {
+		name    string
+		origin  string
+		wantErr bool
+	}{
+		{name: "same origin", origin: server.URL},
+		{name: "allowed origin", origin: "https://dashboard.example.com"},
+		{name: "other origin", origin: "https://evil.example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, err := websocket.Dial(wsURL, "", tt.origin)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			ws.Close()
+		})
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://links.local/ws", nil)
+	assert.NoError(t, checkOrigin(req, nil))
+
+	req.Header.Set("Origin", "http://LINKS.local")
+	assert.NoError(t, checkOrigin(req, nil))
+
+	req.Header.Set("Origin", "https://evil.example.com")
+	assert.Error(t, checkOrigin(req, nil))
+}
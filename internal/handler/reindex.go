@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/repository"
+)
+
+// Reindexer rebuilds the lookup index of a storage.
+type Reindexer interface {
+	Reindex() error
+}
+
+// Reindex rebuilds the storage index and answers 204 once it is done.
+func Reindex(reindexer Reindexer, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		err := reindexer.Reindex()
+		if errors.Is(err, repository.ErrReadOnlyStorage) {
+			http.Error(w, "storage is read-only", http.StatusForbidden)
+			logger.Warn("failed to reindex", zap.Error(err))
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to reindex", http.StatusInternalServerError)
+			logger.Error("failed to reindex", zap.Error(err))
+			return
+		}
+
+		logger.Info("reindex completed", zap.Duration("duration", time.Since(start)))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/report"
+)
+
+// Media types of the report formats GetLinks can answer with.
+const (
+	mediaTypePDF  = "application/pdf"
+	mediaTypeCSV  = "text/csv"
+	mediaTypeJSON = "application/json"
+	mediaTypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	mediaTypeHTML = "text/html"
+)
+
+// reportMediaTypes lists the report formats in the order of preference, the
+// first one is the default.
+var reportMediaTypes = []string{mediaTypePDF, mediaTypeCSV, mediaTypeJSON, mediaTypeXLSX, mediaTypeHTML}
+
+// reportFormats maps the values of the format query parameter to media types.
+var reportFormats = map[string]string{
+	"pdf":  mediaTypePDF,
+	"csv":  mediaTypeCSV,
+	"json": mediaTypeJSON,
+	"xlsx": mediaTypeXLSX,
+	"html": mediaTypeHTML,
+}
+
+// reportMediaType picks the report format of the request. The format query
+// parameter takes precedence over the Accept header. It returns "" when the
+// requested format is not supported.
+func reportMediaType(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return reportFormats[format]
+	}
+
+	return negotiateContentType(r, reportMediaTypes)
+}
+
+// writeCSVReport writes one row per link of the records, with the links of
+// each record sorted.
+func writeCSVReport(w http.ResponseWriter, records []*domain.Record, lastModified time.Time, logger *zap.Logger) {
+	w.Header().Set("Content-Type", mediaTypeCSV)
+	w.Header().Set("Content-Disposition", "attachment; filename=records.csv")
+	setLastModified(w, lastModified)
+
+	cw := csv.NewWriter(w)
+
+	err := cw.Write([]string{"links_num", "link", "status"})
+	if err != nil {
+		logger.Error("failed to write csv", zap.Error(err))
+		return
+	}
+
+	for _, rec := range records {
+		id := strconv.FormatInt(rec.ID, 10)
+
+		links := make([]string, 0, len(rec.Links))
+		for link := range rec.Links {
+			links = append(links, link)
+		}
+		slices.Sort(links)
+
+		for _, link := range links {
+			err = cw.Write([]string{id, link, rec.Links[link]})
+			if err != nil {
+				logger.Error("failed to write csv", zap.Error(err))
+				return
+			}
+		}
+	}
+
+	cw.Flush()
+
+	err = cw.Error()
+	if err != nil {
+		logger.Error("failed to write csv", zap.Error(err))
+	}
+}
+
+// setSummaryHeaders sends the summary of the report in headers, so that it
+// is available whatever the format without changing the body:
+//
+//	X-Report-Total-Links: 3
+//	X-Report-OK-Links: 2
+//	X-Report-Broken-Links: 1
+//	X-Report-OK-Percent: 66.7
+//	X-Report-Broken-Percent: 33.3
+//	X-Report-Failing-Domains: b.com=1
+func setSummaryHeaders(w http.ResponseWriter, summary report.Summary) {
+	w.Header().Set("X-Report-Total-Links", strconv.Itoa(summary.TotalLinks))
+	w.Header().Set("X-Report-OK-Links", strconv.Itoa(summary.OK))
+	w.Header().Set("X-Report-Broken-Links", strconv.Itoa(summary.Broken))
+	w.Header().Set("X-Report-OK-Percent", strconv.FormatFloat(summary.OKPercent(), 'f', 1, 64))
+	w.Header().Set("X-Report-Broken-Percent", strconv.FormatFloat(summary.BrokenPercent(), 'f', 1, 64))
+
+	domains := make([]string, 0, len(summary.TopFailingDomains))
+	for _, d := range summary.TopFailingDomains {
+		domains = append(domains, d.Domain+"="+strconv.Itoa(d.Count))
+	}
+	if len(domains) > 0 {
+		w.Header().Set("X-Report-Failing-Domains", strings.Join(domains, ", "))
+	}
+}
+
+// writeJSONReport writes the records as a JSON array, an empty one when no
+// record matched.
+func writeJSONReport(w http.ResponseWriter, records []*domain.Record, lastModified time.Time, logger *zap.Logger) {
+	w.Header().Set("Content-Type", mediaTypeJSON)
+	setLastModified(w, lastModified)
+
+	if records == nil {
+		records = make([]*domain.Record, 0)
+	}
+
+	err := json.NewEncoder(w).Encode(records)
+	if err != nil {
+		logger.Warn("failed to encode response", zap.Error(err))
+	}
+}
+
+func setLastModified(w http.ResponseWriter, lastModified time.Time) {
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+	}
+}
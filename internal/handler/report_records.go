@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/report"
+	"link-service/internal/report/pdf"
+	"link-service/internal/repository"
+)
+
+// reportBatchSize is the number of records read from the storage at a time
+// for a report.
+const reportBatchSize = 500
+
+// sliceRecords iterates over records already in memory.
+func sliceRecords(records []*domain.Record) pdf.Records {
+	return func(ctx context.Context, fn func(rec *domain.Record) error) error {
+		for _, rec := range records {
+			err := fn(rec)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// batchedRecords reads the records with the ids reportBatchSize at a time,
+// so only a batch is held in memory. Records deleted since the ids were
+// resolved are skipped.
+func batchedRecords(repo repository.Repository, ids []int64) pdf.Records {
+	return func(ctx context.Context, fn func(rec *domain.Record) error) error {
+		for batch := range slices.Chunk(ids, reportBatchSize) {
+			stored, err := repo.GetRecords(ctx, batch)
+			if err != nil {
+				return err
+			}
+
+			for _, id := range batch {
+				rec, ok := stored[id]
+				if !ok {
+					continue
+				}
+
+				err = fn(rec)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+}
+
+// reportScan is what is known of the requested records before the report is
+// rendered.
+type reportScan struct {
+	// found and missing are the requested IDs that exist and do not.
+	found   []int64
+	missing []int64
+	// matched are the found IDs of the records with the tag, in request
+	// order.
+	matched []int64
+	// records are the matched records when they are kept.
+	records []*domain.Record
+	// lastModified is the latest UpdatedAt of the matched records.
+	lastModified time.Time
+	// summary aggregates the links of the matched records.
+	summary report.Summary
+}
+
+// scanRecords reads the requested records in batches and sorts their IDs out.
+// The records themselves are only kept when keep is set, otherwise they are
+// read again while rendering.
+func scanRecords(ctx context.Context, repo repository.Repository, ids []int64, tag string, keep bool, logger *zap.Logger) (*reportScan, error) {
+	scan := &reportScan{
+		found:   make([]int64, 0, len(ids)),
+		missing: make([]int64, 0),
+		matched: make([]int64, 0, len(ids)),
+	}
+
+	var counter report.Counter
+	for batch := range slices.Chunk(ids, reportBatchSize) {
+		// A single read for the batch instead of a lookup per ID.
+		stored, err := repo.GetRecords(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range batch {
+			rec, ok := stored[id]
+			if !ok {
+				scan.missing = append(scan.missing, id)
+				logger.Warn("record not found", zap.Int64("id", id))
+				continue
+			}
+
+			scan.found = append(scan.found, id)
+
+			if tag != "" && !rec.HasTag(tag) {
+				continue
+			}
+
+			scan.matched = append(scan.matched, id)
+			counter.Add(rec)
+			if rec.UpdatedAt.After(scan.lastModified) {
+				scan.lastModified = rec.UpdatedAt
+			}
+
+			if keep {
+				scan.records = append(scan.records, rec)
+			}
+		}
+	}
+
+	scan.lastModified = scan.lastModified.UTC()
+	scan.summary = counter.Summary()
+	return scan, nil
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	filesystem "link-service/internal/repository/file_system"
+)
+
+// batchRepository records the size of every GetRecords call.
+type batchRepository struct {
+	recordsRepository
+	batches []int
+}
+
+func (br *batchRepository) GetRecords(ctx context.Context, ids []int64) (map[int64]*domain.Record, error) {
+	br.batches = append(br.batches, len(ids))
+	return br.recordsRepository.GetRecords(ctx, ids)
+}
+
+func TestGetLinksPDFBatches(t *testing.T) {
+	const count = 2*reportBatchSize + 10
+
+	repo := &batchRepository{recordsRepository: recordsRepository{
+		MockStorage: filesystem.NewMockStorage(),
+		records:     make(map[int64]*domain.Record, count),
+	}}
+
+	ids := make([]string, 0, count)
+	for id := int64(1); id <= count; id++ {
+		repo.records[id] = &domain.Record{ID: id, Links: map[string]string{"example.com": "available"}}
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/links", strings.NewReader(`{"links_list":[`+strings.Join(ids, ",")+`]}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	GetLinks(repo, ReportOptions{}, zap.NewNop())(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "%PDF-", rec.Body.String()[:5])
+
+	// The IDs are resolved and then rendered, three batches each.
+	assert.Equal(t, []int{reportBatchSize, reportBatchSize, 10, reportBatchSize, reportBatchSize, 10}, repo.batches)
+}
+
+func TestBatchedRecords(t *testing.T) {
+	repo := &recordsRepository{
+		MockStorage: filesystem.NewMockStorage(),
+		records: map[int64]*domain.Record{
+			1: {ID: 1},
+			3: {ID: 3},
+		},
+	}
+
+	var got []int64
+	err := batchedRecords(repo, []int64{3, 2, 1, 3})(context.Background(), func(rec *domain.Record) error {
+		got = append(got, rec.ID)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3, 1, 3}, got)
+}
+
+func TestScanRecords(t *testing.T) {
+	repo := &recordsRepository{
+		MockStorage: filesystem.NewMockStorage(),
+		records: map[int64]*domain.Record{
+			1: {ID: 1, Tags: []string{"prod"}},
+			2: {ID: 2},
+		},
+	}
+
+	scan, err := scanRecords(context.Background(), repo, []int64{2, 5, 1}, "prod", false, zap.NewNop())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 1}, scan.found)
+	assert.Equal(t, []int64{5}, scan.missing)
+	assert.Equal(t, []int64{1}, scan.matched)
+	assert.Empty(t, scan.records)
+
+	scan, err = scanRecords(context.Background(), repo, []int64{2, 1}, "", true, zap.NewNop())
+	assert.NoError(t, err)
+	assert.Len(t, scan.records, 2)
+}
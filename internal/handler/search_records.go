@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/repository"
+)
+
+// SearchRecords writes the records matching the url, status, from and to
+// query parameters as a JSON array ordered by ID, see repository.SearchQuery.
+// from and to are RFC 3339 times.
+func SearchRecords(repo repository.Repository, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := parseTimeRange(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			logger.Warn("invalid time range", zap.Error(err))
+			return
+		}
+
+		query := repository.SearchQuery{
+			URL:    r.URL.Query().Get("url"),
+			Status: r.URL.Query().Get("status"),
+			From:   from,
+			To:     to,
+		}
+
+		records, err := repo.SearchRecords(r.Context(), query)
+		if err != nil {
+			http.Error(w, "failed to search records", http.StatusInternalServerError)
+			logger.Error("failed to search records", zap.Error(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		err = json.NewEncoder(w).Encode(records)
+		if err != nil {
+			logger.Warn("failed to encode response", zap.Error(err))
+		}
+	}
+}
+
+// parseTimeRange reads the RFC 3339 from and to query parameters. A missing
+// bound is zero.
+func parseTimeRange(query url.Values) (time.Time, time.Time, error) {
+	var bounds [2]time.Time
+	for i, name := range []string{"from", "to"} {
+		v := query.Get(name)
+		if v == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid %s", name)
+		}
+
+		bounds[i] = t
+	}
+
+	from, to := bounds[0], bounds[1]
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("from must be before to")
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository/inmemory"
+)
+
+func TestSearchRecords(t *testing.T) {
+	repo, err := inmemory.New(&inmemory.Config{}, clock.Real{}, zap.NewNop())
+	assert.NoError(t, err)
+
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, rec := range []*domain.Record{
+		{ID: 1, Links: map[string]string{"a.com": "available"}, CreatedAt: jan},
+		{ID: 2, Links: map[string]string{"a.com/x": "not available"}, CreatedAt: jan.AddDate(0, 1, 0)},
+		{ID: 3, Links: map[string]string{"b.com": "not available"}, CreatedAt: jan.AddDate(0, 2, 0)},
+	} {
+		assert.NoError(t, repo.SaveRecord(context.Background(), rec))
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIDs    []int64
+	}{
+		{name: "domain", query: "?url=a.com", wantStatus: http.StatusOK, wantIDs: []int64{1, 2}},
+		{name: "broken links", query: "?status=not+available", wantStatus: http.StatusOK, wantIDs: []int64{2, 3}},
+		{name: "time range", query: "?from=2025-01-15T00:00:00Z&to=2025-03-01T00:00:00Z", wantStatus: http.StatusOK, wantIDs: []int64{2}},
+		{name: "no match", query: "?url=c.com", wantStatus: http.StatusOK, wantIDs: []int64{}},
+		{name: "invalid from", query: "?from=yesterday", wantStatus: http.StatusBadRequest},
+		{name: "empty range", query: "?from=2025-03-01T00:00:00Z&to=2025-01-01T00:00:00Z", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			SearchRecords(repo, zap.NewNop())(rec, httptest.NewRequest(http.MethodGet, "/records/search"+tt.query, nil))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var records []domain.Record
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&records))
+			ids := make([]int64, 0, len(records))
+			for _, r := range records {
+				ids = append(ids, r.ID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+)
+
+// xlsxSheet is the name of the sheet of the XLSX report.
+const xlsxSheet = "Records"
+
+// writeXLSXReport writes the records as a spreadsheet with a row per link,
+// like the CSV report, and a filter on the header row so the rows can be
+// sorted and filtered in the spreadsheet.
+func writeXLSXReport(w http.ResponseWriter, records []*domain.Record, lastModified time.Time, logger *zap.Logger) {
+	buf, err := renderXLSX(records)
+	if err != nil {
+		http.Error(w, "failed to generate xlsx", http.StatusInternalServerError)
+		logger.Error("failed to generate xlsx", zap.Error(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaTypeXLSX)
+	w.Header().Set("Content-Disposition", "attachment; filename=records.xlsx")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	setLastModified(w, lastModified)
+
+	_, err = buf.WriteTo(w)
+	if err != nil {
+		logger.Warn("failed to write xlsx", zap.Error(err))
+	}
+}
+
+// renderXLSX renders the spreadsheet of the records in memory.
+func renderXLSX(records []*domain.Record) (*bytes.Buffer, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	err := f.SetSheetName(f.GetSheetName(0), xlsxSheet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to name sheet: %w", err)
+	}
+
+	err = f.SetSheetRow(xlsxSheet, "A1", &[]any{"links_num", "link", "status"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+
+	row := 1
+	for _, rec := range records {
+		links := make([]string, 0, len(rec.Links))
+		for link := range rec.Links {
+			links = append(links, link)
+		}
+		slices.Sort(links)
+
+		for _, link := range links {
+			row++
+
+			err = f.SetSheetRow(xlsxSheet, "A"+strconv.Itoa(row), &[]any{rec.ID, link, rec.Links[link]})
+			if err != nil {
+				return nil, fmt.Errorf("failed to write row: %w", err)
+			}
+		}
+	}
+
+	err = f.AutoFilter(xlsxSheet, "A1:C"+strconv.Itoa(row), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set filter: %w", err)
+	}
+
+	err = f.SetPanes(xlsxSheet, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to freeze header: %w", err)
+	}
+
+	err = f.SetColWidth(xlsxSheet, "B", "B", 50)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set column width: %w", err)
+	}
+
+	var buf bytes.Buffer
+	err = f.Write(&buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write xlsx: %w", err)
+	}
+
+	return &buf, nil
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -11,7 +12,21 @@ import (
 )
 
 type Config struct {
-	Env string `env:"LOGGER" env-required:"true"`
+	Env string `env:"LOGGER"`
+}
+
+// Validate reports a missing or unknown environment.
+func (c *Config) Validate() error {
+	switch c.Env {
+	case "dev", "prod":
+		return nil
+
+	case "":
+		return errors.New("LOGGER is required")
+
+	default:
+		return fmt.Errorf("LOGGER must be dev or prod, got %q", c.Env)
+	}
 }
 
 func New(cfg *Config) (*zap.Logger, error) {
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "link_service"
+
+var StorageFileSize = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Subsystem: "storage",
+	Name:      "file_size_bytes",
+	Help:      "Size of the main storage file in bytes.",
+})
+
+var TempFileSize = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Subsystem: "storage",
+	Name:      "temp_file_size_bytes",
+	Help:      "Size of the temp storage file in bytes.",
+})
+
+var StaleRecordsTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "stale_records_total",
+	Help:      "Number of stale records found, summed over all health checks.",
+})
+
+var RecordCacheHits = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "record_cache",
+	Name:      "hits_total",
+	Help:      "Number of records read from the record cache.",
+})
+
+var RecordCacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "record_cache",
+	Name:      "misses_total",
+	Help:      "Number of records read through the record cache from the storage.",
+})
@@ -0,0 +1,378 @@
+// Package pdf renders records as a PDF report: a header with the logo, title
+// and generation time on every page, page numbers in the footer and a table
+// of clickable links per record with the statuses colored, after a summary of
+// the links. The outline of the document lists the summary and the records.
+package pdf
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/phpdave11/gofpdf"
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/report"
+)
+
+// DefaultTitle is the title of a report without a configured one.
+const DefaultTitle = "Link status report"
+
+const (
+	defaultFontFamily = "Arial"
+	customFontFamily  = "report"
+)
+
+// Layout of an A4 page in mm.
+const (
+	headerHeight  = 14
+	logoHeight    = 10
+	footerOffset  = -15
+	headingHeight = 8
+	rowHeight     = 6
+	statusWidth   = 40
+
+	summaryLabelWidth = 45
+)
+
+var (
+	headerFill      = [3]int{230, 230, 230}
+	availableFill   = [3]int{198, 239, 206}
+	unavailableFill = [3]int{255, 199, 206}
+	linkColor       = [3]int{0, 0, 238}
+)
+
+// Options configures the report.
+type Options struct {
+	// Title is printed in the header of every page. When empty, DefaultTitle
+	// is used.
+	Title string
+	// LogoPath points to a PNG, JPEG or GIF image printed left of the title.
+	// When empty, missing or invalid, the header has no logo.
+	LogoPath string
+	// FontPath points to a UTF-8 TTF font. When empty, missing or invalid,
+	// the built-in Latin-only Arial is used.
+	FontPath string
+}
+
+// Records calls fn with the records of a report in order and stops at the
+// first error.
+type Records func(ctx context.Context, fn func(rec *domain.Record) error) error
+
+// ValidateFont checks that the font file can be loaded by the renderer.
+func ValidateFont(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read font: %w", err)
+	}
+
+	err = loadFont(gofpdf.New("P", "mm", "A4", ""), data)
+	if err != nil {
+		return fmt.Errorf("failed to load font: %w", err)
+	}
+
+	return nil
+}
+
+// ValidateLogo checks that the image can be embedded in the report.
+func ValidateLogo(path string) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.RegisterImageOptions(path, gofpdf.ImageOptions{ReadDpi: true})
+
+	err := pdf.Error()
+	if err != nil {
+		return fmt.Errorf("failed to load logo: %w", err)
+	}
+
+	return nil
+}
+
+// Write renders the summary and then the records one by one and writes the
+// document to w. gofpdf only produces the document once every record is
+// rendered, so ctx is checked between records to stop early. A record is
+// dropped once its table is rendered, so with a batched Records only the
+// rendered pages, far smaller than the records, grow with the report.
+func Write(ctx context.Context, w io.Writer, records Records, summary report.Summary, opts Options, logger *zap.Logger) error {
+	pdf, err := render(ctx, records, summary, opts, logger)
+	if err != nil {
+		return err
+	}
+
+	return pdf.Output(w)
+}
+
+// render lays the records out in a new document.
+func render(ctx context.Context, records Records, summary report.Summary, opts Options, logger *zap.Logger) (*gofpdf.Fpdf, error) {
+	logo := opts.LogoPath
+	if logo != "" {
+		err := ValidateLogo(logo)
+		if err != nil {
+			logger.Warn("failed to load report logo, rendering without it",
+				zap.String("logo_path", logo),
+				zap.Error(err),
+			)
+			logo = ""
+		}
+	}
+
+	pdf, family := newPDF(opts.FontPath, logger)
+	r := &renderer{
+		pdf:         pdf,
+		family:      family,
+		title:       cmp.Or(opts.Title, DefaultTitle),
+		logo:        logo,
+		generatedAt: time.Now().UTC(),
+	}
+
+	if logo != "" {
+		info := pdf.RegisterImageOptions(logo, gofpdf.ImageOptions{ReadDpi: true})
+		r.logoWidth = logoHeight * info.Width() / info.Height()
+	}
+
+	pdf.AliasNbPages("")
+	pdf.SetHeaderFunc(r.header)
+	pdf.SetFooterFunc(r.footer)
+	pdf.AddPage()
+	r.summary(summary)
+
+	err := records(ctx, func(rec *domain.Record) error {
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+
+		r.record(rec)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pdf, pdf.Error()
+}
+
+// renderer draws the parts of the report on the document.
+type renderer struct {
+	pdf         *gofpdf.Fpdf
+	family      string
+	title       string
+	logo        string
+	logoWidth   float64
+	generatedAt time.Time
+}
+
+// header draws the logo, the title and the generation time, underlined.
+func (r *renderer) header() {
+	left, top, right, _ := r.pdf.GetMargins()
+	pageWidth, _ := r.pdf.GetPageSize()
+
+	x := left
+	if r.logo != "" {
+		r.pdf.ImageOptions(r.logo, left, top, r.logoWidth, logoHeight, false, gofpdf.ImageOptions{ReadDpi: true}, 0, "")
+		x += r.logoWidth + 4
+	}
+
+	generated := "Generated " + r.generatedAt.Format("2006-01-02 15:04 MST")
+	r.pdf.SetFont(r.family, "", 9)
+	generatedWidth := r.pdf.GetStringWidth(generated) + 2
+
+	r.pdf.SetXY(x, top)
+	r.pdf.SetFont(r.family, "", 14)
+	r.pdf.CellFormat(pageWidth-right-x-generatedWidth, logoHeight,
+		truncateToFit(r.pdf, r.title, pageWidth-right-x-generatedWidth), "", 0, "LM", false, 0, "")
+
+	r.pdf.SetFont(r.family, "", 9)
+	r.pdf.CellFormat(generatedWidth, logoHeight, generated, "", 0, "RM", false, 0, "")
+
+	r.pdf.Line(left, top+logoHeight+1, pageWidth-right, top+logoHeight+1)
+	r.pdf.SetXY(left, top+headerHeight)
+}
+
+// footer draws the page number out of the page count.
+func (r *renderer) footer() {
+	r.pdf.SetY(footerOffset)
+	r.pdf.SetFont(r.family, "", 9)
+	r.pdf.CellFormat(0, 10, fmt.Sprintf("Page %d of {nb}", r.pdf.PageNo()), "", 0, "C", false, 0, "")
+}
+
+// summary draws the summary table of the report.
+func (r *renderer) summary(summary report.Summary) {
+	r.pdf.Bookmark("Summary", 0, -1)
+
+	r.pdf.SetFont(r.family, "", 12)
+	r.pdf.CellFormat(0, headingHeight, "Summary", "", 1, "", false, 0, "")
+
+	failing := make([]string, 0, len(summary.TopFailingDomains))
+	for _, d := range summary.TopFailingDomains {
+		failing = append(failing, fmt.Sprintf("%s (%d)", d.Domain, d.Count))
+	}
+	if len(failing) == 0 {
+		failing = append(failing, "none")
+	}
+
+	rows := [][2]string{
+		{"Links", strconv.Itoa(summary.TotalLinks)},
+		{"OK", fmt.Sprintf("%d (%.1f%%)", summary.OK, summary.OKPercent())},
+		{"Broken", fmt.Sprintf("%d (%.1f%%)", summary.Broken, summary.BrokenPercent())},
+		{"Top failing domains", strings.Join(failing, ", ")},
+	}
+
+	left, _, right, _ := r.pdf.GetMargins()
+	pageWidth, _ := r.pdf.GetPageSize()
+	valueWidth := pageWidth - left - right - summaryLabelWidth
+
+	r.pdf.SetFont(r.family, "", 10)
+	for _, row := range rows {
+		r.pdf.SetFillColor(headerFill[0], headerFill[1], headerFill[2])
+		r.pdf.CellFormat(summaryLabelWidth, rowHeight, row[0], "1", 0, "", true, 0, "")
+		r.pdf.CellFormat(valueWidth, rowHeight, truncateToFit(r.pdf, row[1], valueWidth-2), "1", 1, "", false, 0, "")
+	}
+
+	r.pdf.Ln(6)
+}
+
+// record bookmarks the record and draws its heading and the table of its
+// links sorted by URL. The heading is kept on the page of the first row, and
+// the table header is repeated on every page the table runs over.
+func (r *renderer) record(rec *domain.Record) {
+	left, _, right, _ := r.pdf.GetMargins()
+	pageWidth, _ := r.pdf.GetPageSize()
+	maxWidth := pageWidth - left - right
+
+	heading := "Record " + strconv.FormatInt(rec.ID, 10)
+	if len(rec.Tags) > 0 {
+		heading += " (" + strings.Join(rec.Tags, ", ") + ")"
+	}
+
+	if !r.fits(headingHeight + 2*rowHeight) {
+		r.pdf.AddPage()
+	}
+
+	// The outline of the document, shown by viewers as a table of contents,
+	// has an entry per record to jump to it.
+	r.pdf.Bookmark("Record "+strconv.FormatInt(rec.ID, 10), 0, -1)
+
+	r.pdf.SetFont(r.family, "", 12)
+	r.pdf.CellFormat(0, headingHeight, truncateToFit(r.pdf, heading, maxWidth), "", 1, "", false, 0, "")
+
+	r.pdf.SetFont(r.family, "", 10)
+	r.tableHeader(maxWidth)
+
+	links := make([]string, 0, len(rec.Links))
+	for link := range rec.Links {
+		links = append(links, link)
+	}
+	slices.Sort(links)
+
+	for _, link := range links {
+		if !r.fits(rowHeight) {
+			r.pdf.AddPage()
+			r.pdf.SetFont(r.family, "", 10)
+			r.tableHeader(maxWidth)
+		}
+
+		status := rec.Links[link]
+		fill := unavailableFill
+		if status == domain.StatusAvailable {
+			fill = availableFill
+		}
+
+		r.pdf.SetTextColor(linkColor[0], linkColor[1], linkColor[2])
+		r.pdf.CellFormat(maxWidth-statusWidth, rowHeight, truncateToFit(r.pdf, link, maxWidth-statusWidth-2), "1", 0, "", false, 0, linkTarget(link))
+		r.pdf.SetTextColor(0, 0, 0)
+		r.pdf.SetFillColor(fill[0], fill[1], fill[2])
+		r.pdf.CellFormat(statusWidth, rowHeight, truncateToFit(r.pdf, status, statusWidth-2), "1", 1, "C", true, 0, "")
+	}
+
+	r.pdf.Ln(4)
+}
+
+// linkTarget returns the URL a link cell opens. Links without a scheme are
+// checked over HTTPS, so they open over HTTPS too.
+func linkTarget(link string) string {
+	if strings.HasPrefix(link, "http://") || strings.HasPrefix(link, "https://") {
+		return link
+	}
+
+	return "https://" + link
+}
+
+// tableHeader draws the header row of a links table.
+func (r *renderer) tableHeader(maxWidth float64) {
+	r.pdf.SetFillColor(headerFill[0], headerFill[1], headerFill[2])
+	r.pdf.CellFormat(maxWidth-statusWidth, rowHeight, "Link", "1", 0, "", true, 0, "")
+	r.pdf.CellFormat(statusWidth, rowHeight, "Status", "1", 1, "C", true, 0, "")
+}
+
+// fits reports whether height is left above the bottom margin of the page.
+func (r *renderer) fits(height float64) bool {
+	_, pageHeight := r.pdf.GetPageSize()
+	_, bottom := r.pdf.GetAutoPageBreak()
+
+	return r.pdf.GetY()+height <= pageHeight-bottom
+}
+
+// truncateToFit shortens text with an ellipsis so that it is at most maxWidth
+// wide in the current font. Runes are dropped whole so multi-byte characters
+// are never split.
+func truncateToFit(pdf *gofpdf.Fpdf, text string, maxWidth float64) string {
+	if pdf.GetStringWidth(text) <= maxWidth {
+		return text
+	}
+
+	const ellipsis = "..."
+
+	runes := []rune(text)
+	// Find the first prefix length that no longer fits; the one before it is
+	// the longest prefix that does.
+	n := sort.Search(len(runes), func(i int) bool {
+		return pdf.GetStringWidth(string(runes[:i+1])+ellipsis) > maxWidth
+	})
+
+	return string(runes[:n]) + ellipsis
+}
+
+// loadFont registers the TTF font data as the custom font family. gofpdf does
+// not report every invalid font on load, so the font is selected once to make
+// sure it is usable.
+func loadFont(pdf *gofpdf.Fpdf, data []byte) error {
+	pdf.AddUTF8FontFromBytes(customFontFamily, "", data)
+	pdf.SetFont(customFontFamily, "", 12)
+
+	return pdf.Error()
+}
+
+// newPDF creates a document with the configured font loaded and returns it
+// with the font family to use, falling back to the built-in font with a
+// warning when the font cannot be loaded.
+func newPDF(fontPath string, logger *zap.Logger) (*gofpdf.Fpdf, string) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	if fontPath == "" {
+		return pdf, defaultFontFamily
+	}
+
+	data, err := os.ReadFile(fontPath)
+	if err == nil {
+		err = loadFont(pdf, data)
+	}
+
+	if err != nil {
+		logger.Warn("failed to load report font, falling back to built-in font",
+			zap.String("font_path", fontPath),
+			zap.String("fallback_font", defaultFontFamily),
+			zap.Error(err),
+		)
+
+		return gofpdf.New("P", "mm", "A4", ""), defaultFontFamily
+	}
+
+	return pdf, customFontFamily
+}
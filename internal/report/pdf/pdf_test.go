@@ -0,0 +1,209 @@
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/phpdave11/gofpdf"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"link-service/internal/domain"
+	"link-service/internal/report"
+)
+
+func sliceRecords(records ...*domain.Record) Records {
+	return func(ctx context.Context, fn func(rec *domain.Record) error) error {
+		for _, rec := range records {
+			err := fn(rec)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// output renders the records uncompressed so the text can be searched.
+func output(t *testing.T, records Records, summary report.Summary, opts Options, logger *zap.Logger) string {
+	t.Helper()
+
+	pdf, err := render(context.Background(), records, summary, opts, logger)
+	assert.NoError(t, err)
+
+	pdf.SetCompression(false)
+
+	var buf bytes.Buffer
+	assert.NoError(t, pdf.Output(&buf))
+	return buf.String()
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(context.Background(), &buf, sliceRecords(
+		&domain.Record{Links: map[string]string{"google.com": "available"}, ID: 1},
+		&domain.Record{Links: map[string]string{"12dqf4wgf4.com": "not available"}, ID: 2},
+	), report.Summary{}, Options{}, zap.NewNop())
+	assert.NoError(t, err)
+	assert.Equal(t, "%PDF-", buf.String()[:5])
+}
+
+func TestWriteStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var buf bytes.Buffer
+	err := Write(ctx, &buf, sliceRecords(
+		&domain.Record{Links: map[string]string{"google.com": "available"}, ID: 1},
+	), report.Summary{}, Options{}, zap.NewNop())
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, buf.Len())
+}
+
+func TestLayout(t *testing.T) {
+	links := make(map[string]string, 60)
+	for i := range 60 {
+		links["example.com/"+strconv.Itoa(i)] = "available"
+	}
+	links["broken.com"] = "not available"
+
+	out := output(t, sliceRecords(
+		&domain.Record{ID: 1, Links: links},
+		&domain.Record{ID: 2, Links: map[string]string{"google.com": "available"}},
+	), report.Summary{}, Options{Title: "ACME links"}, zap.NewNop())
+
+	assert.Contains(t, out, "(ACME links)")
+	assert.Contains(t, out, "(Generated ")
+	assert.Contains(t, out, "(Record 1)")
+	assert.Contains(t, out, "(Record 2)")
+	assert.Contains(t, out, "(Page 1 of 2)")
+	assert.Contains(t, out, "(Page 2 of 2)")
+	// The table header is repeated on the second page.
+	assert.Equal(t, 3, strings.Count(out, "(Link)"))
+	assert.Contains(t, out, "(not available)")
+}
+
+func TestSummary(t *testing.T) {
+	summary := report.Summary{
+		TotalLinks:        3,
+		OK:                2,
+		Broken:            1,
+		TopFailingDomains: []report.DomainCount{{Domain: "b.com", Count: 1}},
+	}
+
+	out := output(t, sliceRecords(), summary, Options{}, zap.NewNop())
+	assert.Contains(t, out, "(Summary)")
+	assert.Contains(t, out, `(2 \(66.7%\))`)
+	assert.Contains(t, out, `(1 \(33.3%\))`)
+	assert.Contains(t, out, `(b.com \(1\))`)
+	assert.Contains(t, out, "/Title (Summary)")
+
+	out = output(t, sliceRecords(), report.Summary{}, Options{}, zap.NewNop())
+	assert.Contains(t, out, "(none)")
+}
+
+func TestLinksAndOutline(t *testing.T) {
+	out := output(t, sliceRecords(
+		&domain.Record{ID: 7, Links: map[string]string{"google.com": "available", "http://example.com/a": "not available"}},
+		&domain.Record{ID: 9, Links: map[string]string{"https://go.dev": "available"}},
+	), report.Summary{}, Options{}, zap.NewNop())
+
+	assert.Contains(t, out, "/URI (https://google.com)")
+	assert.Contains(t, out, "/URI (http://example.com/a)")
+	assert.Contains(t, out, "/URI (https://go.dev)")
+
+	assert.Contains(t, out, "/Type /Outlines")
+	assert.Contains(t, out, "/Title (Record 7)")
+	assert.Contains(t, out, "/Title (Record 9)")
+}
+
+func TestLogo(t *testing.T) {
+	logo := filepath.Join(t.TempDir(), "logo.png")
+	f, err := os.Create(logo)
+	assert.NoError(t, err)
+	assert.NoError(t, png.Encode(f, image.NewRGBA(image.Rect(0, 0, 40, 20))))
+	assert.NoError(t, f.Close())
+
+	invalidLogo := filepath.Join(t.TempDir(), "invalid.png")
+	assert.NoError(t, os.WriteFile(invalidLogo, []byte("not an image"), 0644))
+
+	records := sliceRecords(&domain.Record{ID: 1, Links: map[string]string{"google.com": "available"}})
+
+	assert.NoError(t, ValidateLogo(logo))
+	out := output(t, records, report.Summary{}, Options{LogoPath: logo}, zap.NewNop())
+	assert.Contains(t, out, "/Subtype /Image")
+
+	for _, path := range []string{invalidLogo, filepath.Join(t.TempDir(), "missing.png")} {
+		assert.Error(t, ValidateLogo(path))
+
+		core, logs := observer.New(zap.WarnLevel)
+		out = output(t, records, report.Summary{}, Options{LogoPath: path}, zap.New(core))
+		assert.NotContains(t, out, "/Subtype /Image")
+		assert.Equal(t, 1, logs.FilterMessage("failed to load report logo, rendering without it").Len())
+	}
+}
+
+func TestMissingFont(t *testing.T) {
+	invalidFont := filepath.Join(t.TempDir(), "invalid.ttf")
+	err := os.WriteFile(invalidFont, []byte("not a font"), 0644)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		fontPath string
+	}{
+		{
+			name:     "missing font",
+			fontPath: filepath.Join(t.TempDir(), "missing.ttf"),
+		},
+		{
+			name:     "invalid font",
+			fontPath: invalidFont,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.WarnLevel)
+
+			var buf bytes.Buffer
+			err := Write(context.Background(), &buf, sliceRecords(
+				&domain.Record{Links: map[string]string{"google.com": "available"}, ID: 1},
+			), report.Summary{}, Options{FontPath: tt.fontPath}, zap.New(core))
+			assert.NoError(t, err)
+			assert.Greater(t, buf.Len(), 0)
+
+			warnings := logs.FilterMessage("failed to load report font, falling back to built-in font")
+			assert.Equal(t, 1, warnings.Len())
+
+			assert.Error(t, ValidateFont(tt.fontPath))
+		})
+	}
+}
+
+func TestTruncateToFit(t *testing.T) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetFont(defaultFontFamily, "", 12)
+
+	short := "https://example.com"
+	assert.Equal(t, short, truncateToFit(pdf, short, 100))
+
+	long := "https://example.com/" + strings.Repeat("a", 500)
+	truncated := truncateToFit(pdf, long, 100)
+	prefix, ok := strings.CutSuffix(truncated, "...")
+	assert.True(t, ok)
+	assert.True(t, strings.HasPrefix(long, prefix))
+	assert.LessOrEqual(t, pdf.GetStringWidth(truncated), 100.0)
+	assert.Greater(t, pdf.GetStringWidth(long[:len(prefix)+1]+"..."), 100.0)
+
+	assert.Equal(t, "...", truncateToFit(pdf, long, 0))
+}
@@ -0,0 +1,117 @@
+// Package report holds what the report formats have in common.
+package report
+
+import (
+	"cmp"
+	"net/url"
+	"slices"
+	"strings"
+
+	"link-service/internal/domain"
+)
+
+// topFailingDomains is the number of domains listed in a summary.
+const topFailingDomains = 5
+
+// Summary aggregates the links of a report.
+type Summary struct {
+	TotalLinks int `json:"total_links"`
+	OK         int `json:"ok"`
+	Broken     int `json:"broken"`
+	// TopFailingDomains are the domains with the most broken links, most
+	// first.
+	TopFailingDomains []DomainCount `json:"top_failing_domains"`
+}
+
+// DomainCount is the number of broken links of a domain.
+type DomainCount struct {
+	Domain string `json:"domain"`
+	Count  int    `json:"count"`
+}
+
+// OKPercent returns the share of working links in percent, 0 without links.
+func (s Summary) OKPercent() float64 {
+	return percent(s.OK, s.TotalLinks)
+}
+
+// BrokenPercent returns the share of broken links in percent, 0 without
+// links.
+func (s Summary) BrokenPercent() float64 {
+	return percent(s.Broken, s.TotalLinks)
+}
+
+// Counter builds a summary from records added one by one, so the records do
+// not need to be held in memory. The zero value is ready to use.
+type Counter struct {
+	total   int
+	ok      int
+	failing map[string]int
+}
+
+// Add counts the links of the record.
+func (c *Counter) Add(rec *domain.Record) {
+	for link, status := range rec.Links {
+		c.total++
+		if status == domain.StatusAvailable {
+			c.ok++
+			continue
+		}
+
+		if c.failing == nil {
+			c.failing = make(map[string]int)
+		}
+		c.failing[linkDomain(link)]++
+	}
+}
+
+// Summary returns the summary of the records added so far.
+func (c *Counter) Summary() Summary {
+	domains := make([]DomainCount, 0, len(c.failing))
+	for name, count := range c.failing {
+		domains = append(domains, DomainCount{Domain: name, Count: count})
+	}
+	slices.SortFunc(domains, func(a, b DomainCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.Domain, b.Domain))
+	})
+
+	return Summary{
+		TotalLinks:        c.total,
+		OK:                c.ok,
+		Broken:            c.total - c.ok,
+		TopFailingDomains: domains[:min(len(domains), topFailingDomains)],
+	}
+}
+
+// Summarize returns the summary of the records.
+func Summarize(records []*domain.Record) Summary {
+	var c Counter
+	for _, rec := range records {
+		c.Add(rec)
+	}
+
+	return c.Summary()
+}
+
+// linkDomain returns the lowercased host of the link, which may lack a
+// scheme, or the link itself when it has none.
+func linkDomain(link string) string {
+	raw := link
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return link
+	}
+
+	return strings.ToLower(u.Hostname())
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(n) * 100 / float64(total)
+}
@@ -0,0 +1,49 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"link-service/internal/domain"
+)
+
+func TestSummarize(t *testing.T) {
+	summary := Summarize([]*domain.Record{
+		{ID: 1, Links: map[string]string{
+			"a.com":               "available",
+			"b.com/x":             "not available",
+			"https://B.com/y":     "not available",
+			"http://c.com:8080/z": "unknown",
+		}},
+		{ID: 2, Links: map[string]string{
+			"d.com": "not available",
+			"e.com": "not available",
+			"f.com": "not available",
+			"g.com": "not available",
+			"h.com": "available",
+		}},
+	})
+
+	assert.Equal(t, 9, summary.TotalLinks)
+	assert.Equal(t, 2, summary.OK)
+	assert.Equal(t, 7, summary.Broken)
+	assert.InDelta(t, 22.2, summary.OKPercent(), 0.1)
+	assert.InDelta(t, 77.8, summary.BrokenPercent(), 0.1)
+	assert.Equal(t, []DomainCount{
+		{Domain: "b.com", Count: 2},
+		{Domain: "c.com", Count: 1},
+		{Domain: "d.com", Count: 1},
+		{Domain: "e.com", Count: 1},
+		{Domain: "f.com", Count: 1},
+	}, summary.TopFailingDomains)
+}
+
+func TestSummarizeEmpty(t *testing.T) {
+	summary := Summarize(nil)
+
+	assert.Zero(t, summary.TotalLinks)
+	assert.Zero(t, summary.OKPercent())
+	assert.Zero(t, summary.BrokenPercent())
+	assert.Empty(t, summary.TopFailingDomains)
+}
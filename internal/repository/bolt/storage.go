@@ -0,0 +1,761 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+var (
+	recordsBucket     = []byte("records")
+	tempRecordsBucket = []byte("temp_records")
+	eventsBucket      = []byte("events")
+)
+
+// StorageType selects this backend with STORAGE_TYPE.
+const StorageType = "bolt"
+
+func init() {
+	repository.Register(StorageType, repository.FactoryFor(func(cfg *Config, logger *zap.Logger) (*Storage, error) {
+		return New(cfg, clock.Real{}, logger)
+	}))
+}
+
+type Config struct {
+	Path        string        `env:"BOLT_PATH"`
+	FileMode    os.FileMode   `env:"BOLT_FILE_MODE" env-default:"0600"`
+	OpenTimeout time.Duration `env:"BOLT_OPEN_TIMEOUT" env-default:"1s"`
+}
+
+// Validate reports every missing or invalid field of the config.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Path == "" {
+		errs = append(errs, errors.New("BOLT_PATH is required"))
+	}
+
+	if c.OpenTimeout < 0 {
+		errs = append(errs, errors.New("BOLT_OPEN_TIMEOUT must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Storage keeps records in a bbolt database keyed by their big-endian ID,
+// so lookups do not scan and the last key is the last ID. Temp records live
+// in a separate bucket keyed by insertion sequence.
+type Storage struct {
+	db *bbolt.DB
+	// records is the number of keys in the records bucket, see Len.
+	records atomic.Int64
+	clock   clock.Clock
+	logger  *zap.Logger
+}
+
+func New(cfg *Config, clk clock.Clock, logger *zap.Logger) (*Storage, error) {
+	fileMode := cfg.FileMode
+	if fileMode == 0 {
+		fileMode = 0600
+	}
+
+	err := os.MkdirAll(filepath.Dir(cfg.Path), 0755)
+	if err != nil {
+		logger.Error("failed to create dir", zap.String("path", cfg.Path), zap.Error(err))
+		return nil, fmt.Errorf("failed to create dir: %s: %w", filepath.Dir(cfg.Path), err)
+	}
+
+	db, err := bbolt.Open(cfg.Path, fileMode, &bbolt.Options{Timeout: cfg.OpenTimeout})
+	if err != nil {
+		logger.Error("failed to open db", zap.String("path", cfg.Path), zap.Error(err))
+		return nil, fmt.Errorf("failed to open db: %s: %w", cfg.Path, err)
+	}
+
+	var records int
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{recordsBucket, tempRecordsBucket, eventsBucket} {
+			_, err := tx.CreateBucketIfNotExists(name)
+			if err != nil {
+				return fmt.Errorf("failed to create bucket %s: %w", name, err)
+			}
+		}
+
+		records = tx.Bucket(recordsBucket).Stats().KeyN
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		logger.Error("failed to init db", zap.String("path", cfg.Path), zap.Error(err))
+		return nil, fmt.Errorf("failed to init db: %s: %w", cfg.Path, err)
+	}
+
+	logger.Info("bolt db opened", zap.String("path", cfg.Path))
+
+	storage := &Storage{
+		db:     db,
+		clock:  clk,
+		logger: logger,
+	}
+	storage.records.Store(int64(records))
+
+	return storage, nil
+}
+
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+// SaveRecord stores the record under its ID. Missing timestamps are set from
+// the storage clock.
+func (s *Storage) SaveRecord(ctx context.Context, record *domain.Record) error {
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = s.clock.Now().UTC()
+	}
+
+	if record.UpdatedAt.IsZero() {
+		record.UpdatedAt = record.CreatedAt
+	}
+
+	var added bool
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(recordsBucket)
+		key := idKey(record.ID)
+		added = bucket.Get(key) == nil
+
+		err := putRecord(bucket, key, record)
+		if err != nil {
+			return err
+		}
+
+		return s.putEvent(tx, record.ID, domain.EventTypeCreated)
+	})
+	if err != nil {
+		s.logger.Error("failed to save record", zap.Int64("id", record.ID), zap.Error(err))
+		return fmt.Errorf("failed to save record: %w", err)
+	}
+
+	if added {
+		s.records.Add(1)
+	}
+
+	s.logger.Info("successfully wrote record")
+	return nil
+}
+
+// SaveRecords stores the records in a single transaction.
+func (s *Storage) SaveRecords(ctx context.Context, records []*domain.Record) error {
+	now := s.clock.Now().UTC()
+
+	var added int64
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(recordsBucket)
+
+		for _, record := range records {
+			if record.CreatedAt.IsZero() {
+				record.CreatedAt = now
+			}
+
+			if record.UpdatedAt.IsZero() {
+				record.UpdatedAt = record.CreatedAt
+			}
+
+			key := idKey(record.ID)
+			if bucket.Get(key) == nil {
+				added++
+			}
+
+			err := putRecord(bucket, key, record)
+			if err != nil {
+				return err
+			}
+
+			err = s.putEvent(tx, record.ID, domain.EventTypeCreated)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("failed to save records", zap.Error(err))
+		return fmt.Errorf("failed to save records: %w", err)
+	}
+
+	s.records.Add(added)
+
+	s.logger.Info("successfully wrote records", zap.Int("count", len(records)))
+	return nil
+}
+
+func (s *Storage) SaveTempRecord(ctx context.Context, record *domain.Record) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(tempRecordsBucket)
+
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		return putRecord(bucket, seqKey(seq), record)
+	})
+	if err != nil {
+		s.logger.Error("failed to save temp record", zap.Int64("id", record.ID), zap.Error(err))
+		return fmt.Errorf("failed to save temp record: %w", err)
+	}
+
+	s.logger.Info("successfully wrote temp record")
+	return nil
+}
+
+// UpsertRecords inserts or replaces the batch in a single transaction.
+// If the batch contains the same ID several times, the last one wins.
+func (s *Storage) UpsertRecords(ctx context.Context, records []*domain.Record) (int, int, error) {
+	batch := make(map[int64]*domain.Record, len(records))
+	order := make([]int64, 0, len(records))
+	for _, rec := range records {
+		if _, ok := batch[rec.ID]; !ok {
+			order = append(order, rec.ID)
+		}
+		batch[rec.ID] = rec
+	}
+
+	now := s.clock.Now().UTC()
+	inserted, updated := 0, 0
+
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(recordsBucket)
+
+		for _, id := range order {
+			rec := batch[id]
+
+			eventType := domain.EventTypeUpdated
+
+			stored, err := getRecord(bucket, idKey(id))
+			switch {
+			case errors.Is(err, repository.ErrRecordNotFound):
+				if rec.CreatedAt.IsZero() {
+					rec.CreatedAt = now
+				}
+				if rec.UpdatedAt.IsZero() {
+					rec.UpdatedAt = rec.CreatedAt
+				}
+				eventType = domain.EventTypeCreated
+				inserted++
+
+			case err != nil:
+				return err
+
+			default:
+				if rec.CreatedAt.IsZero() {
+					rec.CreatedAt = stored.CreatedAt
+				}
+				rec.UpdatedAt = now
+				updated++
+			}
+
+			err = putRecord(bucket, idKey(id), rec)
+			if err != nil {
+				return err
+			}
+
+			err = s.putEvent(tx, id, eventType)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("failed to upsert records", zap.Error(err))
+		return 0, 0, fmt.Errorf("failed to upsert records: %w", err)
+	}
+
+	s.records.Add(int64(inserted))
+
+	s.logger.Info("successfully upserted records", zap.Int("inserted", inserted), zap.Int("updated", updated))
+	return inserted, updated, nil
+}
+
+// UpdateRecord replaces the stored record and stores its updated event in
+// the same transaction. A zero CreatedAt keeps the stored creation time. It
+// fails with repository.ErrRecordNotFound if the record is not stored.
+func (s *Storage) UpdateRecord(ctx context.Context, record *domain.Record) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(recordsBucket)
+		key := idKey(record.ID)
+
+		stored, err := getRecord(bucket, key)
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return fmt.Errorf("record with ID %d: %w", record.ID, err)
+		}
+		if err != nil {
+			return err
+		}
+
+		if record.CreatedAt.IsZero() {
+			record.CreatedAt = stored.CreatedAt
+		}
+		record.UpdatedAt = s.clock.Now().UTC()
+
+		err = putRecord(bucket, key, record)
+		if err != nil {
+			return err
+		}
+
+		return s.putEvent(tx, record.ID, domain.EventTypeUpdated)
+	})
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return err
+	}
+	if err != nil {
+		s.logger.Error("failed to update record", zap.Int64("id", record.ID), zap.Error(err))
+		return fmt.Errorf("failed to update record: %w", err)
+	}
+
+	s.logger.Info("successfully updated record", zap.Int64("id", record.ID))
+	return nil
+}
+
+// DeleteRecord removes the record and stores its deleted event in the same
+// transaction. It fails with repository.ErrRecordNotFound if the record is
+// not stored.
+func (s *Storage) DeleteRecord(ctx context.Context, id int64) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(recordsBucket)
+		key := idKey(id)
+
+		if bucket.Get(key) == nil {
+			return fmt.Errorf("record with ID %d: %w", id, repository.ErrRecordNotFound)
+		}
+
+		err := bucket.Delete(key)
+		if err != nil {
+			return err
+		}
+
+		return s.putEvent(tx, id, domain.EventTypeDeleted)
+	})
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return err
+	}
+	if err != nil {
+		s.logger.Error("failed to delete record", zap.Int64("id", id), zap.Error(err))
+		return fmt.Errorf("failed to delete record: %w", err)
+	}
+
+	s.records.Add(-1)
+
+	s.logger.Info("successfully deleted record", zap.Int64("id", id))
+	return nil
+}
+
+// LoadTempRecords returns the temp records in insertion order, keeping one
+// record per ID: the most recently updated one and, on ties, the last one.
+func (s *Storage) LoadTempRecords(ctx context.Context) ([]domain.Record, error) {
+	var records []domain.Record
+	positions := make(map[int64]int)
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(tempRecordsBucket).ForEach(func(k, v []byte) error {
+			var rec domain.Record
+			err := json.Unmarshal(v, &rec)
+			if err != nil {
+				return fmt.Errorf("failed to decode temp record: %w", err)
+			}
+
+			pos, ok := positions[rec.ID]
+			if !ok {
+				positions[rec.ID] = len(records)
+				records = append(records, rec)
+				return nil
+			}
+
+			if !rec.UpdatedAt.Before(records[pos].UpdatedAt) {
+				records[pos] = rec
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load temp records: %w", err)
+	}
+
+	return records, nil
+}
+
+func (s *Storage) GetRecord(ctx context.Context, id int64) (*domain.Record, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	var rec *domain.Record
+
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		rec, err = getRecord(tx.Bucket(recordsBucket), idKey(id))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("record with ID %d: %w", id, err)
+	}
+
+	return rec, nil
+}
+
+// GetRecords reads the records with the given IDs in a single read transaction.
+func (s *Storage) GetRecords(ctx context.Context, ids []int64) (map[int64]*domain.Record, error) {
+	records := make(map[int64]*domain.Record, len(ids))
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(recordsBucket)
+
+		for _, id := range ids {
+			rec, err := getRecord(bucket, idKey(id))
+			if errors.Is(err, repository.ErrRecordNotFound) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("record with ID %d: %w", id, err)
+			}
+
+			records[id] = rec
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get records: %w", err)
+	}
+
+	return records, nil
+}
+
+// GetAllRecords returns all records matching the filter options ordered by ID.
+func (s *Storage) GetAllRecords(ctx context.Context, opts ...repository.FilterOption) ([]domain.Record, error) {
+	filter := repository.NewFilter(opts...)
+	records := make([]domain.Record, 0)
+
+	err := s.ForEachRecord(ctx, func(rec *domain.Record) error {
+		if filter.Match(rec) {
+			records = append(records, *rec)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func (s *Storage) GetRecordsBySource(ctx context.Context, source string) ([]domain.Record, error) {
+	return s.GetAllRecords(ctx, repository.WithSource(source))
+}
+
+// GetRecordsSince returns the records created or updated at or after since,
+// ordered by UpdatedAt.
+func (s *Storage) GetRecordsSince(ctx context.Context, since time.Time) ([]domain.Record, error) {
+	records := make([]domain.Record, 0)
+
+	err := s.ForEachRecord(ctx, func(rec *domain.Record) error {
+		if rec.ModifiedSince(since) {
+			records = append(records, *rec)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	repository.SortByUpdatedAt(records)
+	return records, nil
+}
+
+func (s *Storage) FindRecordsByTag(ctx context.Context, tag string) ([]domain.Record, error) {
+	return s.GetAllRecords(ctx, repository.WithTag(tag))
+}
+
+// SearchRecords returns the records matching the query ordered by ID.
+func (s *Storage) SearchRecords(ctx context.Context, query repository.SearchQuery) ([]domain.Record, error) {
+	records := make([]domain.Record, 0)
+
+	err := s.ForEachRecord(ctx, func(rec *domain.Record) error {
+		if query.Match(rec) {
+			records = append(records, *rec)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// ForEachRecord calls fn for every record ordered by ID inside a read
+// transaction and stops with the context error once ctx is done. fn must not
+// write to the storage.
+func (s *Storage) ForEachRecord(ctx context.Context, fn func(rec *domain.Record) error) error {
+	return s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(recordsBucket).ForEach(func(k, v []byte) error {
+			err := ctx.Err()
+			if err != nil {
+				return err
+			}
+
+			var rec domain.Record
+			err = json.Unmarshal(v, &rec)
+			if err != nil {
+				s.logger.Warn("skipping corrupted record", zap.Int64("id", keyID(k)), zap.Error(err))
+				return nil
+			}
+
+			return fn(&rec)
+		})
+	})
+}
+
+// ListRecords walks the records bucket from either end with a cursor, since
+// the keys sort by ID. Corrupted records are skipped and not counted.
+func (s *Storage) ListRecords(ctx context.Context, offset, limit int, order string) ([]domain.Record, error) {
+	err := repository.ValidatePage(offset, limit, order)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]domain.Record, 0)
+
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(recordsBucket).Cursor()
+
+		first, next := c.First, c.Next
+		if order == repository.OrderDesc {
+			first, next = c.Last, c.Prev
+		}
+
+		skipped := 0
+		for k, v := first(); k != nil; k, v = next() {
+			err := ctx.Err()
+			if err != nil {
+				return err
+			}
+
+			var rec domain.Record
+			err = json.Unmarshal(v, &rec)
+			if err != nil {
+				s.logger.Warn("skipping corrupted record", zap.Int64("id", keyID(k)), zap.Error(err))
+				continue
+			}
+
+			if skipped < offset {
+				skipped++
+				continue
+			}
+
+			records = append(records, rec)
+			if len(records) == limit {
+				return nil
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list records: %w", err)
+	}
+
+	return records, nil
+}
+
+func (s *Storage) ListRecordIDs(ctx context.Context) ([]int64, error) {
+	ids := make([]int64, 0)
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(recordsBucket).ForEach(func(k, v []byte) error {
+			ids = append(ids, keyID(k))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list record ids: %w", err)
+	}
+
+	return ids, nil
+}
+
+// ClearTempFile drops and recreates the temp records bucket.
+func (s *Storage) ClearTempFile(ctx context.Context) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		err := tx.DeleteBucket(tempRecordsBucket)
+		if err != nil && !errors.Is(err, bbolt.ErrBucketNotFound) {
+			return err
+		}
+
+		_, err = tx.CreateBucket(tempRecordsBucket)
+		return err
+	})
+}
+
+// LoadLastLinksNum returns the highest stored ID. Deleted records keep their
+// events, which count as well so that their IDs are not handed out again.
+func (s *Storage) LoadLastLinksNum(ctx context.Context) int64 {
+	var last int64
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		k, _ := tx.Bucket(recordsBucket).Cursor().Last()
+		if k != nil {
+			last = keyID(k)
+		}
+
+		k, _ = tx.Bucket(eventsBucket).Cursor().Last()
+		if k != nil {
+			last = max(last, keyID(k[:8]))
+		}
+
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("failed to load last links num", zap.Error(err))
+		return 0
+	}
+
+	return last
+}
+
+// Len returns the number of records without reading the bucket.
+func (s *Storage) Len() int64 {
+	return s.records.Load()
+}
+
+// SaveRecordEvent stores the event. A missing OccurredAt is set from the
+// storage clock.
+func (s *Storage) SaveRecordEvent(ctx context.Context, event *domain.RecordEvent) error {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = s.clock.Now().UTC()
+	}
+
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		return putEvent(tx.Bucket(eventsBucket), event)
+	})
+	if err != nil {
+		s.logger.Error("failed to save record event", zap.Int64("id", event.RecordID), zap.Error(err))
+		return fmt.Errorf("failed to save record event: %w", err)
+	}
+
+	return nil
+}
+
+// GetRecordEvents returns the events of the record in the order they were
+// stored. Event keys are prefixed with the record ID, so only the events of
+// the record are read.
+func (s *Storage) GetRecordEvents(ctx context.Context, id int64) ([]domain.RecordEvent, error) {
+	events := make([]domain.RecordEvent, 0)
+	prefix := idKey(id)
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(eventsBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var event domain.RecordEvent
+			err := json.Unmarshal(v, &event)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal event: %w", err)
+			}
+
+			events = append(events, event)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get record events: %w", err)
+	}
+
+	return events, nil
+}
+
+// putEvent stores a lifecycle event of a record written in tx.
+func (s *Storage) putEvent(tx *bbolt.Tx, id int64, eventType string) error {
+	return putEvent(tx.Bucket(eventsBucket), &domain.RecordEvent{
+		RecordID:   id,
+		EventType:  eventType,
+		OccurredAt: s.clock.Now().UTC(),
+	})
+}
+
+// putEvent stores the event under the record ID followed by the bucket
+// sequence, keeping the events of a record together and in order.
+func putEvent(bucket *bbolt.Bucket, event *domain.RecordEvent) error {
+	seq, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	return bucket.Put(append(idKey(event.RecordID), seqKey(seq)...), data)
+}
+
+func getRecord(bucket *bbolt.Bucket, key []byte) (*domain.Record, error) {
+	data := bucket.Get(key)
+	if data == nil {
+		return nil, repository.ErrRecordNotFound
+	}
+
+	var rec domain.Record
+	err := json.Unmarshal(data, &rec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal record: %w", err)
+	}
+
+	return &rec, nil
+}
+
+func putRecord(bucket *bbolt.Bucket, key []byte, rec *domain.Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	return bucket.Put(key, data)
+}
+
+// idKey encodes the ID big-endian so that keys sort in ID order.
+func idKey(id int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
+
+func seqKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+func keyID(key []byte) int64 {
+	return int64(binary.BigEndian.Uint64(key))
+}
@@ -0,0 +1,331 @@
+package bolt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+	"link-service/internal/repository/repositorytest"
+)
+
+var testNow = time.Date(2025, 11, 30, 12, 0, 0, 0, time.UTC)
+
+func newTestStorage(t *testing.T) (*Storage, *clock.Fake) {
+	t.Helper()
+
+	clk := clock.NewFake(testNow)
+	storage, err := New(&Config{Path: filepath.Join(t.TempDir(), "links.db")}, clk, zap.NewNop())
+	assert.NoError(t, err)
+	t.Cleanup(func() { storage.Close() })
+
+	return storage, clk
+}
+
+func TestConformance(t *testing.T) {
+	repositorytest.RunSuite(t, func(t *testing.T) repository.Repository {
+		storage, _ := newTestStorage(t)
+		return storage
+	})
+}
+
+func TestNewCreatesDBFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "links.db")
+
+	storage, err := New(&Config{Path: path}, clock.Real{}, zap.NewNop())
+	assert.NoError(t, err)
+	defer storage.Close()
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestSaveAndGetRecord(t *testing.T) {
+	storage, _ := newTestStorage(t)
+
+	err := storage.SaveRecord(context.Background(), &domain.Record{ID: 2, Links: map[string]string{"a.com": "available"}})
+	assert.NoError(t, err)
+
+	rec, err := storage.GetRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a.com": "available"}, rec.Links)
+	assert.Equal(t, testNow, rec.CreatedAt)
+	assert.Equal(t, testNow, rec.UpdatedAt)
+
+	_, err = storage.GetRecord(context.Background(), 3)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+}
+
+func TestLoadLastLinksNum(t *testing.T) {
+	storage, _ := newTestStorage(t)
+	assert.Equal(t, int64(0), storage.LoadLastLinksNum(context.Background()))
+
+	for _, id := range []int64{3, 256, 10} {
+		err := storage.SaveRecord(context.Background(), &domain.Record{ID: id})
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, int64(256), storage.LoadLastLinksNum(context.Background()))
+
+	ids, err := storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3, 10, 256}, ids)
+}
+
+func TestGetRecordsSince(t *testing.T) {
+	storage, clk := newTestStorage(t)
+
+	for id := int64(1); id <= 3; id++ {
+		err := storage.SaveRecord(context.Background(), &domain.Record{ID: id})
+		assert.NoError(t, err)
+		clk.Advance(time.Hour)
+	}
+
+	_, _, err := storage.UpsertRecords(context.Background(), []*domain.Record{{ID: 1}})
+	assert.NoError(t, err)
+
+	records, err := storage.GetRecordsSince(context.Background(), testNow.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, []int64{2, 3, 1}, []int64{records[0].ID, records[1].ID, records[2].ID})
+}
+
+func TestLen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "links.db")
+
+	storage, err := New(&Config{Path: path}, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), storage.Len())
+
+	assert.NoError(t, storage.SaveRecord(context.Background(), &domain.Record{ID: 1}))
+	assert.NoError(t, storage.SaveRecord(context.Background(), &domain.Record{ID: 1}))
+	assert.NoError(t, storage.SaveRecords(context.Background(), []*domain.Record{{ID: 2}, {ID: 3}}))
+
+	_, _, err = storage.UpsertRecords(context.Background(), []*domain.Record{{ID: 3}, {ID: 4}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), storage.Len())
+	assert.NoError(t, storage.Close())
+
+	storage, err = New(&Config{Path: path}, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	defer storage.Close()
+
+	assert.Equal(t, int64(4), storage.Len())
+}
+
+func TestUpsertRecords(t *testing.T) {
+	storage, clk := newTestStorage(t)
+
+	err := storage.SaveRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "available"}})
+	assert.NoError(t, err)
+
+	clk.Advance(time.Hour)
+
+	inserted, updated, err := storage.UpsertRecords(context.Background(), []*domain.Record{
+		{ID: 1, Links: map[string]string{"a.com": "not available"}},
+		{ID: 2, Links: map[string]string{"b.com": "available"}},
+		{ID: 2, Links: map[string]string{"c.com": "available"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, inserted)
+	assert.Equal(t, 1, updated)
+
+	rec, err := storage.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "not available", rec.Links["a.com"])
+	assert.Equal(t, testNow, rec.CreatedAt)
+	assert.Equal(t, testNow.Add(time.Hour), rec.UpdatedAt)
+
+	rec, err = storage.GetRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"c.com": "available"}, rec.Links)
+}
+
+func TestTempRecords(t *testing.T) {
+	storage, _ := newTestStorage(t)
+
+	for _, rec := range []*domain.Record{
+		{ID: 1, Links: map[string]string{"a.com": "available"}, UpdatedAt: testNow},
+		{ID: 2, Links: map[string]string{"b.com": "available"}},
+		{ID: 1, Links: map[string]string{"a.com": "not available"}, UpdatedAt: testNow.Add(time.Minute)},
+	} {
+		err := storage.SaveTempRecord(context.Background(), rec)
+		assert.NoError(t, err)
+	}
+
+	records, err := storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, int64(1), records[0].ID)
+	assert.Equal(t, "not available", records[0].Links["a.com"])
+
+	err = storage.ClearTempFile(context.Background())
+	assert.NoError(t, err)
+
+	records, err = storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestRecordEvents(t *testing.T) {
+	storage, clk := newTestStorage(t)
+
+	for _, id := range []int64{1, 256} {
+		err := storage.SaveRecord(context.Background(), &domain.Record{ID: id})
+		assert.NoError(t, err)
+	}
+
+	clk.Advance(time.Minute)
+
+	_, _, err := storage.UpsertRecords(context.Background(), []*domain.Record{{ID: 1}, {ID: 2}})
+	assert.NoError(t, err)
+
+	events, err := storage.GetRecordEvents(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.RecordEvent{
+		{RecordID: 1, EventType: domain.EventTypeCreated, OccurredAt: testNow},
+		{RecordID: 1, EventType: domain.EventTypeUpdated, OccurredAt: testNow.Add(time.Minute)},
+	}, events)
+
+	events, err = storage.GetRecordEvents(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.RecordEvent{
+		{RecordID: 2, EventType: domain.EventTypeCreated, OccurredAt: testNow.Add(time.Minute)},
+	}, events)
+}
+
+func TestGetRecords(t *testing.T) {
+	storage, _ := newTestStorage(t)
+
+	for _, id := range []int64{1, 2} {
+		err := storage.SaveRecord(context.Background(), &domain.Record{ID: id, Links: map[string]string{"a.com": "unknown"}})
+		assert.NoError(t, err)
+	}
+
+	records, err := storage.GetRecords(context.Background(), []int64{2, 5})
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	records[2].Links["a.com"] = "available"
+
+	_, updated, err := storage.UpsertRecords(context.Background(), []*domain.Record{records[2]})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, updated)
+
+	rec, err := storage.GetRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "available", rec.Links["a.com"])
+}
+
+func BenchmarkGetRecord(b *testing.B) {
+	for _, size := range []int{1000, 100000} {
+		b.Run(fmt.Sprintf("records=%d", size), func(b *testing.B) {
+			storage, err := New(&Config{Path: filepath.Join(b.TempDir(), "links.db")}, clock.Real{}, zap.NewNop())
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer storage.Close()
+
+			records := make([]*domain.Record, 0, size)
+			for id := int64(1); id <= int64(size); id++ {
+				records = append(records, &domain.Record{ID: id, Links: map[string]string{"a.com": "available"}})
+			}
+
+			err = storage.SaveRecords(context.Background(), records)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			ctx := context.Background()
+			var id int64
+			for b.Loop() {
+				id = id%int64(size) + 1
+				_, err = storage.GetRecord(ctx, id)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestListRecords(t *testing.T) {
+	storage, _ := newTestStorage(t)
+
+	assert.NoError(t, storage.SaveRecords(context.Background(), []*domain.Record{{ID: 3}, {ID: 1}, {ID: 2}}))
+
+	records, err := storage.ListRecords(context.Background(), 1, 1, "")
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, int64(2), records[0].ID)
+
+	records, err = storage.ListRecords(context.Background(), 0, 0, repository.OrderDesc)
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, int64(3), records[0].ID)
+
+	records, err = storage.ListRecords(context.Background(), 5, 1, "")
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	_, err = storage.ListRecords(context.Background(), 0, 0, "newest")
+	assert.ErrorIs(t, err, repository.ErrInvalidPage)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = storage.ListRecords(ctx, 0, 0, "")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestUpdateRecord(t *testing.T) {
+	storage, clk := newTestStorage(t)
+
+	assert.NoError(t, storage.SaveRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "available"}}))
+
+	clk.Advance(time.Minute)
+
+	err := storage.UpdateRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "not available"}})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, storage.UpdateRecord(context.Background(), &domain.Record{ID: 2}), repository.ErrRecordNotFound)
+	assert.Equal(t, int64(1), storage.Len())
+
+	rec, err := storage.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "not available", rec.Links["a.com"])
+	assert.True(t, rec.CreatedAt.Equal(testNow))
+	assert.True(t, rec.UpdatedAt.Equal(testNow.Add(time.Minute)))
+
+	events, err := storage.GetRecordEvents(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.EventTypeUpdated, events[len(events)-1].EventType)
+}
+
+func TestDeleteRecord(t *testing.T) {
+	storage, _ := newTestStorage(t)
+
+	assert.NoError(t, storage.SaveRecords(context.Background(), []*domain.Record{{ID: 1}, {ID: 2}}))
+
+	assert.NoError(t, storage.DeleteRecord(context.Background(), 2))
+	assert.ErrorIs(t, storage.DeleteRecord(context.Background(), 2), repository.ErrRecordNotFound)
+	assert.Equal(t, int64(1), storage.Len())
+
+	_, err := storage.GetRecord(context.Background(), 2)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	events, err := storage.GetRecordEvents(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.EventTypeDeleted, events[len(events)-1].EventType)
+
+	// The events keep the ID of the deleted record taken.
+	assert.Equal(t, int64(2), storage.LoadLastLinksNum(context.Background()))
+}
@@ -0,0 +1,279 @@
+// Package cache provides a read-through cache of the records in front of any
+// repository.Repository.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/metrics"
+	"link-service/internal/repository"
+)
+
+type Config struct {
+	// Size is the maximum number of cached records. Zero disables the
+	// cache.
+	Size int `env:"STORAGE_CACHE_SIZE" env-default:"0"`
+	// TTL is how long a record stays cached. Zero keeps records until they
+	// are evicted or written.
+	TTL time.Duration `env:"STORAGE_CACHE_TTL" env-default:"0"`
+}
+
+// Validate reports every invalid field of the config.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Size < 0 {
+		errs = append(errs, errors.New("STORAGE_CACHE_SIZE must not be negative"))
+	}
+
+	if c.TTL < 0 {
+		errs = append(errs, errors.New("STORAGE_CACHE_TTL must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+type entry struct {
+	record   domain.Record
+	cachedAt time.Time
+}
+
+// Repository caches the records returned by GetRecord and GetRecords in an
+// LRU and drops the cached records of every ID written through it. Writes
+// that bypass it, e.g. by another process sharing the storage, are only seen
+// once the cached record expires, so a TTL bounds how stale reads can get.
+// Every other method goes straight to the wrapped repository.
+type Repository struct {
+	repository.Repository
+	size   int
+	ttl    time.Duration
+	clock  clock.Clock
+	logger *zap.Logger
+
+	mu sync.Mutex
+	// lru holds the cached IDs, the most recently used first.
+	lru     *list.List
+	entries map[int64]*list.Element
+	// writes counts the invalidations, so a read that raced a write does
+	// not cache the record it read before the write.
+	writes uint64
+}
+
+func New(repo repository.Repository, cfg *Config, clk clock.Clock, logger *zap.Logger) *Repository {
+	return &Repository{
+		Repository: repo,
+		size:       cfg.Size,
+		ttl:        cfg.TTL,
+		clock:      clk,
+		logger:     logger,
+		lru:        list.New(),
+		entries:    make(map[int64]*list.Element),
+	}
+}
+
+// Unwrap returns the cached repository. It implements repository.Wrapper.
+func (c *Repository) Unwrap() repository.Repository {
+	return c.Repository
+}
+
+// Close closes the cached repository if it can be closed.
+func (c *Repository) Close() error {
+	if closer, ok := c.Repository.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+func (c *Repository) GetRecord(ctx context.Context, id int64) (*domain.Record, error) {
+	if rec, ok := c.get(id); ok {
+		metrics.RecordCacheHits.Inc()
+		return rec, nil
+	}
+	metrics.RecordCacheMisses.Inc()
+
+	writes := c.writeCount()
+	rec, err := c.Repository.GetRecord(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	c.set(writes, rec)
+	return rec, nil
+}
+
+// GetRecords returns the cached records and reads the others from the
+// wrapped repository in a single call.
+func (c *Repository) GetRecords(ctx context.Context, ids []int64) (map[int64]*domain.Record, error) {
+	records := make(map[int64]*domain.Record, len(ids))
+	seen := make(map[int64]struct{}, len(ids))
+	var missing []int64
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+
+		if rec, ok := c.get(id); ok {
+			records[id] = rec
+			continue
+		}
+
+		missing = append(missing, id)
+	}
+
+	metrics.RecordCacheHits.Add(float64(len(records)))
+	if len(missing) == 0 {
+		return records, nil
+	}
+	metrics.RecordCacheMisses.Add(float64(len(missing)))
+
+	writes := c.writeCount()
+	stored, err := c.Repository.GetRecords(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	for id, rec := range stored {
+		c.set(writes, rec)
+		records[id] = rec
+	}
+
+	return records, nil
+}
+
+func (c *Repository) SaveRecord(ctx context.Context, record *domain.Record) error {
+	defer c.invalidate(record.ID)
+	return c.Repository.SaveRecord(ctx, record)
+}
+
+func (c *Repository) SaveRecords(ctx context.Context, records []*domain.Record) error {
+	defer c.invalidateRecords(records)
+	return c.Repository.SaveRecords(ctx, records)
+}
+
+func (c *Repository) UpsertRecords(ctx context.Context, records []*domain.Record) (int, int, error) {
+	defer c.invalidateRecords(records)
+	return c.Repository.UpsertRecords(ctx, records)
+}
+
+func (c *Repository) UpdateRecord(ctx context.Context, record *domain.Record) error {
+	defer c.invalidate(record.ID)
+	return c.Repository.UpdateRecord(ctx, record)
+}
+
+func (c *Repository) DeleteRecord(ctx context.Context, id int64) error {
+	defer c.invalidate(id)
+	return c.Repository.DeleteRecord(ctx, id)
+}
+
+// Purge drops every cached record, e.g. after the storage was restored from
+// a backup.
+func (c *Repository) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lru.Init()
+	clear(c.entries)
+	c.writes++
+
+	c.logger.Info("record cache purged")
+}
+
+// get returns a copy of the cached record, so callers may modify it.
+func (c *Repository) get(id int64) (*domain.Record, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+
+	e := elem.Value.(*entry)
+	if c.ttl > 0 && c.clock.Now().Sub(e.cachedAt) >= c.ttl {
+		c.lru.Remove(elem)
+		delete(c.entries, id)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(elem)
+
+	rec := cloneRecord(&e.record)
+	return &rec, true
+}
+
+func (c *Repository) writeCount() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.writes
+}
+
+// set caches a copy of the record read when the write count was writes,
+// evicting the least recently used one if the cache is full. The record is
+// not cached if a write happened since.
+func (c *Repository) set(writes uint64, rec *domain.Record) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.writes != writes {
+		return
+	}
+
+	e := &entry{record: cloneRecord(rec), cachedAt: c.clock.Now()}
+
+	if elem, ok := c.entries[rec.ID]; ok {
+		elem.Value = e
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.entries[rec.ID] = c.lru.PushFront(e)
+
+	if c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).record.ID)
+	}
+}
+
+// invalidate drops the cached record of the ID. Writes invalidate after they
+// return, failed or not, since a failed write may still have changed the
+// record.
+func (c *Repository) invalidate(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writes++
+
+	if elem, ok := c.entries[id]; ok {
+		c.lru.Remove(elem)
+		delete(c.entries, id)
+	}
+}
+
+func (c *Repository) invalidateRecords(records []*domain.Record) {
+	for _, rec := range records {
+		c.invalidate(rec.ID)
+	}
+}
+
+func cloneRecord(rec *domain.Record) domain.Record {
+	clone := *rec
+	clone.Links = maps.Clone(rec.Links)
+	clone.Tags = slices.Clone(rec.Tags)
+
+	return clone
+}
@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+	"link-service/internal/repository/inmemory"
+	"link-service/internal/repository/repositorytest"
+)
+
+var testNow = time.Date(2025, 11, 30, 12, 0, 0, 0, time.UTC)
+
+// countingRepository counts the reads that reach the storage.
+type countingRepository struct {
+	*inmemory.Storage
+	reads int
+}
+
+func (r *countingRepository) GetRecord(ctx context.Context, id int64) (*domain.Record, error) {
+	r.reads++
+	return r.Storage.GetRecord(ctx, id)
+}
+
+func (r *countingRepository) GetRecords(ctx context.Context, ids []int64) (map[int64]*domain.Record, error) {
+	r.reads += len(ids)
+	return r.Storage.GetRecords(ctx, ids)
+}
+
+func newTestCache(t *testing.T, cfg Config) (*Repository, *countingRepository, *clock.Fake) {
+	t.Helper()
+
+	clk := clock.NewFake(testNow)
+	storage, err := inmemory.New(&inmemory.Config{}, clk, zap.NewNop())
+	assert.NoError(t, err)
+
+	for id := int64(1); id <= 3; id++ {
+		err = storage.SaveRecord(context.Background(), &domain.Record{ID: id, Links: map[string]string{"a.com": "available"}})
+		assert.NoError(t, err)
+	}
+
+	inner := &countingRepository{Storage: storage}
+	return New(inner, &cfg, clk, zap.NewNop()), inner, clk
+}
+
+func TestValidate(t *testing.T) {
+	err := (&Config{Size: -1, TTL: -time.Second}).Validate()
+	assert.ErrorContains(t, err, "STORAGE_CACHE_SIZE")
+	assert.ErrorContains(t, err, "STORAGE_CACHE_TTL")
+
+	assert.NoError(t, (&Config{Size: 100, TTL: time.Minute}).Validate())
+}
+
+func TestConformance(t *testing.T) {
+	repositorytest.RunSuite(t, func(t *testing.T) repository.Repository {
+		storage, err := inmemory.New(&inmemory.Config{}, clock.NewFake(testNow), zap.NewNop())
+		assert.NoError(t, err)
+
+		return New(storage, &Config{Size: 2}, clock.NewFake(testNow), zap.NewNop())
+	})
+}
+
+func TestGetRecord(t *testing.T) {
+	c, inner, _ := newTestCache(t, Config{Size: 10})
+
+	for range 3 {
+		rec, err := c.GetRecord(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "available", rec.Links["a.com"])
+
+		// The cache hands out copies.
+		rec.Links["a.com"] = "changed"
+	}
+	assert.Equal(t, 1, inner.reads)
+
+	_, err := c.GetRecord(context.Background(), 4)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+}
+
+func TestGetRecords(t *testing.T) {
+	c, inner, _ := newTestCache(t, Config{Size: 10})
+
+	_, err := c.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+
+	records, err := c.GetRecords(context.Background(), []int64{1, 2, 2, 4})
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Contains(t, records, int64(1))
+	assert.Contains(t, records, int64(2))
+
+	// Only 2 and 4 were read from the storage.
+	assert.Equal(t, 3, inner.reads)
+
+	records, err = c.GetRecords(context.Background(), []int64{1, 2})
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, 3, inner.reads)
+}
+
+func TestInvalidate(t *testing.T) {
+	c, inner, _ := newTestCache(t, Config{Size: 10})
+
+	_, err := c.GetRecords(context.Background(), []int64{1, 2, 3})
+	assert.NoError(t, err)
+
+	err = c.UpdateRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "not available"}})
+	assert.NoError(t, err)
+
+	rec, err := c.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "not available", rec.Links["a.com"])
+
+	err = c.DeleteRecord(context.Background(), 2)
+	assert.NoError(t, err)
+
+	_, err = c.GetRecord(context.Background(), 2)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	_, _, err = c.UpsertRecords(context.Background(), []*domain.Record{{ID: 3, Links: map[string]string{"b.com": "available"}}})
+	assert.NoError(t, err)
+
+	rec, err = c.GetRecord(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"b.com": "available"}, rec.Links)
+	assert.Equal(t, 6, inner.reads)
+
+	c.Purge()
+
+	_, err = c.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, inner.reads)
+}
+
+func TestEviction(t *testing.T) {
+	c, inner, _ := newTestCache(t, Config{Size: 2})
+
+	for _, id := range []int64{1, 2, 1, 3} {
+		_, err := c.GetRecord(context.Background(), id)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 3, inner.reads)
+
+	// 2 was the least recently used record when 3 was cached.
+	_, err := c.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, inner.reads)
+
+	_, err = c.GetRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, inner.reads)
+}
+
+func TestTTL(t *testing.T) {
+	c, inner, clk := newTestCache(t, Config{Size: 10, TTL: time.Minute})
+
+	_, err := c.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+
+	clk.Advance(30 * time.Second)
+	_, err = c.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, inner.reads)
+
+	clk.Advance(30 * time.Second)
+	_, err = c.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, inner.reads)
+}
+
+func TestAs(t *testing.T) {
+	c, inner, _ := newTestCache(t, Config{Size: 10})
+
+	unwrapped, ok := repository.As[*countingRepository](c)
+	assert.True(t, ok)
+	assert.Same(t, inner, unwrapped)
+
+	_, ok = repository.As[interface{ Purge() }](c)
+	assert.True(t, ok)
+
+	_, ok = repository.As[interface{ Compact() (int, error) }](c)
+	assert.False(t, ok)
+}
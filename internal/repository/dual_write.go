@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+)
+
+// DualWrite is a Repository that reads from the primary and writes to both
+// the primary and the secondary, so a new backend can be filled while the
+// old one keeps serving. Only primary failures are returned; secondary
+// failures are logged and left to a later MigrateAll run to repair. Once the
+// primary write succeeded, the secondary write is not canceled with ctx.
+//
+// It does not unwrap to the primary, since the optional capabilities found
+// that way would skip the secondary, e.g. a restore. It mirrors the temp
+// record promotion only.
+type DualWrite struct {
+	Repository
+	secondary Repository
+	logger    *zap.Logger
+}
+
+func NewDualWrite(primary, secondary Repository, logger *zap.Logger) *DualWrite {
+	return &DualWrite{
+		Repository: primary,
+		secondary:  secondary,
+		logger:     logger,
+	}
+}
+
+// Close closes both repositories if they can be closed.
+func (d *DualWrite) Close() error {
+	var errs []error
+
+	for _, repo := range []Repository{d.Repository, d.secondary} {
+		if closer, ok := repo.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (d *DualWrite) SaveRecord(ctx context.Context, record *domain.Record) error {
+	err := d.Repository.SaveRecord(ctx, record)
+	if err != nil {
+		return err
+	}
+
+	d.mirror("save record", d.secondary.SaveRecord(context.WithoutCancel(ctx), record))
+	return nil
+}
+
+func (d *DualWrite) SaveRecords(ctx context.Context, records []*domain.Record) error {
+	err := d.Repository.SaveRecords(ctx, records)
+	if err != nil {
+		return err
+	}
+
+	d.mirror("save records", d.secondary.SaveRecords(context.WithoutCancel(ctx), records))
+	return nil
+}
+
+func (d *DualWrite) SaveTempRecord(ctx context.Context, record *domain.Record) error {
+	err := d.Repository.SaveTempRecord(ctx, record)
+	if err != nil {
+		return err
+	}
+
+	d.mirror("save temp record", d.secondary.SaveTempRecord(context.WithoutCancel(ctx), record))
+	return nil
+}
+
+// UpsertRecords upserts into the primary and then the secondary. The counts
+// are those of the primary.
+func (d *DualWrite) UpsertRecords(ctx context.Context, records []*domain.Record) (int, int, error) {
+	inserted, updated, err := d.Repository.UpsertRecords(ctx, records)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	_, _, err = d.secondary.UpsertRecords(context.WithoutCancel(ctx), records)
+	d.mirror("upsert records", err)
+
+	return inserted, updated, nil
+}
+
+// UpdateRecord updates the primary and then upserts into the secondary,
+// which may not hold the record yet.
+func (d *DualWrite) UpdateRecord(ctx context.Context, record *domain.Record) error {
+	err := d.Repository.UpdateRecord(ctx, record)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = d.secondary.UpsertRecords(context.WithoutCancel(ctx), []*domain.Record{record})
+	d.mirror("update record", err)
+
+	return nil
+}
+
+// DeleteRecord deletes from the primary and then the secondary. A record
+// missing from the secondary was not migrated yet, which is not a failure.
+func (d *DualWrite) DeleteRecord(ctx context.Context, id int64) error {
+	err := d.Repository.DeleteRecord(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = d.secondary.DeleteRecord(context.WithoutCancel(ctx), id)
+	if errors.Is(err, ErrRecordNotFound) {
+		err = nil
+	}
+	d.mirror("delete record", err)
+
+	return nil
+}
+
+func (d *DualWrite) ClearTempFile(ctx context.Context) error {
+	err := d.Repository.ClearTempFile(ctx)
+	if err != nil {
+		return err
+	}
+
+	d.mirror("clear temp file", d.secondary.ClearTempFile(context.WithoutCancel(ctx)))
+	return nil
+}
+
+// tempRecordPromoter is the service.TempRecordPromoter of the primary.
+type tempRecordPromoter interface {
+	PromoteTempRecords(ctx context.Context, records []*domain.Record) (int, error)
+}
+
+// PromoteTempRecords promotes the processed temp records in the primary,
+// then upserts them into the secondary and clears its temp records. A primary
+// that cannot promote them saves them one by one and clears its temp
+// records after, stopping at the first failure.
+func (d *DualWrite) PromoteTempRecords(ctx context.Context, records []*domain.Record) (int, error) {
+	promoted, err := d.promotePrimary(ctx, records)
+	if err != nil {
+		return promoted, err
+	}
+
+	// Upserting does not store a record twice if the secondary got it in
+	// an earlier attempt.
+	_, _, err = d.secondary.UpsertRecords(context.WithoutCancel(ctx), records)
+	d.mirror("promote temp records", err)
+	if err == nil {
+		d.mirror("clear temp file", d.secondary.ClearTempFile(context.WithoutCancel(ctx)))
+	}
+
+	return promoted, nil
+}
+
+func (d *DualWrite) promotePrimary(ctx context.Context, records []*domain.Record) (int, error) {
+	promoter, ok := As[tempRecordPromoter](d.Repository)
+	if ok {
+		return promoter.PromoteTempRecords(ctx, records)
+	}
+
+	for i, rec := range records {
+		err := d.Repository.SaveRecord(ctx, rec)
+		if err != nil {
+			return i, err
+		}
+	}
+
+	return len(records), d.Repository.ClearTempFile(ctx)
+}
+
+func (d *DualWrite) SaveRecordEvent(ctx context.Context, event *domain.RecordEvent) error {
+	err := d.Repository.SaveRecordEvent(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	d.mirror("save record event", d.secondary.SaveRecordEvent(context.WithoutCancel(ctx), event))
+	return nil
+}
+
+func (d *DualWrite) mirror(op string, err error) {
+	if err != nil {
+		d.logger.Warn("failed to mirror write to secondary storage", zap.String("op", op), zap.Error(err))
+	}
+}
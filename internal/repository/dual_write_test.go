@@ -0,0 +1,148 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+	filesystem "link-service/internal/repository/file_system"
+	"link-service/internal/repository/inmemory"
+)
+
+var testNow = time.Date(2025, 11, 30, 12, 0, 0, 0, time.UTC)
+
+func newTestStorage(t *testing.T, clk clock.Clock) *inmemory.Storage {
+	t.Helper()
+
+	storage, err := inmemory.New(&inmemory.Config{}, clk, zap.NewNop())
+	assert.NoError(t, err)
+
+	return storage
+}
+
+// failingRepository fails every write.
+type failingRepository struct {
+	repository.Repository
+}
+
+func (failingRepository) SaveRecord(context.Context, *domain.Record) error {
+	return errors.New("unavailable")
+}
+
+func TestDualWrite(t *testing.T) {
+	clk := clock.NewFake(testNow)
+	primary := newTestStorage(t, clk)
+	secondary := newTestStorage(t, clock.NewFake(testNow.Add(time.Hour)))
+
+	dual := repository.NewDualWrite(primary, secondary, zap.NewNop())
+
+	err := dual.SaveRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "available"}})
+	assert.NoError(t, err)
+
+	err = dual.SaveTempRecord(context.Background(), &domain.Record{ID: 2})
+	assert.NoError(t, err)
+
+	clk.Advance(time.Minute)
+
+	inserted, updated, err := dual.UpsertRecords(context.Background(), []*domain.Record{{ID: 1, Links: map[string]string{"a.com": "not available"}}, {ID: 3}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, inserted)
+	assert.Equal(t, 1, updated)
+
+	for _, repo := range []repository.Repository{primary, secondary} {
+		rec, err := repo.GetRecord(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "not available", rec.Links["a.com"])
+		// The primary sets the creation time for both.
+		assert.Equal(t, testNow, rec.CreatedAt)
+		assert.Equal(t, int64(2), repo.Len())
+
+		temp, err := repo.LoadTempRecords(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, temp, 1)
+	}
+
+	err = dual.ClearTempFile(context.Background())
+	assert.NoError(t, err)
+
+	temp, err := secondary.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, temp)
+
+	err = dual.DeleteRecord(context.Background(), 3)
+	assert.NoError(t, err)
+
+	for _, repo := range []repository.Repository{primary, secondary} {
+		_, err = repo.GetRecord(context.Background(), 3)
+		assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+	}
+
+	// A record the secondary never got is still deleted from the primary.
+	err = primary.SaveRecord(context.Background(), &domain.Record{ID: 4})
+	assert.NoError(t, err)
+
+	err = dual.DeleteRecord(context.Background(), 4)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), primary.Len())
+}
+
+func TestDualWriteSecondaryFailure(t *testing.T) {
+	primary := newTestStorage(t, clock.NewFake(testNow))
+	dual := repository.NewDualWrite(primary, failingRepository{}, zap.NewNop())
+
+	err := dual.SaveRecord(context.Background(), &domain.Record{ID: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), dual.Len())
+
+	dual = repository.NewDualWrite(failingRepository{}, primary, zap.NewNop())
+
+	err = dual.SaveRecord(context.Background(), &domain.Record{ID: 2})
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), primary.Len())
+}
+
+func TestDualWritePromoteTempRecords(t *testing.T) {
+	fsPrimary, err := filesystem.New(&filesystem.Config{
+		DirPath:      t.TempDir(),
+		FileName:     "data.json",
+		TempFileName: "temp.json",
+	}, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	t.Cleanup(func() { fsPrimary.Close() })
+
+	// The filesystem primary promotes the records itself, the in-memory one
+	// saves them one by one.
+	for _, primary := range []repository.Repository{fsPrimary, newTestStorage(t, clock.NewFake(testNow))} {
+		secondary := newTestStorage(t, clock.NewFake(testNow))
+		dual := repository.NewDualWrite(primary, secondary, zap.NewNop())
+
+		err = dual.SaveTempRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "unknown"}})
+		assert.NoError(t, err)
+
+		promoter, ok := repository.As[interface {
+			PromoteTempRecords(ctx context.Context, records []*domain.Record) (int, error)
+		}](dual)
+		assert.True(t, ok)
+
+		promoted, err := promoter.PromoteTempRecords(context.Background(), []*domain.Record{{ID: 1, Links: map[string]string{"a.com": "available"}}})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, promoted)
+
+		for _, repo := range []repository.Repository{primary, secondary} {
+			rec, err := repo.GetRecord(context.Background(), 1)
+			assert.NoError(t, err)
+			assert.Equal(t, "available", rec.Links["a.com"])
+
+			temp, err := repo.LoadTempRecords(context.Background())
+			assert.NoError(t, err)
+			assert.Empty(t, temp)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Factory opens a repository from the config section of its storage type.
+type Factory func(cfg any, logger *zap.Logger) (Repository, error)
+
+// Config selects the storage to open.
+type Config interface {
+	// StorageSection returns the selected storage type and the config
+	// section passed to its factory.
+	StorageSection() (string, any)
+}
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// Register makes a storage type available to NewFromConfig. It is meant to be
+// called from the init function of the backend package and panics if the
+// type is registered twice.
+func Register(storageType string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	if _, ok := factories[storageType]; ok {
+		panic(fmt.Sprintf("repository: storage type %q registered twice", storageType))
+	}
+
+	factories[storageType] = factory
+}
+
+// StorageTypes returns the registered storage types in sorted order.
+func StorageTypes() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	return slices.Sorted(maps.Keys(factories))
+}
+
+// NewFromConfig opens the repository of the configured storage type.
+func NewFromConfig(cfg Config, logger *zap.Logger) (Repository, error) {
+	storageType, section := cfg.StorageSection()
+
+	factoriesMu.RLock()
+	factory, ok := factories[storageType]
+	factoriesMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown storage type %q, registered: %v", storageType, StorageTypes())
+	}
+
+	return factory(section, logger)
+}
+
+// FactoryFor adapts the constructor of a backend, which takes the backend's
+// own config section, to a Factory.
+func FactoryFor[C any, R Repository](open func(cfg *C, logger *zap.Logger) (R, error)) Factory {
+	return func(cfg any, logger *zap.Logger) (Repository, error) {
+		section, ok := cfg.(*C)
+		if !ok {
+			return nil, fmt.Errorf("unexpected config section %T", cfg)
+		}
+
+		repo, err := open(section, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		return repo, nil
+	}
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type testConfig struct {
+	storageType string
+	section     any
+}
+
+func (c testConfig) StorageSection() (string, any) {
+	return c.storageType, c.section
+}
+
+type testSection struct {
+	fail bool
+}
+
+func TestNewFromConfig(t *testing.T) {
+	var opened *testSection
+	Register("test", FactoryFor(func(cfg *testSection, logger *zap.Logger) (Repository, error) {
+		opened = cfg
+		if cfg.fail {
+			return nil, assert.AnError
+		}
+
+		return nil, nil
+	}))
+
+	assert.Contains(t, StorageTypes(), "test")
+	assert.Panics(t, func() {
+		Register("test", nil)
+	})
+
+	section := &testSection{}
+	_, err := NewFromConfig(testConfig{storageType: "test", section: section}, zap.NewNop())
+	assert.NoError(t, err)
+	assert.Same(t, section, opened)
+
+	_, err = NewFromConfig(testConfig{storageType: "test", section: &testSection{fail: true}}, zap.NewNop())
+	assert.ErrorIs(t, err, assert.AnError)
+
+	_, err = NewFromConfig(testConfig{storageType: "test", section: "wrong"}, zap.NewNop())
+	assert.ErrorContains(t, err, "unexpected config section string")
+
+	_, err = NewFromConfig(testConfig{storageType: "tape"}, zap.NewNop())
+	assert.ErrorContains(t, err, `unknown storage type "tape"`)
+}
@@ -0,0 +1,153 @@
+package filesystem
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"sync"
+)
+
+// appendBufferSize is the write buffer size of every appender.
+const appendBufferSize = 64 * 1024
+
+// appender is the long-lived append handle of a storage file. Writes are
+// buffered until Flush, which the storage calls before reading the file,
+// every FlushInterval and on Close.
+type appender struct {
+	// flushMu serializes Flush, which concurrent readers call under the
+	// storage read lock. Every other method runs under the write lock.
+	flushMu sync.Mutex
+	path    string
+	flag    int
+	mode    os.FileMode
+	// direct flushes every write, see SyncPolicyAlways.
+	direct bool
+	file   *os.File
+	w      *bufio.Writer
+	// size is the size of the file including the buffered bytes, so it is
+	// the offset the next write starts at.
+	size int64
+	// unsynced reports writes since the last Sync, see SyncPolicyInterval.
+	unsynced bool
+}
+
+func openAppender(path string, flag int, mode os.FileMode, direct bool) (*appender, error) {
+	a := &appender{path: path, flag: flag, mode: mode, direct: direct}
+
+	err := a.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *appender) open() error {
+	file, err := os.OpenFile(a.path, a.flag, a.mode)
+	if err != nil {
+		return err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	a.file = file
+	a.size = stat.Size()
+	if a.w == nil {
+		a.w = bufio.NewWriterSize(file, appendBufferSize)
+	} else {
+		a.w.Reset(file)
+	}
+
+	return nil
+}
+
+// Write buffers p, or writes it through in direct mode.
+func (a *appender) Write(p []byte) (int, error) {
+	if a.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	n, err := a.w.Write(p)
+	a.size += int64(n)
+	a.unsynced = true
+	if err != nil {
+		return n, err
+	}
+
+	if a.direct {
+		return n, a.Flush()
+	}
+
+	return n, nil
+}
+
+// Flush writes the buffered bytes to the file. After a failed write the
+// buffer is dropped and the size taken from the file again, so a later
+// write does not fail on the same error.
+func (a *appender) Flush() error {
+	a.flushMu.Lock()
+	defer a.flushMu.Unlock()
+
+	if a.file == nil || a.w.Buffered() == 0 {
+		return nil
+	}
+
+	err := a.w.Flush()
+	if err != nil {
+		a.w.Reset(a.file)
+		if stat, statErr := a.file.Stat(); statErr == nil {
+			a.size = stat.Size()
+		}
+	}
+
+	return err
+}
+
+// Sync flushes the buffer and commits the file to the disk.
+func (a *appender) Sync() error {
+	if a.file == nil || !a.unsynced {
+		return nil
+	}
+
+	err := a.Flush()
+	if err != nil {
+		return err
+	}
+
+	err = a.file.Sync()
+	if err != nil {
+		return err
+	}
+
+	a.unsynced = false
+	return nil
+}
+
+// reopen switches to the file now at the path after it was replaced by a
+// rename.
+func (a *appender) reopen() error {
+	err := a.Close()
+	if err != nil {
+		return err
+	}
+
+	return a.open()
+}
+
+// Close flushes the buffer and closes the file. Writes fail with
+// os.ErrClosed afterwards.
+func (a *appender) Close() error {
+	if a.file == nil {
+		return nil
+	}
+
+	err := a.Flush()
+	closeErr := a.file.Close()
+	a.file = nil
+
+	return errors.Join(err, closeErr)
+}
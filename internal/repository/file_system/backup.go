@@ -0,0 +1,329 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/repository"
+)
+
+// A backup is a tar.gz of the main file with its sealed segments and
+// manifest, the temp file and the events file, named by their paths relative
+// to the storage dir. Index files and the snapshot are left out since Restore
+// rebuilds the index.
+
+// Backup writes a tar.gz of the storage files to w. Writes wait until it is
+// done, reads go on.
+func (s *Storage) Backup(w io.Writer) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	err := writeBackup(w, func(tw *tar.Writer) error {
+		return s.backupFiles(tw, "")
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("storage backed up")
+	return nil
+}
+
+// writeBackup writes a tar.gz to w with the entries written by fn.
+func writeBackup(w io.Writer, fn func(tw *tar.Writer) error) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	err := fn(tw)
+	if err != nil {
+		return err
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	err = gw.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	return nil
+}
+
+// backupFiles writes the storage files to the archive, their names prefixed
+// with prefix. The caller must hold the lock, at least for reading.
+func (s *Storage) backupFiles(tw *tar.Writer, prefix string) error {
+	for _, path := range s.backupPaths() {
+		err := s.backupFile(tw, prefix, path)
+		if errors.Is(err, fs.ErrNotExist) && path != s.path {
+			continue
+		}
+		if err != nil {
+			s.logger.Error("failed to back up file", zap.String("path", path), zap.Error(err))
+			return fmt.Errorf("failed to back up file: %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// backupPaths returns the paths of the files a backup holds. The caller must
+// hold the lock, at least for reading.
+func (s *Storage) backupPaths() []string {
+	paths, _ := s.mainFiles()
+	if len(s.segments) > 0 {
+		paths = append(paths, s.manifestPath)
+	}
+
+	return append(paths, s.tempPath, s.eventsPath)
+}
+
+// backupFile writes the file at path to the archive, its name prefixed with
+// prefix. The caller must hold the lock, at least for reading.
+func (s *Storage) backupFile(tw *tar.Writer, prefix, path string) error {
+	file, err := s.openForRead(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	name, err := filepath.Rel(filepath.Dir(s.path), path)
+	if err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:    prefix + filepath.ToSlash(name),
+		Mode:    int64(s.fileMode),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// The size in the header is binding, so appends are not copied even if
+	// the file grew since the stat.
+	_, err = io.CopyN(tw, file, info.Size())
+	return err
+}
+
+// Restore replaces the storage files with the ones of a backup written by
+// Backup and rebuilds the index. The backup must come from a storage with
+// the same file names. It is extracted next to the storage files first, so
+// an invalid backup fails with repository.ErrInvalidBackup before any file
+// is replaced.
+func (s *Storage) Restore(r io.Reader) error {
+	err := s.checkWritable("restore")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	staging, err := s.newStaging()
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging.dir)
+
+	err = readBackup(r, staging.add)
+	if err == nil {
+		err = staging.check()
+	}
+	if err != nil {
+		s.logger.Error("failed to extract backup", zap.Error(err))
+		return err
+	}
+
+	err = s.replaceFiles(staging.files)
+	if err != nil {
+		s.logger.Error("failed to restore backup", zap.Error(err))
+		return fmt.Errorf("failed to restore backup: %w", err)
+	}
+
+	s.logger.Info("storage restored", zap.Int64("records", s.records.Load()))
+	return nil
+}
+
+// readBackup calls fn with the name and the content of every entry of the
+// tar.gz read from r.
+func readBackup(r io.Reader, fn func(name string, r io.Reader) error) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("%w: %w", repository.ErrInvalidBackup, err)
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", repository.ErrInvalidBackup, err)
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			return fmt.Errorf("%w: unexpected entry %q", repository.ErrInvalidBackup, hdr.Name)
+		}
+
+		err = fn(hdr.Name, tr)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// staging extracts the files of a backup next to the storage files before
+// they replace them.
+type staging struct {
+	storage *Storage
+	dir     string
+	// files are the extracted files keyed by the path they replace.
+	files map[string]string
+}
+
+func (s *Storage) newStaging() (*staging, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(s.path), ".restore-")
+	if err != nil {
+		s.logger.Error("failed to create dir", zap.Error(err))
+		return nil, fmt.Errorf("failed to create dir: %w", err)
+	}
+
+	return &staging{storage: s, dir: dir, files: make(map[string]string)}, nil
+}
+
+// add extracts the entry with the name, a path relative to the storage dir.
+func (st *staging) add(name string, r io.Reader) error {
+	s := st.storage
+
+	path := filepath.Join(filepath.Dir(s.path), filepath.FromSlash(name))
+	if !s.restorable(path) {
+		return fmt.Errorf("%w: unexpected entry %q", repository.ErrInvalidBackup, name)
+	}
+
+	if _, ok := st.files[path]; ok {
+		return fmt.Errorf("%w: duplicate entry %q", repository.ErrInvalidBackup, name)
+	}
+
+	stagedPath := filepath.Join(st.dir, strconv.Itoa(len(st.files)))
+	err := s.extractFile(r, stagedPath)
+	if err != nil {
+		return err
+	}
+
+	st.files[path] = stagedPath
+	return nil
+}
+
+// check fails if the backup held no main file.
+func (st *staging) check() error {
+	if _, ok := st.files[st.storage.path]; !ok {
+		return fmt.Errorf("%w: no main file %s", repository.ErrInvalidBackup, filepath.Base(st.storage.path))
+	}
+
+	return nil
+}
+
+// restorable reports whether a backup may hold the file at path: a storage
+// file or a sealed segment of the main file.
+func (s *Storage) restorable(path string) bool {
+	switch path {
+	case s.path, s.manifestPath, s.tempPath, s.eventsPath:
+		return true
+	}
+
+	return filepath.Dir(path) == filepath.Dir(s.path) && isSegmentName(filepath.Base(s.path), filepath.Base(path))
+}
+
+func (s *Storage) extractFile(r io.Reader, path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, s.fileMode)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %s: %w", path, err)
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, r)
+	if err != nil {
+		return fmt.Errorf("%w: %w", repository.ErrInvalidBackup, err)
+	}
+
+	err = file.Sync()
+	if err != nil {
+		return fmt.Errorf("failed to sync file: %s: %w", path, err)
+	}
+
+	return file.Close()
+}
+
+// replaceFiles moves the extracted files in place of the storage files,
+// empties the temp and the events file if the backup has none and reloads
+// the segments, the record count and the index. The caller must hold the
+// lock.
+func (s *Storage) replaceFiles(staged map[string]string) error {
+	err := s.closeAppenders()
+	if err != nil {
+		return err
+	}
+
+	for _, seg := range s.segments {
+		os.Remove(s.segmentPath(seg))
+		os.Remove(s.segmentIndexPath(seg))
+	}
+	os.Remove(s.manifestPath)
+	os.Remove(s.snapshotPath)
+	s.segments = nil
+
+	for path, stagedPath := range staged {
+		err = os.Rename(stagedPath, path)
+		if err != nil {
+			return fmt.Errorf("failed to rename file: %s: %w", stagedPath, err)
+		}
+	}
+
+	for _, path := range []string{s.tempPath, s.eventsPath} {
+		if _, ok := staged[path]; ok {
+			continue
+		}
+
+		err = os.WriteFile(path, nil, s.fileMode)
+		if err != nil {
+			return fmt.Errorf("failed to create file: %s: %w", path, err)
+		}
+	}
+
+	for _, a := range s.appenders() {
+		err = a.reopen()
+		if err != nil {
+			return fmt.Errorf("failed to reopen file: %s: %w", a.path, err)
+		}
+	}
+
+	err = s.loadSegments()
+	if err != nil {
+		return err
+	}
+
+	err = s.reindex()
+	s.countRecords()
+	return err
+}
@@ -0,0 +1,123 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+func TestBackupRestore(t *testing.T) {
+	cfg := newTestConfig(t)
+	storage := newSegmentedStorage(t, cfg)
+	t.Cleanup(func() { storage.Close() })
+
+	for id := int64(1); id <= 3; id++ {
+		err := storage.SaveRecord(context.Background(), &domain.Record{ID: id})
+		assert.NoError(t, err)
+	}
+
+	err := storage.SaveTempRecord(context.Background(), &domain.Record{ID: 4})
+	assert.NoError(t, err)
+
+	var backup bytes.Buffer
+	err = storage.Backup(&backup)
+	assert.NoError(t, err)
+
+	// Changes after the backup are undone by the restore.
+	err = storage.DeleteRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	err = storage.SaveRecord(context.Background(), &domain.Record{ID: 5})
+	assert.NoError(t, err)
+	err = storage.ClearTempFile(context.Background())
+	assert.NoError(t, err)
+
+	err = storage.Restore(bytes.NewReader(backup.Bytes()))
+	assert.NoError(t, err)
+
+	assert.Len(t, storage.segments, 3)
+	assert.Equal(t, int64(3), storage.Len())
+	assert.Equal(t, int64(3), storage.LoadLastLinksNum(context.Background()))
+
+	ids, err := storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+
+	rec, err := storage.GetRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), rec.ID)
+
+	temp, err := storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, temp, 1)
+
+	events, err := storage.GetRecordEvents(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, domain.EventTypeCreated, events[0].EventType)
+
+	// The restored storage takes writes.
+	err = storage.SaveRecord(context.Background(), &domain.Record{ID: 4})
+	assert.NoError(t, err)
+
+	rec, err = storage.GetRecord(context.Background(), 4)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), rec.ID)
+}
+
+func TestRestoreInvalidBackup(t *testing.T) {
+	storage := newTestStorage(t)
+
+	err := storage.SaveRecord(context.Background(), &domain.Record{ID: 1})
+	assert.NoError(t, err)
+
+	archive := func(names ...string) []byte {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gw)
+		for _, name := range names {
+			assert.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644}))
+		}
+		assert.NoError(t, tw.Close())
+		assert.NoError(t, gw.Close())
+
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name   string
+		backup []byte
+	}{
+		{name: "not gzip", backup: []byte("not a backup")},
+		{name: "no main file", backup: archive("temp.json")},
+		{name: "unknown file", backup: archive("data.json", "other.json")},
+		{name: "outside the dir", backup: archive("data.json", "../data-000001.json")},
+		{name: "duplicate file", backup: archive("data.json", "data.json")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := storage.Restore(bytes.NewReader(tt.backup))
+			assert.ErrorIs(t, err, repository.ErrInvalidBackup)
+
+			rec, err := storage.GetRecord(context.Background(), 1)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(1), rec.ID)
+		})
+	}
+}
+
+func TestIsSegmentName(t *testing.T) {
+	assert.True(t, isSegmentName("data.json", "data-000001.json"))
+	assert.True(t, isSegmentName("data.json", "data-000012.json.zst"))
+	assert.False(t, isSegmentName("data.json", "data-1.json"))
+	assert.False(t, isSegmentName("data.json", "data-000000.json"))
+	assert.False(t, isSegmentName("data.json", "data.json"))
+	assert.False(t, isSegmentName("data.json", "temp-000001.json"))
+}
@@ -0,0 +1,189 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+)
+
+// Every line written to the main file and the temp file ends with the
+// CRC-32C of the line without it as a last JSON field, so the line stays a
+// JSON object:
+//
+//	{"links_num":1,...,"crc32":"1a2b3c4d"}
+//
+// The checksum covers the stored line, so a sealed line is checked before it
+// is opened. Lines without a checksum, e.g. written before checksums were
+// added, are read unchecked.
+
+const (
+	checksumField = `,"crc32":"`
+	// checksumSuffixLen is the length of the checksum field with the
+	// closing brace of the line.
+	checksumSuffixLen = len(checksumField) + 2*crc32.Size + len(`"}`)
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errChecksumMismatch reports a line whose checksum does not match its
+// data, i.e. a corrupt line.
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// addChecksum returns the JSON object line with its checksum as the last
+// field.
+func addChecksum(line []byte) []byte {
+	var sum [crc32.Size]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.Checksum(line, crcTable))
+
+	out := make([]byte, 0, len(line)+checksumSuffixLen-1)
+	out = append(out, line[:len(line)-1]...)
+	out = append(out, checksumField...)
+	out = hex.AppendEncode(out, sum[:])
+	return append(out, `"}`...)
+}
+
+// verifyChecksum checks the checksum of the trimmed line and returns the
+// line without it. It reports whether the line had a checksum and fails with
+// errChecksumMismatch if it does not match.
+func verifyChecksum(line []byte) ([]byte, bool, error) {
+	n := len(line) - checksumSuffixLen
+	if n < 1 || !bytes.Equal(line[n:n+len(checksumField)], []byte(checksumField)) || !bytes.HasSuffix(line, []byte(`"}`)) {
+		return line, false, nil
+	}
+
+	var want [crc32.Size]byte
+	_, err := hex.Decode(want[:], line[n+len(checksumField):len(line)-2])
+	if err != nil {
+		return nil, true, fmt.Errorf("%w: malformed checksum", errChecksumMismatch)
+	}
+
+	data := append(line[:n:n], '}')
+
+	var got [crc32.Size]byte
+	binary.BigEndian.PutUint32(got[:], crc32.Checksum(data, crcTable))
+	if got != want {
+		return nil, true, fmt.Errorf("%w: stored %x, computed %x", errChecksumMismatch, want, got)
+	}
+
+	return data, true, nil
+}
+
+// VerifyLine checks the checksum of a line of the main or the temp file for
+// tools that read the files directly. Lines without a checksum pass.
+func VerifyLine(line []byte) error {
+	_, _, err := verifyChecksum(bytes.TrimSpace(line))
+	return err
+}
+
+// maxVerifyProblems caps the lines listed in a VerifyReport.
+const maxVerifyProblems = 100
+
+// VerifyReport counts the non-blank lines of the main file and the temp file
+// by their state.
+type VerifyReport struct {
+	Lines int `json:"lines"`
+	// Unchecked lines have no checksum.
+	Unchecked int `json:"unchecked"`
+	// Corrupt lines fail their checksum.
+	Corrupt int `json:"corrupt"`
+	// Malformed lines pass their checksum but do not decode, e.g. sealed
+	// with an unknown key.
+	Malformed int `json:"malformed"`
+	// Problems lists the first corrupt and malformed lines.
+	Problems []LineProblem `json:"problems,omitempty"`
+}
+
+// LineProblem is a corrupt or malformed line of a storage file.
+type LineProblem struct {
+	Path  string `json:"path"`
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// Verify scans the main file across its segments and the temp file and
+// reports their corrupt and malformed lines. A line of the main file counts
+// from the start of its segment.
+func (s *Storage) Verify() (VerifyReport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var report VerifyReport
+
+	paths, _ := s.mainFiles()
+	paths = append(paths, s.tempPath)
+
+	for _, path := range paths {
+		err := s.verifyFile(path, &report)
+		if path == s.tempPath && s.readOnly && errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			s.logger.Error("failed to verify file", zap.String("path", path), zap.Error(err))
+			return report, fmt.Errorf("failed to verify file: %s: %w", path, err)
+		}
+	}
+
+	s.logger.Info("storage verified",
+		zap.Int("lines", report.Lines),
+		zap.Int("unchecked", report.Unchecked),
+		zap.Int("corrupt", report.Corrupt),
+		zap.Int("malformed", report.Malformed),
+	)
+	return report, nil
+}
+
+// verifyFile adds the lines of the file to the report. The caller must hold
+// the lock, at least for reading.
+func (s *Storage) verifyFile(path string, report *VerifyReport) error {
+	file, err := s.openMainFile(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	problem := func(lineNum int, err error) {
+		if len(report.Problems) < maxVerifyProblems {
+			report.Problems = append(report.Problems, LineProblem{Path: path, Line: lineNum, Error: err.Error()})
+		}
+	}
+
+	lineNum := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNum++
+
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		report.Lines++
+
+		_, checked, err := verifyChecksum(line)
+		if err != nil {
+			report.Corrupt++
+			problem(lineNum, err)
+			continue
+		}
+		if !checked {
+			report.Unchecked++
+		}
+
+		var rec domain.Record
+		err = s.unmarshalLine(line, &rec)
+		if err != nil {
+			report.Malformed++
+			problem(lineNum, err)
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,138 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+func TestChecksum(t *testing.T) {
+	line := addChecksum([]byte(`{"links_num":1}`))
+	assert.Regexp(t, `^\{"links_num":1,"crc32":"[0-9a-f]{8}"\}$`, string(line))
+
+	data, checked, err := verifyChecksum(line)
+	assert.NoError(t, err)
+	assert.True(t, checked)
+	assert.Equal(t, `{"links_num":1}`, string(data))
+
+	corrupt := bytes.Replace(line, []byte("1"), []byte("2"), 1)
+	_, checked, err = verifyChecksum(corrupt)
+	assert.ErrorIs(t, err, errChecksumMismatch)
+	assert.True(t, checked)
+
+	data, checked, err = verifyChecksum([]byte(`{"links_num":1}`))
+	assert.NoError(t, err)
+	assert.False(t, checked)
+	assert.Equal(t, `{"links_num":1}`, string(data))
+
+	assert.NoError(t, VerifyLine(append(line, '\n')))
+	assert.ErrorIs(t, VerifyLine(corrupt), errChecksumMismatch)
+}
+
+// corruptLine flips a byte of the URL in the line of the file holding it.
+func corruptLine(t *testing.T, path, url string) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	i := bytes.Index(data, []byte(url))
+	assert.GreaterOrEqual(t, i, 0)
+	data[i] ^= 0x01
+
+	err = os.WriteFile(path, data, 0644)
+	assert.NoError(t, err)
+}
+
+func TestCorruptLines(t *testing.T) {
+	cfg := newTestConfig(t)
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	for id, url := range []string{"a.com", "b.com", "c.com"} {
+		err = storage.SaveRecord(context.Background(), &domain.Record{ID: int64(id + 1), Links: map[string]string{url: "available"}})
+		assert.NoError(t, err)
+
+		err = storage.SaveTempRecord(context.Background(), &domain.Record{ID: int64(id + 1), Links: map[string]string{url: "unknown"}})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, storage.Close())
+
+	corruptLine(t, storage.path, "b.com")
+	corruptLine(t, storage.tempPath, "c.com")
+
+	storage, err = New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	t.Cleanup(func() { storage.Close() })
+
+	_, err = storage.GetRecord(context.Background(), 2)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	rec, err := storage.GetRecord(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "available", rec.Links["c.com"])
+
+	temp, err := storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, temp, 2)
+
+	report, err := storage.Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, 6, report.Lines)
+	assert.Equal(t, 2, report.Corrupt)
+	assert.Zero(t, report.Malformed)
+	assert.Zero(t, report.Unchecked)
+	assert.Len(t, report.Problems, 2)
+	assert.Equal(t, LineProblem{Path: storage.path, Line: 2, Error: report.Problems[0].Error}, report.Problems[0])
+	assert.Equal(t, storage.tempPath, report.Problems[1].Path)
+	assert.Equal(t, 3, report.Problems[1].Line)
+}
+
+func TestCorruptLinesStrict(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.StrictDecode = true
+
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	t.Cleanup(func() { storage.Close() })
+
+	for _, url := range []string{"a.com", "b.com"} {
+		err = storage.SaveTempRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{url: "unknown"}})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, storage.temp.Flush())
+
+	corruptLine(t, storage.tempPath, "a.com")
+
+	_, err = storage.LoadTempRecords(context.Background())
+	assert.ErrorIs(t, err, errChecksumMismatch)
+	assert.ErrorContains(t, err, "line 1")
+}
+
+func TestVerifyUncheckedLines(t *testing.T) {
+	cfg := newTestConfig(t)
+	err := os.WriteFile(filepath.Join(cfg.DirPath, cfg.FileName), []byte("{\"links_num\":1}\nnot a record\n{\"links_num\":2}\n"), 0644)
+	assert.NoError(t, err)
+
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	t.Cleanup(func() { storage.Close() })
+
+	report, err := storage.Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, VerifyReport{
+		Lines:     3,
+		Unchecked: 3,
+		Malformed: 1,
+		Problems:  []LineProblem{{Path: storage.path, Line: 2, Error: report.Problems[0].Error}},
+	}, report)
+}
@@ -0,0 +1,155 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Compact rewrites the main file keeping one line per record ID: the latest
+// update line of records changed by UpdateRecord, otherwise the most
+// recently updated version and, on ties, the last one. Deleted records keep
+// only their tombstone, so their IDs are not handed out again. Blank lines
+// are dropped and malformed lines are kept for inspection. The kept lines
+// are sealed under the current encryption key, see crypt.go. Only the sealed
+// segments that change are replaced. It returns the number of dropped lines.
+func (s *Storage) Compact() (int, error) {
+	err := s.checkWritable("compact")
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := s.clock.Now()
+
+	keep, err := s.latestLines()
+	if err != nil {
+		s.logger.Error("failed to compact", zap.String("path", s.path), zap.Error(err))
+		return 0, fmt.Errorf("failed to compact: %w", err)
+	}
+
+	lineNum, dropped := 0, 0
+	err = s.rewriteMain(func(line []byte, w *bufio.Writer) error {
+		lineNum++
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			dropped++
+			return nil
+		}
+
+		id, kind, ok := s.lineEntry(line)
+		if !ok {
+			return writeLine(w, line)
+		}
+
+		latest := keep[id] == lineNum
+		if s.isDeleted(id) {
+			latest = kind == tombstoneLine
+		}
+
+		if !latest {
+			dropped++
+			return nil
+		}
+
+		return writeLine(w, s.resealLine(line))
+	}, nil)
+	if err != nil {
+		s.logger.Error("failed to compact", zap.String("path", s.path), zap.Error(err))
+		return 0, fmt.Errorf("failed to compact: %w", err)
+	}
+
+	// The rewrite moves the lines, so the offsets are indexed again.
+	err = s.reindex()
+	if err != nil {
+		s.logger.Warn("failed to rebuild index, run a reindex", zap.Error(err))
+	}
+	s.countRecords()
+
+	s.logger.Info("main file compacted",
+		zap.String("path", s.path),
+		zap.Int("dropped", dropped),
+		zap.Duration("duration", s.clock.Now().Sub(start)),
+	)
+	return dropped, nil
+}
+
+// latestLines returns the number of the line holding the latest version of
+// every record ID, counting lines like rewriteFile. The caller must hold the
+// lock.
+func (s *Storage) latestLines() (map[int64]int, error) {
+	file, err := s.openMain()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %s: %w", s.path, err)
+	}
+	defer file.Close()
+
+	type version struct {
+		line      int
+		updatedAt time.Time
+	}
+	latest := make(map[int64]version)
+	updates := make(map[int64]int)
+
+	lineNum := 0
+	var offset int64
+	scanner := bufio.NewScanner(file)
+	scanner.Split(splitLines)
+	for scanner.Scan() {
+		lineNum++
+		lineOffset := offset
+		offset += int64(len(scanner.Bytes()))
+
+		id, ok := s.lineRecordID(scanner.Bytes())
+		if !ok {
+			continue
+		}
+
+		if current, ok := s.updated[id]; ok && current == lineOffset {
+			updates[id] = lineNum
+		}
+
+		// A line with an unreadable timestamp still competes as the oldest.
+		var rec struct {
+			UpdatedAt time.Time `json:"updated_at"`
+		}
+		_ = s.unmarshalLine(scanner.Bytes(), &rec)
+
+		stored, ok := latest[id]
+		if ok && rec.UpdatedAt.Before(stored.updatedAt) {
+			continue
+		}
+
+		latest[id] = version{line: lineNum, updatedAt: rec.UpdatedAt}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan file: %s: %w", s.path, err)
+	}
+
+	lines := make(map[int64]int, len(latest))
+	for id, v := range latest {
+		lines[id] = v.line
+	}
+
+	// Reads return the latest update line regardless of its timestamp.
+	for id, line := range updates {
+		lines[id] = line
+	}
+
+	return lines, nil
+}
+
+// compactScheduled is the CompactInterval loop body.
+func (s *Storage) compactScheduled() {
+	_, err := s.Compact()
+	if err != nil {
+		s.logger.Warn("scheduled compaction failed", zap.Error(err))
+	}
+}
@@ -0,0 +1,94 @@
+package filesystem
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/repository"
+)
+
+const uncompactedFile = `{"links":{"a.com":"available"},"links_num":1,"updated_at":"2025-11-30T12:00:00Z"}
+{"links":{},"links_num":2}
+{"links":{"a.com":"not available"},"links_num":1,"updated_at":"2025-11-30T13:00:00Z"}
+
+not a record
+{"links":{"b.com":"available"},"links_num":3,"updated_at":"2025-11-30T12:00:00Z"}
+{"links":{"b.com":"not available"},"links_num":3,"updated_at":"2025-11-30T12:00:00Z"}
+{"links":{"a.com":"stale"},"links_num":1,"updated_at":"2025-11-30T12:30:00Z"}
+`
+
+func TestCompact(t *testing.T) {
+	cfg := newTestConfig(t)
+	err := os.WriteFile(filepath.Join(cfg.DirPath, cfg.FileName), []byte(uncompactedFile), 0644)
+	assert.NoError(t, err)
+
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), storage.Len())
+
+	dropped, err := storage.Compact()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, dropped)
+	assert.Equal(t, int64(3), storage.Len())
+
+	data, err := os.ReadFile(storage.path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`{"links":{},"links_num":2}`,
+		`{"links":{"a.com":"not available"},"links_num":1,"updated_at":"2025-11-30T13:00:00Z"}`,
+		`not a record`,
+		`{"links":{"b.com":"not available"},"links_num":3,"updated_at":"2025-11-30T12:00:00Z"}`,
+	}, strings.Split(strings.TrimSpace(string(data)), "\n"))
+
+	rec, ok := storage.indexedRecord(1)
+	assert.True(t, ok)
+	assert.Equal(t, "not available", rec.Links["a.com"])
+
+	rec, err = storage.GetRecord(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "not available", rec.Links["b.com"])
+
+	// A compacted file stays as it is.
+	dropped, err = storage.Compact()
+	assert.NoError(t, err)
+	assert.Zero(t, dropped)
+}
+
+func TestCompactReadOnly(t *testing.T) {
+	cfg := newTestConfig(t)
+	err := os.WriteFile(filepath.Join(cfg.DirPath, cfg.FileName), []byte(uncompactedFile), 0644)
+	assert.NoError(t, err)
+
+	cfg.ReadOnly = true
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	_, err = storage.Compact()
+	assert.ErrorIs(t, err, repository.ErrReadOnlyStorage)
+}
+
+func TestScheduledCompaction(t *testing.T) {
+	cfg := newTestConfig(t)
+	err := os.WriteFile(filepath.Join(cfg.DirPath, cfg.FileName), []byte(uncompactedFile), 0644)
+	assert.NoError(t, err)
+
+	cfg.CompactInterval = 10 * time.Millisecond
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		data, err := os.ReadFile(storage.path)
+		return err == nil && strings.Count(string(data), "\n") == 4
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, storage.Close())
+	assert.NoError(t, storage.Close())
+}
@@ -0,0 +1,421 @@
+package filesystem
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Only sealed segments are compressed, when they are sealed by a rotation,
+// so appends still go to a plain main file. The codec of a segment follows
+// from its file name, e.g. data-000001.json.zst, so segments written with
+// another Compression stay readable. Offsets in the main file are offsets
+// in the decompressed data.
+//
+// A segment is compressed in independent frames of frameSize decompressed
+// bytes, followed by a table of where each frame starts, so a read
+// decompresses at most one frame before the record instead of the segment
+// up to it. Both codecs decode concatenated frames as a single stream. The
+// table is wrapped in a zstd skippable frame, which zstd tools ignore; gzip
+// tools warn about it as trailing data. Segments compressed before the
+// table existed are read from the start.
+
+const (
+	// CompressionNone keeps the sealed segments as they are.
+	CompressionNone = "none"
+	// CompressionGzip compresses the sealed segments with gzip.
+	CompressionGzip = "gzip"
+	// CompressionZstd compresses the sealed segments with zstd.
+	CompressionZstd = "zstd"
+)
+
+// frameSize is the decompressed size of a frame of a compressed segment.
+// Smaller frames make seeks cheaper and the compression worse.
+const frameSize = 64 << 10
+
+// The frame table ends with a trailer holding the end of the frames, the
+// number of frames and frameTableMagic.
+const frameTrailerSize = 16
+
+var frameTableMagic = [4]byte{'L', 'S', 'F', 'T'}
+
+// zstdSkippableMagic starts a zstd frame that decoders skip.
+const zstdSkippableMagic = 0x184D2A50
+
+// frame is where a frame starts in the decompressed and the compressed data.
+type frame struct {
+	plain      int64
+	compressed int64
+}
+
+var compressionSuffixes = map[string]string{
+	CompressionGzip: ".gz",
+	CompressionZstd: ".zst",
+}
+
+// compressionOf returns the compression of the file at path from its suffix.
+func compressionOf(path string) string {
+	for compression, suffix := range compressionSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return compression
+		}
+	}
+
+	return CompressionNone
+}
+
+// newDecompressor returns a reader of the data compressed in r.
+func newDecompressor(r io.Reader, compression string) (io.ReadCloser, error) {
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionZstd:
+		dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+
+		return dec.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unknown compression %q", compression)
+	}
+}
+
+// newCompressor returns a writer compressing to w. It must be closed to
+// write out the compressed data.
+func newCompressor(w io.Writer, compression string) (io.WriteCloser, error) {
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	default:
+		return nil, fmt.Errorf("unknown compression %q", compression)
+	}
+}
+
+// framedWriter compresses to w in frames of frameSize and writes the frame
+// table on Close.
+type framedWriter struct {
+	out         io.Writer
+	compression string
+	// dst writes to out and counts the compressed bytes in size.
+	dst    io.Writer
+	size   *countingWriter
+	enc    io.WriteCloser
+	open   bool
+	frames []frame
+	plain  int64
+}
+
+// newFramedCompressor returns a writer compressing to w in frames that can be
+// read on their own, see decompressFile. It must be closed to write out the
+// compressed data and the frame table.
+func newFramedCompressor(w io.Writer, compression string) (io.WriteCloser, error) {
+	size := &countingWriter{}
+	fw := &framedWriter{out: w, compression: compression, dst: io.MultiWriter(w, size), size: size}
+
+	var err error
+	fw.enc, err = newCompressor(fw.dst, compression)
+	if err != nil {
+		return nil, err
+	}
+
+	fw.open = true
+	fw.frames = append(fw.frames, frame{})
+	return fw, nil
+}
+
+func (fw *framedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if !fw.open {
+			// The encoder was closed at the end of the previous frame.
+			fw.enc.(interface{ Reset(io.Writer) }).Reset(fw.dst)
+			fw.open = true
+			fw.frames = append(fw.frames, frame{plain: fw.plain, compressed: fw.size.n})
+		}
+
+		start := fw.frames[len(fw.frames)-1].plain
+		n, err := fw.enc.Write(p[:min(int64(len(p)), start+frameSize-fw.plain)])
+		written += n
+		fw.plain += int64(n)
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+
+		if fw.plain-start == frameSize {
+			err = fw.enc.Close()
+			if err != nil {
+				return written, err
+			}
+			fw.open = false
+		}
+	}
+
+	return written, nil
+}
+
+func (fw *framedWriter) Close() error {
+	if fw.open {
+		err := fw.enc.Close()
+		if err != nil {
+			return err
+		}
+		fw.open = false
+	}
+
+	var table bytes.Buffer
+	tableSize := len(fw.frames)*16 + frameTrailerSize
+	if fw.compression == CompressionZstd {
+		table.Write(binary.LittleEndian.AppendUint32(nil, zstdSkippableMagic))
+		table.Write(binary.LittleEndian.AppendUint32(nil, uint32(tableSize)))
+	}
+	for _, f := range fw.frames {
+		table.Write(binary.LittleEndian.AppendUint64(nil, uint64(f.plain)))
+		table.Write(binary.LittleEndian.AppendUint64(nil, uint64(f.compressed)))
+	}
+	table.Write(binary.LittleEndian.AppendUint64(nil, uint64(fw.size.n)))
+	table.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(fw.frames))))
+	table.Write(frameTableMagic[:])
+
+	_, err := fw.out.Write(table.Bytes())
+	return err
+}
+
+// openMainFile opens a file of the main file for reading, decompressing a
+// compressed segment. The caller must hold the lock, at least for reading.
+func (s *Storage) openMainFile(path string) (io.ReadSeekCloser, error) {
+	file, err := s.openForRead(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return decompressFile(file, compressionOf(path))
+}
+
+// OpenMainFile opens a file listed by MainFilePaths for reading,
+// decompressing a compressed segment.
+func OpenMainFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return decompressFile(file, compressionOf(path))
+}
+
+func decompressFile(file *os.File, compression string) (io.ReadSeekCloser, error) {
+	if compression == CompressionNone {
+		return file, nil
+	}
+
+	r := &decompressedFile{file: file, compression: compression}
+
+	err := r.loadFrames()
+	if err == nil {
+		err = r.reset(0)
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to decompress file: %s: %w", file.Name(), err)
+	}
+
+	return r, nil
+}
+
+// decompressedFile reads the decompressed data of a compressed file. Seek
+// decompresses from the start of the frame holding the offset when it goes
+// backwards or past the current frame.
+type decompressedFile struct {
+	file        *os.File
+	compression string
+	// frames are the frames of the file and end is where the last one
+	// ends. A file without a frame table is a single frame.
+	frames []frame
+	end    int64
+	dec    io.ReadCloser
+	pos    int64
+}
+
+// loadFrames reads the frame table at the end of the file, if any.
+func (r *decompressedFile) loadFrames() error {
+	info, err := r.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	r.frames = []frame{{}}
+	r.end = info.Size()
+	if r.end < frameTrailerSize {
+		return nil
+	}
+
+	trailer := make([]byte, frameTrailerSize)
+	_, err = r.file.ReadAt(trailer, r.end-frameTrailerSize)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(trailer[12:], frameTableMagic[:]) {
+		return nil
+	}
+
+	end := int64(binary.LittleEndian.Uint64(trailer))
+	count := int64(binary.LittleEndian.Uint32(trailer[8:]))
+	tableStart := r.end - frameTrailerSize - count*16
+	if count == 0 || end > tableStart {
+		return errors.New("invalid frame table")
+	}
+
+	table := make([]byte, count*16)
+	_, err = r.file.ReadAt(table, tableStart)
+	if err != nil {
+		return err
+	}
+
+	r.frames = make([]frame, count)
+	for i := range r.frames {
+		r.frames[i] = frame{
+			plain:      int64(binary.LittleEndian.Uint64(table[i*16:])),
+			compressed: int64(binary.LittleEndian.Uint64(table[i*16+8:])),
+		}
+	}
+	r.end = end
+
+	return nil
+}
+
+// reset starts decompressing at the start of frame i.
+func (r *decompressedFile) reset(i int) error {
+	if r.dec != nil {
+		r.dec.Close()
+	}
+
+	f := r.frames[i]
+
+	var err error
+	r.dec, err = newDecompressor(io.NewSectionReader(r.file, f.compressed, r.end-f.compressed), r.compression)
+	if err != nil {
+		return err
+	}
+
+	r.pos = f.plain
+	return nil
+}
+
+func (r *decompressedFile) Read(p []byte) (int, error) {
+	n, err := r.dec.Read(p)
+	r.pos += int64(n)
+
+	return n, err
+}
+
+// Seek only supports io.SeekStart.
+func (r *decompressedFile) Seek(offset int64, whence int) (int64, error) {
+	if whence != io.SeekStart {
+		return 0, errors.New("compressed file only seeks from the start")
+	}
+
+	i := sort.Search(len(r.frames), func(i int) bool { return r.frames[i].plain > offset }) - 1
+	if offset < r.pos || r.frames[i].plain > r.pos {
+		err := r.reset(i)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	_, err := io.CopyN(io.Discard, r, offset-r.pos)
+	if err != nil {
+		return 0, err
+	}
+
+	return offset, nil
+}
+
+func (r *decompressedFile) Close() error {
+	return errors.Join(r.dec.Close(), r.file.Close())
+}
+
+// decompressedSize returns the size of the decompressed data of the file.
+func (s *Storage) decompressedSize(path string) (int64, error) {
+	r, err := s.openMainFile(path)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	return io.Copy(io.Discard, r)
+}
+
+// compressSegment replaces the just sealed plain segment with its
+// compressed copy and renames its index file to match. The plain file is
+// left for the caller to remove once the manifest lists the compressed one.
+// The caller must hold the lock.
+func (s *Storage) compressSegment(seg *segment) error {
+	plain := *seg
+	compressed := segment{Name: plain.Name + compressionSuffixes[s.compression], Size: plain.Size}
+
+	src, err := os.Open(s.segmentPath(plain))
+	if err != nil {
+		return fmt.Errorf("failed to open segment: %w", err)
+	}
+	defer src.Close()
+
+	path := s.segmentPath(compressed)
+	tmpPath := path + ".tmp"
+	dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, s.fileMode)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %s: %w", tmpPath, err)
+	}
+	defer os.Remove(tmpPath)
+	defer dst.Close()
+
+	w, err := newFramedCompressor(dst, s.compression)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, src)
+	if err != nil {
+		return fmt.Errorf("failed to compress segment: %s: %w", plain.Name, err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		return fmt.Errorf("failed to compress segment: %s: %w", plain.Name, err)
+	}
+
+	err = dst.Sync()
+	if err != nil {
+		return fmt.Errorf("failed to sync file: %s: %w", tmpPath, err)
+	}
+
+	err = dst.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close file: %s: %w", tmpPath, err)
+	}
+
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return fmt.Errorf("failed to rename file: %s: %w", tmpPath, err)
+	}
+
+	// Without its index the segment is indexed on the next start.
+	err = os.Rename(s.segmentIndexPath(plain), s.segmentIndexPath(compressed))
+	if err != nil {
+		os.Remove(s.segmentIndexPath(plain))
+	}
+
+	*seg = compressed
+	return nil
+}
@@ -0,0 +1,285 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+)
+
+func TestCompressSegments(t *testing.T) {
+	for _, compression := range []string{CompressionGzip, CompressionZstd} {
+		t.Run(compression, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			cfg.SegmentSizeMB = 1
+			cfg.Compression = compression
+
+			storage := newSegmentedStorage(t, cfg)
+
+			for id := int64(1); id <= 3; id++ {
+				err := storage.SaveRecord(context.Background(), &domain.Record{ID: id, Links: map[string]string{"a.com": "available"}})
+				assert.NoError(t, err)
+			}
+
+			err := storage.UpdateRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "not available"}})
+			assert.NoError(t, err)
+
+			suffix := compressionSuffixes[compression]
+			assert.Equal(t, "data-000001.json"+suffix, storage.segments[0].Name)
+			assert.FileExists(t, filepath.Join(cfg.DirPath, "data-000001.json"+suffix))
+			assert.FileExists(t, filepath.Join(cfg.DirPath, "data-000001.json"+suffix+indexFileSuffix))
+			assert.NoFileExists(t, filepath.Join(cfg.DirPath, "data-000001.json"))
+			assert.NoFileExists(t, filepath.Join(cfg.DirPath, "data-000001.json"+indexFileSuffix))
+
+			dropped, err := storage.Compact()
+			assert.NoError(t, err)
+			assert.Equal(t, 1, dropped)
+			assert.Zero(t, storage.segments[0].Size)
+
+			check := func(storage *Storage) {
+				t.Helper()
+
+				rec, err := storage.GetRecord(context.Background(), 1)
+				assert.NoError(t, err)
+				assert.Equal(t, "not available", rec.Links["a.com"])
+
+				rec, err = storage.GetRecord(context.Background(), 3)
+				assert.NoError(t, err)
+				assert.Equal(t, "available", rec.Links["a.com"])
+
+				records, err := storage.GetAllRecords(context.Background())
+				assert.NoError(t, err)
+				assert.Len(t, records, 3)
+
+				assert.Equal(t, int64(3), storage.LoadLastLinksNum(context.Background()))
+			}
+
+			check(storage)
+			assert.NoError(t, storage.Close())
+
+			reopened, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+			assert.NoError(t, err)
+			t.Cleanup(func() { reopened.Close() })
+
+			assert.Equal(t, int64(3), reopened.Len())
+			check(reopened)
+
+			// A reindex reads the compressed segments as well.
+			assert.NoError(t, reopened.Reindex())
+			check(reopened)
+		})
+	}
+}
+
+func TestLoadCompressedSegmentMissingFromManifest(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.SegmentSizeMB = 1
+	cfg.Compression = CompressionZstd
+
+	storage := newSegmentedStorage(t, cfg)
+
+	err := storage.SaveRecord(context.Background(), &domain.Record{ID: 1})
+	assert.NoError(t, err)
+	size := storage.segments[0].Size
+	assert.NoError(t, storage.Close())
+
+	err = os.Remove(storage.manifestPath)
+	assert.NoError(t, err)
+
+	reopened, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	t.Cleanup(func() { reopened.Close() })
+
+	assert.Equal(t, []segment{{Name: "data-000001.json.zst", Size: size}}, reopened.segments)
+
+	rec, err := reopened.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rec.ID)
+}
+
+// TestDecompressedFileSeek reads a file compressed as a single stream, as the
+// segments were before the frame table.
+func TestDecompressedFileSeek(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json.gz")
+
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+	w, err := newCompressor(file, CompressionGzip)
+	assert.NoError(t, err)
+	_, err = w.Write([]byte("ab\ncd\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	assert.NoError(t, file.Close())
+
+	r, err := OpenMainFile(path)
+	assert.NoError(t, err)
+	t.Cleanup(func() { r.Close() })
+
+	data, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "ab\ncd\n", string(data))
+
+	seeker := r.(io.Seeker)
+
+	_, err = seeker.Seek(4, io.SeekStart)
+	assert.NoError(t, err)
+	data, err = io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "d\n", string(data))
+
+	_, err = seeker.Seek(1, io.SeekStart)
+	assert.NoError(t, err)
+	data, err = io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "b\ncd\n", string(data))
+}
+
+func TestConfigValidateCompression(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.Compression = CompressionZstd
+
+	err := cfg.Validate()
+	assert.ErrorContains(t, err, "STORAGE_SEGMENT_SIZE_MB")
+
+	cfg.Compression = "lz4"
+	err = cfg.Validate()
+	assert.ErrorContains(t, err, "STORAGE_COMPRESSION")
+
+	cfg.Compression = CompressionGzip
+	cfg.SegmentSizeMB = 64
+	assert.NoError(t, cfg.Validate())
+}
+
+// writeCompressed writes data to a new file in dir compressed with the
+// writer returned by newWriter and returns its path.
+func writeCompressed(tb testing.TB, compression string, data []byte, newWriter func(io.Writer, string) (io.WriteCloser, error)) string {
+	tb.Helper()
+
+	path := filepath.Join(tb.TempDir(), "data.json"+compressionSuffixes[compression])
+	file, err := os.Create(path)
+	assert.NoError(tb, err)
+	w, err := newWriter(file, compression)
+	assert.NoError(tb, err)
+	_, err = w.Write(data)
+	assert.NoError(tb, err)
+	assert.NoError(tb, w.Close())
+	assert.NoError(tb, file.Close())
+
+	return path
+}
+
+func testData(size int) []byte {
+	var data bytes.Buffer
+	for i := 0; data.Len() < size; i++ {
+		fmt.Fprintf(&data, "{\"id\":%d,\"links\":{\"example.com/%d\":\"available\"}}\n", i, i)
+	}
+
+	return data.Bytes()
+}
+
+func TestFramedCompression(t *testing.T) {
+	data := testData(3*frameSize + 100)
+
+	for _, compression := range []string{CompressionGzip, CompressionZstd} {
+		t.Run(compression, func(t *testing.T) {
+			path := writeCompressed(t, compression, data, newFramedCompressor)
+
+			r, err := OpenMainFile(path)
+			assert.NoError(t, err)
+			t.Cleanup(func() { r.Close() })
+
+			assert.Len(t, r.(*decompressedFile).frames, 4)
+
+			all, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, data, all)
+
+			// Forward within a frame, across frames and backwards.
+			for _, offset := range []int64{10, 20, 2*frameSize + 5, frameSize - 1, 3 * frameSize, 0} {
+				_, err = r.(io.Seeker).Seek(offset, io.SeekStart)
+				assert.NoError(t, err)
+
+				buf := make([]byte, 50)
+				_, err = io.ReadFull(r, buf)
+				assert.NoError(t, err)
+				assert.Equal(t, data[offset:offset+50], buf)
+			}
+
+			// The frames are a valid stream of the codec, the table is skipped
+			// by zstd.
+			if compression == CompressionZstd {
+				file, err := os.Open(path)
+				assert.NoError(t, err)
+				defer file.Close()
+
+				dec, err := newDecompressor(file, compression)
+				assert.NoError(t, err)
+				defer dec.Close()
+
+				all, err = io.ReadAll(dec)
+				assert.NoError(t, err)
+				assert.Equal(t, data, all)
+			}
+		})
+	}
+}
+
+func TestFramedCompressionEmpty(t *testing.T) {
+	path := writeCompressed(t, CompressionZstd, nil, newFramedCompressor)
+
+	r, err := OpenMainFile(path)
+	assert.NoError(t, err)
+	defer r.Close()
+
+	all, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+// BenchmarkDecompressedFileSeek reads a line at a random offset of a 4 MiB
+// segment, compressed in frames and as a single stream as before the frame
+// table.
+func BenchmarkDecompressedFileSeek(b *testing.B) {
+	data := testData(4 << 20)
+
+	for _, bb := range []struct {
+		name      string
+		newWriter func(io.Writer, string) (io.WriteCloser, error)
+	}{
+		{name: "framed", newWriter: newFramedCompressor},
+		{name: "single_stream", newWriter: newCompressor},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			path := writeCompressed(b, CompressionZstd, data, bb.newWriter)
+
+			r, err := OpenMainFile(path)
+			assert.NoError(b, err)
+			defer r.Close()
+
+			rng := rand.New(rand.NewPCG(1, 2))
+			buf := make([]byte, 64)
+
+			for b.Loop() {
+				_, err = r.(io.Seeker).Seek(rng.Int64N(int64(len(data)-len(buf))), io.SeekStart)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				_, err = io.ReadFull(r, buf)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package filesystem
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// With encryption keys, every line written to the main file, the temp file
+// and the events file is sealed with AES-GCM under the first key and stored
+// as an envelope naming the key:
+//
+//	{"key_id":"2025-11","sealed":"<base64 of the nonce and the ciphertext>"}
+//
+// A line is opened with the key it names, so after a key rotation the old
+// keys stay configured until Compact has sealed every line again under the
+// new first key. Plain lines, e.g. written before the keys were set, are read
+// as they are and sealed by the next Compact. Compact does not rewrite the
+// events file, so its lines keep the key they were sealed under.
+
+// envelopePrefix starts every sealed line, see sealLine.
+var envelopePrefix = []byte(`{"key_id":`)
+
+type envelope struct {
+	KeyID  string `json:"key_id"`
+	Sealed []byte `json:"sealed"`
+}
+
+// keyring holds the AEADs of the encryption keys by key ID.
+type keyring struct {
+	// current is the ID of the key sealing new lines.
+	current string
+	aeads   map[string]cipher.AEAD
+}
+
+// loadKeyring builds the keyring from STORAGE_ENCRYPTION_KEYS or the key
+// file, which list "id:base64-key" entries separated by commas or newlines.
+// Without keys it returns nil and lines are stored plain.
+func loadKeyring(cfg *Config) (*keyring, error) {
+	spec := cfg.EncryptionKeys
+	if cfg.EncryptionKeyFile != "" {
+		data, err := os.ReadFile(cfg.EncryptionKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+
+		spec = string(data)
+	}
+
+	entries := strings.FieldsFunc(spec, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r'
+	})
+
+	var k *keyring
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, errors.New(`encryption keys must be "id:base64-key" entries`)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode encryption key %q: %w", id, err)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key %q: %w", id, err)
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key %q: %w", id, err)
+		}
+
+		if k == nil {
+			k = &keyring{current: id, aeads: make(map[string]cipher.AEAD)}
+		}
+
+		if _, ok := k.aeads[id]; ok {
+			return nil, fmt.Errorf("duplicate encryption key %q", id)
+		}
+
+		k.aeads[id] = aead
+	}
+
+	return k, nil
+}
+
+// marshalLine encodes v as a line of the main, the temp or the events file,
+// without the newline: sealed if there are encryption keys and with its
+// checksum.
+func (s *Storage) marshalLine(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.encodeLine(data)
+}
+
+// encodeLine seals the JSON line and adds its checksum.
+func (s *Storage) encodeLine(data []byte) ([]byte, error) {
+	sealed, err := s.sealLine(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return addChecksum(sealed), nil
+}
+
+// unmarshalLine decodes a line of the main, the temp or the events file into
+// v.
+func (s *Storage) unmarshalLine(line []byte, v any) error {
+	plain, err := s.openLine(line)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(plain, v)
+}
+
+// sealLine encrypts the line under the current key. Without keys the line
+// is returned as it is.
+func (s *Storage) sealLine(line []byte) ([]byte, error) {
+	if s.keys == nil {
+		return line, nil
+	}
+
+	aead := s.keys.aeads[s.keys.current]
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(line)+aead.Overhead())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, line, []byte(s.keys.current))
+
+	return json.Marshal(envelope{KeyID: s.keys.current, Sealed: sealed})
+}
+
+// openLine verifies the checksum of a line and returns its plaintext
+// without the checksum, keeping its newline. Plain lines without a checksum
+// are returned as they are.
+func (s *Storage) openLine(line []byte) ([]byte, error) {
+	plain, checked, err := verifyChecksum(bytes.TrimSpace(line))
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(plain, envelopePrefix):
+		plain, err = s.unsealLine(plain)
+		if err != nil {
+			return nil, err
+		}
+	case !checked:
+		return line, nil
+	}
+
+	if bytes.HasSuffix(line, []byte{'\n'}) {
+		plain = append(plain, '\n')
+	}
+
+	return plain, nil
+}
+
+// unsealLine decrypts a sealed line with the key it names.
+func (s *Storage) unsealLine(line []byte) ([]byte, error) {
+	var env envelope
+	err := json.Unmarshal(line, &env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sealed line: %w", err)
+	}
+
+	if s.keys == nil {
+		return nil, fmt.Errorf("line is sealed with key %q but no encryption keys are set", env.KeyID)
+	}
+
+	aead, ok := s.keys.aeads[env.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("line is sealed with unknown key %q", env.KeyID)
+	}
+
+	if len(env.Sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed line is too short")
+	}
+
+	nonce, ciphertext := env.Sealed[:aead.NonceSize()], env.Sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(env.KeyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open line sealed with key %q: %w", env.KeyID, err)
+	}
+
+	return plain, nil
+}
+
+// resealLine encodes the line again like marshalLine unless it already is
+// sealed under the current key. Lines that cannot be opened are returned as
+// they are.
+func (s *Storage) resealLine(line []byte) []byte {
+	if s.keys == nil || bytes.HasPrefix(line, s.currentPrefix()) {
+		return line
+	}
+
+	plain, err := s.openLine(line)
+	if err != nil {
+		return line
+	}
+
+	encoded, err := s.encodeLine(bytes.TrimSpace(plain))
+	if err != nil {
+		return line
+	}
+
+	return encoded
+}
+
+// currentPrefix starts the lines sealed under the current key.
+func (s *Storage) currentPrefix() []byte {
+	id, _ := json.Marshal(s.keys.current)
+	return append(append([]byte(nil), envelopePrefix...), id...)
+}
@@ -0,0 +1,192 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+)
+
+func testKey(id string, b byte) string {
+	return id + ":" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func TestEncryptedStorage(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.EncryptionKeys = testKey("k1", 1)
+
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	for id := int64(1); id <= 3; id++ {
+		err = storage.SaveRecord(context.Background(), &domain.Record{ID: id, Links: map[string]string{"internal.example": "available"}})
+		assert.NoError(t, err)
+	}
+
+	err = storage.UpdateRecord(context.Background(), &domain.Record{ID: 2, Links: map[string]string{"internal.example": "not available"}})
+	assert.NoError(t, err)
+
+	err = storage.DeleteRecord(context.Background(), 3)
+	assert.NoError(t, err)
+
+	err = storage.SaveTempRecord(context.Background(), &domain.Record{ID: 4, Links: map[string]string{"internal.example": "available"}})
+	assert.NoError(t, err)
+
+	// The details of checked events list the links.
+	err = storage.SaveRecordEvent(context.Background(), &domain.RecordEvent{
+		RecordID:  1,
+		EventType: domain.EventTypeChecked,
+		Detail:    "internal.example: available",
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, storage.Close())
+
+	for _, path := range []string{storage.path, storage.tempPath, storage.eventsPath} {
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(data), "internal.example")
+		assert.Contains(t, string(data), `{"key_id":"k1"`)
+	}
+
+	reopened, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	t.Cleanup(func() { reopened.Close() })
+
+	rec, err := reopened.GetRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "not available", rec.Links["internal.example"])
+
+	records, err := reopened.GetAllRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	ids, err := reopened.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, ids)
+
+	temp, err := reopened.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, temp, 1)
+
+	assert.Equal(t, int64(3), reopened.LoadLastLinksNum(context.Background()))
+
+	events, err := reopened.GetRecordEvents(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.EventTypeChecked, events[len(events)-1].EventType)
+	assert.Equal(t, "internal.example: available", events[len(events)-1].Detail)
+
+	var buf bytes.Buffer
+	_, err = reopened.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(buf.String(), "internal.example"))
+	assert.NotContains(t, buf.String(), "key_id")
+}
+
+func TestEncryptionKeyRotation(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	// Lines written before encryption was enabled stay readable.
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	err = storage.SaveRecord(context.Background(), &domain.Record{ID: 1})
+	assert.NoError(t, err)
+	assert.NoError(t, storage.Close())
+
+	cfg.EncryptionKeys = testKey("k1", 1)
+	storage, err = New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	err = storage.SaveRecord(context.Background(), &domain.Record{ID: 2})
+	assert.NoError(t, err)
+	assert.NoError(t, storage.Close())
+
+	cfg.EncryptionKeys = testKey("k2", 2) + "," + testKey("k1", 1)
+	storage, err = New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	err = storage.SaveRecord(context.Background(), &domain.Record{ID: 3})
+	assert.NoError(t, err)
+
+	ids, err := storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+
+	dropped, err := storage.Compact()
+	assert.NoError(t, err)
+	assert.Zero(t, dropped)
+	assert.NoError(t, storage.Close())
+
+	data, err := os.ReadFile(storage.path)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(string(data), `{"key_id":"k2"`))
+
+	// Once every line is sealed under the new key, the old one is dropped.
+	cfg.EncryptionKeys = testKey("k2", 2)
+	storage, err = New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	t.Cleanup(func() { storage.Close() })
+
+	rec, err := storage.GetRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), rec.ID)
+}
+
+func TestEncryptionKeyMissing(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.EncryptionKeys = testKey("k1", 1)
+
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	err = storage.SaveRecord(context.Background(), &domain.Record{ID: 1})
+	assert.NoError(t, err)
+	assert.NoError(t, storage.Close())
+
+	cfg.EncryptionKeys = ""
+	_, err = New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.ErrorContains(t, err, "check the encryption keys")
+
+	cfg.EncryptionKeys = testKey("k2", 2)
+	_, err = New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.ErrorContains(t, err, `unknown key "k1"`)
+}
+
+func TestLoadKeyring(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "keys")
+	err := os.WriteFile(keyFile, []byte(testKey("k2", 2)+"\n"+testKey("k1", 1)+"\n"), 0600)
+	assert.NoError(t, err)
+
+	keys, err := loadKeyring(&Config{EncryptionKeyFile: keyFile})
+	assert.NoError(t, err)
+	assert.Equal(t, "k2", keys.current)
+	assert.Len(t, keys.aeads, 2)
+
+	keys, err = loadKeyring(&Config{})
+	assert.NoError(t, err)
+	assert.Nil(t, keys)
+
+	_, err = loadKeyring(&Config{EncryptionKeys: testKey("k1", 1) + "," + testKey("k1", 2)})
+	assert.ErrorContains(t, err, "duplicate")
+
+	_, err = loadKeyring(&Config{EncryptionKeys: "k1:" + base64.StdEncoding.EncodeToString([]byte("short"))})
+	assert.ErrorContains(t, err, "invalid encryption key")
+
+	_, err = loadKeyring(&Config{EncryptionKeys: "no-id"})
+	assert.Error(t, err)
+}
+
+func TestConfigValidateEncryptionKeys(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.EncryptionKeys = testKey("k1", 1)
+	cfg.EncryptionKeyFile = "keys"
+
+	err := cfg.Validate()
+	assert.ErrorContains(t, err, "STORAGE_ENCRYPTION_KEY_FILE")
+}
@@ -0,0 +1,93 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+// tombstone is the line DeleteRecord appends to the main file. Only its
+// deleted_at field tells it apart from a record line.
+type tombstone struct {
+	ID        int64     `json:"links_num"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// DeleteRecord appends a tombstone for the record to the main file. Reads
+// skip the record from then on and the next Compact drops its lines, keeping
+// the tombstone so the ID is not handed out again. Saving a record under a
+// deleted ID does not bring it back, UpsertRecords does. It fails with
+// repository.ErrRecordNotFound if the record is not stored.
+func (s *Storage) DeleteRecord(ctx context.Context, id int64) error {
+	err := s.checkWritable("delete record")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exists, err := s.recordExists(id)
+	if err != nil {
+		s.logger.Error("failed to delete record", zap.Int64("id", id), zap.Error(err))
+		return fmt.Errorf("failed to delete record %d: %w", id, err)
+	}
+
+	if !exists {
+		return fmt.Errorf("record with ID %d: %w", id, repository.ErrRecordNotFound)
+	}
+
+	data, err := s.marshalLine(tombstone{ID: id, DeletedAt: s.clock.Now().UTC()})
+	if err != nil {
+		s.logger.Error("failed to marshal tombstone", zap.Int64("id", id), zap.Error(err))
+		return fmt.Errorf("failed to marshal tombstone: %w", err)
+	}
+
+	data = append(data, '\n')
+	offset := s.mainSize()
+
+	_, err = s.main.Write(data)
+	if err != nil {
+		s.logger.Error("failed to write tombstone", zap.String("path", s.path), zap.Error(err))
+		return fmt.Errorf("failed to write tombstone: %s: %w", s.path, err)
+	}
+
+	// Indexing the tombstone marks the record deleted. It is marked here as
+	// well in case the index cannot be written.
+	s.extendIndex(offset, bytes.NewReader(data))
+	s.markDeleted(id)
+	s.countRecords()
+	s.rotateIfFull()
+
+	s.emitEvent(id, domain.EventTypeDeleted, "")
+
+	s.logger.Info("successfully deleted record", zap.Int64("id", id))
+	return nil
+}
+
+// recordExists reports whether a record with the ID is stored and not
+// deleted. The caller must hold the lock.
+func (s *Storage) recordExists(id int64) (bool, error) {
+	if s.isDeleted(id) {
+		return false, nil
+	}
+
+	if _, ok := s.offsets[id]; ok {
+		return true, nil
+	}
+
+	// Records after a malformed line are not indexed in strict mode.
+	stored, err := s.storedIDs()
+	if err != nil {
+		return false, err
+	}
+
+	_, ok := stored[id]
+	return ok, nil
+}
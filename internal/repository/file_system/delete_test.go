@@ -0,0 +1,131 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+func TestDeleteRecord(t *testing.T) {
+	storage := newTestStorage(t)
+
+	for id := int64(1); id <= 3; id++ {
+		err := storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{"a.com": "available"}, ID: id})
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, storage.DeleteRecord(context.Background(), 2))
+	assert.Equal(t, int64(2), storage.Len())
+	assert.Equal(t, int64(3), storage.LoadLastLinksNum(context.Background()))
+
+	_, err := storage.GetRecord(context.Background(), 2)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	err = storage.DeleteRecord(context.Background(), 2)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	err = storage.DeleteRecord(context.Background(), 4)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	ids, err := storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 3}, ids)
+
+	records, err := storage.GetAllRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	found, err := storage.GetRecords(context.Background(), []int64{1, 2})
+	assert.NoError(t, err)
+	assert.Contains(t, found, int64(1))
+	assert.NotContains(t, found, int64(2))
+
+	var buf bytes.Buffer
+	_, err = storage.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte{'\n'}))
+
+	events, err := storage.GetRecordEvents(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.EventTypeDeleted, events[len(events)-1].EventType)
+
+	// The tombstone keeps the ID taken.
+	assert.NoError(t, storage.DeleteRecord(context.Background(), 3))
+	assert.Equal(t, int64(3), storage.LoadLastLinksNum(context.Background()))
+}
+
+func TestDeleteRecordSurvivesRestart(t *testing.T) {
+	cfg := newTestConfig(t)
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	for id := int64(1); id <= 2; id++ {
+		err = storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{"a.com": "available"}, ID: id})
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, storage.DeleteRecord(context.Background(), 1))
+	assert.NoError(t, storage.Close())
+
+	// Once from the index file and once from the main file.
+	for _, reindex := range []bool{false, true} {
+		storage, err = New(cfg, clock.NewFake(testNow), zap.NewNop())
+		assert.NoError(t, err)
+
+		if reindex {
+			assert.NoError(t, storage.Reindex())
+		}
+
+		_, err = storage.GetRecord(context.Background(), 1)
+		assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+		rec, err := storage.GetRecord(context.Background(), 2)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), rec.ID)
+
+		// The tombstone is not counted as a record.
+		assert.Equal(t, int64(1), storage.Len())
+
+		assert.NoError(t, storage.Close())
+	}
+}
+
+func TestCompactDropsDeletedRecords(t *testing.T) {
+	storage := newTestStorage(t)
+
+	for _, rec := range []*domain.Record{
+		{Links: map[string]string{"a.com": "available"}, ID: 1},
+		{Links: map[string]string{"b.com": "available"}, ID: 2},
+	} {
+		assert.NoError(t, storage.SaveRecord(context.Background(), rec))
+	}
+
+	assert.NoError(t, storage.DeleteRecord(context.Background(), 1))
+
+	dropped, err := storage.Compact()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, dropped)
+	assert.Equal(t, int64(1), storage.Len())
+
+	_, err = storage.GetRecord(context.Background(), 1)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	ids, err := storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2}, ids)
+
+	// Upserting the ID replaces the tombstone.
+	_, _, err = storage.UpsertRecords(context.Background(), []*domain.Record{{Links: map[string]string{"c.com": "available"}, ID: 1}})
+	assert.NoError(t, err)
+
+	rec, err := storage.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"c.com": "available"}, rec.Links)
+}
@@ -0,0 +1,9 @@
+//go:build linux || darwin
+
+package filesystem
+
+import "syscall"
+
+// dataSyncFlag makes each write return once the data reaches the disk
+// without waiting for the metadata.
+const dataSyncFlag = syscall.O_DSYNC
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package filesystem
+
+import "os"
+
+// dataSyncFlag falls back to O_SYNC where O_DSYNC is not available.
+const dataSyncFlag = os.O_SYNC
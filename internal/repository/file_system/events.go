@@ -0,0 +1,107 @@
+package filesystem
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+)
+
+// SaveRecordEvent appends the event to the events file. A missing OccurredAt
+// is set from the storage clock.
+func (s *Storage) SaveRecordEvent(ctx context.Context, event *domain.RecordEvent) error {
+	err := s.checkWritable("save record event")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.saveEvent(event)
+}
+
+// GetRecordEvents returns the events of the record in the order they were
+// written. Malformed lines are skipped.
+func (s *Storage) GetRecordEvents(ctx context.Context, id int64) ([]domain.RecordEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]domain.RecordEvent, 0)
+
+	file, err := s.openForRead(s.eventsPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return events, nil
+	}
+	if err != nil {
+		s.logger.Error("failed to open file", zap.String("path", s.eventsPath), zap.Error(err))
+		return nil, fmt.Errorf("failed to open file: %s: %w", s.eventsPath, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event domain.RecordEvent
+		err = s.unmarshalLine(scanner.Bytes(), &event)
+		if err != nil {
+			continue
+		}
+
+		if event.RecordID == id {
+			events = append(events, event)
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		s.logger.Error("failed to scan file", zap.String("path", s.eventsPath), zap.Error(err))
+		return nil, fmt.Errorf("failed to scan file: %s: %w", s.eventsPath, err)
+	}
+
+	return events, nil
+}
+
+// emitEvent records a lifecycle event of a record that was just written. The
+// record itself is already stored, so a failure is only logged. The caller
+// must hold the lock.
+func (s *Storage) emitEvent(id int64, eventType, detail string) {
+	err := s.saveEvent(&domain.RecordEvent{
+		RecordID:  id,
+		EventType: eventType,
+		Detail:    detail,
+	})
+	if err != nil {
+		s.logger.Warn("failed to save record event",
+			zap.Int64("id", id),
+			zap.String("event_type", eventType),
+			zap.Error(err),
+		)
+	}
+}
+
+// saveEvent appends the event as a JSON line to the events file, sealed like
+// the record lines since the details of checked events hold the links. The
+// caller must hold the lock.
+func (s *Storage) saveEvent(event *domain.RecordEvent) error {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = s.clock.Now().UTC()
+	}
+
+	data, err := s.marshalLine(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	_, err = s.events.Write(append(data, '\n'))
+	if err != nil {
+		s.logger.Error("failed to write event", zap.String("path", s.eventsPath), zap.Error(err))
+		return fmt.Errorf("failed to write event: %s: %w", s.eventsPath, err)
+	}
+
+	return nil
+}
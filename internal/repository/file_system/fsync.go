@@ -0,0 +1,188 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// openAppenders opens the append handles of the main, temp, events and index
+// files.
+func (s *Storage) openAppenders(flag int, direct bool) error {
+	var err error
+
+	for _, f := range []struct {
+		a    **appender
+		path string
+	}{
+		{&s.main, s.path},
+		{&s.temp, s.tempPath},
+		{&s.events, s.eventsPath},
+		{&s.index, s.indexPath},
+	} {
+		*f.a, err = openAppender(f.path, flag, s.fileMode, direct)
+		if err != nil {
+			s.logger.Error("failed to open file", zap.String("path", f.path), zap.Error(err))
+			s.closeAppenders()
+			return fmt.Errorf("failed to open file: %s: %w", f.path, err)
+		}
+	}
+
+	return nil
+}
+
+// appenderFor returns the append handle of the file at path, or nil if the
+// storage does not append to it.
+func (s *Storage) appenderFor(path string) *appender {
+	for _, a := range s.appenders() {
+		if a.path == path {
+			return a
+		}
+	}
+
+	return nil
+}
+
+// appenders returns the open append handles.
+func (s *Storage) appenders() []*appender {
+	appenders := make([]*appender, 0, 4)
+	for _, a := range []*appender{s.main, s.temp, s.events, s.index} {
+		if a != nil {
+			appenders = append(appenders, a)
+		}
+	}
+
+	return appenders
+}
+
+// openForRead opens a read-only handle of the file at path after flushing the
+// appends buffered for it. Every reader gets its own handle, so concurrent
+// readers do not share a file offset. The caller must hold the lock, at
+// least for reading.
+func (s *Storage) openForRead(path string) (*os.File, error) {
+	if a := s.appenderFor(path); a != nil {
+		err := a.Flush()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return os.Open(path)
+}
+
+// every runs fn every interval until Close.
+func (s *Storage) every(interval time.Duration, fn func()) {
+	if s.stop == nil {
+		s.stop = make(chan struct{})
+	}
+
+	stop := s.stop
+	s.loops.Add(1)
+
+	go func() {
+		defer s.loops.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				fn()
+			}
+		}
+	}()
+}
+
+// Close stops the background loops, takes a last snapshot if snapshots are
+// enabled, writes out the buffered appends, syncs them under
+// SyncPolicyInterval, closes the files and releases the lock of the storage
+// dir. Writes fail afterwards.
+func (s *Storage) Close() error {
+	if s.stop != nil {
+		close(s.stop)
+		s.loops.Wait()
+		s.stop = nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	// The last snapshot is only taken by the first Close.
+	if s.snapshotInterval > 0 && s.main != nil {
+		err = s.snapshot()
+		s.snapshotInterval = 0
+	}
+
+	if s.syncInterval > 0 {
+		err = errors.Join(err, s.syncPending())
+	}
+
+	err = errors.Join(err, s.closeAppenders())
+
+	// The lock is released last, once nothing is appended anymore.
+	if s.lock != nil {
+		err = errors.Join(err, s.lock.Close())
+		s.lock = nil
+	}
+
+	return err
+}
+
+func (s *Storage) closeAppenders() error {
+	var errs []error
+	for _, a := range s.appenders() {
+		err := a.Close()
+		if err != nil {
+			s.logger.Error("failed to close file", zap.String("path", a.path), zap.Error(err))
+			errs = append(errs, fmt.Errorf("failed to close file: %s: %w", a.path, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// flushScheduled is the FlushInterval loop body.
+func (s *Storage) flushScheduled() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, a := range s.appenders() {
+		err := a.Flush()
+		if err != nil {
+			s.logger.Warn("scheduled flush failed", zap.String("path", a.path), zap.Error(err))
+		}
+	}
+}
+
+// syncScheduled is the SyncInterval loop body.
+func (s *Storage) syncScheduled() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.syncPending()
+	if err != nil {
+		s.logger.Warn("scheduled sync failed", zap.Error(err))
+	}
+}
+
+// syncPending fsyncs every file appended to since the last sync. A file that
+// fails to sync stays pending. The caller must hold the lock.
+func (s *Storage) syncPending() error {
+	var errs []error
+	for _, a := range s.appenders() {
+		err := a.Sync()
+		if err != nil {
+			s.logger.Error("failed to sync file", zap.String("path", a.path), zap.Error(err))
+			errs = append(errs, fmt.Errorf("failed to sync file: %s: %w", a.path, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,492 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+)
+
+// The index file maps record IDs to the byte offsets of their lines in the
+// main file, and the index file of every sealed segment to their offsets in
+// the segment. Every entry is the big-endian ID followed by the big-endian
+// offset. The index is loaded into memory on startup and kept in memory as
+// lines are appended, so GetRecord seeks straight to the line. The index only
+// speeds up GetRecord: a lookup is checked against the main file and falls
+// back to a scan when the index is missing, corrupt or out of sync, so index
+// failures never fail a write.
+const indexEntrySize = 16
+
+// tombstoneEntry and updateEntry are set in the offset of the index entries
+// of tombstones and update lines, see DeleteRecord and UpdateRecord.
+const (
+	tombstoneEntry uint64 = 1 << 63
+	updateEntry    uint64 = 1 << 62
+)
+
+// lineKind tells the lines of the main file apart, see lineEntry.
+type lineKind int
+
+const (
+	recordLine lineKind = iota
+	tombstoneLine
+	updateLine
+)
+
+// reindexLogInterval is the number of indexed records between progress logs
+// of Reindex.
+const reindexLogInterval = 10000
+
+// Reindex rebuilds the index files from the main file and its segments and
+// atomically replaces the old ones. It recovers an index that became corrupt
+// or out of sync.
+func (s *Storage) Reindex() error {
+	err := s.checkWritable("reindex")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.reindex()
+}
+
+func (s *Storage) reindex() error {
+	s.resetIndex()
+
+	var base int64
+	for _, seg := range s.segments {
+		err := s.rebuildIndex(s.segmentPath(seg), s.segmentIndexPath(seg), base)
+		if err != nil {
+			return err
+		}
+
+		base += seg.Size
+	}
+
+	err := s.rebuildIndex(s.path, s.indexPath, base)
+	if err != nil {
+		return err
+	}
+
+	if s.index != nil {
+		err = s.index.reopen()
+		if err != nil {
+			s.logger.Error("failed to reopen file", zap.String("path", s.indexPath), zap.Error(err))
+			return fmt.Errorf("failed to reopen file: %s: %w", s.indexPath, err)
+		}
+	}
+
+	return nil
+}
+
+// rebuildIndex indexes the file at path, which starts at base in the main
+// file, and atomically replaces its index file.
+func (s *Storage) rebuildIndex(path, indexPath string, base int64) error {
+	src, err := s.openMainFile(path)
+	if err != nil {
+		s.logger.Error("failed to open file", zap.String("path", path), zap.Error(err))
+		return fmt.Errorf("failed to open file: %s: %w", path, err)
+	}
+	defer src.Close()
+
+	tmpPath := indexPath + ".new"
+	dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, s.fileMode)
+	if err != nil {
+		s.logger.Error("failed to create file", zap.String("path", tmpPath), zap.Error(err))
+		return fmt.Errorf("failed to create file: %s: %w", tmpPath, err)
+	}
+	defer os.Remove(tmpPath)
+	defer dst.Close()
+
+	s.logger.Info("rebuilding index", zap.String("path", indexPath))
+
+	w := bufio.NewWriter(dst)
+	indexed, err := s.indexLines(src, base, 0, w, true)
+	if err != nil {
+		s.logger.Error("failed to index records", zap.String("path", path), zap.Error(err))
+		return fmt.Errorf("failed to index records: %s: %w", path, err)
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to flush file: %s: %w", tmpPath, err)
+	}
+
+	err = dst.Sync()
+	if err != nil {
+		return fmt.Errorf("failed to sync file: %s: %w", tmpPath, err)
+	}
+
+	err = dst.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close file: %s: %w", tmpPath, err)
+	}
+
+	err = os.Rename(tmpPath, indexPath)
+	if err != nil {
+		s.logger.Error("failed to replace index", zap.String("path", indexPath), zap.Error(err))
+		return fmt.Errorf("failed to rename file: %s: %w", tmpPath, err)
+	}
+
+	s.logger.Info("index rebuilt", zap.String("path", indexPath), zap.Int("records", indexed))
+	return nil
+}
+
+// indexLines writes an index entry to w for every record line read from r.
+// offset is the position of the first byte of r in its file and base the
+// position of that file in the main file: the entries written to w hold
+// offsets in the file, the in-memory index offsets in the main file. In strict
+// mode indexing stops at the first malformed line, so lookups of the records
+// after it scan the file and report the line like before.
+func (s *Storage) indexLines(r io.Reader, base, offset int64, w io.Writer, logProgress bool) (int, error) {
+	br := bufio.NewReader(r)
+
+	var entry [indexEntrySize]byte
+	indexed := 0
+
+	for {
+		line, err := br.ReadBytes('\n')
+
+		id, kind, ok := s.lineEntry(line)
+		if !ok && s.strictDecode && len(bytes.TrimSpace(line)) > 0 {
+			s.logger.Warn("malformed record, the records after it are not indexed", zap.Int64("offset", offset))
+			return indexed, nil
+		}
+
+		if ok {
+			entryOffset := uint64(offset)
+			switch kind {
+			case tombstoneLine:
+				s.markDeleted(id)
+				entryOffset |= tombstoneEntry
+			case updateLine:
+				s.markUpdated(id, base+offset)
+				entryOffset |= updateEntry
+			default:
+				s.addOffset(id, base+offset)
+			}
+
+			binary.BigEndian.PutUint64(entry[:8], uint64(id))
+			binary.BigEndian.PutUint64(entry[8:], entryOffset)
+
+			_, werr := w.Write(entry[:])
+			if werr != nil {
+				return indexed, werr
+			}
+
+			indexed++
+			if logProgress && indexed%reindexLogInterval == 0 {
+				s.logger.Info("indexing records", zap.Int("records", indexed))
+			}
+		}
+
+		offset += int64(len(line))
+
+		if errors.Is(err, io.EOF) {
+			return indexed, nil
+		}
+		if err != nil {
+			return indexed, err
+		}
+	}
+}
+
+// extendIndex indexes the lines read from r, which were just appended to the
+// main file at offset. Failures are only logged since the index is rebuilt
+// by Reindex. The caller must hold the lock.
+func (s *Storage) extendIndex(offset int64, r io.Reader) {
+	err := s.appendIndex(offset, r)
+	if err != nil {
+		s.logger.Warn("failed to update index, run a reindex", zap.String("path", s.indexPath), zap.Error(err))
+	}
+}
+
+func (s *Storage) appendIndex(offset int64, r io.Reader) error {
+	base := s.sealedSize()
+	_, err := s.indexLines(r, base, offset-base, s.index, false)
+	return err
+}
+
+// indexedRecord reads the record at the offset indexed for the ID. It
+// reports false when the record cannot be found that way and the main file
+// has to be scanned.
+func (s *Storage) indexedRecord(id int64) (*domain.Record, bool) {
+	offset, ok := s.offsets[id]
+	if !ok {
+		return nil, false
+	}
+
+	rec, err := s.readRecordAt(offset)
+	if errors.Is(err, errChecksumMismatch) {
+		s.logger.Error("skipping corrupt record",
+			zap.String("path", s.path),
+			zap.Int64("id", id),
+			zap.Int64("offset", offset),
+			zap.Error(err),
+		)
+		return nil, false
+	}
+	if err != nil || rec.ID != id {
+		s.logger.Warn("index is out of sync with the main file, run a reindex",
+			zap.String("path", s.indexPath),
+			zap.Int64("id", id),
+			zap.Int64("offset", offset),
+		)
+		return nil, false
+	}
+
+	return rec, true
+}
+
+// loadOffsets fills the in-memory index from the index files. Without usable
+// index files, e.g. in read-only mode, the main file is indexed in memory
+// only.
+func (s *Storage) loadOffsets() error {
+	s.resetIndex()
+
+	if s.validIndexes() {
+		err := s.readIndexes()
+		if err == nil {
+			return nil
+		}
+
+		s.logger.Warn("failed to read index, indexing the main file", zap.String("path", s.indexPath), zap.Error(err))
+		s.resetIndex()
+	}
+
+	file, err := s.openMain()
+	if err != nil {
+		s.logger.Error("failed to open file", zap.String("path", s.path), zap.Error(err))
+		return fmt.Errorf("failed to open file: %s: %w", s.path, err)
+	}
+	defer file.Close()
+
+	_, err = s.indexLines(file, 0, 0, io.Discard, true)
+	if err != nil {
+		s.logger.Error("failed to index records", zap.String("path", s.path), zap.Error(err))
+		return fmt.Errorf("failed to index records: %s: %w", s.path, err)
+	}
+
+	return nil
+}
+
+// readIndexes reads the index files of the sealed segments and the main file
+// in order.
+func (s *Storage) readIndexes() error {
+	var base int64
+	for _, seg := range s.segments {
+		err := s.readOffsets(s.segmentIndexPath(seg), base)
+		if err != nil {
+			return err
+		}
+
+		base += seg.Size
+	}
+
+	return s.readOffsets(s.indexPath, base)
+}
+
+// readOffsets reads the index file at path of a file that starts at base in
+// the main file.
+func (s *Storage) readOffsets(path string, base int64) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+
+	var entry [indexEntrySize]byte
+	for {
+		_, err = io.ReadFull(r, entry[:])
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		id := int64(binary.BigEndian.Uint64(entry[:8]))
+		offset := binary.BigEndian.Uint64(entry[8:])
+		switch {
+		case offset&tombstoneEntry != 0:
+			s.markDeleted(id)
+		case offset&updateEntry != 0:
+			s.markUpdated(id, base+int64(offset&^updateEntry))
+		default:
+			s.addOffset(id, base+int64(offset))
+		}
+	}
+}
+
+// resetIndex empties the in-memory index before it is filled again.
+func (s *Storage) resetIndex() {
+	s.offsets = make(map[int64]int64)
+	s.deleted = make(map[int64]struct{})
+	s.updated = make(map[int64]int64)
+}
+
+// addOffset records the offset of a line of the ID unless an earlier line is
+// already indexed, since lookups return the first stored record of an ID
+// until it is updated. Lines of deleted records are not indexed.
+func (s *Storage) addOffset(id, offset int64) {
+	if _, ok := s.deleted[id]; ok {
+		return
+	}
+
+	if _, ok := s.offsets[id]; !ok {
+		s.offsets[id] = offset
+	}
+}
+
+// markDeleted hides the record from reads after its tombstone was read or
+// written.
+func (s *Storage) markDeleted(id int64) {
+	delete(s.offsets, id)
+	delete(s.updated, id)
+	s.deleted[id] = struct{}{}
+}
+
+// markUpdated makes the update line at offset the current version of the
+// record, superseding its earlier lines.
+func (s *Storage) markUpdated(id, offset int64) {
+	if _, ok := s.deleted[id]; ok {
+		return
+	}
+
+	s.offsets[id] = offset
+	s.updated[id] = offset
+}
+
+// countRecords sets the count reported by Len to the number of live record
+// IDs, so tombstones and update lines are not counted. The caller must hold
+// the lock.
+func (s *Storage) countRecords() {
+	s.records.Store(int64(len(s.offsets)))
+}
+
+// isDeleted reports whether the record has a tombstone. The caller must hold
+// the lock.
+func (s *Storage) isDeleted(id int64) bool {
+	_, ok := s.deleted[id]
+	return ok
+}
+
+// current reports whether the line of the ID at offset is read: the lines of
+// deleted records are not, and of an updated record only its latest update
+// line is. The caller must hold the lock.
+func (s *Storage) current(id, offset int64) bool {
+	if s.isDeleted(id) {
+		return false
+	}
+
+	latest, ok := s.updated[id]
+	return !ok || latest == offset
+}
+
+// readRecordAt reads the record at the offset in the main file from the
+// segment holding it.
+func (s *Storage) readRecordAt(offset int64) (*domain.Record, error) {
+	paths, bases := s.mainFiles()
+	i := fileAt(bases, offset)
+
+	file, err := s.openMainFile(paths[i])
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	_, err = file.Seek(offset-bases[i], io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	var rec domain.Record
+	err = s.unmarshalLine(line, &rec)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rec, nil
+}
+
+// validIndex reports whether the index file exists and holds whole entries.
+func validIndex(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	return info.Size()%indexEntrySize == 0
+}
+
+// lineRecordID decodes only the links_num field of a line of the main file.
+func (s *Storage) lineRecordID(line []byte) (int64, bool) {
+	id, _, ok := s.lineEntry(line)
+	return id, ok
+}
+
+// lineEntry decodes the links_num field of a line and tells a tombstone or an
+// update line from a record line.
+func (s *Storage) lineEntry(line []byte) (int64, lineKind, bool) {
+	line, err := s.openLine(line)
+	if err != nil {
+		return 0, recordLine, false
+	}
+
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return 0, recordLine, false
+	}
+
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(line, &fields)
+	if err != nil {
+		return 0, recordLine, false
+	}
+
+	var id int64
+	err = json.Unmarshal(fields["links_num"], &id)
+	if err != nil {
+		return 0, recordLine, false
+	}
+
+	switch {
+	case fields["deleted_at"] != nil:
+		return id, tombstoneLine, true
+	case fields["supersedes"] != nil:
+		return id, updateLine, true
+	default:
+		return id, recordLine, true
+	}
+}
+
+// splitLines is bufio.ScanLines keeping the line endings, so the tokens add
+// up to the offsets of the lines in the file.
+func splitLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
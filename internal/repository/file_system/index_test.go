@@ -0,0 +1,146 @@
+package filesystem
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+)
+
+func TestIndexFollowsWrites(t *testing.T) {
+	storage := newTestStorage(t)
+
+	err := storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{"a.com": "available"}, ID: 1})
+	assert.NoError(t, err)
+
+	err = storage.SaveRecords(context.Background(), []*domain.Record{
+		{Links: map[string]string{"b.com": "available"}, ID: 2},
+		{Links: map[string]string{"c.com": "available"}, ID: 3},
+	})
+	assert.NoError(t, err)
+
+	_, err = storage.ReadFrom(strings.NewReader(`{"links":{"d.com":"available"}}` + "\n"))
+	assert.NoError(t, err)
+
+	_, _, err = storage.UpsertRecords(context.Background(), []*domain.Record{{Links: map[string]string{"a.com": "not available"}, ID: 1}})
+	assert.NoError(t, err)
+
+	for id := int64(1); id <= 4; id++ {
+		rec, ok := storage.indexedRecord(id)
+		assert.True(t, ok, "record %d is not indexed", id)
+		assert.Equal(t, id, rec.ID)
+	}
+
+	rec, _ := storage.indexedRecord(1)
+	assert.Equal(t, "not available", rec.Links["a.com"])
+}
+
+func TestReindex(t *testing.T) {
+	storage := newTestStorage(t)
+
+	records := make([]*domain.Record, 0, 5)
+	for id := int64(1); id <= 5; id++ {
+		records = append(records, &domain.Record{Links: map[string]string{"a.com": "available"}, ID: id})
+	}
+	err := storage.SaveRecords(context.Background(), records)
+	assert.NoError(t, err)
+
+	// Point every entry at the wrong line and cut the last one short.
+	assert.NoError(t, storage.index.Flush())
+	data, err := os.ReadFile(storage.indexPath)
+	assert.NoError(t, err)
+	for i := indexEntrySize; i < len(data); i += indexEntrySize {
+		copy(data[i+8:i+indexEntrySize], data[8:indexEntrySize])
+	}
+	err = os.WriteFile(storage.indexPath, data[:len(data)-3], 0644)
+	assert.NoError(t, err)
+
+	for id := range storage.offsets {
+		storage.offsets[id] = 0
+	}
+	delete(storage.offsets, 5)
+
+	_, ok := storage.indexedRecord(3)
+	assert.False(t, ok)
+
+	// The lookup falls back to a scan while the index is broken.
+	rec, err := storage.GetRecord(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), rec.ID)
+
+	err = storage.Reindex()
+	assert.NoError(t, err)
+
+	info, err := os.Stat(storage.indexPath)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5*indexEntrySize), info.Size())
+
+	for id := int64(1); id <= 5; id++ {
+		rec, ok := storage.indexedRecord(id)
+		assert.True(t, ok)
+		assert.Equal(t, id, rec.ID)
+
+		rec, err = storage.GetRecord(context.Background(), id)
+		assert.NoError(t, err)
+		assert.Equal(t, id, rec.ID)
+	}
+}
+
+func TestNewRebuildsMissingIndex(t *testing.T) {
+	cfg := newTestConfig(t)
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	err = storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{}, ID: 7})
+	assert.NoError(t, err)
+
+	assert.NoError(t, storage.Close())
+
+	err = os.Remove(storage.indexPath)
+	assert.NoError(t, err)
+
+	storage, err = New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	rec, ok := storage.indexedRecord(7)
+	assert.True(t, ok)
+	assert.Equal(t, int64(7), rec.ID)
+}
+
+func TestNewLoadsIndex(t *testing.T) {
+	cfg := newTestConfig(t)
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	for id := int64(1); id <= 3; id++ {
+		err = storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{}, ID: id})
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, storage.Close())
+
+	storage, err = New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	assert.Len(t, storage.offsets, 3)
+	assert.NoError(t, storage.Close())
+
+	// Without an index file, read-only mode indexes the main file in memory
+	// and leaves the directory untouched.
+	err = os.Remove(storage.indexPath)
+	assert.NoError(t, err)
+
+	cfg.ReadOnly = true
+	storage, err = New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	assert.NoFileExists(t, storage.indexPath)
+
+	rec, ok := storage.indexedRecord(3)
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), rec.ID)
+}
@@ -0,0 +1,34 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lockFileName is the file in the storage dir that a writable storage holds
+// an advisory lock on, so two processes never append to the same files.
+const lockFileName = ".lock"
+
+// errDirLocked reports that another storage holds the lock of the dir.
+var errDirLocked = errors.New("storage dir is locked by another process")
+
+// lockDir creates the lock file of the dir if needed and locks it. Closing
+// the returned file releases the lock; the lock file itself is kept.
+func lockDir(dirPath string, fileMode os.FileMode) (*os.File, error) {
+	path := filepath.Join(dirPath, lockFileName)
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, fileMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %s: %w", path, err)
+	}
+
+	err = lockFile(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	return file, nil
+}
@@ -0,0 +1,48 @@
+//go:build linux || darwin || windows
+
+package filesystem
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+func TestDirLock(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	first, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	_, err = New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.ErrorIs(t, err, errDirLocked)
+
+	// A read-only storage does not need the lock.
+	readOnlyCfg := *cfg
+	readOnlyCfg.ReadOnly = true
+	readOnly, err := New(&readOnlyCfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	assert.NoError(t, readOnly.Close())
+
+	fallbackCfg := *cfg
+	fallbackCfg.ReadOnlyIfLocked = true
+	fallback, err := New(&fallbackCfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	err = fallback.SaveRecord(context.Background(), &domain.Record{ID: 1})
+	assert.ErrorIs(t, err, repository.ErrReadOnlyStorage)
+	assert.NoError(t, fallback.Close())
+
+	// Close releases the lock.
+	assert.NoError(t, first.Close())
+
+	second, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	assert.NoError(t, second.Close())
+}
@@ -1,12 +1,61 @@
 package filesystem
 
-import "link-service/internal/domain"
+import (
+	"context"
+	"time"
+
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
 
 type MockStorage struct{}
 
 func NewMockStorage() *MockStorage { return &MockStorage{} }
 
 func (ms *MockStorage) Init(dirPath string, fileName string, tempFileName string) error { return nil }
-func (ms *MockStorage) SaveRecord(record *domain.Record) error                          { return nil }
-func (ms *MockStorage) SaveTempRecord(record *domain.Record) error                      { return nil }
-func (ms *MockStorage) LoadLastLinksNum() int64                                         { return 0 }
+func (ms *MockStorage) SaveRecord(ctx context.Context, record *domain.Record) error     { return nil }
+func (ms *MockStorage) SaveRecords(ctx context.Context, records []*domain.Record) error { return nil }
+func (ms *MockStorage) SaveTempRecord(ctx context.Context, record *domain.Record) error { return nil }
+func (ms *MockStorage) UpsertRecords(ctx context.Context, records []*domain.Record) (int, int, error) {
+	return 0, 0, nil
+}
+func (ms *MockStorage) UpdateRecord(ctx context.Context, record *domain.Record) error { return nil }
+func (ms *MockStorage) DeleteRecord(ctx context.Context, id int64) error              { return nil }
+func (ms *MockStorage) LoadTempRecords(ctx context.Context) ([]domain.Record, error)  { return nil, nil }
+func (ms *MockStorage) GetRecord(ctx context.Context, id int64) (*domain.Record, error) {
+	return nil, repository.ErrRecordNotFound
+}
+func (ms *MockStorage) GetRecords(ctx context.Context, ids []int64) (map[int64]*domain.Record, error) {
+	return map[int64]*domain.Record{}, nil
+}
+func (ms *MockStorage) GetAllRecords(ctx context.Context, opts ...repository.FilterOption) ([]domain.Record, error) {
+	return nil, nil
+}
+func (ms *MockStorage) GetRecordsBySource(ctx context.Context, source string) ([]domain.Record, error) {
+	return nil, nil
+}
+func (ms *MockStorage) GetRecordsSince(ctx context.Context, since time.Time) ([]domain.Record, error) {
+	return nil, nil
+}
+func (ms *MockStorage) FindRecordsByTag(ctx context.Context, tag string) ([]domain.Record, error) {
+	return nil, nil
+}
+func (ms *MockStorage) SearchRecords(ctx context.Context, query repository.SearchQuery) ([]domain.Record, error) {
+	return nil, nil
+}
+func (ms *MockStorage) ForEachRecord(ctx context.Context, fn func(rec *domain.Record) error) error {
+	return nil
+}
+func (ms *MockStorage) ListRecords(ctx context.Context, offset, limit int, order string) ([]domain.Record, error) {
+	return nil, nil
+}
+func (ms *MockStorage) ListRecordIDs(ctx context.Context) ([]int64, error) { return nil, nil }
+func (ms *MockStorage) ClearTempFile(ctx context.Context) error            { return nil }
+func (ms *MockStorage) LoadLastLinksNum(ctx context.Context) int64         { return 0 }
+func (ms *MockStorage) Len() int64                                         { return 0 }
+func (ms *MockStorage) SaveRecordEvent(ctx context.Context, event *domain.RecordEvent) error {
+	return nil
+}
+func (ms *MockStorage) GetRecordEvents(ctx context.Context, id int64) ([]domain.RecordEvent, error) {
+	return nil, nil
+}
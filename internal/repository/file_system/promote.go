@@ -0,0 +1,142 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+)
+
+// PromoteTempRecords stores the records processed from the temp records,
+// clears the temp file and returns the number of records stored. Missing
+// timestamps are set from the storage clock.
+//
+// The records are synced to the main file before the temp file is cleared,
+// and records whose ID is already in the main file are skipped. A crash part
+// way through therefore neither loses temp records nor stores them twice:
+// processing the temp records again finishes it.
+func (s *Storage) PromoteTempRecords(ctx context.Context, records []*domain.Record) (int, error) {
+	err := s.checkWritable("promote temp records")
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	promoted, err := s.storePromoted(records)
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.clearTempFile()
+	if err != nil {
+		return promoted, err
+	}
+
+	s.logger.Info("successfully promoted temp records", zap.Int("count", promoted))
+	return promoted, nil
+}
+
+// storePromoted appends the records whose ID is not in the main file yet and
+// syncs them, see PromoteTempRecords. It returns the number of records
+// stored. The caller must hold the lock.
+func (s *Storage) storePromoted(records []*domain.Record) (int, error) {
+	stored, err := s.storedIDs()
+	if err != nil {
+		s.logger.Error("failed to promote temp records", zap.String("path", s.path), zap.Error(err))
+		return 0, err
+	}
+
+	now := s.clock.Now().UTC()
+
+	var (
+		buf      bytes.Buffer
+		promoted []int64
+	)
+	for _, rec := range records {
+		if _, ok := stored[rec.ID]; ok {
+			s.logger.Info("temp record already promoted", zap.Int64("id", rec.ID))
+			continue
+		}
+
+		if rec.CreatedAt.IsZero() {
+			rec.CreatedAt = now
+		}
+
+		if rec.UpdatedAt.IsZero() {
+			rec.UpdatedAt = rec.CreatedAt
+		}
+
+		data, err := s.marshalLine(rec)
+		if err != nil {
+			s.logger.Error("failed to marshal record", zap.Int64("id", rec.ID), zap.Error(err))
+			return 0, fmt.Errorf("failed to marshal record %d: %w", rec.ID, err)
+		}
+
+		buf.Write(data)
+		buf.WriteByte('\n')
+		promoted = append(promoted, rec.ID)
+	}
+
+	if len(promoted) > 0 {
+		offset := s.mainSize()
+		lines := buf.Bytes()
+
+		_, err = s.main.Write(lines)
+		if err != nil {
+			s.logger.Error("failed to write records", zap.String("path", s.path), zap.Error(err))
+			return 0, fmt.Errorf("failed to write records: %s: %w", s.path, err)
+		}
+
+		// The temp file is cleared next, so the records must be on disk
+		// whatever the sync policy is.
+		err = s.main.Sync()
+		if err != nil {
+			s.logger.Error("failed to sync file", zap.String("path", s.path), zap.Error(err))
+			return 0, fmt.Errorf("failed to sync file: %s: %w", s.path, err)
+		}
+
+		s.extendIndex(offset, bytes.NewReader(lines))
+		s.rotateIfFull()
+		s.countRecords()
+
+		for _, id := range promoted {
+			s.emitEvent(id, domain.EventTypeCreated, "")
+		}
+	}
+
+	return len(promoted), nil
+}
+
+// storedIDs returns the IDs of the records in the main file. It reads the
+// file rather than trusting the index, which may miss the last writes after
+// a crash. The caller must hold the lock.
+func (s *Storage) storedIDs() (map[int64]struct{}, error) {
+	file, err := s.openMain()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %s: %w", s.path, err)
+	}
+	defer file.Close()
+
+	ids := make(map[int64]struct{})
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		id, ok := s.lineRecordID(scanner.Bytes())
+		if ok {
+			ids[id] = struct{}{}
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan file: %s: %w", s.path, err)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,66 @@
+package filesystem
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"link-service/internal/domain"
+)
+
+func TestPromoteTempRecords(t *testing.T) {
+	storage := newTestStorage(t)
+
+	err := storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{"a.com": "available"}, ID: 1})
+	assert.NoError(t, err)
+
+	assert.NoError(t, storage.SaveTempRecord(context.Background(), &domain.Record{Links: map[string]string{"b.com": "unknown"}, ID: 2}))
+
+	promoted, err := storage.PromoteTempRecords(context.Background(), []*domain.Record{
+		{Links: map[string]string{"b.com": "not available"}, ID: 2},
+		{Links: map[string]string{"c.com": "available"}, ID: 3},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, promoted)
+	assert.Equal(t, int64(3), storage.Len())
+
+	rec, err := storage.GetRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"b.com": "not available"}, rec.Links)
+	assert.Equal(t, testNow, rec.CreatedAt)
+
+	records, err := storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	events, err := storage.GetRecordEvents(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+}
+
+func TestPromoteTempRecordsAfterCrash(t *testing.T) {
+	storage := newTestStorage(t)
+
+	// A promotion that stopped after writing the main file but before
+	// clearing the temp file.
+	rec := &domain.Record{Links: map[string]string{"a.com": "available"}, ID: 1}
+	assert.NoError(t, storage.SaveRecord(context.Background(), rec))
+	assert.NoError(t, storage.SaveTempRecord(context.Background(), rec))
+	assert.NoError(t, storage.SaveTempRecord(context.Background(), &domain.Record{Links: map[string]string{"b.com": "available"}, ID: 2}))
+
+	clear(storage.offsets)
+
+	records := []*domain.Record{rec, {Links: map[string]string{"b.com": "available"}, ID: 2}}
+	promoted, err := storage.PromoteTempRecords(context.Background(), records)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, promoted)
+
+	ids, err := storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, ids)
+
+	promoted, err = storage.PromoteTempRecords(context.Background(), records)
+	assert.NoError(t, err)
+	assert.Zero(t, promoted)
+}
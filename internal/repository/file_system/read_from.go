@@ -0,0 +1,144 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+)
+
+// ReadFrom implements io.ReaderFrom. It streams NDJSON records from r into the
+// main file, assigning each record the next ID after the last stored one, and
+// returns the number of bytes read. Malformed lines are handled according to
+// the strict decode setting. The service keeps its own ID counter, so ReadFrom
+// is meant for restores and CLI imports while the service is not running.
+func (s *Storage) ReadFrom(r io.Reader) (int64, error) {
+	err := s.checkWritable("read from")
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offset := s.mainSize()
+
+	counter := &countingReader{r: r}
+	w := bufio.NewWriter(s.main)
+
+	imported, readErr := s.readRecords(counter, w)
+
+	// Every buffered line is a complete record, so flushing after a read
+	// error keeps the file valid and the records read so far imported.
+	err = w.Flush()
+	if err != nil {
+		s.logger.Error("failed to write records", zap.String("path", s.path), zap.Error(err))
+		return counter.n, fmt.Errorf("failed to write records: %s: %w", s.path, err)
+	}
+
+	s.indexAppended(offset)
+	s.rotateIfFull()
+	s.countRecords()
+
+	for _, id := range imported {
+		s.emitEvent(id, domain.EventTypeCreated, "")
+	}
+
+	if readErr != nil {
+		s.logger.Error("failed to read records", zap.Int("imported", len(imported)), zap.Error(readErr))
+		return counter.n, readErr
+	}
+
+	s.logger.Info("successfully read records", zap.Int("count", len(imported)), zap.Int64("bytes", counter.n))
+	return counter.n, nil
+}
+
+// indexAppended indexes the lines of the main file from offset on. Failures
+// are only logged like in extendIndex. The caller must hold the lock.
+func (s *Storage) indexAppended(offset int64) {
+	file, err := s.openMain()
+	if err != nil {
+		s.logger.Warn("failed to update index, run a reindex", zap.String("path", s.indexPath), zap.Error(err))
+		return
+	}
+	defer file.Close()
+
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		s.logger.Warn("failed to update index, run a reindex", zap.String("path", s.indexPath), zap.Error(err))
+		return
+	}
+
+	s.extendIndex(offset, file)
+}
+
+// readRecords decodes NDJSON records from r and writes them to w under new
+// IDs. It returns the IDs written. The caller must hold the lock.
+func (s *Storage) readRecords(r io.Reader, w *bufio.Writer) ([]int64, error) {
+	now := s.clock.Now().UTC()
+	nextID := s.lastLinksNum()
+
+	var imported []int64
+	lineNum := 0
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineNum++
+
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var rec domain.Record
+		err := json.Unmarshal(line, &rec)
+		if err != nil {
+			if s.strictDecode {
+				return imported, fmt.Errorf("failed to decode record at line %d: %w", lineNum, err)
+			}
+
+			s.logger.Warn("skipping malformed record", zap.Int("line", lineNum), zap.Error(err))
+			continue
+		}
+
+		nextID++
+		rec.ID = nextID
+		if rec.CreatedAt.IsZero() {
+			rec.CreatedAt = now
+		}
+		if rec.UpdatedAt.IsZero() {
+			rec.UpdatedAt = rec.CreatedAt
+		}
+
+		err = s.writeRecord(w, &rec)
+		if err != nil {
+			return imported, err
+		}
+
+		imported = append(imported, rec.ID)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return imported, fmt.Errorf("failed to read records: %w", err)
+	}
+
+	return imported, nil
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,437 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// With a segment size, the main file is rotated once it reaches the size:
+// it is renamed to the next sealed segment, e.g. data-000001.json for the
+// main file data.json, together with its index file, and writes continue in
+// a new empty main file. The manifest lists the sealed segments in write
+// order. Sealed segments are only rewritten by Compact and UpsertRecords, so
+// they can be copied to cold storage; they must be in place while the
+// storage is open.
+//
+// Offsets in the main file span the sealed segments and the main file as if
+// they were a single file, see openMain. Every index file holds the offsets
+// in its own file, so a rewrite of a segment only shifts the offsets of the
+// later files in memory.
+
+// manifestSuffix is appended to the main file name to get the manifest path.
+const manifestSuffix = ".manifest"
+
+// segment is a sealed segment of the main file as listed in the manifest.
+type segment struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+type manifest struct {
+	Segments []segment `json:"segments"`
+}
+
+// segmentName returns the file name of the nth sealed segment of the main
+// file.
+func segmentName(fileName string, n int) string {
+	ext := filepath.Ext(fileName)
+	return fmt.Sprintf("%s-%06d%s", strings.TrimSuffix(fileName, ext), n, ext)
+}
+
+// isSegmentName reports whether name is the file name of a sealed segment of
+// the main file, plain or compressed.
+func isSegmentName(fileName, name string) bool {
+	for _, suffix := range compressionSuffixes {
+		if plain, ok := strings.CutSuffix(name, suffix); ok {
+			name = plain
+			break
+		}
+	}
+
+	ext := filepath.Ext(fileName)
+	num, ok := strings.CutPrefix(strings.TrimSuffix(name, ext), strings.TrimSuffix(fileName, ext)+"-")
+	if !ok {
+		return false
+	}
+
+	n, err := strconv.Atoi(num)
+	return err == nil && n > 0 && segmentName(fileName, n) == name
+}
+
+// segmentPath returns the path of the sealed segment.
+func (s *Storage) segmentPath(seg segment) string {
+	return filepath.Join(filepath.Dir(s.path), seg.Name)
+}
+
+// segmentIndexPath returns the path of the index file of the sealed segment.
+func (s *Storage) segmentIndexPath(seg segment) string {
+	return s.segmentPath(seg) + indexFileSuffix
+}
+
+// sealedSize returns the size of the sealed segments, which is the offset of
+// the main file proper in the main file. The caller must hold the lock.
+func (s *Storage) sealedSize() int64 {
+	var size int64
+	for _, seg := range s.segments {
+		size += seg.Size
+	}
+
+	return size
+}
+
+// mainSize returns the size of the main file across its segments, so it is
+// the offset the next append starts at. The caller must hold the lock.
+func (s *Storage) mainSize() int64 {
+	return s.sealedSize() + s.main.size
+}
+
+// loadSegments reads the manifest and takes the sizes of the segments from
+// their files. A segment left out of the manifest by a rotation that did not
+// finish is added to it, in read-only mode only in memory.
+func (s *Storage) loadSegments() error {
+	data, err := os.ReadFile(s.manifestPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read manifest: %s: %w", s.manifestPath, err)
+	}
+
+	var m manifest
+	if len(data) > 0 {
+		err = json.Unmarshal(data, &m)
+		if err != nil {
+			return fmt.Errorf("failed to decode manifest: %s: %w", s.manifestPath, err)
+		}
+	}
+
+	changed := false
+	for {
+		seg, ok := s.findSegment(len(m.Segments) + 1)
+		if !ok {
+			break
+		}
+
+		s.logger.Warn("found segment missing from the manifest, adding it", zap.String("segment", seg.Name))
+		if compressionOf(seg.Name) != CompressionNone {
+			seg.Size, err = s.decompressedSize(s.segmentPath(seg))
+			if err != nil {
+				return fmt.Errorf("failed to read segment: %s: %w", seg.Name, err)
+			}
+		}
+
+		m.Segments = append(m.Segments, seg)
+		changed = true
+	}
+
+	for i := range m.Segments {
+		seg := &m.Segments[i]
+
+		info, err := os.Stat(s.segmentPath(*seg))
+		if err != nil {
+			return fmt.Errorf("failed to open segment, restore it if it was archived: %w", err)
+		}
+
+		// The size of a compressed segment is the size of its data, which
+		// only the manifest keeps.
+		if compressionOf(seg.Name) == CompressionNone && info.Size() != seg.Size {
+			seg.Size = info.Size()
+			changed = true
+		}
+	}
+
+	s.segments = m.Segments
+
+	if changed && !s.readOnly {
+		return s.writeManifest()
+	}
+
+	return nil
+}
+
+// findSegment looks for the nth sealed segment, plain or compressed, in the
+// dir of the main file.
+func (s *Storage) findSegment(n int) (segment, bool) {
+	name := segmentName(filepath.Base(s.path), n)
+
+	names := []string{name}
+	for _, suffix := range compressionSuffixes {
+		names = append(names, name+suffix)
+	}
+
+	for _, name := range names {
+		seg := segment{Name: name}
+		if _, err := os.Stat(s.segmentPath(seg)); err == nil {
+			return seg, true
+		}
+	}
+
+	return segment{}, false
+}
+
+// validIndexes reports whether the index files of the main file and of every
+// sealed segment are valid, see validIndex.
+func (s *Storage) validIndexes() bool {
+	for _, seg := range s.segments {
+		if !validIndex(s.segmentIndexPath(seg)) {
+			return false
+		}
+	}
+
+	return validIndex(s.indexPath)
+}
+
+// writeManifest atomically replaces the manifest with the current segments.
+// The caller must hold the lock.
+func (s *Storage) writeManifest() error {
+	data, err := json.MarshalIndent(manifest{Segments: s.segments}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	return s.writeAtomic(s.manifestPath, append(data, '\n'))
+}
+
+// writeAtomic replaces the file at path with data through a synced temp
+// file, so readers find either the old or the new data.
+func (s *Storage) writeAtomic(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, s.fileMode)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %s: %w", tmpPath, err)
+	}
+	defer os.Remove(tmpPath)
+	defer file.Close()
+
+	_, err = file.Write(data)
+	if err != nil {
+		return fmt.Errorf("failed to write file: %s: %w", tmpPath, err)
+	}
+
+	err = file.Sync()
+	if err != nil {
+		return fmt.Errorf("failed to sync file: %s: %w", tmpPath, err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close file: %s: %w", tmpPath, err)
+	}
+
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return fmt.Errorf("failed to rename file: %s: %w", tmpPath, err)
+	}
+
+	return nil
+}
+
+// rotateIfFull seals the main file once it reaches the segment size.
+// Failures are only logged since the write that filled the file succeeded.
+// The caller must hold the lock.
+func (s *Storage) rotateIfFull() {
+	if s.segmentSize <= 0 || s.main.size < s.segmentSize {
+		return
+	}
+
+	err := s.rotate()
+	if err != nil {
+		s.logger.Warn("failed to rotate main file", zap.String("path", s.path), zap.Error(err))
+	}
+}
+
+// rotate renames the main file and its index file to the next sealed
+// segment, lists it in the manifest and reopens both files empty. With a
+// compression the segment is compressed before it is listed. The manifest
+// is written last: a segment missing from it is added back by loadSegments.
+// The caller must hold the lock.
+func (s *Storage) rotate() error {
+	for _, a := range []*appender{s.main, s.index} {
+		err := a.Sync()
+		if err != nil {
+			return fmt.Errorf("failed to sync file: %s: %w", a.path, err)
+		}
+	}
+
+	seg := segment{Name: segmentName(filepath.Base(s.path), len(s.segments)+1), Size: s.main.size}
+
+	err := os.Rename(s.path, s.segmentPath(seg))
+	if err != nil {
+		return fmt.Errorf("failed to rename file: %s: %w", s.path, err)
+	}
+
+	// Without its index the segment is indexed on the next start, but the
+	// index file of the main file must not keep its entries.
+	err = os.Rename(s.indexPath, s.segmentIndexPath(seg))
+	if err != nil {
+		s.logger.Warn("failed to move index of the segment", zap.String("path", s.indexPath), zap.Error(err))
+		os.Remove(s.indexPath)
+	}
+
+	s.segments = append(s.segments, seg)
+
+	for _, a := range []*appender{s.main, s.index} {
+		err = a.reopen()
+		if err != nil {
+			return fmt.Errorf("failed to reopen file: %s: %w", a.path, err)
+		}
+	}
+
+	plain := seg
+	if s.compression != CompressionNone {
+		err = s.compressSegment(&s.segments[len(s.segments)-1])
+		if err != nil {
+			s.logger.Warn("failed to compress segment, keeping it plain", zap.String("segment", seg.Name), zap.Error(err))
+		}
+		seg = s.segments[len(s.segments)-1]
+	}
+
+	err = s.writeManifest()
+	if err != nil {
+		return err
+	}
+
+	if seg != plain {
+		os.Remove(s.segmentPath(plain))
+	}
+
+	s.logger.Info("main file rotated", zap.String("segment", seg.Name), zap.Int64("size_bytes", seg.Size))
+	return nil
+}
+
+// mainFiles returns the paths of the sealed segments and the main file with
+// their offsets in the main file. The caller must hold the lock.
+func (s *Storage) mainFiles() ([]string, []int64) {
+	paths := make([]string, 0, len(s.segments)+1)
+	bases := make([]int64, 0, len(s.segments)+1)
+
+	var base int64
+	for _, seg := range s.segments {
+		paths = append(paths, s.segmentPath(seg))
+		bases = append(bases, base)
+		base += seg.Size
+	}
+
+	return append(paths, s.path), append(bases, base)
+}
+
+// fileAt returns the index of the file holding the offset in the main file
+// given the offsets the files start at.
+func fileAt(bases []int64, offset int64) int {
+	i := len(bases) - 1
+	for i > 0 && offset < bases[i] {
+		i--
+	}
+
+	return i
+}
+
+// openMain opens the main file across its segments for reading after
+// flushing the appends buffered for it. The caller must hold the lock, at
+// least for reading.
+func (s *Storage) openMain() (*mainReader, error) {
+	paths, bases := s.mainFiles()
+	r := &mainReader{bases: bases}
+
+	for _, path := range paths {
+		file, err := s.openMainFile(path)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+
+		r.files = append(r.files, file)
+	}
+
+	return r, nil
+}
+
+// mainReader reads the sealed segments and the main file one after the
+// other as a single file.
+type mainReader struct {
+	files []io.ReadSeekCloser
+	bases []int64
+	cur   int
+}
+
+func (r *mainReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.files[r.cur].Read(p)
+		if errors.Is(err, io.EOF) && r.cur < len(r.files)-1 {
+			r.cur++
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+
+		return n, err
+	}
+}
+
+// Seek only supports io.SeekStart.
+func (r *mainReader) Seek(offset int64, whence int) (int64, error) {
+	if whence != io.SeekStart {
+		return 0, errors.New("main file only seeks from the start")
+	}
+
+	i := fileAt(r.bases, offset)
+
+	_, err := r.files[i].Seek(offset-r.bases[i], io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, file := range r.files[i+1:] {
+		_, err = file.Seek(0, io.SeekStart)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	r.cur = i
+	return offset, nil
+}
+
+func (r *mainReader) Close() error {
+	var errs []error
+	for _, file := range r.files {
+		errs = append(errs, file.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// MainFilePaths returns the paths of the sealed segments listed in the
+// manifest followed by the main file, for tools that read the files
+// directly.
+func MainFilePaths(cfg *Config) ([]string, error) {
+	path := filepath.Join(filepath.Clean(cfg.DirPath), cfg.FileName)
+
+	data, err := os.ReadFile(path + manifestSuffix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []string{path}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var m manifest
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+
+	paths := make([]string, 0, len(m.Segments)+1)
+	for _, seg := range m.Segments {
+		paths = append(paths, filepath.Join(filepath.Dir(path), seg.Name))
+	}
+
+	return append(paths, path), nil
+}
@@ -0,0 +1,199 @@
+package filesystem
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+)
+
+// newSegmentedStorage returns a storage that rotates the main file after
+// every record.
+func newSegmentedStorage(t *testing.T, cfg *Config) *Storage {
+	t.Helper()
+
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	storage.segmentSize = 1
+
+	return storage
+}
+
+func TestSegmentName(t *testing.T) {
+	assert.Equal(t, "data-000001.json", segmentName("data.json", 1))
+	assert.Equal(t, "records-000012.jsonl", segmentName("records.jsonl", 12))
+	assert.Equal(t, "data-000003", segmentName("data", 3))
+}
+
+func TestRotate(t *testing.T) {
+	cfg := newTestConfig(t)
+	storage := newSegmentedStorage(t, cfg)
+
+	for id := int64(1); id <= 3; id++ {
+		err := storage.SaveRecord(context.Background(), &domain.Record{ID: id, Links: map[string]string{"a.com": "available"}})
+		assert.NoError(t, err)
+	}
+
+	err := storage.UpdateRecord(context.Background(), &domain.Record{ID: 2, Links: map[string]string{"a.com": "not available"}})
+	assert.NoError(t, err)
+
+	err = storage.DeleteRecord(context.Background(), 3)
+	assert.NoError(t, err)
+
+	assert.Len(t, storage.segments, 5)
+	assert.FileExists(t, filepath.Join(cfg.DirPath, "data-000001.json"))
+	assert.FileExists(t, filepath.Join(cfg.DirPath, "data-000001.json.idx"))
+	assert.FileExists(t, filepath.Join(cfg.DirPath, "data.json.manifest"))
+
+	info, err := os.Stat(storage.path)
+	assert.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	check := func(storage *Storage) {
+		t.Helper()
+
+		rec, err := storage.GetRecord(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "available", rec.Links["a.com"])
+
+		rec, err = storage.GetRecord(context.Background(), 2)
+		assert.NoError(t, err)
+		assert.Equal(t, "not available", rec.Links["a.com"])
+
+		ids, err := storage.ListRecordIDs(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{1, 2}, ids)
+
+		assert.Equal(t, int64(3), storage.LoadLastLinksNum(context.Background()))
+	}
+
+	check(storage)
+	assert.NoError(t, storage.Close())
+
+	// The segments are found through the manifest and their index files.
+	reopened, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	t.Cleanup(func() { reopened.Close() })
+
+	assert.Len(t, reopened.segments, 5)
+	assert.Equal(t, int64(2), reopened.Len())
+	check(reopened)
+
+	paths, err := MainFilePaths(cfg)
+	assert.NoError(t, err)
+	assert.Len(t, paths, 6)
+	assert.Equal(t, reopened.path, paths[5])
+}
+
+func TestLoadSegmentsMissingFromManifest(t *testing.T) {
+	cfg := newTestConfig(t)
+	storage := newSegmentedStorage(t, cfg)
+
+	for id := int64(1); id <= 2; id++ {
+		err := storage.SaveRecord(context.Background(), &domain.Record{ID: id})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, storage.Close())
+
+	// A rotation that stopped before writing the manifest.
+	err := os.Remove(storage.manifestPath)
+	assert.NoError(t, err)
+	err = os.Remove(filepath.Join(cfg.DirPath, "data-000002.json.idx"))
+	assert.NoError(t, err)
+
+	reopened, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	t.Cleanup(func() { reopened.Close() })
+
+	assert.Len(t, reopened.segments, 2)
+	assert.FileExists(t, reopened.manifestPath)
+	assert.FileExists(t, filepath.Join(cfg.DirPath, "data-000002.json.idx"))
+
+	rec, err := reopened.GetRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), rec.ID)
+}
+
+func TestCompactSegments(t *testing.T) {
+	cfg := newTestConfig(t)
+	storage := newSegmentedStorage(t, cfg)
+	t.Cleanup(func() { storage.Close() })
+
+	for id := int64(1); id <= 3; id++ {
+		err := storage.SaveRecord(context.Background(), &domain.Record{ID: id, Links: map[string]string{"a.com": "available"}})
+		assert.NoError(t, err)
+	}
+
+	err := storage.UpdateRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "not available"}})
+	assert.NoError(t, err)
+
+	untouched := filepath.Join(cfg.DirPath, "data-000002.json")
+	before, err := os.Stat(untouched)
+	assert.NoError(t, err)
+
+	dropped, err := storage.Compact()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, dropped)
+
+	// Only the segment holding the superseded line is replaced.
+	after, err := os.Stat(untouched)
+	assert.NoError(t, err)
+	assert.True(t, os.SameFile(before, after))
+
+	first, err := os.ReadFile(filepath.Join(cfg.DirPath, "data-000001.json"))
+	assert.NoError(t, err)
+	assert.Empty(t, first)
+	assert.Zero(t, storage.segments[0].Size)
+
+	rec, err := storage.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "not available", rec.Links["a.com"])
+
+	rec, err = storage.GetRecord(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), rec.ID)
+}
+
+func TestMainReaderSeek(t *testing.T) {
+	dir := t.TempDir()
+
+	var files []io.ReadSeekCloser
+	for i, content := range []string{"ab\n", "", "cd\nef\n"} {
+		path := filepath.Join(dir, segmentName("data", i))
+		err := os.WriteFile(path, []byte(content), 0644)
+		assert.NoError(t, err)
+
+		file, err := os.Open(path)
+		assert.NoError(t, err)
+		files = append(files, file)
+	}
+
+	r := &mainReader{files: files, bases: []int64{0, 3, 3}}
+	t.Cleanup(func() { r.Close() })
+
+	data, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "ab\ncd\nef\n", string(data))
+
+	_, err = r.Seek(4, io.SeekStart)
+	assert.NoError(t, err)
+
+	data, err = io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "d\nef\n", string(data))
+
+	_, err = r.Seek(1, io.SeekStart)
+	assert.NoError(t, err)
+
+	data, err = io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "cd", "ef"}, strings.Fields(string(data)))
+}
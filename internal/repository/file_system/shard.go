@@ -0,0 +1,100 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/repository"
+)
+
+// With Shards above one the records are partitioned by ID across that many
+// storages, each in its own shard-N subdir of the storage dir with its own
+// lock, see repository.Sharded. The storage dir holds either a single
+// storage or the shard dirs, and the number of shard dirs must match Shards;
+// cmd/migrate -reshard copies the records to a dir with another layout.
+
+var shardDirPattern = regexp.MustCompile(`^shard-[0-9]+$`)
+
+// shardName returns the dir name of the ith shard.
+func shardName(i int) string {
+	return fmt.Sprintf("shard-%d", i)
+}
+
+// shardDir returns the dir of the ith shard.
+func shardDir(dirPath string, i int) string {
+	return filepath.Join(dirPath, shardName(i))
+}
+
+// Open opens the storage, a ShardedStorage if the config asks for more than
+// one shard. It is the factory of STORAGE_TYPE filesystem.
+func Open(cfg *Config, clk clock.Clock, logger *zap.Logger) (repository.Repository, error) {
+	err := checkShardLayout(cfg)
+	if err != nil {
+		logger.Error("storage dir does not match the shard count", zap.String("dir_path", cfg.DirPath), zap.Error(err))
+		return nil, err
+	}
+
+	if cfg.Shards <= 1 {
+		return New(cfg, clk, logger)
+	}
+
+	shards := make([]*Storage, 0, cfg.Shards)
+	for i := range cfg.Shards {
+		shardCfg := *cfg
+		shardCfg.DirPath = shardDir(cfg.DirPath, i)
+		shardCfg.Shards = 0
+
+		storage, err := New(&shardCfg, clk, logger.With(zap.Int("shard", i)))
+		if err != nil {
+			newShardedStorage(shards, logger).Close()
+			return nil, fmt.Errorf("failed to open shard %d: %w", i, err)
+		}
+
+		shards = append(shards, storage)
+	}
+
+	logger.Info("storage sharded", zap.String("dir_path", cfg.DirPath), zap.Int("shards", cfg.Shards))
+	return newShardedStorage(shards, logger), nil
+}
+
+// checkShardLayout fails if the storage dir was written with another shard
+// count, since the records would be looked up in the wrong shards.
+func checkShardLayout(cfg *Config) error {
+	entries, err := os.ReadDir(cfg.DirPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read dir: %s: %w", cfg.DirPath, err)
+	}
+
+	shardDirs := 0
+	single := false
+	for _, entry := range entries {
+		switch {
+		case entry.IsDir() && shardDirPattern.MatchString(entry.Name()):
+			shardDirs++
+		case entry.Name() == cfg.FileName:
+			single = true
+		}
+	}
+
+	shards := max(cfg.Shards, 1)
+	switch {
+	case shards == 1 && shardDirs > 0:
+		return fmt.Errorf("storage dir holds %d shards but STORAGE_SHARDS is not set, reshard it with cmd/migrate: %s", shardDirs, cfg.DirPath)
+	case shards > 1 && single:
+		return fmt.Errorf("storage dir holds an unsharded storage, reshard it with cmd/migrate: %s", cfg.DirPath)
+	case shards > 1 && shardDirs > 0 && shardDirs != shards:
+		return fmt.Errorf("storage dir holds %d shards but STORAGE_SHARDS is %d, reshard it with cmd/migrate: %s", shardDirs, shards, cfg.DirPath)
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+func openTestShards(t *testing.T, cfg *Config) repository.Repository {
+	t.Helper()
+
+	repo, err := Open(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	return repo
+}
+
+func TestOpenSharded(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.Shards = 2
+
+	repo := openTestShards(t, cfg)
+	assert.IsType(t, &ShardedStorage{}, repo)
+
+	for id := int64(1); id <= 4; id++ {
+		err := repo.SaveRecord(context.Background(), &domain.Record{ID: id, Links: map[string]string{"a.com": "available"}})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, repo.(io.Closer).Close())
+
+	for i, want := range [][]int64{{2, 4}, {1, 3}} {
+		shardCfg := *cfg
+		shardCfg.DirPath = shardDir(cfg.DirPath, i)
+		shardCfg.Shards = 0
+
+		storage, err := New(&shardCfg, clock.NewFake(testNow), zap.NewNop())
+		assert.NoError(t, err)
+
+		ids, err := storage.ListRecordIDs(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, want, ids)
+		assert.NoError(t, storage.Close())
+	}
+
+	repo = openTestShards(t, cfg)
+	t.Cleanup(func() { repo.(io.Closer).Close() })
+
+	rec, err := repo.GetRecord(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), rec.ID)
+}
+
+func TestShardedStorageMaintenance(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.Shards = 2
+
+	storage := openTestShards(t, cfg).(*ShardedStorage)
+	t.Cleanup(func() { storage.Close() })
+
+	for id := int64(1); id <= 4; id++ {
+		err := storage.SaveRecord(context.Background(), &domain.Record{ID: id, Links: map[string]string{"a.com": "available"}})
+		assert.NoError(t, err)
+	}
+
+	var backup bytes.Buffer
+	assert.NoError(t, storage.Backup(&backup))
+
+	assert.NoError(t, storage.DeleteRecord(context.Background(), 1))
+	assert.NoError(t, storage.DeleteRecord(context.Background(), 2))
+
+	dropped, err := storage.Compact()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, dropped)
+	assert.NoError(t, storage.Reindex())
+	assert.Equal(t, int64(2), storage.Len())
+
+	var export bytes.Buffer
+	_, err = storage.WriteTo(&export)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, bytes.Count(export.Bytes(), []byte{'\n'}))
+
+	assert.NoError(t, storage.Restore(bytes.NewReader(backup.Bytes())))
+	ids, err := storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3, 4}, ids)
+
+	// A backup of an unsharded storage does not fit.
+	single := newTestStorage(t)
+	backup.Reset()
+	assert.NoError(t, single.Backup(&backup))
+	err = storage.Restore(&backup)
+	assert.ErrorIs(t, err, repository.ErrInvalidBackup)
+}
+
+func TestShardedStoragePromoteTempRecords(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.Shards = 2
+
+	storage := openTestShards(t, cfg).(*ShardedStorage)
+	t.Cleanup(func() { storage.Close() })
+
+	promoter, ok := repository.As[interface {
+		PromoteTempRecords(ctx context.Context, records []*domain.Record) (int, error)
+	}](storage)
+	assert.True(t, ok)
+
+	for id := int64(1); id <= 2; id++ {
+		err := storage.SaveTempRecord(context.Background(), &domain.Record{ID: id, Links: map[string]string{"a.com": "unknown"}})
+		assert.NoError(t, err)
+	}
+
+	// The temp record 2 was given the ID 5, which lives in the other shard.
+	promoted, err := promoter.PromoteTempRecords(context.Background(), []*domain.Record{
+		{ID: 1, Links: map[string]string{"a.com": "available"}},
+		{ID: 5, Links: map[string]string{"a.com": "available"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, promoted)
+
+	temp, err := storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, temp)
+
+	ids, err := storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 5}, ids)
+
+	ids, err = storage.shards[1].ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 5}, ids)
+}
+
+func TestShardLayout(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.Shards = 2
+
+	repo := openTestShards(t, cfg)
+	assert.NoError(t, repo.(io.Closer).Close())
+
+	cfg.Shards = 3
+	_, err := Open(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.ErrorContains(t, err, "holds 2 shards but STORAGE_SHARDS is 3")
+
+	cfg.Shards = 0
+	_, err = Open(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.ErrorContains(t, err, "STORAGE_SHARDS is not set")
+
+	single := newTestConfig(t)
+	err = os.WriteFile(filepath.Join(single.DirPath, single.FileName), nil, 0644)
+	assert.NoError(t, err)
+
+	single.Shards = 2
+	_, err = Open(single, clock.NewFake(testNow), zap.NewNop())
+	assert.ErrorContains(t, err, "unsharded storage")
+}
+
+func TestReshard(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	src := openTestShards(t, cfg)
+	t.Cleanup(func() { src.(io.Closer).Close() })
+
+	for id := int64(1); id <= 5; id++ {
+		err := src.SaveRecord(context.Background(), &domain.Record{ID: id, Links: map[string]string{"a.com": "available"}})
+		assert.NoError(t, err)
+	}
+
+	dstCfg := *cfg
+	dstCfg.DirPath = t.TempDir()
+	dstCfg.Shards = 3
+
+	dst := openTestShards(t, &dstCfg)
+	t.Cleanup(func() { dst.(io.Closer).Close() })
+
+	migrated, err := repository.MigrateAll(context.Background(), src, dst)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, migrated)
+
+	ids, err := dst.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, ids)
+}
@@ -0,0 +1,245 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+// ShardedStorage is the repository.Sharded of the shard storages opened by
+// Open. It adds the maintenance operations of a Storage, run on every shard,
+// so the admin endpoints and the crash-safe temp record promotion work with
+// shards as well.
+type ShardedStorage struct {
+	*repository.Sharded
+	shards []*Storage
+	logger *zap.Logger
+}
+
+func newShardedStorage(shards []*Storage, logger *zap.Logger) *ShardedStorage {
+	repos := make([]repository.Repository, len(shards))
+	for i, shard := range shards {
+		repos[i] = shard
+	}
+
+	return &ShardedStorage{
+		Sharded: repository.NewSharded(repos),
+		shards:  shards,
+		logger:  logger,
+	}
+}
+
+// Compact compacts every shard and returns the number of lines dropped
+// from all of them. It stops at the first shard that fails.
+func (s *ShardedStorage) Compact() (int, error) {
+	dropped := 0
+	for i, shard := range s.shards {
+		n, err := shard.Compact()
+		dropped += n
+		if err != nil {
+			return dropped, fmt.Errorf("failed to compact shard %d: %w", i, err)
+		}
+	}
+
+	return dropped, nil
+}
+
+// Reindex rebuilds the index of every shard. It stops at the first shard
+// that fails.
+func (s *ShardedStorage) Reindex() error {
+	for i, shard := range s.shards {
+		err := shard.Reindex()
+		if err != nil {
+			return fmt.Errorf("failed to reindex shard %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// WriteTo streams the main files of the shards in turn as NDJSON to w, see
+// Storage.WriteTo. The records are ordered by shard, not by ID.
+func (s *ShardedStorage) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, shard := range s.shards {
+		n, err := shard.WriteTo(w)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// PromoteTempRecords stores every processed record in the shard of its ID,
+// which may not be the shard of its temp record, and then clears the temp
+// files, see Storage.PromoteTempRecords. Every shard is locked throughout,
+// and the temp files are cleared only once the records of all shards are
+// synced, so a crash part way through loses no temp record.
+func (s *ShardedStorage) PromoteTempRecords(ctx context.Context, records []*domain.Record) (int, error) {
+	for _, shard := range s.shards {
+		err := shard.checkWritable("promote temp records")
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	defer s.lock()()
+
+	byShard := make([][]*domain.Record, len(s.shards))
+	for _, rec := range records {
+		i := s.ShardOf(rec.ID)
+		byShard[i] = append(byShard[i], rec)
+	}
+
+	promoted := 0
+	for i, shard := range s.shards {
+		n, err := shard.storePromoted(byShard[i])
+		promoted += n
+		if err != nil {
+			return promoted, fmt.Errorf("failed to promote temp records of shard %d: %w", i, err)
+		}
+	}
+
+	for i, shard := range s.shards {
+		err := shard.clearTempFile()
+		if err != nil {
+			return promoted, fmt.Errorf("failed to clear temp file of shard %d: %w", i, err)
+		}
+	}
+
+	s.logger.Info("successfully promoted temp records", zap.Int("count", promoted))
+	return promoted, nil
+}
+
+// Backup writes a tar.gz of the files of every shard to w, named by their
+// paths relative to the storage dir, i.e. under the shard dirs. Writes wait
+// until it is done, reads go on.
+func (s *ShardedStorage) Backup(w io.Writer) error {
+	defer s.rlock()()
+
+	err := writeBackup(w, func(tw *tar.Writer) error {
+		for i, shard := range s.shards {
+			err := shard.backupFiles(tw, shardName(i)+"/")
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("storage backed up", zap.Int("shards", len(s.shards)))
+	return nil
+}
+
+// Restore replaces the files of every shard with the ones of a backup
+// written by Backup, which must come from a storage with the same shard
+// count and file names. It is extracted next to the shard files first, so an
+// invalid backup fails with repository.ErrInvalidBackup before any file is
+// replaced. A shard failing to load its files stops the restore, leaving
+// the shards before it restored.
+func (s *ShardedStorage) Restore(r io.Reader) error {
+	for _, shard := range s.shards {
+		err := shard.checkWritable("restore")
+		if err != nil {
+			return err
+		}
+	}
+
+	defer s.lock()()
+
+	stagings := make([]*staging, len(s.shards))
+	for i, shard := range s.shards {
+		st, err := shard.newStaging()
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(st.dir)
+
+		stagings[i] = st
+	}
+
+	err := readBackup(r, func(name string, r io.Reader) error {
+		dir, rest, ok := strings.Cut(name, "/")
+		i := s.shardIndex(dir)
+		if !ok || i < 0 {
+			return fmt.Errorf("%w: unexpected entry %q", repository.ErrInvalidBackup, name)
+		}
+
+		return stagings[i].add(rest, r)
+	})
+	for _, st := range stagings {
+		if err != nil {
+			break
+		}
+
+		err = st.check()
+	}
+	if err != nil {
+		s.logger.Error("failed to extract backup", zap.Error(err))
+		return err
+	}
+
+	for i, shard := range s.shards {
+		err = shard.replaceFiles(stagings[i].files)
+		if err != nil {
+			s.logger.Error("failed to restore backup", zap.Int("shard", i), zap.Error(err))
+			return fmt.Errorf("failed to restore backup of shard %d: %w", i, err)
+		}
+	}
+
+	s.logger.Info("storage restored", zap.Int64("records", s.Len()))
+	return nil
+}
+
+// shardIndex returns the index of the shard with the dir name, or -1.
+func (s *ShardedStorage) shardIndex(name string) int {
+	for i := range s.shards {
+		if shardName(i) == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// lock locks every shard for writing, in order, and returns the func that
+// unlocks them.
+func (s *ShardedStorage) lock() func() {
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+	}
+
+	return func() {
+		for _, shard := range s.shards {
+			shard.mu.Unlock()
+		}
+	}
+}
+
+// rlock locks every shard for reading, in order, and returns the func that
+// unlocks them.
+func (s *ShardedStorage) rlock() func() {
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+	}
+
+	return func() {
+		for _, shard := range s.shards {
+			shard.mu.RUnlock()
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"go.uber.org/zap"
+)
+
+// With a snapshot interval the main file proper works as a write-ahead log
+// of the sealed segments. Every snapshot seals the main file into the next
+// segment, which truncates the log, and saves the in-memory index and the
+// record count of the sealed segments to the snapshot file next to the
+// manifest. On startup the snapshot replaces reading the index and counting
+// the lines of the segments it covers: only the segments sealed after it and
+// the main file are replayed.
+//
+// A snapshot covers the segments it lists, so it stays valid while more
+// segments are sealed after it. Rewriting the segments, see rewriteMain,
+// moves their lines and removes the snapshot until the next one is taken.
+
+// snapshotSuffix is appended to the main file name to get the snapshot path.
+const snapshotSuffix = ".snapshot"
+
+type snapshot struct {
+	// Segments are the sealed segments the snapshot covers.
+	Segments []segment       `json:"segments"`
+	Offsets  map[int64]int64 `json:"offsets"`
+	Deleted  []int64         `json:"deleted,omitempty"`
+	Updated  map[int64]int64 `json:"updated,omitempty"`
+}
+
+// Snapshot seals the main file into a segment and saves the index of the
+// sealed segments to the snapshot file, so the next start only replays the
+// writes made after it.
+func (s *Storage) Snapshot() error {
+	err := s.checkWritable("snapshot")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.snapshot()
+}
+
+// snapshot is Snapshot without the checks. The caller must hold the lock.
+func (s *Storage) snapshot() error {
+	if s.main.size > 0 {
+		err := s.rotate()
+		if err != nil {
+			s.logger.Error("failed to seal main file", zap.String("path", s.path), zap.Error(err))
+			return fmt.Errorf("failed to seal main file: %w", err)
+		}
+	}
+
+	snap := snapshot{
+		Segments: s.segments,
+		Offsets:  s.offsets,
+		Updated:  s.updated,
+	}
+	for id := range s.deleted {
+		snap.Deleted = append(snap.Deleted, id)
+	}
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	err = s.writeAtomic(s.snapshotPath, data)
+	if err != nil {
+		s.logger.Error("failed to write snapshot", zap.String("path", s.snapshotPath), zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("snapshot written",
+		zap.String("path", s.snapshotPath),
+		zap.Int("segments", len(snap.Segments)),
+		zap.Int("records", len(snap.Offsets)),
+	)
+	return nil
+}
+
+// snapshotScheduled is the SnapshotInterval loop body.
+func (s *Storage) snapshotScheduled() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.snapshot()
+	if err != nil {
+		s.logger.Warn("scheduled snapshot failed", zap.Error(err))
+	}
+}
+
+// loadSnapshot reads the snapshot file. It reports false when there is no
+// snapshot or it does not match the sealed segments, so the storage has to
+// be loaded in full.
+func (s *Storage) loadSnapshot() (*snapshot, bool) {
+	data, err := os.ReadFile(s.snapshotPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false
+	}
+	if err != nil {
+		s.logger.Warn("failed to read snapshot, loading the whole main file", zap.String("path", s.snapshotPath), zap.Error(err))
+		return nil, false
+	}
+
+	var snap snapshot
+	err = json.Unmarshal(data, &snap)
+	if err != nil {
+		s.logger.Warn("failed to decode snapshot, loading the whole main file", zap.String("path", s.snapshotPath), zap.Error(err))
+		return nil, false
+	}
+
+	if len(snap.Segments) > len(s.segments) {
+		s.logger.Warn("snapshot does not match the segments, loading the whole main file", zap.String("path", s.snapshotPath))
+		return nil, false
+	}
+
+	for i, seg := range snap.Segments {
+		if seg != s.segments[i] {
+			s.logger.Warn("snapshot does not match the segments, loading the whole main file",
+				zap.String("path", s.snapshotPath),
+				zap.String("segment", seg.Name),
+			)
+			return nil, false
+		}
+	}
+
+	return &snap, true
+}
+
+// replaySnapshot fills the in-memory index from the snapshot and indexes
+// the segments sealed after it and the main file from their index files.
+// Missing or partial index files are rebuilt, in read-only mode the file is
+// indexed in memory only.
+func (s *Storage) replaySnapshot(snap *snapshot) error {
+	s.resetIndex()
+
+	for id, offset := range snap.Offsets {
+		s.offsets[id] = offset
+	}
+	for id, offset := range snap.Updated {
+		s.updated[id] = offset
+	}
+	for _, id := range snap.Deleted {
+		s.deleted[id] = struct{}{}
+	}
+
+	paths, bases := s.mainFiles()
+	for i := len(snap.Segments); i < len(paths); i++ {
+		indexPath := s.indexPath
+		if i < len(s.segments) {
+			indexPath = s.segmentIndexPath(s.segments[i])
+		}
+
+		err := s.replayFile(paths[i], indexPath, bases[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	s.logger.Info("snapshot loaded",
+		zap.String("path", s.snapshotPath),
+		zap.Int("segments", len(snap.Segments)),
+		zap.Int("replayed_files", len(paths)-len(snap.Segments)),
+	)
+	return nil
+}
+
+// replayFile indexes the file at path, which starts at base in the main
+// file, from its index file if it is valid.
+func (s *Storage) replayFile(path, indexPath string, base int64) error {
+	if validIndex(indexPath) {
+		err := s.readOffsets(indexPath, base)
+		if err == nil {
+			return nil
+		}
+
+		s.logger.Warn("failed to read index, indexing the file", zap.String("path", indexPath), zap.Error(err))
+	}
+
+	if !s.readOnly {
+		return s.rebuildIndex(path, indexPath, base)
+	}
+
+	file, err := s.openMainFile(path)
+	if err != nil {
+		s.logger.Error("failed to open file", zap.String("path", path), zap.Error(err))
+		return fmt.Errorf("failed to open file: %s: %w", path, err)
+	}
+	defer file.Close()
+
+	_, err = s.indexLines(file, base, 0, io.Discard, true)
+	if err != nil {
+		s.logger.Error("failed to index records", zap.String("path", path), zap.Error(err))
+		return fmt.Errorf("failed to index records: %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// removeSnapshot drops the snapshot before the sealed segments are
+// rewritten. The caller must hold the lock.
+func (s *Storage) removeSnapshot() error {
+	err := os.Remove(s.snapshotPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove snapshot: %s: %w", s.snapshotPath, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package filesystem
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+)
+
+func TestSnapshot(t *testing.T) {
+	cfg := newTestConfig(t)
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	for id := int64(1); id <= 3; id++ {
+		err = storage.SaveRecord(context.Background(), &domain.Record{ID: id, Links: map[string]string{"a.com": "available"}})
+		assert.NoError(t, err)
+	}
+
+	err = storage.DeleteRecord(context.Background(), 3)
+	assert.NoError(t, err)
+
+	err = storage.Snapshot()
+	assert.NoError(t, err)
+
+	// The snapshot truncates the main file into the first segment.
+	assert.Len(t, storage.segments, 1)
+	assert.FileExists(t, filepath.Join(cfg.DirPath, "data.json.snapshot"))
+
+	info, err := os.Stat(storage.path)
+	assert.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	// Writes after the snapshot are replayed from the main file.
+	err = storage.UpdateRecord(context.Background(), &domain.Record{ID: 2, Links: map[string]string{"a.com": "not available"}})
+	assert.NoError(t, err)
+
+	err = storage.SaveRecord(context.Background(), &domain.Record{ID: 4, Links: map[string]string{"b.com": "available"}})
+	assert.NoError(t, err)
+	assert.NoError(t, storage.Close())
+
+	// A full load would index the covered segment again.
+	segmentIndex := filepath.Join(cfg.DirPath, "data-000001.json.idx")
+	assert.NoError(t, os.Remove(segmentIndex))
+
+	storage, err = New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	t.Cleanup(func() { storage.Close() })
+
+	assert.NoFileExists(t, segmentIndex)
+	assert.Equal(t, int64(3), storage.Len())
+	assert.Equal(t, int64(4), storage.LoadLastLinksNum(context.Background()))
+
+	ids, err := storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 4}, ids)
+
+	rec, err := storage.GetRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "not available", rec.Links["a.com"])
+
+	rec, err = storage.GetRecord(context.Background(), 4)
+	assert.NoError(t, err)
+	assert.Equal(t, "available", rec.Links["b.com"])
+}
+
+func TestSnapshotOnClose(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.SnapshotInterval = time.Hour
+
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	err = storage.SaveRecord(context.Background(), &domain.Record{ID: 1})
+	assert.NoError(t, err)
+	assert.NoError(t, storage.Close())
+	assert.NoError(t, storage.Close())
+
+	storage, err = New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	t.Cleanup(func() { storage.Close() })
+
+	assert.Len(t, storage.segments, 1)
+
+	snap, ok := storage.loadSnapshot()
+	assert.True(t, ok)
+	assert.Equal(t, storage.segments, snap.Segments)
+	assert.Equal(t, map[int64]int64{1: 0}, snap.Offsets)
+}
+
+func TestSnapshotInvalidated(t *testing.T) {
+	cfg := newTestConfig(t)
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	t.Cleanup(func() { storage.Close() })
+
+	for id := int64(1); id <= 2; id++ {
+		err = storage.SaveRecord(context.Background(), &domain.Record{ID: id})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, storage.Snapshot())
+
+	err = storage.DeleteRecord(context.Background(), 1)
+	assert.NoError(t, err)
+
+	// Compaction rewrites the covered segment.
+	_, err = storage.Compact()
+	assert.NoError(t, err)
+	assert.NoFileExists(t, storage.snapshotPath)
+
+	assert.NoError(t, storage.Snapshot())
+
+	// A segment changed behind the snapshot's back loads the whole file.
+	storage.segments[0].Size++
+	_, ok := storage.loadSnapshot()
+	assert.False(t, ok)
+}
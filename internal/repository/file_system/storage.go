@@ -2,183 +2,956 @@ package filesystem
 
 import (
 	"bufio"
+	"bytes"
+	"cmp"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/zap"
 
+	"link-service/internal/clock"
 	"link-service/internal/domain"
+	"link-service/internal/metrics"
+	"link-service/internal/repository"
 )
 
+// StorageType selects this backend with STORAGE_TYPE.
+const StorageType = "filesystem"
+
+func init() {
+	repository.Register(StorageType, repository.FactoryFor(func(cfg *Config, logger *zap.Logger) (repository.Repository, error) {
+		return Open(cfg, clock.Real{}, logger)
+	}))
+}
+
 type Config struct {
-	DirPath      string `env:"STORAGE_DIR_PATH" env-required:"true"`
-	FileName     string `env:"STORAGE_FILE_NAME" env-required:"true"`
-	TempFileName string `env:"STORAGE_TEMP_FILE_NAME" env-required:"true"`
+	DirPath        string `env:"STORAGE_DIR_PATH"`
+	FileName       string `env:"STORAGE_FILE_NAME"`
+	TempFileName   string `env:"STORAGE_TEMP_FILE_NAME"`
+	EventsFileName string `env:"STORAGE_EVENTS_FILE_NAME" env-default:"events.json"`
+	// IndexFileName is the file mapping record IDs to their offsets in the
+	// main file. Empty means the main file name with an ".idx" suffix.
+	IndexFileName  string      `env:"STORAGE_INDEX_FILE_NAME"`
+	WarnFileSizeMB int64       `env:"STORAGE_WARN_FILE_SIZE_MB" env-default:"100"`
+	FileMode       os.FileMode `env:"STORAGE_FILE_MODE" env-default:"0644"`
+	DirMode        os.FileMode `env:"STORAGE_DIR_MODE" env-default:"0755"`
+	// StrictDecode makes record reads fail on the first malformed line
+	// instead of skipping it.
+	StrictDecode bool `env:"STORAGE_STRICT_DECODE" env-default:"false"`
+	// MaxTempFileSizeBytes stops temp writes once the temp file reaches this
+	// size. Zero means unlimited.
+	MaxTempFileSizeBytes int64 `env:"STORAGE_MAX_TEMP_FILE_SIZE_BYTES" env-default:"0"`
+	// ReadOnly rejects every write with repository.ErrReadOnlyStorage. The
+	// main file must already exist and no file is created.
+	ReadOnly bool `env:"STORAGE_READ_ONLY" env-default:"false"`
+	// ReadOnlyIfLocked starts the storage in read-only mode when another
+	// process holds the lock of the storage dir instead of failing.
+	ReadOnlyIfLocked bool `env:"STORAGE_READ_ONLY_IF_LOCKED" env-default:"false"`
+	// SyncOnWrite is the former spelling of SyncPolicy "always". It only
+	// applies when SyncPolicy is empty.
+	SyncOnWrite bool `env:"STORAGE_SYNC_ON_WRITE" env-default:"false"`
+	// SyncPolicy decides when appends reach the disk, see the SyncPolicy
+	// constants. Empty means SyncPolicyNever.
+	SyncPolicy string `env:"STORAGE_SYNC_POLICY"`
+	// SyncInterval is the fsync period of SyncPolicyInterval. Zero means
+	// one second.
+	SyncInterval time.Duration `env:"STORAGE_SYNC_INTERVAL" env-default:"1s"`
+	// FlushInterval is how long appends may stay in the write buffer before
+	// they are written to the file. Reads flush the buffer first, so it only
+	// bounds what a crash of the process loses. Zero means one second.
+	FlushInterval time.Duration `env:"STORAGE_FLUSH_INTERVAL" env-default:"1s"`
+	// CompactInterval runs Compact on a schedule. Zero disables it.
+	CompactInterval time.Duration `env:"STORAGE_COMPACT_INTERVAL" env-default:"0"`
+	// SegmentSizeMB rotates the main file into sealed segments of about
+	// this size, see segment.go. Zero keeps a single main file.
+	SegmentSizeMB int64 `env:"STORAGE_SEGMENT_SIZE_MB" env-default:"0"`
+	// Compression compresses the sealed segments, see the Compression
+	// constants. It needs SegmentSizeMB. Empty means CompressionNone.
+	Compression string `env:"STORAGE_COMPRESSION"`
+	// EncryptionKeys seals the record and event lines with AES-GCM, see
+	// crypt.go. It lists "id:base64-key" entries separated by commas; the
+	// first key seals new lines and the others only open older ones.
+	EncryptionKeys string `env:"STORAGE_ENCRYPTION_KEYS"`
+	// EncryptionKeyFile reads the encryption keys from a file instead, one
+	// entry per line.
+	EncryptionKeyFile string `env:"STORAGE_ENCRYPTION_KEY_FILE"`
+	// SnapshotInterval seals the main file and saves the index of the
+	// segments on a schedule and on Close, so startup only replays the
+	// writes since the last snapshot, see snapshot.go. Zero disables it.
+	SnapshotInterval time.Duration `env:"STORAGE_SNAPSHOT_INTERVAL" env-default:"0"`
+	// Shards partitions the records by ID across that many storages in
+	// subdirs of DirPath, see shard.go. Zero or one keeps a single storage.
+	Shards int `env:"STORAGE_SHARDS" env-default:"0"`
+}
+
+const (
+	// sizeCheckInterval is the number of writes between main file size checks.
+	sizeCheckInterval = 100
+	// ctxCheckInterval is the number of scanned lines between context checks.
+	ctxCheckInterval = 1000
+
+	defaultEventsFileName = "events.json"
+	indexFileSuffix       = ".idx"
+
+	defaultFileMode os.FileMode = 0644
+	defaultDirMode  os.FileMode = 0755
+
+	defaultSyncInterval  = time.Second
+	defaultFlushInterval = time.Second
+)
+
+const (
+	// SyncPolicyNever leaves flushing the appends to the OS. A crash may
+	// lose the records written in the last seconds.
+	SyncPolicyNever = "never"
+	// SyncPolicyAlways bypasses the write buffer and opens the files with
+	// O_DSYNC so every append is on disk before it returns.
+	SyncPolicyAlways = "always"
+	// SyncPolicyInterval syncs the files written since the last sync every
+	// SyncInterval, so a single fsync covers all the appends in between.
+	SyncPolicyInterval = "interval"
+)
+
+// Validate reports every missing or invalid field of the config.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.DirPath == "" {
+		errs = append(errs, errors.New("STORAGE_DIR_PATH is required"))
+	}
+
+	if c.FileName == "" {
+		errs = append(errs, errors.New("STORAGE_FILE_NAME is required"))
+	}
+
+	if c.TempFileName == "" {
+		errs = append(errs, errors.New("STORAGE_TEMP_FILE_NAME is required"))
+	}
+
+	if c.WarnFileSizeMB < 0 {
+		errs = append(errs, errors.New("STORAGE_WARN_FILE_SIZE_MB must not be negative"))
+	}
+
+	if c.MaxTempFileSizeBytes < 0 {
+		errs = append(errs, errors.New("STORAGE_MAX_TEMP_FILE_SIZE_BYTES must not be negative"))
+	}
+
+	if c.CompactInterval < 0 {
+		errs = append(errs, errors.New("STORAGE_COMPACT_INTERVAL must not be negative"))
+	}
+
+	if c.Shards < 0 {
+		errs = append(errs, errors.New("STORAGE_SHARDS must not be negative"))
+	}
+
+	if c.SnapshotInterval < 0 {
+		errs = append(errs, errors.New("STORAGE_SNAPSHOT_INTERVAL must not be negative"))
+	}
+
+	if c.SegmentSizeMB < 0 {
+		errs = append(errs, errors.New("STORAGE_SEGMENT_SIZE_MB must not be negative"))
+	}
+
+	switch c.Compression {
+	case "", CompressionNone:
+	case CompressionGzip, CompressionZstd:
+		if c.SegmentSizeMB == 0 {
+			errs = append(errs, errors.New("STORAGE_COMPRESSION requires STORAGE_SEGMENT_SIZE_MB"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("STORAGE_COMPRESSION must be one of %s, %s, %s, got %q",
+			CompressionNone, CompressionGzip, CompressionZstd, c.Compression))
+	}
+
+	if c.EncryptionKeys != "" && c.EncryptionKeyFile != "" {
+		errs = append(errs, errors.New("STORAGE_ENCRYPTION_KEYS and STORAGE_ENCRYPTION_KEY_FILE are mutually exclusive"))
+	}
+
+	switch c.SyncPolicy {
+	case "", SyncPolicyNever, SyncPolicyAlways, SyncPolicyInterval:
+	default:
+		errs = append(errs, fmt.Errorf("STORAGE_SYNC_POLICY must be one of %s, %s, %s, got %q",
+			SyncPolicyNever, SyncPolicyAlways, SyncPolicyInterval, c.SyncPolicy))
+	}
+
+	if c.SyncInterval < 0 {
+		errs = append(errs, errors.New("STORAGE_SYNC_INTERVAL must not be negative"))
+	}
+
+	if c.FlushInterval < 0 {
+		errs = append(errs, errors.New("STORAGE_FLUSH_INTERVAL must not be negative"))
+	}
+
+	return errors.Join(errs...)
 }
 
 type Storage struct {
-	mu       *sync.Mutex
-	path     string
-	tempPath string
-	logger   *zap.Logger
+	// mu is held for writing by the writes and for reading by the reads,
+	// so reads run concurrently with each other but not with a write.
+	mu            *sync.RWMutex
+	path          string
+	tempPath      string
+	eventsPath    string
+	indexPath     string
+	fileMode      os.FileMode
+	strictDecode  bool
+	warnFileSize  int64
+	maxTempSize   int64
+	readOnly      bool
+	writesCounter atomic.Int64
+	// records is the number of live record IDs in offsets, see Len and
+	// countRecords.
+	records atomic.Int64
+	// offsets is the in-memory copy of the index files, guarded by mu. It
+	// holds offsets in the main file across its segments and takes about 40
+	// bytes per record.
+	offsets map[int64]int64
+	// deleted holds the IDs with a tombstone in the main file, guarded by mu.
+	deleted map[int64]struct{}
+	// updated maps the IDs changed by UpdateRecord to the offset of their
+	// latest update line, guarded by mu.
+	updated map[int64]int64
+	// main, temp, events and index are the append handles of the storage
+	// files, guarded by mu. They are nil in read-only mode.
+	main   *appender
+	temp   *appender
+	events *appender
+	index  *appender
+	// segments are the sealed segments of the main file, guarded by mu.
+	segments     []segment
+	segmentSize  int64
+	manifestPath string
+	snapshotPath string
+	// snapshotInterval is set when snapshots are taken, see snapshot.go.
+	snapshotInterval time.Duration
+	// compression is applied to the segments sealed from now on.
+	compression string
+	// keys seal the lines of the main and the temp file. It is nil without
+	// encryption keys.
+	keys *keyring
+	// lock holds the lock of the storage dir until Close. It is nil in
+	// read-only mode.
+	lock *os.File
+	// syncInterval is set under SyncPolicyInterval.
+	syncInterval time.Duration
+	// stop and loops control the background loops started by every.
+	stop   chan struct{}
+	loops  sync.WaitGroup
+	clock  clock.Clock
+	logger *zap.Logger
 }
 
-func New(cfg *Config, logger *zap.Logger) (*Storage, error) {
-	err := os.MkdirAll(cfg.DirPath, 0755)
-	if err != nil {
-		logger.Error("failed to create dir", zap.String("dir_path", cfg.DirPath), zap.Error(err))
-		return nil, fmt.Errorf("failed to create dir: %s: %w", cfg.DirPath, err)
+func New(cfg *Config, clk clock.Clock, logger *zap.Logger) (*Storage, error) {
+	// filepath.Join already cleans the file paths, but the dir path is used
+	// on its own as well and MkdirAll would create every directory a ".."
+	// component steps out of.
+	dirPath := filepath.Clean(cfg.DirPath)
+	filePath := filepath.Join(dirPath, cfg.FileName)
+	tempFilePath := filepath.Join(dirPath, cfg.TempFileName)
+
+	if filePath == tempFilePath {
+		logger.Error("file and temp file resolve to the same path", zap.String("path", filePath))
+		return nil, fmt.Errorf("file name %q and temp file name %q resolve to the same path: %s",
+			cfg.FileName, cfg.TempFileName, filePath)
 	}
 
-	filePath := filepath.Join(cfg.DirPath, cfg.FileName)
-	tempFilePath := filepath.Join(cfg.DirPath, cfg.TempFileName)
+	eventsFileName := cfg.EventsFileName
+	if eventsFileName == "" {
+		eventsFileName = defaultEventsFileName
+	}
+	eventsPath := filepath.Join(dirPath, eventsFileName)
 
-	file, err := os.OpenFile(filePath,
-		os.O_CREATE|os.O_WRONLY|os.O_APPEND,
-		0644,
-	)
+	if eventsPath == filePath || eventsPath == tempFilePath {
+		logger.Error("events file resolves to the path of another file", zap.String("path", eventsPath))
+		return nil, fmt.Errorf("events file name %q resolves to the path of another storage file: %s",
+			eventsFileName, eventsPath)
+	}
+
+	indexFileName := cfg.IndexFileName
+	if indexFileName == "" {
+		indexFileName = cfg.FileName + indexFileSuffix
+	}
+	indexPath := filepath.Join(dirPath, indexFileName)
+
+	if indexPath == filePath || indexPath == tempFilePath || indexPath == eventsPath {
+		logger.Error("index file resolves to the path of another file", zap.String("path", indexPath))
+		return nil, fmt.Errorf("index file name %q resolves to the path of another storage file: %s",
+			indexFileName, indexPath)
+	}
+
+	fileMode := cfg.FileMode
+	if fileMode == 0 {
+		fileMode = defaultFileMode
+	}
+
+	dirMode := cfg.DirMode
+	if dirMode == 0 {
+		dirMode = defaultDirMode
+	}
+
+	readOnly := cfg.ReadOnly
+
+	// lock is released on every failure below and owned by the storage
+	// once New succeeds.
+	var lock *os.File
+	defer func() {
+		if lock != nil {
+			lock.Close()
+		}
+	}()
+
+	if !readOnly {
+		err := os.MkdirAll(dirPath, dirMode)
+		if err != nil {
+			logger.Error("failed to create dir", zap.String("dir_path", dirPath), zap.Error(err))
+			return nil, fmt.Errorf("failed to create dir: %s: %w", dirPath, err)
+		}
+
+		lock, err = lockDir(dirPath, fileMode)
+		switch {
+		case errors.Is(err, errDirLocked) && cfg.ReadOnlyIfLocked:
+			logger.Warn("storage dir is locked by another process, starting read-only", zap.String("dir_path", dirPath))
+			readOnly = true
+		case err != nil:
+			logger.Error("failed to lock dir", zap.String("dir_path", dirPath), zap.Error(err))
+			return nil, err
+		}
+	}
+
+	flag := os.O_RDONLY
+	if !readOnly {
+		flag = os.O_CREATE | os.O_RDWR | os.O_APPEND
+	}
+
+	file, err := os.OpenFile(filePath, flag, fileMode)
 	if err != nil {
-		logger.Error("failed to create file", zap.String("file_name", cfg.FileName), zap.Error(err))
-		return nil, fmt.Errorf("failed to create file: %s: %w", cfg.FileName, err)
+		logger.Error("failed to open file", zap.String("file_name", cfg.FileName), zap.Error(err))
+		return nil, fmt.Errorf("failed to open file: %s: %w", cfg.FileName, err)
 	}
 
 	defer file.Close()
 
+	err = validateLastRecord(file)
+	if err != nil {
+		logger.Error("file is corrupted", zap.String("file", filePath), zap.Error(err))
+		return nil, fmt.Errorf("file is corrupted, inspect it with cmd/verify: %s: %w", filePath, err)
+	}
+
+	if readOnly {
+		logger.Warn("storage is read-only, writes will be rejected", zap.String("file", filePath))
+	} else {
+		err = createTempFile(tempFilePath, fileMode, dirMode, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Info("files created", zap.String("file", filePath), zap.String("temp_path", tempFilePath))
+	}
+
+	syncPolicy := cfg.SyncPolicy
+	if syncPolicy == "" {
+		syncPolicy = SyncPolicyNever
+		if cfg.SyncOnWrite {
+			syncPolicy = SyncPolicyAlways
+		}
+	}
+
+	keys, err := loadKeyring(cfg)
+	if err != nil {
+		logger.Error("failed to load encryption keys", zap.Error(err))
+		return nil, fmt.Errorf("failed to load encryption keys: %w", err)
+	}
+
+	compression := cfg.Compression
+	if compression == "" {
+		compression = CompressionNone
+	}
+
+	appendFlag := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if syncPolicy == SyncPolicyAlways {
+		appendFlag |= dataSyncFlag
+	}
+
+	storage := &Storage{
+		mu:           &sync.RWMutex{},
+		path:         filePath,
+		tempPath:     tempFilePath,
+		eventsPath:   eventsPath,
+		indexPath:    indexPath,
+		fileMode:     fileMode,
+		strictDecode: cfg.StrictDecode,
+		warnFileSize: cfg.WarnFileSizeMB * 1024 * 1024,
+		maxTempSize:  cfg.MaxTempFileSizeBytes,
+		segmentSize:  cfg.SegmentSizeMB * 1024 * 1024,
+		manifestPath: filePath + manifestSuffix,
+		snapshotPath: filePath + snapshotSuffix,
+		compression:  compression,
+		keys:         keys,
+		readOnly:     readOnly,
+		clock:        clk,
+		logger:       logger,
+	}
+
+	// A last line sealed with a key that is not set means the keys are
+	// wrong, and every sealed line would be skipped as malformed. A last
+	// line failing its checksum was torn by a crash or corrupted.
+	lastLine, err := readLastLine(file)
+	if err == nil {
+		_, err = storage.openLine(lastLine)
+	}
+	if err != nil {
+		logger.Error("failed to open last record", zap.String("file", filePath), zap.Error(err))
+		return nil, fmt.Errorf("failed to open last record, check the encryption keys or inspect it with cmd/verify: %s: %w", filePath, err)
+	}
+
+	err = storage.loadSegments()
+	if err != nil {
+		logger.Error("failed to load segments", zap.String("path", storage.manifestPath), zap.Error(err))
+		return nil, err
+	}
+
+	snap, ok := storage.loadSnapshot()
+	switch {
+	case ok:
+		err = storage.replaySnapshot(snap)
+	case !readOnly && !storage.validIndexes():
+		err = storage.reindex()
+	default:
+		err = storage.loadOffsets()
+	}
+	if err != nil {
+		return nil, err
+	}
+	storage.countRecords()
+
+	if readOnly {
+		return storage, nil
+	}
+
+	err = storage.openAppenders(appendFlag, syncPolicy == SyncPolicyAlways)
+	if err != nil {
+		return nil, err
+	}
+
+	flushInterval := cfg.FlushInterval
+	if flushInterval == 0 {
+		flushInterval = defaultFlushInterval
+	}
+	storage.every(flushInterval, storage.flushScheduled)
+
+	if cfg.CompactInterval > 0 {
+		storage.every(cfg.CompactInterval, storage.compactScheduled)
+	}
+
+	if cfg.SnapshotInterval > 0 {
+		storage.snapshotInterval = cfg.SnapshotInterval
+		storage.every(cfg.SnapshotInterval, storage.snapshotScheduled)
+	}
+
+	if syncPolicy == SyncPolicyInterval {
+		storage.syncInterval = cfg.SyncInterval
+		if storage.syncInterval == 0 {
+			storage.syncInterval = defaultSyncInterval
+		}
+		storage.every(storage.syncInterval, storage.syncScheduled)
+	}
+
+	storage.lock, lock = lock, nil
+	return storage, nil
+}
+
+// createTempFile creates the temp file and its directory if they do not exist.
+func createTempFile(tempFilePath string, fileMode, dirMode os.FileMode, logger *zap.Logger) error {
+	tempDir := filepath.Dir(tempFilePath)
+	err := os.MkdirAll(tempDir, dirMode)
+	if err != nil {
+		logger.Error("failed to create temp dir", zap.String("dir_path", tempDir), zap.Error(err))
+		return fmt.Errorf("failed to create temp dir: %s: %w", tempDir, err)
+	}
+
 	tempFile, err := os.OpenFile(tempFilePath,
 		os.O_CREATE|os.O_WRONLY|os.O_APPEND,
-		0644,
+		fileMode,
 	)
 	if err != nil {
-		logger.Error("failed to create temp file", zap.String("file_name", cfg.TempFileName), zap.Error(err))
-		return nil, fmt.Errorf("failed to create temp file: %s: %w", cfg.TempFileName, err)
+		logger.Error("failed to create temp file", zap.String("path", tempFilePath), zap.Error(err))
+		return fmt.Errorf("failed to create temp file: %s: %w", tempFilePath, err)
 	}
 
 	defer tempFile.Close()
 
-	logger.Info("files created", zap.String("file", filePath), zap.String("temp_path", tempFilePath))
+	tempStat, err := tempFile.Stat()
+	if err == nil {
+		metrics.TempFileSize.Set(float64(tempStat.Size()))
+	}
 
-	return &Storage{
-		mu:       &sync.Mutex{},
-		path:     filePath,
-		tempPath: tempFilePath,
-		logger:   logger,
-	}, nil
+	return nil
 }
 
-func (s *Storage) SaveRecord(record *domain.Record) error {
+// checkWritable fails with repository.ErrReadOnlyStorage in read-only mode.
+func (s *Storage) checkWritable(op string) error {
+	if !s.readOnly {
+		return nil
+	}
+
+	s.logger.Warn("write attempted on read-only storage", zap.String("operation", op))
+	return fmt.Errorf("%s: %w", op, repository.ErrReadOnlyStorage)
+}
+
+// SaveRecord appends the record to the main file. Missing timestamps are
+// set from the storage clock.
+func (s *Storage) SaveRecord(ctx context.Context, record *domain.Record) error {
+	err := s.checkWritable("save record")
+	if err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		s.logger.Error("failed to open file", zap.String("path", s.path), zap.Error(err))
-		return fmt.Errorf("failed to open file: %s: %w", s.path, err)
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = s.clock.Now().UTC()
 	}
-	defer file.Close()
 
-	data, err := json.Marshal(record)
-	if err != nil {
-		s.logger.Error("failed to marshal record", zap.Error(err))
-		return fmt.Errorf("failed to marshal record: %w", err)
+	if record.UpdatedAt.IsZero() {
+		record.UpdatedAt = record.CreatedAt
 	}
 
-	data = append(data, '\n')
+	offset := s.mainSize()
 
-	_, err = file.Write(data)
+	line, err := s.appendRecord(s.main, record)
 	if err != nil {
-		s.logger.Error("failed to write record", zap.Error(err))
-		return fmt.Errorf("failed to write record: %w", err)
+		return err
+	}
+
+	s.extendIndex(offset, bytes.NewReader(line))
+	s.rotateIfFull()
+	s.countRecords()
+
+	if s.writesCounter.Add(1)%sizeCheckInterval == 0 {
+		s.checkFileSize()
 	}
 
+	s.emitEvent(record.ID, domain.EventTypeCreated, "")
+
 	s.logger.Info("successfully wrote record")
 	return nil
 }
 
-func (s *Storage) SaveTempRecord(record *domain.Record) error {
+// SaveRecords appends the records to the main file with a single write.
+// Missing timestamps are set from the storage clock.
+func (s *Storage) SaveRecords(ctx context.Context, records []*domain.Record) error {
+	err := s.checkWritable("save records")
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	tempFile, err := os.OpenFile(s.tempPath, os.O_WRONLY|os.O_APPEND, 0644)
+	now := s.clock.Now().UTC()
+
+	var buf bytes.Buffer
+	for _, record := range records {
+		if record.CreatedAt.IsZero() {
+			record.CreatedAt = now
+		}
+
+		if record.UpdatedAt.IsZero() {
+			record.UpdatedAt = record.CreatedAt
+		}
+
+		data, err := s.marshalLine(record)
+		if err != nil {
+			s.logger.Error("failed to marshal record", zap.Int64("id", record.ID), zap.Error(err))
+			return fmt.Errorf("failed to marshal record %d: %w", record.ID, err)
+		}
+
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	offset := s.mainSize()
+	lines := buf.Bytes()
+
+	_, err = s.main.Write(lines)
 	if err != nil {
-		s.logger.Error("failed to open temp file", zap.String("path", s.tempPath), zap.Error(err))
-		return fmt.Errorf("failed to open temp file: %s: %w", s.tempPath, err)
+		s.logger.Error("failed to write records", zap.String("path", s.path), zap.Error(err))
+		return fmt.Errorf("failed to write records: %s: %w", s.path, err)
 	}
-	defer tempFile.Close()
 
-	data, err := json.Marshal(record)
+	s.extendIndex(offset, bytes.NewReader(lines))
+	s.rotateIfFull()
+
+	s.countRecords()
+
+	n := int64(len(records))
+
+	if written := s.writesCounter.Add(n); written/sizeCheckInterval != (written-n)/sizeCheckInterval {
+		s.checkFileSize()
+	}
+
+	for _, record := range records {
+		s.emitEvent(record.ID, domain.EventTypeCreated, "")
+	}
+
+	s.logger.Info("successfully wrote records", zap.Int("count", len(records)))
+	return nil
+}
+
+// SaveTempRecord appends the record to the temp file. It fails with
+// repository.ErrTempFileFull once the temp file reaches the configured
+// maximum size.
+func (s *Storage) SaveTempRecord(ctx context.Context, record *domain.Record) error {
+	err := s.checkWritable("save temp record")
 	if err != nil {
-		s.logger.Error("failed to marshal temp record", zap.Error(err))
-		return fmt.Errorf("failed to marshal temp record: %w", err)
+		return err
 	}
 
-	data = append(data, '\n')
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	size := s.temp.size
+	if s.maxTempSize > 0 && size >= s.maxTempSize {
+		s.logger.Error("temp file is full",
+			zap.String("path", s.tempPath),
+			zap.Int64("size_bytes", size),
+			zap.Int64("max_size_bytes", s.maxTempSize),
+		)
+		return fmt.Errorf("%s: %w", s.tempPath, repository.ErrTempFileFull)
+	}
 
-	_, err = tempFile.Write(data)
+	_, err = s.appendRecord(s.temp, record)
 	if err != nil {
-		s.logger.Error("failed to write temp record", zap.Error(err))
-		return fmt.Errorf("failed to write temp record: %w", err)
+		return err
 	}
 
+	metrics.TempFileSize.Set(float64(s.temp.size))
+
 	s.logger.Info("successfully wrote temp record")
 	return nil
 }
 
-func (s *Storage) LoadTempRecords() ([]domain.Record, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// appendRecord writes the record as a JSON line to the appender and returns
+// the line.
+func (s *Storage) appendRecord(a *appender, record *domain.Record) ([]byte, error) {
+	data, err := s.marshalLine(record)
+	if err != nil {
+		s.logger.Error("failed to marshal record", zap.Error(err))
+		return nil, fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	data = append(data, '\n')
+
+	_, err = a.Write(data)
+	if err != nil {
+		s.logger.Error("failed to write record", zap.String("path", a.path), zap.Error(err))
+		return nil, fmt.Errorf("failed to write record: %s: %w", a.path, err)
+	}
+
+	return data, nil
+}
+
+// checkFileSize updates the file size gauge and warns when the main file
+// grows past the configured limit.
+func (s *Storage) checkFileSize() {
+	size := s.main.size
+	metrics.StorageFileSize.Set(float64(size))
+
+	if s.warnFileSize > 0 && size > s.warnFileSize {
+		s.logger.Warn("storage file is too large, consider compacting or rotating it",
+			zap.String("path", s.path),
+			zap.Int64("size_bytes", size),
+			zap.Int64("warn_size_bytes", s.warnFileSize),
+		)
+	}
+}
+
+// LoadTempRecords returns the temp records deduplicated by ID. Malformed
+// lines are handled according to the strict decode setting. In read-only mode
+// a missing temp file holds no records.
+func (s *Storage) LoadTempRecords(ctx context.Context) ([]domain.Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.loadTempRecords(ctx)
+}
 
-	tempFile, err := os.Open(s.tempPath)
+// loadTempRecords implements LoadTempRecords. The caller must hold the lock.
+func (s *Storage) loadTempRecords(ctx context.Context) ([]domain.Record, error) {
+	tempFile, err := s.openForRead(s.tempPath)
+	if s.readOnly && errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to open temp file: %w", err)
 	}
 	defer tempFile.Close()
 
 	var records []domain.Record
-	decoder := json.NewDecoder(tempFile)
+	err = s.scanRecords(ctx, tempFile, s.tempPath, func(rec *domain.Record) bool {
+		records = append(records, *rec)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load temp records: %w", err)
+	}
+
+	return s.dedupTempRecords(records), nil
+}
+
+// scanRecords decodes the JSON lines read from the file at path and calls fn
+// for every record until it returns false. Blank lines and, in the main file,
+// the lines of deleted records and superseded versions are ignored. In strict
+// decode mode a malformed line aborts the scan with its line number,
+// otherwise malformed lines are skipped and reported with a single warning.
+// ctx is checked every ctxCheckInterval lines.
+func (s *Storage) scanRecords(ctx context.Context, r io.Reader, path string, fn func(rec *domain.Record) bool) error {
+	lineNum := 0
+	skipped := 0
+
+	defer func() {
+		if skipped > 0 {
+			s.logger.Warn("skipped malformed records", zap.String("path", path), zap.Int("skipped", skipped))
+		}
+	}()
+
+	var offset int64
+	scanner := bufio.NewScanner(r)
+	scanner.Split(splitLines)
+	for scanner.Scan() {
+		lineNum++
+		lineOffset := offset
+		offset += int64(len(scanner.Bytes()))
+
+		if lineNum%ctxCheckInterval == 0 {
+			err := ctx.Err()
+			if err != nil {
+				return err
+			}
+		}
+
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
 
-	for {
 		var rec domain.Record
-		err = decoder.Decode(&rec)
+		err := s.unmarshalLine(line, &rec)
+		if errors.Is(err, errChecksumMismatch) {
+			s.logger.Error("skipping corrupt record", zap.String("path", path), zap.Int("line", lineNum), zap.Error(err))
+			if s.strictDecode {
+				return fmt.Errorf("failed to decode record at line %d: %s: %w", lineNum, path, err)
+			}
+
+			skipped++
+			continue
+		}
 		if err != nil {
-			if errors.Is(err, io.EOF) {
-				break
+			if s.strictDecode {
+				s.logger.Error("malformed record", zap.String("path", path), zap.Int("line", lineNum), zap.Error(err))
+				return fmt.Errorf("failed to decode record at line %d: %s: %w", lineNum, path, err)
 			}
 
-			return nil, fmt.Errorf("failed to decode temp record: %w", err)
+			skipped++
+			continue
+		}
+
+		if path == s.path && !s.current(rec.ID, lineOffset) {
+			continue
+		}
+
+		if !fn(&rec) {
+			return nil
 		}
+	}
 
-		records = append(records, rec)
+	err := scanner.Err()
+	if err != nil {
+		s.logger.Error("failed to scan file", zap.String("path", path), zap.Error(err))
+		return fmt.Errorf("failed to scan file: %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// dedupTempRecords keeps a single record per ID, preferring the most recently
+// updated one and, on ties, the last one written. Records keep the position
+// of the first occurrence of their ID.
+func (s *Storage) dedupTempRecords(records []domain.Record) []domain.Record {
+	positions := make(map[int64]int, len(records))
+	deduped := make([]domain.Record, 0, len(records))
+
+	for _, rec := range records {
+		pos, ok := positions[rec.ID]
+		if !ok {
+			positions[rec.ID] = len(deduped)
+			deduped = append(deduped, rec)
+			continue
+		}
+
+		if !rec.UpdatedAt.Before(deduped[pos].UpdatedAt) {
+			deduped[pos] = rec
+		}
+	}
+
+	if duplicates := len(records) - len(deduped); duplicates > 0 {
+		s.logger.Warn("found duplicate temp records", zap.Int("duplicates", duplicates))
+	}
+
+	return deduped
+}
+
+// GetRecord reads the record at its indexed offset. Records missing from the
+// index are looked up by scanning the main file, which stops with the context
+// error once ctx is done.
+func (s *Storage) GetRecord(ctx context.Context, id int64) (*domain.Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.getRecord(ctx, id)
+}
+
+// getRecord implements GetRecord. The caller must hold the lock.
+func (s *Storage) getRecord(ctx context.Context, id int64) (*domain.Record, error) {
+	if s.isDeleted(id) {
+		return nil, fmt.Errorf("record with ID %d: %w", id, repository.ErrRecordNotFound)
+	}
+
+	rec, ok := s.indexedRecord(id)
+	if ok {
+		return rec, nil
+	}
+
+	file, err := s.openMain()
+	if err != nil {
+		s.logger.Error("failed to open file", zap.String("path", s.path), zap.Error(err))
+		return nil, fmt.Errorf("failed to open file: %s: %w", s.path, err)
+	}
+	defer file.Close()
+
+	var found *domain.Record
+	err = s.scanRecords(ctx, file, s.path, func(rec *domain.Record) bool {
+		if rec.ID == id {
+			found = rec
+			return false
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("record with ID %d: %w", id, repository.ErrRecordNotFound)
+	}
+
+	return found, nil
+}
+
+// GetRecords reads the records with the given IDs in a single scan.
+// Like GetRecord, the latest update of an ID or else its first stored record
+// is returned.
+func (s *Storage) GetRecords(ctx context.Context, ids []int64) (map[int64]*domain.Record, error) {
+	if len(ids) == 0 {
+		return make(map[int64]*domain.Record), nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.getRecords(ctx, ids)
+}
+
+// getRecords implements GetRecords for a non-empty ID list.
+// The caller must hold the lock.
+func (s *Storage) getRecords(ctx context.Context, ids []int64) (map[int64]*domain.Record, error) {
+	wanted := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	records := make(map[int64]*domain.Record, len(ids))
+
+	file, err := s.openMain()
+	if err != nil {
+		s.logger.Error("failed to open file", zap.String("path", s.path), zap.Error(err))
+		return nil, fmt.Errorf("failed to open file: %s: %w", s.path, err)
+	}
+	defer file.Close()
+
+	err = s.scanRecords(ctx, file, s.path, func(rec *domain.Record) bool {
+		if wanted[rec.ID] {
+			if _, ok := records[rec.ID]; !ok {
+				records[rec.ID] = rec
+			}
+		}
+
+		return len(records) < len(wanted)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return records, nil
 }
 
-func (s *Storage) GetRecord(id int64) (*domain.Record, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// GetAllRecords returns all records matching the filter options in file order.
+// The scan stops with the context error once ctx is done.
+func (s *Storage) GetAllRecords(ctx context.Context, opts ...repository.FilterOption) ([]domain.Record, error) {
+	filter := repository.NewFilter(opts...)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	file, err := os.Open(s.path)
+	file, err := s.openMain()
 	if err != nil {
 		s.logger.Error("failed to open file", zap.String("path", s.path), zap.Error(err))
 		return nil, fmt.Errorf("failed to open file: %s: %w", s.path, err)
 	}
 	defer file.Close()
 
+	records := make([]domain.Record, 0)
+
+	var offset int64
+	lineNum := 0
 	scanner := bufio.NewScanner(file)
+	scanner.Split(splitLines)
 	for scanner.Scan() {
+		lineNum++
+		lineOffset := offset
+		offset += int64(len(scanner.Bytes()))
+
+		if lineNum%ctxCheckInterval == 0 {
+			err = ctx.Err()
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		var rec domain.Record
-		err = json.Unmarshal(scanner.Bytes(), &rec)
-		if err != nil {
+		err = s.unmarshalLine(scanner.Bytes(), &rec)
+		if err != nil || !s.current(rec.ID, lineOffset) {
 			continue
 		}
 
-		if rec.ID == id {
-			return &rec, nil
+		if filter.Match(&rec) {
+			records = append(records, rec)
 		}
 	}
 
@@ -188,37 +961,447 @@ func (s *Storage) GetRecord(id int64) (*domain.Record, error) {
 		return nil, fmt.Errorf("failed to scan file: %s: %w", s.path, err)
 	}
 
-	return nil, fmt.Errorf("record with ID %d not found", id)
+	return records, nil
 }
 
-func (s *Storage) ClearTempFile() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// GetRecordsBySource returns all records created by the given source.
+func (s *Storage) GetRecordsBySource(ctx context.Context, source string) ([]domain.Record, error) {
+	return s.GetAllRecords(ctx, repository.WithSource(source))
+}
+
+// GetRecordsSince returns the records created or updated at or after since,
+// ordered by UpdatedAt.
+func (s *Storage) GetRecordsSince(ctx context.Context, since time.Time) ([]domain.Record, error) {
+	records := make([]domain.Record, 0)
+
+	err := s.ForEachRecord(ctx, func(rec *domain.Record) error {
+		if rec.ModifiedSince(since) {
+			records = append(records, *rec)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	repository.SortByUpdatedAt(records)
+	return records, nil
+}
+
+// FindRecordsByTag returns all records labeled with the tag.
+func (s *Storage) FindRecordsByTag(ctx context.Context, tag string) ([]domain.Record, error) {
+	return s.GetAllRecords(ctx, repository.WithTag(tag))
+}
+
+// SearchRecords returns the records matching the query ordered by ID.
+func (s *Storage) SearchRecords(ctx context.Context, query repository.SearchQuery) ([]domain.Record, error) {
+	records := make([]domain.Record, 0)
+
+	err := s.ForEachRecord(ctx, func(rec *domain.Record) error {
+		if query.Match(rec) {
+			records = append(records, *rec)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(records, func(a, b domain.Record) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return records, nil
+}
+
+// ForEachRecord calls fn for every record in file order without loading the
+// whole file into memory. Iteration stops at the first error returned by fn
+// or with the context error once ctx is done.
+func (s *Storage) ForEachRecord(ctx context.Context, fn func(rec *domain.Record) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	file, err := s.openMain()
+	if err != nil {
+		s.logger.Error("failed to open file", zap.String("path", s.path), zap.Error(err))
+		return fmt.Errorf("failed to open file: %s: %w", s.path, err)
+	}
+	defer file.Close()
+
+	var offset int64
+	lineNum := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Split(splitLines)
+	for scanner.Scan() {
+		lineNum++
+		lineOffset := offset
+		offset += int64(len(scanner.Bytes()))
+
+		if lineNum%ctxCheckInterval == 0 {
+			err = ctx.Err()
+			if err != nil {
+				return err
+			}
+		}
+
+		var rec domain.Record
+		err = s.unmarshalLine(scanner.Bytes(), &rec)
+		if err != nil || !s.current(rec.ID, lineOffset) {
+			continue
+		}
+
+		err = fn(&rec)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		s.logger.Error("failed to scan file", zap.String("path", s.path), zap.Error(err))
+		return fmt.Errorf("failed to scan file: %s: %w", s.path, err)
+	}
+
+	return nil
+}
+
+// WriteTo streams the main file as NDJSON to w. It implements io.WriterTo.
+// The lines of deleted records and superseded versions are left out and
+// sealed lines are written opened.
+func (s *Storage) WriteTo(w io.Writer) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	file, err := s.openMain()
+	if err != nil {
+		s.logger.Error("failed to open file", zap.String("path", s.path), zap.Error(err))
+		return 0, fmt.Errorf("failed to open file: %s: %w", s.path, err)
+	}
+	defer file.Close()
+
+	if len(s.deleted) == 0 && len(s.updated) == 0 && s.keys == nil {
+		n, err := io.Copy(w, file)
+		if err != nil {
+			return n, fmt.Errorf("failed to copy file: %s: %w", s.path, err)
+		}
+
+		return n, nil
+	}
+
+	var n, offset int64
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if id, ok := s.lineRecordID(line); !ok || s.current(id, offset) {
+			out := line
+			if s.keys != nil {
+				if plain, err := s.openLine(line); err == nil {
+					out = plain
+				}
+			}
+
+			written, werr := w.Write(out)
+			n += int64(written)
+			if werr != nil {
+				return n, fmt.Errorf("failed to copy file: %s: %w", s.path, werr)
+			}
+		}
+		offset += int64(len(line))
+
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return n, fmt.Errorf("failed to copy file: %s: %w", s.path, err)
+		}
+	}
+}
+
+// ListAllLinks returns the sorted set of distinct links across all records.
+func (s *Storage) ListAllLinks(ctx context.Context) ([]string, error) {
+	seen := make(map[string]struct{})
+
+	err := s.ForEachRecord(ctx, func(rec *domain.Record) error {
+		for link := range rec.Links {
+			seen[link] = struct{}{}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]string, 0, len(seen))
+	for link := range seen {
+		links = append(links, link)
+	}
+	slices.Sort(links)
+
+	return links, nil
+}
+
+// ListRecordIDs returns the IDs of all records in file order, decoding only
+// the ID field of each line.
+func (s *Storage) ListRecordIDs(ctx context.Context) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.listRecordIDs(ctx)
+}
+
+// listRecordIDs implements ListRecordIDs. The caller must hold the lock.
+func (s *Storage) listRecordIDs(ctx context.Context) ([]int64, error) {
+	file, err := s.openMain()
+	if err != nil {
+		s.logger.Error("failed to open file", zap.String("path", s.path), zap.Error(err))
+		return nil, fmt.Errorf("failed to open file: %s: %w", s.path, err)
+	}
+	defer file.Close()
+
+	ids := make([]int64, 0)
+
+	var offset int64
+	lineNum := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Split(splitLines)
+	for scanner.Scan() {
+		lineNum++
+		lineOffset := offset
+		offset += int64(len(scanner.Bytes()))
+
+		if lineNum%ctxCheckInterval == 0 {
+			err = ctx.Err()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if id, ok := s.lineRecordID(scanner.Bytes()); ok && s.current(id, lineOffset) {
+			ids = append(ids, id)
+		}
+	}
 
-	err := os.WriteFile(s.tempPath, []byte{}, 0644)
+	err = scanner.Err()
+	if err != nil {
+		s.logger.Error("failed to scan file", zap.String("path", s.path), zap.Error(err))
+		return nil, fmt.Errorf("failed to scan file: %s: %w", s.path, err)
+	}
 
-	return err
+	return ids, nil
 }
 
-func (s *Storage) LoadLastLinksNum() int64 {
+// ListRecords returns a page of the records ordered by ID. The IDs are read
+// first to pick the page, then the records of the page are read in a second
+// scan, so only the page is decoded and kept in memory.
+func (s *Storage) ListRecords(ctx context.Context, offset, limit int, order string) ([]domain.Record, error) {
+	err := repository.ValidatePage(offset, limit, order)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids, err := s.listRecordIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	page := repository.PageIDs(ids, offset, limit, order)
+	records := make([]domain.Record, 0, len(page))
+	if len(page) == 0 {
+		return records, nil
+	}
+
+	found, err := s.getRecords(ctx, page)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range page {
+		if rec, ok := found[id]; ok {
+			records = append(records, *rec)
+		}
+	}
+
+	return records, nil
+}
+
+// ClearTempFile replaces the temp file with an empty one. The empty file is
+// created and synced next to the temp file and renamed over it, so the temp
+// file is either intact or empty, never partially truncated.
+func (s *Storage) ClearTempFile(ctx context.Context) error {
+	err := s.checkWritable("clear temp file")
+	if err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	file, err := os.Open(s.path)
+	return s.clearTempFile()
+}
+
+// clearTempFile implements ClearTempFile. The caller must hold the lock.
+func (s *Storage) clearTempFile() error {
+	newPath := s.tempPath + ".new"
+	file, err := os.OpenFile(newPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, s.fileMode)
 	if err != nil {
-		s.logger.Error("failed to open file", zap.String("path", s.path), zap.Error(err))
-		return 0
+		s.logger.Error("failed to create file", zap.String("path", newPath), zap.Error(err))
+		return fmt.Errorf("failed to create file: %s: %w", newPath, err)
 	}
+	defer os.Remove(newPath)
 	defer file.Close()
 
-	stat, err := file.Stat()
+	err = file.Sync()
+	if err != nil {
+		s.logger.Error("failed to sync file", zap.String("path", newPath), zap.Error(err))
+		return fmt.Errorf("failed to sync file: %s: %w", newPath, err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		s.logger.Error("failed to close file", zap.String("path", newPath), zap.Error(err))
+		return fmt.Errorf("failed to close file: %s: %w", newPath, err)
+	}
+
+	err = os.Rename(newPath, s.tempPath)
+	if err != nil {
+		s.logger.Error("failed to rename file", zap.String("path", newPath), zap.Error(err))
+		return fmt.Errorf("failed to rename file: %s: %w", newPath, err)
+	}
+
+	err = s.temp.reopen()
+	if err != nil {
+		s.logger.Error("failed to reopen file", zap.String("path", s.tempPath), zap.Error(err))
+		return fmt.Errorf("failed to reopen file: %s: %w", s.tempPath, err)
+	}
+
+	metrics.TempFileSize.Set(0)
+	return nil
+}
+
+// Len returns the number of stored records without reading the main file.
+// It counts the live IDs of the index, so deleted records, superseded
+// versions and malformed lines are not counted. In strict decode mode the
+// records after a malformed line are not indexed and not counted either.
+func (s *Storage) Len() int64 {
+	return s.records.Load()
+}
+
+func (s *Storage) LoadLastLinksNum(ctx context.Context) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.lastLinksNum()
+}
+
+// lastLinksNum returns the highest ID in the main file: the ID of its last
+// line or a higher indexed one, since tombstones and update lines are
+// appended for older IDs. The caller must hold the lock.
+func (s *Storage) lastLinksNum() int64 {
+	last := s.lastLineID()
+	for id := range s.offsets {
+		last = max(last, id)
+	}
+	for id := range s.deleted {
+		last = max(last, id)
+	}
+
+	return last
+}
+
+// lastLineID returns the ID of the last line of the main file, which is in
+// the last sealed segment right after a rotation.
+func (s *Storage) lastLineID() int64 {
+	paths, _ := s.mainFiles()
+
+	var lastLine []byte
+	for i := len(paths) - 1; i >= 0 && len(lastLine) == 0; i-- {
+		file, err := s.openMainFile(paths[i])
+		if err != nil {
+			s.logger.Error("failed to open file", zap.String("path", paths[i]), zap.Error(err))
+			return 0
+		}
+
+		lastLine, err = lastLineOf(file)
+		file.Close()
+		if err != nil {
+			s.logger.Error("failed to read last line", zap.Error(err))
+			return 0
+		}
+	}
+
+	if len(lastLine) == 0 {
+		return 0
+	}
+
+	var rec domain.Record
+	err := s.unmarshalLine(lastLine, &rec)
 	if err != nil {
-		s.logger.Error("failed to stat file", zap.Error(err))
+		s.logger.Error("failed to unmarshal last record", zap.Error(err))
 		return 0
 	}
 
+	return rec.ID
+}
+
+// validateLastRecord checks that the last line of a non-empty file is a valid record.
+func validateLastRecord(file *os.File) error {
+	lastLine, err := readLastLine(file)
+	if err != nil {
+		return err
+	}
+
+	if len(lastLine) == 0 {
+		return nil
+	}
+
+	var rec domain.Record
+	err = json.Unmarshal(lastLine, &rec)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal last record: %w", err)
+	}
+
+	return nil
+}
+
+// lastLineOf returns the last line of a file of the main file like
+// readLastLine. A compressed segment is read up to its end.
+func lastLineOf(r io.Reader) ([]byte, error) {
+	if file, ok := r.(*os.File); ok {
+		return readLastLine(file)
+	}
+
+	var lastLine []byte
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			lastLine = line
+		}
+		if errors.Is(err, io.EOF) {
+			return bytes.TrimSuffix(lastLine, []byte{'\n'}), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+}
+
+// readLastLine returns the last line of the file without the trailing newline,
+// or nil if the file is empty.
+func readLastLine(file *os.File) ([]byte, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
 	if stat.Size() == 0 {
-		return 0
+		return nil, nil
 	}
 
 	buf := make([]byte, 1)
@@ -228,8 +1411,7 @@ func (s *Storage) LoadLastLinksNum() int64 {
 	for pos >= 0 {
 		_, err = file.ReadAt(buf, pos)
 		if err != nil {
-			s.logger.Error("failed to read file", zap.Error(err))
-			return 0
+			return nil, fmt.Errorf("failed to read file: %w", err)
 		}
 
 		if buf[0] == '\n' && pos != stat.Size()-1 {
@@ -240,12 +1422,5 @@ func (s *Storage) LoadLastLinksNum() int64 {
 		pos--
 	}
 
-	var rec domain.Record
-	err = json.Unmarshal(lastLine, &rec)
-	if err != nil {
-		s.logger.Error("failed to unmarshal last record", zap.Error(err))
-		return 0
-	}
-
-	return rec.ID
+	return bytes.TrimSuffix(lastLine, []byte{'\n'}), nil
 }
@@ -0,0 +1,1057 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+	"link-service/internal/repository/repositorytest"
+)
+
+var testNow = time.Date(2025, 11, 30, 12, 0, 0, 0, time.UTC)
+
+func newTestConfig(t *testing.T) *Config {
+	t.Helper()
+
+	return &Config{
+		DirPath:      t.TempDir(),
+		FileName:     "data.json",
+		TempFileName: "temp.json",
+	}
+}
+
+func TestNewExistingFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name:    "empty file",
+			content: "",
+			wantErr: false,
+		},
+		{
+			name:    "valid records",
+			content: "{\"links\":{\"a.com\":\"available\"},\"links_num\":1}\n{\"links\":{},\"links_num\":2}\n",
+			wantErr: false,
+		},
+		{
+			name:    "corrupted last line",
+			content: "{\"links\":{},\"links_num\":1}\n\x00\x00\x00\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+
+			err := os.WriteFile(filepath.Join(cfg.DirPath, cfg.FileName), []byte(tt.content), 0644)
+			assert.NoError(t, err)
+
+			_, err = New(cfg, clock.NewFake(testNow), zap.NewNop())
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "cmd/verify")
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	storage, err := New(newTestConfig(t), clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	t.Cleanup(func() { storage.Close() })
+
+	return storage
+}
+
+func TestConformance(t *testing.T) {
+	repositorytest.RunSuite(t, func(t *testing.T) repository.Repository {
+		return newTestStorage(t)
+	})
+}
+
+func TestListRecordIDs(t *testing.T) {
+	storage := newTestStorage(t)
+
+	ids, err := storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+
+	for _, id := range []int64{1, 2, 3} {
+		err = storage.SaveRecord(context.Background(), &domain.Record{
+			Links: map[string]string{"a.com": "available"},
+			ID:    id,
+		})
+		assert.NoError(t, err)
+	}
+
+	ids, err = storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+}
+
+func TestGetAllRecordsFilter(t *testing.T) {
+	storage := newTestStorage(t)
+
+	for id, linksCount := range []int{1, 3, 5} {
+		rec := &domain.Record{
+			Links: make(map[string]string),
+			ID:    int64(id + 1),
+		}
+		for i := range linksCount {
+			rec.Links[strconv.Itoa(i)+".com"] = "available"
+		}
+
+		err := storage.SaveRecord(context.Background(), rec)
+		assert.NoError(t, err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    []repository.FilterOption
+		wantIDs []int64
+	}{
+		{
+			name:    "no filter",
+			wantIDs: []int64{1, 2, 3},
+		},
+		{
+			name:    "min links",
+			opts:    []repository.FilterOption{repository.WithMinLinks(3)},
+			wantIDs: []int64{2, 3},
+		},
+		{
+			name:    "max links",
+			opts:    []repository.FilterOption{repository.WithMaxLinks(3)},
+			wantIDs: []int64{1, 2},
+		},
+		{
+			name:    "range",
+			opts:    []repository.FilterOption{repository.WithMinLinks(2), repository.WithMaxLinks(4)},
+			wantIDs: []int64{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := storage.GetAllRecords(context.Background(), tt.opts...)
+			assert.NoError(t, err)
+
+			ids := make([]int64, 0, len(records))
+			for _, rec := range records {
+				ids = append(ids, rec.ID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+}
+
+func TestJSONArrayRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []domain.Record
+		want    string
+	}{
+		{
+			name:    "empty dataset",
+			records: nil,
+			want:    "[]",
+		},
+		{
+			name: "several records",
+			records: []domain.Record{
+				{Links: map[string]string{"a.com": "available"}, ID: 1},
+				{Links: map[string]string{"b.com": "not available"}, ID: 2},
+				{Links: map[string]string{}, ID: 5},
+			},
+			want: `[{"links":{"a.com":"available"},"links_num":1,` +
+				`"created_at":"2025-11-30T12:00:00Z","updated_at":"2025-11-30T12:00:00Z"},` +
+				`{"links":{"b.com":"not available"},"links_num":2,` +
+				`"created_at":"2025-11-30T12:00:00Z","updated_at":"2025-11-30T12:00:00Z"},` +
+				`{"links":{},"links_num":5,` +
+				`"created_at":"2025-11-30T12:00:00Z","updated_at":"2025-11-30T12:00:00Z"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := newTestStorage(t)
+			for _, rec := range tt.records {
+				err := src.SaveRecord(context.Background(), &rec)
+				assert.NoError(t, err)
+			}
+
+			var buf bytes.Buffer
+			err := repository.WriteJSONArray(context.Background(), &buf, src)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, buf.String())
+
+			dst := newTestStorage(t)
+			count, err := repository.ReadJSONArray(&buf, func(rec *domain.Record) error {
+				return dst.SaveRecord(context.Background(), rec)
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, len(tt.records), count)
+
+			srcRecords, err := src.GetAllRecords(context.Background())
+			assert.NoError(t, err)
+			dstRecords, err := dst.GetAllRecords(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, srcRecords, dstRecords)
+		})
+	}
+}
+
+func TestSaveRecordTimestamps(t *testing.T) {
+	clk := clock.NewFake(testNow)
+	storage, err := New(newTestConfig(t), clk, zap.NewNop())
+	assert.NoError(t, err)
+
+	err = storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{}, ID: 1})
+	assert.NoError(t, err)
+
+	clk.Advance(time.Hour)
+
+	createdAt := testNow.Add(-time.Minute)
+	err = storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{}, ID: 2, CreatedAt: createdAt})
+	assert.NoError(t, err)
+
+	rec, err := storage.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, testNow, rec.CreatedAt)
+	assert.Equal(t, testNow, rec.UpdatedAt)
+
+	rec, err = storage.GetRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, createdAt, rec.CreatedAt)
+	assert.Equal(t, createdAt, rec.UpdatedAt)
+}
+
+func TestNewSamePaths(t *testing.T) {
+	tests := []struct {
+		name         string
+		fileName     string
+		tempFileName string
+	}{
+		{
+			name:         "identical names",
+			fileName:     "data.json",
+			tempFileName: "data.json",
+		},
+		{
+			name:         "same resolved path",
+			fileName:     "data.json",
+			tempFileName: "./sub/../data.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			cfg.FileName = tt.fileName
+			cfg.TempFileName = tt.tempFileName
+
+			_, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+			assert.ErrorContains(t, err, "resolve to the same path")
+		})
+	}
+}
+
+func TestNewFileModes(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.DirPath = filepath.Join(cfg.DirPath, "storage")
+	cfg.FileMode = 0600
+	cfg.DirMode = 0700
+
+	_, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	for path, want := range map[string]os.FileMode{
+		cfg.DirPath:                                  0700,
+		filepath.Join(cfg.DirPath, cfg.FileName):     0600,
+		filepath.Join(cfg.DirPath, cfg.TempFileName): 0600,
+	} {
+		stat, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, want, stat.Mode().Perm(), path)
+	}
+}
+
+func TestListAllLinks(t *testing.T) {
+	storage := newTestStorage(t)
+
+	links, err := storage.ListAllLinks(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, links)
+
+	for id, recLinks := range []map[string]string{
+		{"c.com": "available", "a.com": "available"},
+		{"b.com": "not available", "a.com": "not available"},
+		{"c.com": "available", "d.com": "available"},
+	} {
+		err = storage.SaveRecord(context.Background(), &domain.Record{Links: recLinks, ID: int64(id + 1)})
+		assert.NoError(t, err)
+	}
+
+	links, err = storage.ListAllLinks(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.com", "b.com", "c.com", "d.com"}, links)
+}
+
+func TestLen(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	err := os.WriteFile(filepath.Join(cfg.DirPath, cfg.FileName), []byte("{\"links\":{},\"links_num\":1}\n{\"links\":{},\"links_num\":2}\n"), 0644)
+	assert.NoError(t, err)
+
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), storage.Len())
+
+	err = storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{}, ID: 3})
+	assert.NoError(t, err)
+
+	err = storage.SaveRecords(context.Background(), []*domain.Record{{Links: map[string]string{}, ID: 4}, {Links: map[string]string{}, ID: 5}})
+	assert.NoError(t, err)
+
+	_, _, err = storage.UpsertRecords(context.Background(), []*domain.Record{{Links: map[string]string{}, ID: 1}, {Links: map[string]string{}, ID: 6}})
+	assert.NoError(t, err)
+
+	assert.Equal(t, int64(6), storage.Len())
+}
+
+func TestGetRecordsBySource(t *testing.T) {
+	storage := newTestStorage(t)
+
+	for id, source := range []string{domain.SourceAPI, domain.SourceImport, domain.SourceAPI, ""} {
+		err := storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{}, ID: int64(id + 1), Source: source})
+		assert.NoError(t, err)
+	}
+
+	records, err := storage.GetRecordsBySource(context.Background(), domain.SourceAPI)
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, int64(1), records[0].ID)
+	assert.Equal(t, int64(3), records[1].ID)
+
+	records, err = storage.GetRecordsBySource(context.Background(), domain.SourceScheduler)
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestGetRecordsSince(t *testing.T) {
+	clk := clock.NewFake(testNow)
+	storage, err := New(newTestConfig(t), clk, zap.NewNop())
+	assert.NoError(t, err)
+
+	for id := int64(1); id <= 2; id++ {
+		err = storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{}, ID: id})
+		assert.NoError(t, err)
+		clk.Advance(time.Hour)
+	}
+
+	_, _, err = storage.UpsertRecords(context.Background(), []*domain.Record{{Links: map[string]string{"a.com": "available"}, ID: 1}})
+	assert.NoError(t, err)
+
+	records, err := storage.GetRecordsSince(context.Background(), testNow.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, int64(2), records[0].ID)
+	assert.Equal(t, int64(1), records[1].ID)
+
+	records, err = storage.GetRecordsSince(context.Background(), testNow.Add(3*time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestFindRecordsByTag(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	// A record written before tags existed.
+	err := os.WriteFile(filepath.Join(cfg.DirPath, cfg.FileName),
+		[]byte("{\"links\":{\"old.com\":\"available\"},\"links_num\":1}\n"), 0644)
+	assert.NoError(t, err)
+
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	for id, tags := range [][]string{{"prod"}, {"prod", "docs"}, nil} {
+		err = storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{}, ID: int64(id + 2), Tags: tags})
+		assert.NoError(t, err)
+	}
+
+	tests := []struct {
+		name    string
+		tag     string
+		wantIDs []int64
+	}{
+		{
+			name:    "shared tag",
+			tag:     "prod",
+			wantIDs: []int64{2, 3},
+		},
+		{
+			name:    "single record tag",
+			tag:     "docs",
+			wantIDs: []int64{3},
+		},
+		{
+			name:    "unknown tag",
+			tag:     "staging",
+			wantIDs: []int64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := storage.FindRecordsByTag(context.Background(), tt.tag)
+			assert.NoError(t, err)
+
+			ids := make([]int64, 0, len(records))
+			for _, rec := range records {
+				ids = append(ids, rec.ID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+
+	rec, err := storage.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Empty(t, rec.Tags)
+	assert.False(t, rec.HasTag("prod"))
+}
+
+func TestWriteTo(t *testing.T) {
+	storage := newTestStorage(t)
+
+	for id := range 3 {
+		err := storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{"a.com": "available"}, ID: int64(id + 1)})
+		assert.NoError(t, err)
+	}
+
+	var buf bytes.Buffer
+	n, err := storage.WriteTo(&buf)
+	assert.NoError(t, err)
+
+	want, err := os.ReadFile(storage.path)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(want)), n)
+	assert.Equal(t, want, buf.Bytes())
+}
+
+func TestLoadTempRecordsDedup(t *testing.T) {
+	storage := newTestStorage(t)
+
+	tempRecords := []*domain.Record{
+		{Links: map[string]string{"a.com": "unknown"}, ID: 1},
+		{Links: map[string]string{"b.com": "unknown"}, ID: 2, UpdatedAt: testNow.Add(time.Minute)},
+		{Links: map[string]string{"c.com": "unknown"}, ID: 1},
+		{Links: map[string]string{"d.com": "unknown"}, ID: 2, UpdatedAt: testNow},
+		{Links: map[string]string{"e.com": "unknown"}, ID: 3},
+	}
+	for _, rec := range tempRecords {
+		err := storage.SaveTempRecord(context.Background(), rec)
+		assert.NoError(t, err)
+	}
+
+	records, err := storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Record{
+		{Links: map[string]string{"c.com": "unknown"}, ID: 1},
+		{Links: map[string]string{"b.com": "unknown"}, ID: 2, UpdatedAt: testNow.Add(time.Minute)},
+		{Links: map[string]string{"e.com": "unknown"}, ID: 3},
+	}, records)
+}
+
+func TestStrictDecode(t *testing.T) {
+	content := "{\"links\":{\"a.com\":\"unknown\"},\"links_num\":1}\n" +
+		"{\"links\":{\"b.com\":\n" +
+		"{\"links\":{\"c.com\":\"unknown\"},\"links_num\":3}\n"
+
+	tests := []struct {
+		name         string
+		strictDecode bool
+		wantIDs      []int64
+		wantErr      bool
+	}{
+		{
+			name:         "strict",
+			strictDecode: true,
+			wantErr:      true,
+		},
+		{
+			name:         "lenient",
+			strictDecode: false,
+			wantIDs:      []int64{1, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			cfg.StrictDecode = tt.strictDecode
+
+			for _, name := range []string{cfg.FileName, cfg.TempFileName} {
+				err := os.WriteFile(filepath.Join(cfg.DirPath, name), []byte(content), 0644)
+				assert.NoError(t, err)
+			}
+
+			storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+			assert.NoError(t, err)
+
+			tempRecords, tempErr := storage.LoadTempRecords(context.Background())
+			rec, getErr := storage.GetRecord(context.Background(), 3)
+			if tt.wantErr {
+				assert.ErrorContains(t, tempErr, "line 2")
+				assert.ErrorContains(t, getErr, "line 2")
+				return
+			}
+
+			assert.NoError(t, tempErr)
+			assert.NoError(t, getErr)
+			assert.Equal(t, int64(3), rec.ID)
+
+			ids := make([]int64, 0, len(tempRecords))
+			for _, rec := range tempRecords {
+				ids = append(ids, rec.ID)
+			}
+			assert.Equal(t, tt.wantIDs, ids)
+		})
+	}
+}
+
+func TestSaveTempRecordMaxSize(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.MaxTempFileSizeBytes = 100
+
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	rec := &domain.Record{ID: 1, Links: map[string]string{"https://example.com": "unknown"}}
+
+	err = storage.SaveTempRecord(context.Background(), rec)
+	assert.NoError(t, err)
+
+	err = storage.SaveTempRecord(context.Background(), rec)
+	assert.NoError(t, err)
+
+	err = storage.SaveTempRecord(context.Background(), rec)
+	assert.ErrorIs(t, err, repository.ErrTempFileFull)
+
+	records, err := storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	err = storage.ClearTempFile(context.Background())
+	assert.NoError(t, err)
+
+	err = storage.SaveTempRecord(context.Background(), rec)
+	assert.NoError(t, err)
+}
+
+func TestSaveRecords(t *testing.T) {
+	storage := newTestStorage(t)
+
+	err := storage.SaveRecords(context.Background(), nil)
+	assert.NoError(t, err)
+
+	err = storage.SaveRecords(context.Background(), []*domain.Record{
+		{ID: 1, Links: map[string]string{"a.com": "available"}},
+		{ID: 2, Links: map[string]string{"b.com": "not available"}},
+	})
+	assert.NoError(t, err)
+
+	records, err := storage.GetAllRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, int64(2), records[1].ID)
+	assert.Equal(t, testNow, records[1].CreatedAt)
+	assert.Equal(t, int64(2), storage.LoadLastLinksNum(context.Background()))
+}
+
+func TestNewTempFileInSubdir(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.TempFileName = filepath.Join("subdir", "temp.json")
+
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(cfg.DirPath, "subdir", "temp.json"))
+	assert.NoError(t, err)
+
+	err = storage.SaveTempRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "unknown"}})
+	assert.NoError(t, err)
+}
+
+func TestRecordEvents(t *testing.T) {
+	clk := clock.NewFake(testNow)
+	storage, err := New(newTestConfig(t), clk, zap.NewNop())
+	assert.NoError(t, err)
+
+	err = storage.SaveRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "available"}})
+	assert.NoError(t, err)
+
+	err = storage.SaveRecord(context.Background(), &domain.Record{ID: 2, Links: map[string]string{"b.com": "available"}})
+	assert.NoError(t, err)
+
+	clk.Advance(time.Minute)
+
+	_, _, err = storage.UpsertRecords(context.Background(), []*domain.Record{{ID: 1, Links: map[string]string{"a.com": "not available"}}})
+	assert.NoError(t, err)
+
+	err = storage.SaveRecordEvent(context.Background(), &domain.RecordEvent{
+		RecordID:  1,
+		EventType: domain.EventTypeChecked,
+		Detail:    "a.com: not available",
+	})
+	assert.NoError(t, err)
+
+	events, err := storage.GetRecordEvents(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.RecordEvent{
+		{RecordID: 1, EventType: domain.EventTypeCreated, OccurredAt: testNow},
+		{RecordID: 1, EventType: domain.EventTypeUpdated, OccurredAt: testNow.Add(time.Minute)},
+		{RecordID: 1, EventType: domain.EventTypeChecked, OccurredAt: testNow.Add(time.Minute), Detail: "a.com: not available"},
+	}, events)
+
+	events, err = storage.GetRecordEvents(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestNewCleansDirPath(t *testing.T) {
+	root := t.TempDir()
+	cfg := newTestConfig(t)
+	cfg.DirPath = filepath.Join(root, "sub") + "/../data/./"
+
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "data", "data.json"), storage.path)
+	assert.Equal(t, filepath.Join(root, "data", "temp.json"), storage.tempPath)
+
+	_, err = os.Stat(filepath.Join(root, "sub"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestReadOnly(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	err = storage.SaveRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "available"}})
+	assert.NoError(t, err)
+	assert.NoError(t, storage.Close())
+
+	err = os.Remove(filepath.Join(cfg.DirPath, cfg.TempFileName))
+	assert.NoError(t, err)
+
+	before, err := os.ReadFile(filepath.Join(cfg.DirPath, cfg.FileName))
+	assert.NoError(t, err)
+
+	cfg.ReadOnly = true
+	storage, err = New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	rec := &domain.Record{ID: 2, Links: map[string]string{"b.com": "available"}}
+
+	assert.ErrorIs(t, storage.SaveRecord(context.Background(), rec), repository.ErrReadOnlyStorage)
+	assert.ErrorIs(t, storage.SaveRecords(context.Background(), []*domain.Record{rec}), repository.ErrReadOnlyStorage)
+	assert.ErrorIs(t, storage.SaveTempRecord(context.Background(), rec), repository.ErrReadOnlyStorage)
+	assert.ErrorIs(t, storage.ClearTempFile(context.Background()), repository.ErrReadOnlyStorage)
+	assert.ErrorIs(t, storage.DeleteRecord(context.Background(), 1), repository.ErrReadOnlyStorage)
+	assert.ErrorIs(t, storage.SaveRecordEvent(context.Background(), &domain.RecordEvent{RecordID: 2}), repository.ErrReadOnlyStorage)
+	_, _, err = storage.UpsertRecords(context.Background(), []*domain.Record{rec})
+	assert.ErrorIs(t, err, repository.ErrReadOnlyStorage)
+
+	got, err := storage.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "available", got.Links["a.com"])
+
+	records, err := storage.GetAllRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	tempRecords, err := storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, tempRecords)
+
+	assert.Equal(t, int64(1), storage.LoadLastLinksNum(context.Background()))
+
+	after, err := os.ReadFile(filepath.Join(cfg.DirPath, cfg.FileName))
+	assert.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	_, err = os.Stat(filepath.Join(cfg.DirPath, cfg.TempFileName))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestReadOnlyMissingFile(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.ReadOnly = true
+
+	_, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.Error(t, err)
+}
+
+func TestGetRecordContextCanceled(t *testing.T) {
+	storage := newTestStorage(t)
+
+	records := make([]*domain.Record, 0, 2*ctxCheckInterval)
+	for id := int64(1); id <= 2*ctxCheckInterval; id++ {
+		records = append(records, &domain.Record{ID: id, Links: map[string]string{"a.com": "available"}})
+	}
+	err := storage.SaveRecords(context.Background(), records)
+	assert.NoError(t, err)
+
+	// Only lookups of records missing from the index scan the file.
+	clear(storage.offsets)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = storage.GetRecord(ctx, 2*ctxCheckInterval)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Records before the first check are still found.
+	rec, err := storage.GetRecord(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rec.ID)
+}
+
+func TestScanContextCanceled(t *testing.T) {
+	storage := newTestStorage(t)
+
+	records := make([]*domain.Record, 0, ctxCheckInterval)
+	for id := int64(1); id <= ctxCheckInterval; id++ {
+		records = append(records, &domain.Record{ID: id, Links: map[string]string{"a.com": "available"}})
+	}
+	err := storage.SaveRecords(context.Background(), records)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = storage.GetAllRecords(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = storage.ListRecordIDs(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	err = storage.ForEachRecord(ctx, func(*domain.Record) error { return nil })
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestConcurrentReadsAndWrites(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.FlushInterval = time.Millisecond
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	t.Cleanup(func() { storage.Close() })
+
+	err = storage.SaveRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "available"}})
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			for range 50 {
+				rec, err := storage.GetRecord(context.Background(), 1)
+				assert.NoError(t, err)
+				assert.Equal(t, int64(1), rec.ID)
+
+				_, err = storage.GetAllRecords(context.Background())
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	for id := int64(2); id <= 50; id++ {
+		err := storage.SaveRecord(context.Background(), &domain.Record{ID: id, Links: map[string]string{"b.com": "available"}})
+		assert.NoError(t, err)
+	}
+	wg.Wait()
+
+	ids, err := storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, ids, 50)
+}
+
+func TestClearTempFile(t *testing.T) {
+	cfg := newTestConfig(t)
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	err = storage.SaveTempRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "unknown"}})
+	assert.NoError(t, err)
+
+	err = storage.ClearTempFile(context.Background())
+	assert.NoError(t, err)
+
+	info, err := os.Stat(filepath.Join(cfg.DirPath, cfg.TempFileName))
+	assert.NoError(t, err)
+	assert.Zero(t, info.Size())
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	_, err = os.Stat(filepath.Join(cfg.DirPath, cfg.TempFileName+".new"))
+	assert.True(t, os.IsNotExist(err))
+
+	err = storage.SaveTempRecord(context.Background(), &domain.Record{ID: 2, Links: map[string]string{"b.com": "unknown"}})
+	assert.NoError(t, err)
+
+	records, err := storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+}
+
+func TestReadFrom(t *testing.T) {
+	storage := newTestStorage(t)
+
+	err := storage.SaveRecord(context.Background(), &domain.Record{ID: 5, Links: map[string]string{"a.com": "available"}})
+	assert.NoError(t, err)
+
+	input := "{\"links\":{\"b.com\":\"available\"},\"links_num\":1}\n" +
+		"\n" +
+		"{\"links\":{\"c.com\":\"not available\"},\"links_num\":1}\n"
+
+	n, err := storage.ReadFrom(strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(input)), n)
+
+	ids, err := storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{5, 6, 7}, ids)
+
+	rec, err := storage.GetRecord(context.Background(), 7)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"c.com": "not available"}, rec.Links)
+	assert.Equal(t, testNow, rec.CreatedAt)
+}
+
+func TestReadFromStrictDecode(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.StrictDecode = true
+
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	input := "{\"links\":{\"a.com\":\"available\"}}\n" +
+		"{\"links\":\n" +
+		"{\"links\":{\"c.com\":\"available\"}}\n"
+
+	_, err = storage.ReadFrom(strings.NewReader(input))
+	assert.ErrorContains(t, err, "line 2")
+
+	ids, err := storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1}, ids)
+	assert.Equal(t, int64(1), storage.LoadLastLinksNum(context.Background()))
+}
+
+func TestSyncOnWrite(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.SyncOnWrite = true
+
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	err = storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{"a.com": "available"}, ID: 1})
+	assert.NoError(t, err)
+
+	err = storage.SaveTempRecord(context.Background(), &domain.Record{Links: map[string]string{"b.com": ""}, ID: 2})
+	assert.NoError(t, err)
+
+	rec, err := storage.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a.com": "available"}, rec.Links)
+
+	records, err := storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+}
+
+func TestAppendsBuffered(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.FlushInterval = time.Hour
+
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	err = storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{"a.com": "available"}, ID: 1})
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(storage.path)
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+
+	// Reads flush the buffer first.
+	rec, err := storage.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rec.ID)
+
+	err = storage.SaveTempRecord(context.Background(), &domain.Record{Links: map[string]string{"b.com": ""}, ID: 2})
+	assert.NoError(t, err)
+
+	assert.NoError(t, storage.Close())
+
+	data, err = os.ReadFile(storage.tempPath)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"b.com"`)
+
+	err = storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{"c.com": "available"}, ID: 3})
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
+
+func TestScheduledFlush(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.FlushInterval = 10 * time.Millisecond
+
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	defer storage.Close()
+
+	err = storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{"a.com": "available"}, ID: 1})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		data, err := os.ReadFile(storage.path)
+		return err == nil && len(data) > 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSyncPolicyInterval(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.SyncPolicy = SyncPolicyInterval
+	cfg.SyncInterval = 10 * time.Millisecond
+
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	err = storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{"a.com": "available"}, ID: 1})
+	assert.NoError(t, err)
+
+	err = storage.SaveTempRecord(context.Background(), &domain.Record{Links: map[string]string{"b.com": ""}, ID: 2})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		storage.mu.Lock()
+		defer storage.mu.Unlock()
+
+		return !storage.main.unsynced && !storage.temp.unsynced
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, storage.Close())
+}
+
+func TestSyncPolicyIntervalClose(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.SyncPolicy = SyncPolicyInterval
+	cfg.SyncInterval = time.Hour
+
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	err = storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{"a.com": "available"}, ID: 1})
+	assert.NoError(t, err)
+
+	assert.True(t, storage.main.unsynced)
+	assert.True(t, storage.events.unsynced)
+
+	assert.NoError(t, storage.Close())
+	assert.False(t, storage.main.unsynced)
+	assert.False(t, storage.events.unsynced)
+}
+
+func TestConfigValidateSyncPolicy(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.SyncPolicy = "sometimes"
+	cfg.SyncInterval = -time.Second
+
+	err := cfg.Validate()
+	assert.ErrorContains(t, err, "STORAGE_SYNC_POLICY")
+	assert.ErrorContains(t, err, "STORAGE_SYNC_INTERVAL")
+}
+
+func BenchmarkSaveRecord(b *testing.B) {
+	for _, policy := range []string{SyncPolicyNever, SyncPolicyInterval, SyncPolicyAlways} {
+		b.Run("sync_policy="+policy, func(b *testing.B) {
+			storage, err := New(&Config{
+				DirPath:      b.TempDir(),
+				FileName:     "data.json",
+				TempFileName: "temp.json",
+				SyncPolicy:   policy,
+			}, clock.Real{}, zap.NewNop())
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.Cleanup(func() { storage.Close() })
+
+			links := map[string]string{"a.com": "available", "b.com": "not available"}
+			var id int64
+			for b.Loop() {
+				id++
+				err = storage.SaveRecord(context.Background(), &domain.Record{Links: links, ID: id})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetRecordParallel(b *testing.B) {
+	const size = 10000
+
+	storage, err := New(&Config{
+		DirPath:      b.TempDir(),
+		FileName:     "data.json",
+		TempFileName: "temp.json",
+	}, clock.Real{}, zap.NewNop())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { storage.Close() })
+
+	records := make([]*domain.Record, 0, size)
+	for id := int64(1); id <= size; id++ {
+		records = append(records, &domain.Record{ID: id, Links: map[string]string{"a.com": "available"}})
+	}
+
+	err = storage.SaveRecords(context.Background(), records)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	ctx := context.Background()
+	var next atomic.Int64
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			id := next.Add(1)%size + 1
+			_, err := storage.GetRecord(ctx, id)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
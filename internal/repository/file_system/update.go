@@ -0,0 +1,69 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+)
+
+// update is the line UpdateRecord appends to the main file. Only its
+// supersedes field tells it apart from a record line, so it decodes as the
+// record it carries.
+type update struct {
+	*domain.Record
+	Supersedes bool `json:"supersedes"`
+}
+
+// UpdateRecord appends the record to the main file as an update line that
+// supersedes the stored versions of its ID without rewriting the file. Reads
+// return the latest update line from then on and the next Compact drops the
+// older lines. A zero CreatedAt keeps the stored creation time and UpdatedAt
+// is set from the storage clock. It fails with repository.ErrRecordNotFound
+// if the record is not stored.
+func (s *Storage) UpdateRecord(ctx context.Context, record *domain.Record) error {
+	err := s.checkWritable("update record")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.getRecord(ctx, record.ID)
+	if err != nil {
+		return err
+	}
+
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = stored.CreatedAt
+	}
+	record.UpdatedAt = s.clock.Now().UTC()
+
+	data, err := s.marshalLine(update{Record: record, Supersedes: true})
+	if err != nil {
+		s.logger.Error("failed to marshal record", zap.Int64("id", record.ID), zap.Error(err))
+		return fmt.Errorf("failed to marshal record %d: %w", record.ID, err)
+	}
+
+	data = append(data, '\n')
+	offset := s.mainSize()
+
+	_, err = s.main.Write(data)
+	if err != nil {
+		s.logger.Error("failed to write record", zap.String("path", s.path), zap.Error(err))
+		return fmt.Errorf("failed to write record: %s: %w", s.path, err)
+	}
+
+	// Indexing the update line makes it the current version of the record.
+	s.extendIndex(offset, bytes.NewReader(data))
+	s.rotateIfFull()
+
+	s.emitEvent(record.ID, domain.EventTypeUpdated, "")
+
+	s.logger.Info("successfully updated record", zap.Int64("id", record.ID))
+	return nil
+}
@@ -0,0 +1,166 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+func TestUpdateRecord(t *testing.T) {
+	clk := clock.NewFake(testNow)
+	cfg := newTestConfig(t)
+	storage, err := New(cfg, clk, zap.NewNop())
+	assert.NoError(t, err)
+
+	for id := int64(1); id <= 2; id++ {
+		err = storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{"a.com": "available"}, ID: id})
+		assert.NoError(t, err)
+	}
+
+	clk.Advance(time.Minute)
+
+	err = storage.UpdateRecord(context.Background(), &domain.Record{Links: map[string]string{"a.com": "not available"}, ID: 1})
+	assert.NoError(t, err)
+
+	rec, err := storage.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "not available", rec.Links["a.com"])
+	assert.Equal(t, testNow, rec.CreatedAt)
+	assert.Equal(t, testNow.Add(time.Minute), rec.UpdatedAt)
+	assert.Equal(t, int64(2), storage.Len())
+	assert.Equal(t, int64(2), storage.LoadLastLinksNum(context.Background()))
+
+	err = storage.UpdateRecord(context.Background(), &domain.Record{ID: 3})
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	// Only the update line of the record is read.
+	ids, err := storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 1}, ids)
+
+	records, err := storage.GetAllRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "not available", records[1].Links["a.com"])
+
+	found, err := storage.GetRecords(context.Background(), []int64{1})
+	assert.NoError(t, err)
+	assert.Equal(t, "not available", found[1].Links["a.com"])
+
+	var buf bytes.Buffer
+	_, err = storage.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, bytes.Count(buf.Bytes(), []byte{'\n'}))
+
+	events, err := storage.GetRecordEvents(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.EventTypeUpdated, events[len(events)-1].EventType)
+
+	// The update survives a restart, from the index and from the main file.
+	assert.NoError(t, storage.Close())
+	for _, reindex := range []bool{false, true} {
+		storage, err = New(cfg, clk, zap.NewNop())
+		assert.NoError(t, err)
+
+		if reindex {
+			assert.NoError(t, storage.Reindex())
+		}
+
+		rec, err = storage.GetRecord(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "not available", rec.Links["a.com"])
+		// The update line is not counted as a record.
+		assert.Equal(t, int64(2), storage.Len())
+
+		assert.NoError(t, storage.Close())
+	}
+}
+
+func TestCompactKeepsUpdatedRecords(t *testing.T) {
+	storage := newTestStorage(t)
+
+	err := storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{"a.com": "available"}, ID: 1})
+	assert.NoError(t, err)
+
+	err = storage.UpdateRecord(context.Background(), &domain.Record{Links: map[string]string{"a.com": "not available"}, ID: 1})
+	assert.NoError(t, err)
+
+	// The update line wins even though the clock did not move.
+	err = storage.UpdateRecord(context.Background(), &domain.Record{Links: map[string]string{"a.com": "available"}, ID: 1})
+	assert.NoError(t, err)
+
+	dropped, err := storage.Compact()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, dropped)
+	assert.Equal(t, int64(1), storage.Len())
+
+	rec, err := storage.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "available", rec.Links["a.com"])
+
+	err = storage.DeleteRecord(context.Background(), 1)
+	assert.NoError(t, err)
+
+	_, err = storage.GetRecord(context.Background(), 1)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+}
+
+func TestLenAfterCompact(t *testing.T) {
+	storage := newTestStorage(t)
+
+	for id := int64(1); id <= 3; id++ {
+		err := storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{"a.com": "available"}, ID: id})
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, storage.DeleteRecord(context.Background(), 1))
+
+	err := storage.UpdateRecord(context.Background(), &domain.Record{Links: map[string]string{"a.com": "not available"}, ID: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), storage.Len())
+
+	// The original lines of records 1 and 2 are dropped.
+	dropped, err := storage.Compact()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, dropped)
+	assert.Equal(t, int64(2), storage.Len())
+}
+
+func TestListRecords(t *testing.T) {
+	storage := newTestStorage(t)
+
+	for _, id := range []int64{3, 1, 2, 4} {
+		err := storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{"a.com": "available"}, ID: id})
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, storage.UpdateRecord(context.Background(), &domain.Record{Links: map[string]string{"a.com": "not available"}, ID: 1}))
+	assert.NoError(t, storage.DeleteRecord(context.Background(), 4))
+
+	records, err := storage.ListRecords(context.Background(), 0, 2, "")
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, int64(1), records[0].ID)
+	assert.Equal(t, "not available", records[0].Links["a.com"])
+	assert.Equal(t, int64(2), records[1].ID)
+
+	records, err = storage.ListRecords(context.Background(), 1, 0, repository.OrderDesc)
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, int64(2), records[0].ID)
+
+	records, err = storage.ListRecords(context.Background(), 3, 1, "")
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	_, err = storage.ListRecords(context.Background(), -1, 0, "")
+	assert.ErrorIs(t, err, repository.ErrInvalidPage)
+}
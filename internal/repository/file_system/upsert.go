@@ -0,0 +1,297 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+)
+
+// UpsertRecords merges the batch into the main file with a single rewrite:
+// records with existing IDs replace the stored ones, the rest are appended.
+// A record with a deleted ID replaces its tombstone and is counted as
+// inserted, since it was not stored. If the batch contains the same ID
+// several times, the last one wins.
+func (s *Storage) UpsertRecords(ctx context.Context, records []*domain.Record) (int, int, error) {
+	err := s.checkWritable("upsert records")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch := make(map[int64]*domain.Record, len(records))
+	order := make([]int64, 0, len(records))
+	for _, rec := range records {
+		if _, ok := batch[rec.ID]; !ok {
+			order = append(order, rec.ID)
+		}
+		batch[rec.ID] = rec
+	}
+
+	now := s.clock.Now().UTC()
+	written := make(map[int64]bool, len(batch))
+	revived := make(map[int64]bool)
+	updated := 0
+
+	err = s.rewriteMain(func(line []byte, w *bufio.Writer) error {
+		var stored domain.Record
+		err := s.unmarshalLine(line, &stored)
+		if err != nil {
+			return writeLine(w, line)
+		}
+
+		rec, ok := batch[stored.ID]
+		if !ok {
+			return writeLine(w, line)
+		}
+
+		if written[stored.ID] {
+			return nil
+		}
+
+		written[stored.ID] = true
+
+		if s.isDeleted(stored.ID) {
+			revived[stored.ID] = true
+			if rec.CreatedAt.IsZero() {
+				rec.CreatedAt = now
+			}
+			if rec.UpdatedAt.IsZero() {
+				rec.UpdatedAt = rec.CreatedAt
+			}
+
+			return s.writeRecord(w, rec)
+		}
+
+		if rec.CreatedAt.IsZero() {
+			rec.CreatedAt = stored.CreatedAt
+		}
+		rec.UpdatedAt = now
+		updated++
+
+		return s.writeRecord(w, rec)
+	}, func(w *bufio.Writer) error {
+		for _, id := range order {
+			if written[id] {
+				continue
+			}
+
+			rec := batch[id]
+			if rec.CreatedAt.IsZero() {
+				rec.CreatedAt = now
+			}
+			if rec.UpdatedAt.IsZero() {
+				rec.UpdatedAt = rec.CreatedAt
+			}
+
+			err := s.writeRecord(w, rec)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("failed to upsert records", zap.Error(err))
+		return 0, 0, fmt.Errorf("failed to upsert records: %w", err)
+	}
+
+	// The rewrite moves the lines, so the offsets are indexed again.
+	err = s.reindex()
+	if err != nil {
+		s.logger.Warn("failed to rebuild index, run a reindex", zap.Error(err))
+	}
+
+	s.rotateIfFull()
+
+	for _, id := range order {
+		if written[id] && !revived[id] {
+			s.emitEvent(id, domain.EventTypeUpdated, "")
+		} else {
+			s.emitEvent(id, domain.EventTypeCreated, "")
+		}
+	}
+
+	s.countRecords()
+
+	inserted := len(order) - updated
+	s.logger.Info("successfully upserted records", zap.Int("inserted", inserted), zap.Int("updated", updated))
+	return inserted, updated, nil
+}
+
+// rewriteMain rewrites the sealed segments and then the main file proper
+// with rewriteFile, so onLine gets the lines of the main file in order and
+// onEnd appends to the main file proper. The manifest is updated with the
+// new segment sizes and the snapshot is removed. The lines move, so the
+// caller has to reindex.
+func (s *Storage) rewriteMain(onLine func(line []byte, w *bufio.Writer) error, onEnd func(w *bufio.Writer) error) error {
+	err := s.removeSnapshot()
+	if err != nil {
+		return err
+	}
+
+	resized := false
+	for i := range s.segments {
+		seg := &s.segments[i]
+
+		size, err := s.rewriteFile(s.segmentPath(*seg), onLine, nil)
+		if err != nil {
+			return err
+		}
+
+		if size != seg.Size {
+			seg.Size = size
+			resized = true
+		}
+	}
+
+	if resized {
+		err = s.writeManifest()
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = s.rewriteFile(s.path, onLine, onEnd)
+	return err
+}
+
+// rewriteFile atomically replaces the file: every existing line is passed to
+// onLine, then onEnd may append more data. The result is written to a
+// temporary file, synced and renamed over the original. A file that comes
+// out unchanged is not replaced, so a rewrite of the main file only replaces
+// the segments it changes. A compressed segment is rewritten compressed. It
+// returns the size of the new data before compression.
+func (s *Storage) rewriteFile(path string, onLine func(line []byte, w *bufio.Writer) error, onEnd func(w *bufio.Writer) error) (int64, error) {
+	file, err := s.openMainFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file: %s: %w", path, err)
+	}
+	defer file.Close()
+
+	srcHash, dstHash := sha256.New(), sha256.New()
+	src := io.TeeReader(file, srcHash)
+
+	tmpPath := path + ".tmp"
+	dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, s.fileMode)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file: %s: %w", tmpPath, err)
+	}
+	defer os.Remove(tmpPath)
+	defer dst.Close()
+
+	var (
+		out        io.Writer = dst
+		compressor io.WriteCloser
+	)
+	if compression := compressionOf(path); compression != CompressionNone {
+		compressor, err = newFramedCompressor(dst, compression)
+		if err != nil {
+			return 0, err
+		}
+		defer compressor.Close()
+
+		out = compressor
+	}
+
+	size := &countingWriter{}
+	w := bufio.NewWriter(io.MultiWriter(out, dstHash, size))
+
+	scanner := bufio.NewScanner(src)
+	for scanner.Scan() {
+		err = onLine(scanner.Bytes(), w)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan file: %s: %w", path, err)
+	}
+
+	if onEnd != nil {
+		err = onEnd(w)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return 0, fmt.Errorf("failed to flush file: %s: %w", tmpPath, err)
+	}
+
+	if bytes.Equal(srcHash.Sum(nil), dstHash.Sum(nil)) {
+		return size.n, nil
+	}
+
+	if compressor != nil {
+		err = compressor.Close()
+		if err != nil {
+			return 0, fmt.Errorf("failed to compress file: %s: %w", tmpPath, err)
+		}
+	}
+
+	err = dst.Sync()
+	if err != nil {
+		return 0, fmt.Errorf("failed to sync file: %s: %w", tmpPath, err)
+	}
+
+	err = dst.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to close file: %s: %w", tmpPath, err)
+	}
+
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to rename file: %s: %w", tmpPath, err)
+	}
+
+	if a := s.appenderFor(path); a != nil {
+		err = a.reopen()
+		if err != nil {
+			return 0, fmt.Errorf("failed to reopen file: %s: %w", path, err)
+		}
+	}
+
+	return size.n, nil
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+func writeLine(w *bufio.Writer, line []byte) error {
+	_, err := w.Write(line)
+	if err != nil {
+		return err
+	}
+
+	return w.WriteByte('\n')
+}
+
+func (s *Storage) writeRecord(w *bufio.Writer, rec *domain.Record) error {
+	data, err := s.marshalLine(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	return writeLine(w, data)
+}
@@ -0,0 +1,127 @@
+package filesystem
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+)
+
+func TestUpsertRecords(t *testing.T) {
+	clk := clock.NewFake(testNow)
+	storage, err := New(newTestConfig(t), clk, zap.NewNop())
+	assert.NoError(t, err)
+
+	for _, id := range []int64{1, 2, 3} {
+		err = storage.SaveRecord(context.Background(), &domain.Record{
+			Links: map[string]string{"old.com": "available"},
+			ID:    id,
+		})
+		assert.NoError(t, err)
+	}
+
+	clk.Advance(time.Hour)
+
+	inserted, updated, err := storage.UpsertRecords(context.Background(), []*domain.Record{
+		{Links: map[string]string{"new.com": "available"}, ID: 2},
+		{Links: map[string]string{"new.com": "available"}, ID: 4},
+		{Links: map[string]string{"new.com": "not available"}, ID: 3},
+		{Links: map[string]string{"new.com": "available"}, ID: 5},
+		{Links: map[string]string{"newest.com": "available"}, ID: 4},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, inserted)
+	assert.Equal(t, 2, updated)
+
+	records, err := storage.GetAllRecords(context.Background())
+	assert.NoError(t, err)
+
+	updatedAt := testNow.Add(time.Hour)
+	assert.Equal(t, []domain.Record{
+		{Links: map[string]string{"old.com": "available"}, ID: 1, CreatedAt: testNow, UpdatedAt: testNow},
+		{Links: map[string]string{"new.com": "available"}, ID: 2, CreatedAt: testNow, UpdatedAt: updatedAt},
+		{Links: map[string]string{"new.com": "not available"}, ID: 3, CreatedAt: testNow, UpdatedAt: updatedAt},
+		{Links: map[string]string{"newest.com": "available"}, ID: 4, CreatedAt: updatedAt, UpdatedAt: updatedAt},
+		{Links: map[string]string{"new.com": "available"}, ID: 5, CreatedAt: updatedAt, UpdatedAt: updatedAt},
+	}, records)
+}
+
+func TestUpsertRecordsDeletedID(t *testing.T) {
+	clk := clock.NewFake(testNow)
+	storage, err := New(newTestConfig(t), clk, zap.NewNop())
+	assert.NoError(t, err)
+
+	for _, id := range []int64{1, 2} {
+		err = storage.SaveRecord(context.Background(), &domain.Record{
+			Links: map[string]string{"old.com": "available"},
+			ID:    id,
+		})
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, storage.DeleteRecord(context.Background(), 1))
+	assert.Equal(t, int64(1), storage.Len())
+
+	clk.Advance(time.Hour)
+
+	inserted, updated, err := storage.UpsertRecords(context.Background(), []*domain.Record{
+		{Links: map[string]string{"new.com": "available"}, ID: 1},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, inserted)
+	assert.Equal(t, 0, updated)
+	assert.Equal(t, int64(2), storage.Len())
+
+	updatedAt := testNow.Add(time.Hour)
+	rec, err := storage.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.Record{Links: map[string]string{"new.com": "available"}, ID: 1, CreatedAt: updatedAt, UpdatedAt: updatedAt}, rec)
+}
+
+func TestGetRecords(t *testing.T) {
+	clk := clock.NewFake(testNow)
+	storage, err := New(newTestConfig(t), clk, zap.NewNop())
+	assert.NoError(t, err)
+
+	for _, id := range []int64{1, 2, 3} {
+		err = storage.SaveRecord(context.Background(), &domain.Record{
+			Links: map[string]string{"a.com": "unknown"},
+			ID:    id,
+		})
+		assert.NoError(t, err)
+	}
+
+	records, err := storage.GetRecords(context.Background(), []int64{1, 3, 7})
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.NotContains(t, records, int64(7))
+
+	clk.Advance(time.Hour)
+
+	batch := make([]*domain.Record, 0, len(records))
+	for _, rec := range records {
+		rec.Links["a.com"] = "available"
+		batch = append(batch, rec)
+	}
+
+	inserted, updated, err := storage.UpsertRecords(context.Background(), batch)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, inserted)
+	assert.Equal(t, 2, updated)
+
+	for id, want := range map[int64]string{1: "available", 2: "unknown", 3: "available"} {
+		rec, err := storage.GetRecord(context.Background(), id)
+		assert.NoError(t, err)
+		assert.Equal(t, want, rec.Links["a.com"])
+		assert.Equal(t, testNow, rec.CreatedAt)
+	}
+
+	rec, err := storage.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, testNow.Add(time.Hour), rec.UpdatedAt)
+}
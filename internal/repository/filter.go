@@ -0,0 +1,69 @@
+package repository
+
+import "link-service/internal/domain"
+
+// Filter selects records returned by GetAllRecords. Zero values disable a bound.
+type Filter struct {
+	MinLinks int
+	MaxLinks int
+	Source   string
+	Tag      string
+}
+
+type FilterOption func(*Filter)
+
+func WithMinLinks(n int) FilterOption {
+	return func(f *Filter) {
+		f.MinLinks = n
+	}
+}
+
+func WithMaxLinks(n int) FilterOption {
+	return func(f *Filter) {
+		f.MaxLinks = n
+	}
+}
+
+func WithSource(source string) FilterOption {
+	return func(f *Filter) {
+		f.Source = source
+	}
+}
+
+func WithTag(tag string) FilterOption {
+	return func(f *Filter) {
+		f.Tag = tag
+	}
+}
+
+func NewFilter(opts ...FilterOption) Filter {
+	var f Filter
+	for _, opt := range opts {
+		opt(&f)
+	}
+
+	return f
+}
+
+// Match reports whether the record passes the filter.
+func (f Filter) Match(rec *domain.Record) bool {
+	count := rec.LinkCount()
+
+	if f.MinLinks > 0 && count < f.MinLinks {
+		return false
+	}
+
+	if f.MaxLinks > 0 && count > f.MaxLinks {
+		return false
+	}
+
+	if f.Source != "" && rec.Source != f.Source {
+		return false
+	}
+
+	if f.Tag != "" && !rec.HasTag(f.Tag) {
+		return false
+	}
+
+	return true
+}
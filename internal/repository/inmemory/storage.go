@@ -0,0 +1,637 @@
+package inmemory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+// StorageType selects this backend with STORAGE_TYPE.
+const StorageType = "inmemory"
+
+func init() {
+	repository.Register(StorageType, repository.FactoryFor(func(cfg *Config, logger *zap.Logger) (*Storage, error) {
+		return New(cfg, clock.Real{}, logger)
+	}))
+}
+
+type Config struct {
+	// SnapshotPath is the file the records are persisted to. Empty keeps the
+	// records in memory only.
+	SnapshotPath     string        `env:"INMEMORY_SNAPSHOT_PATH"`
+	SnapshotInterval time.Duration `env:"INMEMORY_SNAPSHOT_INTERVAL" env-default:"1m"`
+}
+
+const defaultSnapshotInterval = time.Minute
+
+// Validate reports every missing or invalid field of the config.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.SnapshotInterval < 0 {
+		errs = append(errs, errors.New("INMEMORY_SNAPSHOT_INTERVAL must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// snapshot is the on-disk form of the storage.
+type snapshot struct {
+	Records     []domain.Record      `json:"records"`
+	TempRecords []domain.Record      `json:"temp_records"`
+	Events      []domain.RecordEvent `json:"events"`
+}
+
+// Storage keeps records in a map keyed by ID. Records are copied on the way in
+// and out, so callers never share maps or slices with the storage. If a
+// snapshot path is configured, the storage is loaded from it on startup and
+// written back on every interval when it changed, and on Close.
+type Storage struct {
+	mu          sync.RWMutex
+	records     map[int64]*domain.Record
+	tempRecords []domain.Record
+	events      map[int64][]domain.RecordEvent
+	// dirty is set by every write and cleared by Snapshot.
+	dirty bool
+
+	snapshotPath string
+	stop         chan struct{}
+	done         chan struct{}
+	clock        clock.Clock
+	logger       *zap.Logger
+}
+
+func New(cfg *Config, clk clock.Clock, logger *zap.Logger) (*Storage, error) {
+	s := &Storage{
+		records:      make(map[int64]*domain.Record),
+		events:       make(map[int64][]domain.RecordEvent),
+		snapshotPath: cfg.SnapshotPath,
+		clock:        clk,
+		logger:       logger,
+	}
+
+	if s.snapshotPath == "" {
+		return s, nil
+	}
+
+	err := s.load()
+	if err != nil {
+		logger.Error("failed to load snapshot", zap.String("path", s.snapshotPath), zap.Error(err))
+		return nil, fmt.Errorf("failed to load snapshot: %s: %w", s.snapshotPath, err)
+	}
+
+	interval := cfg.SnapshotInterval
+	if interval == 0 {
+		interval = defaultSnapshotInterval
+	}
+
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.snapshotLoop(interval)
+
+	logger.Info("in-memory storage loaded", zap.String("path", s.snapshotPath), zap.Int("records", len(s.records)))
+
+	return s, nil
+}
+
+// Close stops the snapshot loop and writes a final snapshot.
+func (s *Storage) Close() error {
+	if s.stop == nil {
+		return nil
+	}
+
+	close(s.stop)
+	<-s.done
+	s.stop = nil
+
+	return s.Snapshot()
+}
+
+func (s *Storage) snapshotLoop(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			err := s.Snapshot()
+			if err != nil {
+				s.logger.Warn("failed to write snapshot", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Snapshot writes the storage to the snapshot path if it changed since the
+// last snapshot. The file is replaced atomically, so a crash mid-write keeps
+// the previous snapshot.
+func (s *Storage) Snapshot() error {
+	if s.snapshotPath == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+
+	snap := snapshot{
+		Records:     make([]domain.Record, 0, len(s.records)),
+		TempRecords: slices.Clone(s.tempRecords),
+		Events:      make([]domain.RecordEvent, 0),
+	}
+	for _, id := range s.sortedIDs() {
+		snap.Records = append(snap.Records, *s.records[id])
+	}
+	for _, id := range slices.Sorted(maps.Keys(s.events)) {
+		snap.Events = append(snap.Events, s.events[id]...)
+	}
+	s.dirty = false
+	s.mu.Unlock()
+
+	err := writeSnapshot(s.snapshotPath, &snap)
+	if err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+
+		return fmt.Errorf("failed to write snapshot: %s: %w", s.snapshotPath, err)
+	}
+
+	s.logger.Debug("snapshot written", zap.String("path", s.snapshotPath), zap.Int("records", len(snap.Records)))
+	return nil
+}
+
+func writeSnapshot(path string, snap *snapshot) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".new"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// load reads the snapshot file. A missing file is an empty storage.
+func (s *Storage) load() error {
+	data, err := os.ReadFile(s.snapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snap snapshot
+	err = json.Unmarshal(data, &snap)
+	if err != nil {
+		return err
+	}
+
+	for i := range snap.Records {
+		rec := snap.Records[i]
+		s.records[rec.ID] = &rec
+	}
+
+	s.tempRecords = snap.TempRecords
+
+	for _, event := range snap.Events {
+		s.events[event.RecordID] = append(s.events[event.RecordID], event)
+	}
+
+	return nil
+}
+
+// SaveRecord stores a copy of the record under its ID. Missing timestamps are
+// set from the storage clock.
+func (s *Storage) SaveRecord(ctx context.Context, record *domain.Record) error {
+	return s.SaveRecords(ctx, []*domain.Record{record})
+}
+
+// SaveRecords stores copies of the records under one lock.
+func (s *Storage) SaveRecords(ctx context.Context, records []*domain.Record) error {
+	now := s.clock.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range records {
+		if record.CreatedAt.IsZero() {
+			record.CreatedAt = now
+		}
+
+		if record.UpdatedAt.IsZero() {
+			record.UpdatedAt = record.CreatedAt
+		}
+
+		s.put(record, domain.EventTypeCreated, now)
+	}
+
+	s.logger.Info("successfully wrote records", zap.Int("count", len(records)))
+	return nil
+}
+
+func (s *Storage) SaveTempRecord(ctx context.Context, record *domain.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tempRecords = append(s.tempRecords, cloneRecord(record))
+	s.dirty = true
+
+	return nil
+}
+
+// UpsertRecords inserts or replaces the batch under one lock.
+// If the batch contains the same ID several times, the last one wins.
+func (s *Storage) UpsertRecords(ctx context.Context, records []*domain.Record) (int, int, error) {
+	batch := make(map[int64]*domain.Record, len(records))
+	order := make([]int64, 0, len(records))
+	for _, rec := range records {
+		if _, ok := batch[rec.ID]; !ok {
+			order = append(order, rec.ID)
+		}
+		batch[rec.ID] = rec
+	}
+
+	now := s.clock.Now().UTC()
+	inserted, updated := 0, 0
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range order {
+		rec := batch[id]
+
+		stored, ok := s.records[id]
+		if !ok {
+			if rec.CreatedAt.IsZero() {
+				rec.CreatedAt = now
+			}
+			if rec.UpdatedAt.IsZero() {
+				rec.UpdatedAt = rec.CreatedAt
+			}
+
+			s.put(rec, domain.EventTypeCreated, now)
+			inserted++
+			continue
+		}
+
+		if rec.CreatedAt.IsZero() {
+			rec.CreatedAt = stored.CreatedAt
+		}
+		rec.UpdatedAt = now
+
+		s.put(rec, domain.EventTypeUpdated, now)
+		updated++
+	}
+
+	s.logger.Info("successfully upserted records", zap.Int("inserted", inserted), zap.Int("updated", updated))
+	return inserted, updated, nil
+}
+
+// UpdateRecord replaces the stored record and stores its updated event. A
+// zero CreatedAt keeps the stored creation time. It fails with
+// repository.ErrRecordNotFound if the record is not stored.
+func (s *Storage) UpdateRecord(ctx context.Context, record *domain.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.records[record.ID]
+	if !ok {
+		return fmt.Errorf("record with ID %d: %w", record.ID, repository.ErrRecordNotFound)
+	}
+
+	now := s.clock.Now().UTC()
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = stored.CreatedAt
+	}
+	record.UpdatedAt = now
+
+	s.put(record, domain.EventTypeUpdated, now)
+
+	s.logger.Info("successfully updated record", zap.Int64("id", record.ID))
+	return nil
+}
+
+// DeleteRecord removes the record and stores its deleted event. It fails
+// with repository.ErrRecordNotFound if the record is not stored.
+func (s *Storage) DeleteRecord(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.records[id]; !ok {
+		return fmt.Errorf("record with ID %d: %w", id, repository.ErrRecordNotFound)
+	}
+
+	delete(s.records, id)
+	s.events[id] = append(s.events[id], domain.RecordEvent{
+		RecordID:   id,
+		EventType:  domain.EventTypeDeleted,
+		OccurredAt: s.clock.Now().UTC(),
+	})
+	s.dirty = true
+
+	s.logger.Info("successfully deleted record", zap.Int64("id", id))
+	return nil
+}
+
+// LoadTempRecords returns the temp records in insertion order, keeping one
+// record per ID: the most recently updated one and, on ties, the last one.
+func (s *Storage) LoadTempRecords(ctx context.Context) ([]domain.Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []domain.Record
+	positions := make(map[int64]int)
+
+	for _, rec := range s.tempRecords {
+		pos, ok := positions[rec.ID]
+		if !ok {
+			positions[rec.ID] = len(records)
+			records = append(records, cloneRecord(&rec))
+			continue
+		}
+
+		if !rec.UpdatedAt.Before(records[pos].UpdatedAt) {
+			records[pos] = cloneRecord(&rec)
+		}
+	}
+
+	return records, nil
+}
+
+func (s *Storage) GetRecord(ctx context.Context, id int64) (*domain.Record, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.records[id]
+	if !ok {
+		return nil, fmt.Errorf("record with ID %d: %w", id, repository.ErrRecordNotFound)
+	}
+
+	clone := cloneRecord(rec)
+	return &clone, nil
+}
+
+// GetRecords returns copies of the records with the given IDs.
+func (s *Storage) GetRecords(ctx context.Context, ids []int64) (map[int64]*domain.Record, error) {
+	records := make(map[int64]*domain.Record, len(ids))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, id := range ids {
+		rec, ok := s.records[id]
+		if !ok {
+			continue
+		}
+
+		clone := cloneRecord(rec)
+		records[id] = &clone
+	}
+
+	return records, nil
+}
+
+// GetAllRecords returns all records matching the filter options ordered by ID.
+func (s *Storage) GetAllRecords(ctx context.Context, opts ...repository.FilterOption) ([]domain.Record, error) {
+	filter := repository.NewFilter(opts...)
+	records := make([]domain.Record, 0)
+
+	err := s.ForEachRecord(ctx, func(rec *domain.Record) error {
+		if filter.Match(rec) {
+			records = append(records, *rec)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func (s *Storage) GetRecordsBySource(ctx context.Context, source string) ([]domain.Record, error) {
+	return s.GetAllRecords(ctx, repository.WithSource(source))
+}
+
+// GetRecordsSince returns the records created or updated at or after since,
+// ordered by UpdatedAt.
+func (s *Storage) GetRecordsSince(ctx context.Context, since time.Time) ([]domain.Record, error) {
+	records := make([]domain.Record, 0)
+
+	err := s.ForEachRecord(ctx, func(rec *domain.Record) error {
+		if rec.ModifiedSince(since) {
+			records = append(records, *rec)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	repository.SortByUpdatedAt(records)
+	return records, nil
+}
+
+func (s *Storage) FindRecordsByTag(ctx context.Context, tag string) ([]domain.Record, error) {
+	return s.GetAllRecords(ctx, repository.WithTag(tag))
+}
+
+// SearchRecords returns the records matching the query ordered by ID.
+func (s *Storage) SearchRecords(ctx context.Context, query repository.SearchQuery) ([]domain.Record, error) {
+	records := make([]domain.Record, 0)
+
+	err := s.ForEachRecord(ctx, func(rec *domain.Record) error {
+		if query.Match(rec) {
+			records = append(records, *rec)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// ForEachRecord calls fn with a copy of every record ordered by ID. The read
+// lock is held while fn runs, so fn must not write to the storage.
+func (s *Storage) ForEachRecord(ctx context.Context, fn func(rec *domain.Record) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, id := range s.sortedIDs() {
+		rec := cloneRecord(s.records[id])
+
+		err := fn(&rec)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListRecords returns copies of a page of the records ordered by ID.
+func (s *Storage) ListRecords(ctx context.Context, offset, limit int, order string) ([]domain.Record, error) {
+	err := repository.ValidatePage(offset, limit, order)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	page := repository.PageIDs(s.sortedIDs(), offset, limit, order)
+	records := make([]domain.Record, 0, len(page))
+	for _, id := range page {
+		records = append(records, cloneRecord(s.records[id]))
+	}
+
+	return records, nil
+}
+
+func (s *Storage) ListRecordIDs(ctx context.Context) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sortedIDs(), nil
+}
+
+func (s *Storage) ClearTempFile(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tempRecords = nil
+	s.dirty = true
+
+	return nil
+}
+
+// LoadLastLinksNum returns the highest stored ID. Deleted records keep their
+// events, which count as well so that their IDs are not handed out again.
+func (s *Storage) LoadLastLinksNum(ctx context.Context) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var last int64
+	for id := range s.records {
+		last = max(last, id)
+	}
+
+	for id := range s.events {
+		last = max(last, id)
+	}
+
+	return last
+}
+
+func (s *Storage) Len() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return int64(len(s.records))
+}
+
+// SaveRecordEvent stores the event. A missing OccurredAt is set from the
+// storage clock.
+func (s *Storage) SaveRecordEvent(ctx context.Context, event *domain.RecordEvent) error {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = s.clock.Now().UTC()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events[event.RecordID] = append(s.events[event.RecordID], *event)
+	s.dirty = true
+
+	return nil
+}
+
+// GetRecordEvents returns the events of the record in the order they were
+// stored.
+func (s *Storage) GetRecordEvents(ctx context.Context, id int64) ([]domain.RecordEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]domain.RecordEvent, len(s.events[id]))
+	copy(events, s.events[id])
+
+	return events, nil
+}
+
+// put stores a copy of the record with its lifecycle event. s.mu must be held.
+func (s *Storage) put(rec *domain.Record, eventType string, now time.Time) {
+	clone := cloneRecord(rec)
+	s.records[rec.ID] = &clone
+	s.events[rec.ID] = append(s.events[rec.ID], domain.RecordEvent{
+		RecordID:   rec.ID,
+		EventType:  eventType,
+		OccurredAt: now,
+	})
+	s.dirty = true
+}
+
+// sortedIDs returns the stored IDs in ascending order. s.mu must be held.
+func (s *Storage) sortedIDs() []int64 {
+	return slices.Sorted(maps.Keys(s.records))
+}
+
+func cloneRecord(rec *domain.Record) domain.Record {
+	clone := *rec
+	clone.Links = maps.Clone(rec.Links)
+	clone.Tags = slices.Clone(rec.Tags)
+
+	return clone
+}
@@ -0,0 +1,328 @@
+package inmemory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+	"link-service/internal/repository/repositorytest"
+)
+
+var testNow = time.Date(2025, 11, 30, 12, 0, 0, 0, time.UTC)
+
+func newTestStorage(t *testing.T) (*Storage, *clock.Fake) {
+	t.Helper()
+
+	clk := clock.NewFake(testNow)
+	storage, err := New(&Config{}, clk, zap.NewNop())
+	assert.NoError(t, err)
+
+	return storage, clk
+}
+
+func TestConformance(t *testing.T) {
+	repositorytest.RunSuite(t, func(t *testing.T) repository.Repository {
+		storage, _ := newTestStorage(t)
+		return storage
+	})
+}
+
+func TestSaveAndGetRecord(t *testing.T) {
+	storage, _ := newTestStorage(t)
+
+	links := map[string]string{"a.com": "available"}
+	err := storage.SaveRecord(context.Background(), &domain.Record{ID: 2, Links: links, Tags: []string{"prod"}})
+	assert.NoError(t, err)
+
+	// The storage keeps its own copy.
+	links["b.com"] = "available"
+
+	rec, err := storage.GetRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a.com": "available"}, rec.Links)
+	assert.Equal(t, []string{"prod"}, rec.Tags)
+	assert.Equal(t, testNow, rec.CreatedAt)
+	assert.Equal(t, testNow, rec.UpdatedAt)
+
+	rec.Links["c.com"] = "available"
+
+	rec, err = storage.GetRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Len(t, rec.Links, 1)
+
+	_, err = storage.GetRecord(context.Background(), 3)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = storage.GetRecord(ctx, 2)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestLoadLastLinksNum(t *testing.T) {
+	storage, _ := newTestStorage(t)
+	assert.Equal(t, int64(0), storage.LoadLastLinksNum(context.Background()))
+
+	for _, id := range []int64{3, 256, 10} {
+		err := storage.SaveRecord(context.Background(), &domain.Record{ID: id})
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, int64(256), storage.LoadLastLinksNum(context.Background()))
+	assert.Equal(t, int64(3), storage.Len())
+
+	ids, err := storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3, 10, 256}, ids)
+}
+
+func TestUpsertRecords(t *testing.T) {
+	storage, clk := newTestStorage(t)
+
+	err := storage.SaveRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "available"}})
+	assert.NoError(t, err)
+
+	clk.Advance(time.Hour)
+
+	inserted, updated, err := storage.UpsertRecords(context.Background(), []*domain.Record{
+		{ID: 1, Links: map[string]string{"a.com": "not available"}},
+		{ID: 2, Links: map[string]string{"b.com": "available"}},
+		{ID: 2, Links: map[string]string{"c.com": "available"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, inserted)
+	assert.Equal(t, 1, updated)
+	assert.Equal(t, int64(2), storage.Len())
+
+	rec, err := storage.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "not available", rec.Links["a.com"])
+	assert.Equal(t, testNow, rec.CreatedAt)
+	assert.Equal(t, testNow.Add(time.Hour), rec.UpdatedAt)
+
+	rec, err = storage.GetRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"c.com": "available"}, rec.Links)
+
+	records, err := storage.GetRecordsSince(context.Background(), testNow.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+}
+
+func TestTempRecords(t *testing.T) {
+	storage, _ := newTestStorage(t)
+
+	for _, rec := range []*domain.Record{
+		{ID: 1, Links: map[string]string{"a.com": "available"}, UpdatedAt: testNow},
+		{ID: 2, Links: map[string]string{"b.com": "available"}},
+		{ID: 1, Links: map[string]string{"a.com": "not available"}, UpdatedAt: testNow.Add(time.Minute)},
+	} {
+		err := storage.SaveTempRecord(context.Background(), rec)
+		assert.NoError(t, err)
+	}
+
+	records, err := storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, int64(1), records[0].ID)
+	assert.Equal(t, "not available", records[0].Links["a.com"])
+
+	err = storage.ClearTempFile(context.Background())
+	assert.NoError(t, err)
+
+	records, err = storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestRecordEvents(t *testing.T) {
+	storage, clk := newTestStorage(t)
+
+	err := storage.SaveRecord(context.Background(), &domain.Record{ID: 1})
+	assert.NoError(t, err)
+
+	clk.Advance(time.Minute)
+
+	_, _, err = storage.UpsertRecords(context.Background(), []*domain.Record{{ID: 1}})
+	assert.NoError(t, err)
+
+	events, err := storage.GetRecordEvents(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.RecordEvent{
+		{RecordID: 1, EventType: domain.EventTypeCreated, OccurredAt: testNow},
+		{RecordID: 1, EventType: domain.EventTypeUpdated, OccurredAt: testNow.Add(time.Minute)},
+	}, events)
+}
+
+func TestQueries(t *testing.T) {
+	storage, _ := newTestStorage(t)
+
+	err := storage.SaveRecords(context.Background(), []*domain.Record{
+		{ID: 3, Links: map[string]string{}, Source: domain.SourceAPI, Tags: []string{"prod", "eu"}},
+		{ID: 1, Links: map[string]string{"a.com": "available"}, Source: domain.SourceImport},
+		{ID: 2, Links: map[string]string{}, Source: domain.SourceAPI, Tags: []string{"eu"}},
+	})
+	assert.NoError(t, err)
+
+	records, err := storage.GetRecordsBySource(context.Background(), domain.SourceAPI)
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	records, err = storage.FindRecordsByTag(context.Background(), "prod")
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, int64(3), records[0].ID)
+
+	records, err = storage.GetAllRecords(context.Background(), repository.WithMinLinks(1))
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	found, err := storage.GetRecords(context.Background(), []int64{1, 4})
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+	assert.Contains(t, found, int64(1))
+}
+
+func TestSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "snapshot.json")
+	cfg := &Config{SnapshotPath: path, SnapshotInterval: time.Hour}
+
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	// Nothing to write until the storage changes.
+	assert.NoError(t, storage.Snapshot())
+	assert.NoFileExists(t, path)
+
+	err = storage.SaveRecords(context.Background(), []*domain.Record{
+		{ID: 1, Links: map[string]string{"a.com": "available"}, Tags: []string{"prod"}},
+		{ID: 2, Links: map[string]string{}},
+	})
+	assert.NoError(t, err)
+
+	err = storage.SaveTempRecord(context.Background(), &domain.Record{ID: 3})
+	assert.NoError(t, err)
+
+	assert.NoError(t, storage.Close())
+	assert.FileExists(t, path)
+	assert.NoFileExists(t, path+".new")
+
+	storage, err = New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	defer storage.Close()
+
+	records, err := storage.GetAllRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Record{
+		{ID: 1, Links: map[string]string{"a.com": "available"}, Tags: []string{"prod"}, CreatedAt: testNow, UpdatedAt: testNow},
+		{ID: 2, Links: map[string]string{}, CreatedAt: testNow, UpdatedAt: testNow},
+	}, records)
+
+	temp, err := storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, temp, 1)
+
+	events, err := storage.GetRecordEvents(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+}
+
+func TestSnapshotInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	storage, err := New(&Config{SnapshotPath: path, SnapshotInterval: 10 * time.Millisecond}, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	defer storage.Close()
+
+	err = storage.SaveRecord(context.Background(), &domain.Record{ID: 1})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestNewInvalidSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	err := os.WriteFile(path, []byte("{"), 0644)
+	assert.NoError(t, err)
+
+	_, err = New(&Config{SnapshotPath: path}, clock.NewFake(testNow), zap.NewNop())
+	assert.ErrorContains(t, err, "failed to load snapshot")
+}
+
+func TestListRecords(t *testing.T) {
+	storage, _ := newTestStorage(t)
+
+	assert.NoError(t, storage.SaveRecords(context.Background(), []*domain.Record{{ID: 3}, {ID: 1}, {ID: 2}}))
+
+	records, err := storage.ListRecords(context.Background(), 1, 1, "")
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, int64(2), records[0].ID)
+
+	records, err = storage.ListRecords(context.Background(), 0, 0, repository.OrderDesc)
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, int64(3), records[0].ID)
+
+	records, err = storage.ListRecords(context.Background(), 5, 1, "")
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	_, err = storage.ListRecords(context.Background(), 0, 0, "newest")
+	assert.ErrorIs(t, err, repository.ErrInvalidPage)
+}
+
+func TestUpdateRecord(t *testing.T) {
+	storage, clk := newTestStorage(t)
+
+	assert.NoError(t, storage.SaveRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "available"}}))
+
+	clk.Advance(time.Minute)
+
+	err := storage.UpdateRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "not available"}})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, storage.UpdateRecord(context.Background(), &domain.Record{ID: 2}), repository.ErrRecordNotFound)
+	assert.Equal(t, int64(1), storage.Len())
+
+	rec, err := storage.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "not available", rec.Links["a.com"])
+	assert.True(t, rec.CreatedAt.Equal(testNow))
+	assert.True(t, rec.UpdatedAt.Equal(testNow.Add(time.Minute)))
+
+	events, err := storage.GetRecordEvents(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.EventTypeUpdated, events[len(events)-1].EventType)
+}
+
+func TestDeleteRecord(t *testing.T) {
+	storage, _ := newTestStorage(t)
+
+	assert.NoError(t, storage.SaveRecords(context.Background(), []*domain.Record{{ID: 1}, {ID: 2}}))
+
+	assert.NoError(t, storage.DeleteRecord(context.Background(), 2))
+	assert.ErrorIs(t, storage.DeleteRecord(context.Background(), 2), repository.ErrRecordNotFound)
+	assert.Equal(t, int64(1), storage.Len())
+
+	_, err := storage.GetRecord(context.Background(), 2)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	events, err := storage.GetRecordEvents(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.EventTypeDeleted, events[len(events)-1].EventType)
+
+	// The events keep the ID of the deleted record taken.
+	assert.Equal(t, int64(2), storage.LoadLastLinksNum(context.Background()))
+}
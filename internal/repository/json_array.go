@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"link-service/internal/domain"
+)
+
+// WriteJSONArray streams all records of the repository to w as a single JSON array.
+// An empty repository produces "[]".
+func WriteJSONArray(ctx context.Context, w io.Writer, repo Repository) error {
+	_, err := io.WriteString(w, "[")
+	if err != nil {
+		return err
+	}
+
+	first := true
+	err = repo.ForEachRecord(ctx, func(rec *domain.Record) error {
+		if !first {
+			_, err := io.WriteString(w, ",")
+			if err != nil {
+				return err
+			}
+		}
+		first = false
+
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("failed to marshal record: %w", err)
+		}
+
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]")
+	return err
+}
+
+// ReadJSONArray decodes a JSON array of records from r one element at a time
+// and calls fn for each of them. It returns the number of records passed to fn.
+func ReadJSONArray(r io.Reader, fn func(rec *domain.Record) error) (int, error) {
+	decoder := json.NewDecoder(r)
+
+	token, err := decoder.Token()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read array start: %w", err)
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return 0, errors.New("expected JSON array")
+	}
+
+	count := 0
+	for decoder.More() {
+		var rec domain.Record
+		err = decoder.Decode(&rec)
+		if err != nil {
+			return count, fmt.Errorf("failed to decode record %d: %w", count, err)
+		}
+
+		err = fn(&rec)
+		if err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	_, err = decoder.Token()
+	if err != nil {
+		return count, fmt.Errorf("failed to read array end: %w", err)
+	}
+
+	return count, nil
+}
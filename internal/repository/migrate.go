@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"link-service/internal/domain"
+)
+
+// migrateBatchSize is the number of records written to the destination at
+// once by MigrateAll.
+const migrateBatchSize = 500
+
+// MigrateAll copies every record of src to dst in batches, keeping the
+// timestamps. Records that dst already holds with the same or a later
+// UpdatedAt are skipped, so the migration can be rerun and can run while a
+// DualWrite keeps writing to both. Events are not copied; dst records its
+// own creation events. It returns the number of records written.
+func MigrateAll(ctx context.Context, src, dst Repository) (int, error) {
+	migrated := 0
+	batch := make([]*domain.Record, 0, migrateBatchSize)
+
+	flush := func() error {
+		n, err := migrateBatch(ctx, dst, batch)
+		if err != nil {
+			return err
+		}
+
+		migrated += n
+		batch = batch[:0]
+		return nil
+	}
+
+	err := src.ForEachRecord(ctx, func(rec *domain.Record) error {
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+
+		clone := *rec
+		batch = append(batch, &clone)
+		if len(batch) < migrateBatchSize {
+			return nil
+		}
+
+		return flush()
+	})
+	if err == nil && len(batch) > 0 {
+		err = flush()
+	}
+	if err != nil {
+		return migrated, fmt.Errorf("failed to migrate records: %w", err)
+	}
+
+	return migrated, nil
+}
+
+func migrateBatch(ctx context.Context, dst Repository, batch []*domain.Record) (int, error) {
+	ids := make([]int64, 0, len(batch))
+	for _, rec := range batch {
+		ids = append(ids, rec.ID)
+	}
+
+	existing, err := dst.GetRecords(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	records := make([]*domain.Record, 0, len(batch))
+	for _, rec := range batch {
+		stored, ok := existing[rec.ID]
+		if ok && !stored.UpdatedAt.Before(rec.UpdatedAt) {
+			continue
+		}
+
+		records = append(records, rec)
+	}
+
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	err = dst.SaveRecords(ctx, records)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(records), nil
+}
@@ -0,0 +1,64 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+func TestMigrateAll(t *testing.T) {
+	src := newTestStorage(t, clock.NewFake(testNow))
+	dst := newTestStorage(t, clock.NewFake(testNow.Add(time.Hour)))
+
+	records := make([]*domain.Record, 0, 1200)
+	for id := int64(1); id <= 1200; id++ {
+		records = append(records, &domain.Record{ID: id, Links: map[string]string{"a.com": "available"}})
+	}
+
+	err := src.SaveRecords(context.Background(), records)
+	assert.NoError(t, err)
+
+	// dst already has a newer version of record 1, which is kept.
+	err = dst.SaveRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "not available"}})
+	assert.NoError(t, err)
+
+	migrated, err := repository.MigrateAll(context.Background(), src, dst)
+	assert.NoError(t, err)
+	assert.Equal(t, 1199, migrated)
+	assert.Equal(t, int64(1200), dst.Len())
+
+	rec, err := dst.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "not available", rec.Links["a.com"])
+
+	rec, err = dst.GetRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, testNow, rec.CreatedAt)
+	assert.Equal(t, testNow, rec.UpdatedAt)
+
+	// Rerunning copies nothing.
+	migrated, err = repository.MigrateAll(context.Background(), src, dst)
+	assert.NoError(t, err)
+	assert.Zero(t, migrated)
+}
+
+func TestMigrateAllCanceled(t *testing.T) {
+	src := newTestStorage(t, clock.NewFake(testNow))
+	dst := newTestStorage(t, clock.NewFake(testNow))
+
+	err := src.SaveRecord(context.Background(), &domain.Record{ID: 1})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = repository.MigrateAll(ctx, src, dst)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, dst.Len())
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"fmt"
+	"slices"
+)
+
+// The orders of ListRecords.
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// ValidatePage checks the arguments of ListRecords: offset and limit must not
+// be negative and order must be empty, OrderAsc or OrderDesc.
+func ValidatePage(offset, limit int, order string) error {
+	switch {
+	case offset < 0:
+		return fmt.Errorf("offset must not be negative, got %d: %w", offset, ErrInvalidPage)
+	case limit < 0:
+		return fmt.Errorf("limit must not be negative, got %d: %w", limit, ErrInvalidPage)
+	case order != "" && order != OrderAsc && order != OrderDesc:
+		return fmt.Errorf("order must be %s or %s, got %q: %w", OrderAsc, OrderDesc, order, ErrInvalidPage)
+	}
+
+	return nil
+}
+
+// PageIDs sorts the IDs in the order, drops repeated ones and returns the
+// page of them. It is meant for storages that cannot page by ID themselves.
+// The slice is modified in place.
+func PageIDs(ids []int64, offset, limit int, order string) []int64 {
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	if order == OrderDesc {
+		slices.Reverse(ids)
+	}
+
+	if offset >= len(ids) {
+		return nil
+	}
+	ids = ids[offset:]
+
+	if limit > 0 && limit < len(ids) {
+		ids = ids[:limit]
+	}
+
+	return ids
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePage(t *testing.T) {
+	assert.NoError(t, ValidatePage(0, 0, ""))
+	assert.NoError(t, ValidatePage(10, 5, OrderDesc))
+
+	assert.ErrorIs(t, ValidatePage(-1, 0, ""), ErrInvalidPage)
+	assert.ErrorIs(t, ValidatePage(0, -1, ""), ErrInvalidPage)
+	assert.ErrorIs(t, ValidatePage(0, 0, "newest"), ErrInvalidPage)
+}
+
+func TestPageIDs(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset int
+		limit  int
+		order  string
+		want   []int64
+	}{
+		{name: "all", want: []int64{1, 2, 3, 4}},
+		{name: "first page", limit: 2, want: []int64{1, 2}},
+		{name: "last page", offset: 2, limit: 2, want: []int64{3, 4}},
+		{name: "past the end", offset: 4, want: nil},
+		{name: "descending", offset: 1, limit: 2, order: OrderDesc, want: []int64{3, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := []int64{3, 1, 4, 1, 2}
+			assert.Equal(t, tt.want, PageIDs(ids, tt.offset, tt.limit, tt.order))
+		})
+	}
+}
@@ -0,0 +1,800 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+// StorageType selects this backend with STORAGE_TYPE.
+const StorageType = "postgres"
+
+func init() {
+	repository.Register(StorageType, repository.FactoryFor(func(cfg *Config, logger *zap.Logger) (*Storage, error) {
+		return New(cfg, clock.Real{}, logger)
+	}))
+}
+
+type Config struct {
+	DSN            string        `env:"POSTGRES_DSN"`
+	MaxConns       int32         `env:"POSTGRES_MAX_CONNS" env-default:"10"`
+	ConnectTimeout time.Duration `env:"POSTGRES_CONNECT_TIMEOUT" env-default:"5s"`
+	// QueryTimeout bounds every storage call but ForEachRecord, which
+	// streams the whole table.
+	QueryTimeout time.Duration `env:"POSTGRES_QUERY_TIMEOUT" env-default:"5s"`
+}
+
+const (
+	defaultConnectTimeout = 5 * time.Second
+	defaultQueryTimeout   = 5 * time.Second
+)
+
+// Validate reports every missing or invalid field of the config.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.DSN == "" {
+		errs = append(errs, errors.New("POSTGRES_DSN is required"))
+	}
+
+	if c.MaxConns < 0 {
+		errs = append(errs, errors.New("POSTGRES_MAX_CONNS must not be negative"))
+	}
+
+	if c.ConnectTimeout < 0 {
+		errs = append(errs, errors.New("POSTGRES_CONNECT_TIMEOUT must not be negative"))
+	}
+
+	if c.QueryTimeout < 0 {
+		errs = append(errs, errors.New("POSTGRES_QUERY_TIMEOUT must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// schema creates the tables on first start. Records are keyed by ID, temp
+// records and events by an insertion sequence.
+const schema = `
+CREATE TABLE IF NOT EXISTS records (
+	id         BIGINT PRIMARY KEY,
+	links      JSONB NOT NULL,
+	source     TEXT NOT NULL DEFAULT '',
+	tags       TEXT[] NOT NULL DEFAULT '{}',
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS records_updated_at_idx ON records (updated_at);
+
+CREATE TABLE IF NOT EXISTS temp_records (
+	seq  BIGSERIAL PRIMARY KEY,
+	data JSONB NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS record_events (
+	seq         BIGSERIAL PRIMARY KEY,
+	record_id   BIGINT NOT NULL,
+	event_type  TEXT NOT NULL,
+	occurred_at TIMESTAMPTZ NOT NULL,
+	detail      TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS record_events_record_id_idx ON record_events (record_id, seq);
+`
+
+const recordColumns = `id, links, source, tags, created_at, updated_at`
+
+// upsertRecord inserts the record or replaces the stored one. Missing
+// timestamps ($5, $6) are taken from the stored row or $7, the current time,
+// and a replaced row gets $8 as UpdatedAt. The last column reports whether
+// the row was inserted.
+const upsertRecord = `
+INSERT INTO records (id, links, source, tags, created_at, updated_at)
+VALUES ($1, $2, $3, $4, COALESCE($5::timestamptz, $7), COALESCE($6::timestamptz, $5::timestamptz, $7::timestamptz))
+ON CONFLICT (id) DO UPDATE SET
+	links      = EXCLUDED.links,
+	source     = EXCLUDED.source,
+	tags       = EXCLUDED.tags,
+	created_at = COALESCE($5::timestamptz, records.created_at),
+	updated_at = $8::timestamptz
+RETURNING created_at, updated_at, xmax = 0`
+
+// Storage keeps records in PostgreSQL. Saves run in transactions together
+// with their events, and lookups by ID use the primary key.
+type Storage struct {
+	pool         *pgxpool.Pool
+	queryTimeout time.Duration
+	// records is the number of rows in the records table, see Len.
+	records atomic.Int64
+	clock   clock.Clock
+	logger  *zap.Logger
+}
+
+func New(cfg *Config, clk clock.Clock, logger *zap.Logger) (*Storage, error) {
+	poolCfg, err := pgxpool.ParseConfig(cfg.DSN)
+	if err != nil {
+		logger.Error("failed to parse dsn", zap.Error(err))
+		return nil, fmt.Errorf("failed to parse dsn: %w", err)
+	}
+
+	if cfg.MaxConns > 0 {
+		poolCfg.MaxConns = cfg.MaxConns
+	}
+
+	connectTimeout := cfg.ConnectTimeout
+	if connectTimeout == 0 {
+		connectTimeout = defaultConnectTimeout
+	}
+
+	queryTimeout := cfg.QueryTimeout
+	if queryTimeout == 0 {
+		queryTimeout = defaultQueryTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		logger.Error("failed to connect to postgres", zap.Error(err))
+		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+
+	_, err = pool.Exec(ctx, schema)
+	if err != nil {
+		pool.Close()
+		logger.Error("failed to init schema", zap.Error(err))
+		return nil, fmt.Errorf("failed to init schema: %w", err)
+	}
+
+	var records int64
+	err = pool.QueryRow(ctx, `SELECT count(*) FROM records`).Scan(&records)
+	if err != nil {
+		pool.Close()
+		logger.Error("failed to count records", zap.Error(err))
+		return nil, fmt.Errorf("failed to count records: %w", err)
+	}
+
+	logger.Info("postgres connected", zap.String("host", poolCfg.ConnConfig.Host), zap.String("database", poolCfg.ConnConfig.Database))
+
+	storage := &Storage{
+		pool:         pool,
+		queryTimeout: queryTimeout,
+		clock:        clk,
+		logger:       logger,
+	}
+	storage.records.Store(records)
+
+	return storage, nil
+}
+
+// Close closes every connection of the pool.
+func (s *Storage) Close() error {
+	s.pool.Close()
+	return nil
+}
+
+// context bounds a storage call with the query timeout.
+func (s *Storage) context(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, s.queryTimeout)
+}
+
+// SaveRecord stores the record under its ID. Missing timestamps are set from
+// the storage clock.
+func (s *Storage) SaveRecord(ctx context.Context, record *domain.Record) error {
+	return s.saveRecords(ctx, []*domain.Record{record}, "save record")
+}
+
+// SaveRecords stores the records in a single transaction.
+func (s *Storage) SaveRecords(ctx context.Context, records []*domain.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	return s.saveRecords(ctx, records, "save records")
+}
+
+func (s *Storage) saveRecords(ctx context.Context, records []*domain.Record, op string) error {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	now := s.clock.Now().UTC()
+	var added int64
+
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		for _, record := range records {
+			if record.CreatedAt.IsZero() {
+				record.CreatedAt = now
+			}
+
+			if record.UpdatedAt.IsZero() {
+				record.UpdatedAt = record.CreatedAt
+			}
+
+			// The record keeps its own UpdatedAt when it replaces a stored one.
+			inserted, err := putRecord(ctx, tx, record, now, record.UpdatedAt)
+			if err != nil {
+				return err
+			}
+			if inserted {
+				added++
+			}
+
+			err = s.putEvent(ctx, tx, record.ID, domain.EventTypeCreated)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("failed to "+op, zap.Error(err))
+		return fmt.Errorf("failed to %s: %w", op, err)
+	}
+
+	s.records.Add(added)
+
+	s.logger.Info("successfully wrote records", zap.Int("count", len(records)))
+	return nil
+}
+
+func (s *Storage) SaveTempRecord(ctx context.Context, record *domain.Record) error {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		s.logger.Error("failed to marshal record", zap.Error(err))
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	_, err = s.pool.Exec(ctx, `INSERT INTO temp_records (data) VALUES ($1)`, data)
+	if err != nil {
+		s.logger.Error("failed to save temp record", zap.Int64("id", record.ID), zap.Error(err))
+		return fmt.Errorf("failed to save temp record: %w", err)
+	}
+
+	s.logger.Info("successfully wrote temp record")
+	return nil
+}
+
+// UpsertRecords inserts or replaces the batch in a single transaction.
+// If the batch contains the same ID several times, the last one wins.
+func (s *Storage) UpsertRecords(ctx context.Context, records []*domain.Record) (int, int, error) {
+	batch := make(map[int64]*domain.Record, len(records))
+	order := make([]int64, 0, len(records))
+	for _, rec := range records {
+		if _, ok := batch[rec.ID]; !ok {
+			order = append(order, rec.ID)
+		}
+		batch[rec.ID] = rec
+	}
+
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	now := s.clock.Now().UTC()
+	inserted, updated := 0, 0
+
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		for _, id := range order {
+			rec := batch[id]
+
+			created, err := putRecord(ctx, tx, rec, now, now)
+			if err != nil {
+				return err
+			}
+
+			eventType := domain.EventTypeUpdated
+			if created {
+				eventType = domain.EventTypeCreated
+				inserted++
+			} else {
+				updated++
+			}
+
+			err = s.putEvent(ctx, tx, id, eventType)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("failed to upsert records", zap.Error(err))
+		return 0, 0, fmt.Errorf("failed to upsert records: %w", err)
+	}
+
+	s.records.Add(int64(inserted))
+
+	s.logger.Info("successfully upserted records", zap.Int("inserted", inserted), zap.Int("updated", updated))
+	return inserted, updated, nil
+}
+
+// UpdateRecord replaces the stored record and stores its updated event in
+// the same transaction. A zero CreatedAt keeps the stored creation time. It
+// fails with repository.ErrRecordNotFound if the record is not stored.
+func (s *Storage) UpdateRecord(ctx context.Context, record *domain.Record) error {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	now := s.clock.Now().UTC()
+
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		// The row lock keeps a concurrent DeleteRecord from turning the
+		// upsert into an insert.
+		var exists bool
+		err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM records WHERE id = $1 FOR UPDATE)`, record.ID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			return fmt.Errorf("record with ID %d: %w", record.ID, repository.ErrRecordNotFound)
+		}
+
+		_, err = putRecord(ctx, tx, record, now, now)
+		if err != nil {
+			return err
+		}
+
+		return s.putEvent(ctx, tx, record.ID, domain.EventTypeUpdated)
+	})
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return err
+	}
+	if err != nil {
+		s.logger.Error("failed to update record", zap.Int64("id", record.ID), zap.Error(err))
+		return fmt.Errorf("failed to update record: %w", err)
+	}
+
+	s.logger.Info("successfully updated record", zap.Int64("id", record.ID))
+	return nil
+}
+
+// DeleteRecord removes the record and stores its deleted event in the same
+// transaction. It fails with repository.ErrRecordNotFound if the record is
+// not stored.
+func (s *Storage) DeleteRecord(ctx context.Context, id int64) error {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `DELETE FROM records WHERE id = $1`, id)
+		if err != nil {
+			return err
+		}
+
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("record with ID %d: %w", id, repository.ErrRecordNotFound)
+		}
+
+		return s.putEvent(ctx, tx, id, domain.EventTypeDeleted)
+	})
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return err
+	}
+	if err != nil {
+		s.logger.Error("failed to delete record", zap.Int64("id", id), zap.Error(err))
+		return fmt.Errorf("failed to delete record: %w", err)
+	}
+
+	s.records.Add(-1)
+
+	s.logger.Info("successfully deleted record", zap.Int64("id", id))
+	return nil
+}
+
+// LoadTempRecords returns the temp records in insertion order, keeping one
+// record per ID: the most recently updated one and, on ties, the last one.
+func (s *Storage) LoadTempRecords(ctx context.Context) ([]domain.Record, error) {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `SELECT data FROM temp_records ORDER BY seq`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load temp records: %w", err)
+	}
+	defer rows.Close()
+
+	var records []domain.Record
+	positions := make(map[int64]int)
+
+	for rows.Next() {
+		var data []byte
+		err = rows.Scan(&data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load temp records: %w", err)
+		}
+
+		var rec domain.Record
+		err = json.Unmarshal(data, &rec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode temp record: %w", err)
+		}
+
+		pos, ok := positions[rec.ID]
+		if !ok {
+			positions[rec.ID] = len(records)
+			records = append(records, rec)
+			continue
+		}
+
+		if !rec.UpdatedAt.Before(records[pos].UpdatedAt) {
+			records[pos] = rec
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load temp records: %w", err)
+	}
+
+	return records, nil
+}
+
+func (s *Storage) GetRecord(ctx context.Context, id int64) (*domain.Record, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	row := s.pool.QueryRow(ctx, `SELECT `+recordColumns+` FROM records WHERE id = $1`, id)
+
+	rec, err := scanRecord(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("record with ID %d: %w", id, repository.ErrRecordNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("record with ID %d: %w", id, err)
+	}
+
+	return rec, nil
+}
+
+// GetRecords reads the records with the given IDs in a single query.
+func (s *Storage) GetRecords(ctx context.Context, ids []int64) (map[int64]*domain.Record, error) {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	records := make(map[int64]*domain.Record, len(ids))
+
+	err := s.queryRecords(ctx, func(rec *domain.Record) error {
+		records[rec.ID] = rec
+		return nil
+	}, `SELECT `+recordColumns+` FROM records WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get records: %w", err)
+	}
+
+	return records, nil
+}
+
+// GetAllRecords returns all records matching the filter options ordered by ID.
+func (s *Storage) GetAllRecords(ctx context.Context, opts ...repository.FilterOption) ([]domain.Record, error) {
+	filter := repository.NewFilter(opts...)
+	records := make([]domain.Record, 0)
+
+	err := s.ForEachRecord(ctx, func(rec *domain.Record) error {
+		if filter.Match(rec) {
+			records = append(records, *rec)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func (s *Storage) GetRecordsBySource(ctx context.Context, source string) ([]domain.Record, error) {
+	return s.listRecords(ctx, `SELECT `+recordColumns+` FROM records WHERE source = $1 ORDER BY id`, source)
+}
+
+// GetRecordsSince returns the records created or updated at or after since,
+// ordered by UpdatedAt.
+func (s *Storage) GetRecordsSince(ctx context.Context, since time.Time) ([]domain.Record, error) {
+	return s.listRecords(ctx, `SELECT `+recordColumns+` FROM records
+		WHERE created_at >= $1 OR updated_at >= $1
+		ORDER BY updated_at, id`, since)
+}
+
+func (s *Storage) FindRecordsByTag(ctx context.Context, tag string) ([]domain.Record, error) {
+	return s.listRecords(ctx, `SELECT `+recordColumns+` FROM records WHERE $1 = ANY(tags) ORDER BY id`, tag)
+}
+
+// SearchRecords returns the records matching the query ordered by ID. The
+// links are matched with jsonb_each_text, so every record is read.
+func (s *Storage) SearchRecords(ctx context.Context, query repository.SearchQuery) ([]domain.Record, error) {
+	// A NULL bound is no bound.
+	var from, to *time.Time
+	if !query.From.IsZero() {
+		from = &query.From
+	}
+	if !query.To.IsZero() {
+		to = &query.To
+	}
+
+	return s.listRecords(ctx, `SELECT `+recordColumns+` FROM records
+		WHERE ($1 = '' AND $2 = '' OR EXISTS (
+				SELECT 1 FROM jsonb_each_text(links) AS l
+				WHERE strpos(l.key, $1) > 0 AND ($2 = '' OR l.value = $2)))
+			AND ($3::timestamptz IS NULL OR created_at >= $3)
+			AND ($4::timestamptz IS NULL OR created_at < $4)
+		ORDER BY id`, query.URL, query.Status, from, to)
+}
+
+// ForEachRecord calls fn for every record ordered by ID while the rows are
+// read. fn must not write to the storage. The scan is only bounded by ctx,
+// not by the query timeout, as it lasts as long as fn takes for all records.
+func (s *Storage) ForEachRecord(ctx context.Context, fn func(rec *domain.Record) error) error {
+	return s.queryRecords(ctx, fn, `SELECT `+recordColumns+` FROM records ORDER BY id`)
+}
+
+// ListRecords returns a page of the records ordered by ID with LIMIT and
+// OFFSET.
+func (s *Storage) ListRecords(ctx context.Context, offset, limit int, order string) ([]domain.Record, error) {
+	err := repository.ValidatePage(offset, limit, order)
+	if err != nil {
+		return nil, err
+	}
+
+	direction := "ASC"
+	if order == repository.OrderDesc {
+		direction = "DESC"
+	}
+
+	// LIMIT NULL is no limit.
+	var limitArg *int
+	if limit > 0 {
+		limitArg = &limit
+	}
+
+	return s.listRecords(ctx, `SELECT `+recordColumns+` FROM records ORDER BY id `+direction+` LIMIT $1 OFFSET $2`, limitArg, offset)
+}
+
+func (s *Storage) ListRecordIDs(ctx context.Context) ([]int64, error) {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `SELECT id FROM records ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list record ids: %w", err)
+	}
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list record ids: %w", err)
+	}
+
+	if ids == nil {
+		ids = make([]int64, 0)
+	}
+
+	return ids, nil
+}
+
+// ClearTempFile removes every temp record.
+func (s *Storage) ClearTempFile(ctx context.Context) error {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	_, err := s.pool.Exec(ctx, `TRUNCATE temp_records`)
+	if err != nil {
+		s.logger.Error("failed to clear temp records", zap.Error(err))
+		return fmt.Errorf("failed to clear temp records: %w", err)
+	}
+
+	return nil
+}
+
+// LoadLastLinksNum returns the highest stored ID. Deleted records keep their
+// events, which count as well so that their IDs are not handed out again.
+func (s *Storage) LoadLastLinksNum(ctx context.Context) int64 {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	var last int64
+	err := s.pool.QueryRow(ctx, `SELECT GREATEST(
+		(SELECT COALESCE(MAX(id), 0) FROM records),
+		(SELECT COALESCE(MAX(record_id), 0) FROM record_events))`).Scan(&last)
+	if err != nil {
+		s.logger.Error("failed to load last links num", zap.Error(err))
+		return 0
+	}
+
+	return last
+}
+
+// Len returns the number of records counted at startup and kept up to date
+// by the writes of this process.
+func (s *Storage) Len() int64 {
+	return s.records.Load()
+}
+
+// SaveRecordEvent stores the event. A missing OccurredAt is set from the
+// storage clock.
+func (s *Storage) SaveRecordEvent(ctx context.Context, event *domain.RecordEvent) error {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = s.clock.Now().UTC()
+	}
+
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	err := insertEvent(ctx, s.pool, event)
+	if err != nil {
+		s.logger.Error("failed to save record event", zap.Int64("id", event.RecordID), zap.Error(err))
+		return fmt.Errorf("failed to save record event: %w", err)
+	}
+
+	return nil
+}
+
+// GetRecordEvents returns the events of the record in the order they were
+// stored.
+func (s *Storage) GetRecordEvents(ctx context.Context, id int64) ([]domain.RecordEvent, error) {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `SELECT record_id, event_type, occurred_at, detail
+		FROM record_events WHERE record_id = $1 ORDER BY seq`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get record events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]domain.RecordEvent, 0)
+	for rows.Next() {
+		var event domain.RecordEvent
+		err = rows.Scan(&event.RecordID, &event.EventType, &event.OccurredAt, &event.Detail)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get record events: %w", err)
+		}
+
+		event.OccurredAt = event.OccurredAt.UTC()
+		events = append(events, event)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get record events: %w", err)
+	}
+
+	return events, nil
+}
+
+func (s *Storage) putEvent(ctx context.Context, tx pgx.Tx, id int64, eventType string) error {
+	return insertEvent(ctx, tx, &domain.RecordEvent{
+		RecordID:   id,
+		EventType:  eventType,
+		OccurredAt: s.clock.Now().UTC(),
+	})
+}
+
+// execer is implemented by both the pool and transactions.
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func insertEvent(ctx context.Context, db execer, event *domain.RecordEvent) error {
+	_, err := db.Exec(ctx, `INSERT INTO record_events (record_id, event_type, occurred_at, detail)
+		VALUES ($1, $2, $3, $4)`, event.RecordID, event.EventType, event.OccurredAt, event.Detail)
+	return err
+}
+
+// putRecord upserts the record and writes the stored timestamps back to it.
+// updatedAt is the UpdatedAt of a replaced row. It reports whether the row was
+// inserted.
+func putRecord(ctx context.Context, tx pgx.Tx, rec *domain.Record, now, updatedAt time.Time) (bool, error) {
+	tags := rec.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	links := rec.Links
+	if links == nil {
+		links = map[string]string{}
+	}
+
+	var inserted bool
+	err := tx.QueryRow(ctx, upsertRecord,
+		rec.ID, links, rec.Source, tags,
+		nullTime(rec.CreatedAt), nullTime(rec.UpdatedAt), now, updatedAt,
+	).Scan(&rec.CreatedAt, &rec.UpdatedAt, &inserted)
+	if err != nil {
+		return false, fmt.Errorf("failed to put record %d: %w", rec.ID, err)
+	}
+
+	rec.CreatedAt = rec.CreatedAt.UTC()
+	rec.UpdatedAt = rec.UpdatedAt.UTC()
+
+	return inserted, nil
+}
+
+// listRecords returns the records selected by the query.
+func (s *Storage) listRecords(ctx context.Context, query string, args ...any) ([]domain.Record, error) {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	records := make([]domain.Record, 0)
+
+	err := s.queryRecords(ctx, func(rec *domain.Record) error {
+		records = append(records, *rec)
+		return nil
+	}, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// queryRecords calls fn for every record selected by the query. The caller
+// decides whether the query timeout applies.
+func (s *Storage) queryRecords(ctx context.Context, fn func(rec *domain.Record) error, query string, args ...any) error {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query records: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rec, err := scanRecord(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan record: %w", err)
+		}
+
+		err = fn(rec)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("failed to query records: %w", err)
+	}
+
+	return nil
+}
+
+func scanRecord(row pgx.Row) (*domain.Record, error) {
+	var rec domain.Record
+	err := row.Scan(&rec.ID, &rec.Links, &rec.Source, &rec.Tags, &rec.CreatedAt, &rec.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rec.Tags) == 0 {
+		rec.Tags = nil
+	}
+
+	rec.CreatedAt = rec.CreatedAt.UTC()
+	rec.UpdatedAt = rec.UpdatedAt.UTC()
+
+	return &rec, nil
+}
+
+// nullTime maps the zero time to NULL.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
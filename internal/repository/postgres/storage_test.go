@@ -0,0 +1,243 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+	"link-service/internal/repository/repositorytest"
+)
+
+var testNow = time.Date(2025, 11, 30, 12, 0, 0, 0, time.UTC)
+
+// newTestStorage connects to the database in POSTGRES_TEST_DSN and empties
+// the tables. The tests are skipped when it is not set.
+func newTestStorage(t *testing.T) (*Storage, *clock.Fake) {
+	t.Helper()
+
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+
+	clk := clock.NewFake(testNow)
+	storage, err := New(&Config{DSN: dsn}, clk, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { storage.Close() })
+
+	_, err = storage.pool.Exec(context.Background(), `TRUNCATE records, temp_records, record_events`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage.records.Store(0)
+
+	return storage, clk
+}
+
+func TestConformance(t *testing.T) {
+	repositorytest.RunSuite(t, func(t *testing.T) repository.Repository {
+		storage, _ := newTestStorage(t)
+		return storage
+	})
+}
+
+func TestValidate(t *testing.T) {
+	err := (&Config{}).Validate()
+	assert.ErrorContains(t, err, "POSTGRES_DSN")
+
+	err = (&Config{DSN: "postgres://localhost/links", MaxConns: -1}).Validate()
+	assert.ErrorContains(t, err, "POSTGRES_MAX_CONNS")
+}
+
+func TestSaveAndGetRecord(t *testing.T) {
+	storage, _ := newTestStorage(t)
+
+	err := storage.SaveRecord(context.Background(), &domain.Record{ID: 2, Links: map[string]string{"a.com": "available"}, Tags: []string{"prod"}})
+	assert.NoError(t, err)
+
+	rec, err := storage.GetRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a.com": "available"}, rec.Links)
+	assert.Equal(t, []string{"prod"}, rec.Tags)
+	assert.Equal(t, testNow, rec.CreatedAt)
+	assert.Equal(t, testNow, rec.UpdatedAt)
+
+	_, err = storage.GetRecord(context.Background(), 3)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	assert.Equal(t, int64(1), storage.Len())
+	assert.Equal(t, int64(2), storage.LoadLastLinksNum(context.Background()))
+}
+
+func TestUpsertRecords(t *testing.T) {
+	storage, clk := newTestStorage(t)
+
+	err := storage.SaveRecords(context.Background(), []*domain.Record{
+		{ID: 1, Links: map[string]string{"old.com": "available"}},
+		{ID: 2, Links: map[string]string{"old.com": "available"}},
+	})
+	assert.NoError(t, err)
+
+	clk.Advance(time.Hour)
+
+	inserted, updated, err := storage.UpsertRecords(context.Background(), []*domain.Record{
+		{ID: 2, Links: map[string]string{"new.com": "available"}},
+		{ID: 3, Links: map[string]string{"new.com": "available"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, inserted)
+	assert.Equal(t, 1, updated)
+	assert.Equal(t, int64(3), storage.Len())
+
+	rec, err := storage.GetRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"new.com": "available"}, rec.Links)
+	assert.Equal(t, testNow, rec.CreatedAt)
+	assert.Equal(t, testNow.Add(time.Hour), rec.UpdatedAt)
+
+	records, err := storage.GetRecordsSince(context.Background(), testNow.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	events, err := storage.GetRecordEvents(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, domain.EventTypeUpdated, events[1].EventType)
+}
+
+func TestTempRecords(t *testing.T) {
+	storage, clk := newTestStorage(t)
+
+	err := storage.SaveTempRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": ""}, UpdatedAt: clk.Now()})
+	assert.NoError(t, err)
+	err = storage.SaveTempRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"b.com": ""}, UpdatedAt: clk.Now()})
+	assert.NoError(t, err)
+
+	records, err := storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, map[string]string{"b.com": ""}, records[0].Links)
+
+	err = storage.ClearTempFile(context.Background())
+	assert.NoError(t, err)
+
+	records, err = storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestQueries(t *testing.T) {
+	storage, _ := newTestStorage(t)
+
+	err := storage.SaveRecords(context.Background(), []*domain.Record{
+		{ID: 3, Links: map[string]string{}, Source: domain.SourceAPI, Tags: []string{"prod"}},
+		{ID: 1, Links: map[string]string{"a.com": "available"}, Source: domain.SourceImport},
+		{ID: 2, Links: map[string]string{}, Source: domain.SourceAPI},
+	})
+	assert.NoError(t, err)
+
+	ids, err := storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+
+	records, err := storage.GetRecordsBySource(context.Background(), domain.SourceAPI)
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	records, err = storage.FindRecordsByTag(context.Background(), "prod")
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, int64(3), records[0].ID)
+
+	records, err = storage.GetAllRecords(context.Background(), repository.WithMinLinks(1))
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	found, err := storage.GetRecords(context.Background(), []int64{1, 4})
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+	assert.Contains(t, found, int64(1))
+}
+
+func TestListRecords(t *testing.T) {
+	storage, _ := newTestStorage(t)
+
+	assert.NoError(t, storage.SaveRecords(context.Background(), []*domain.Record{{ID: 3}, {ID: 1}, {ID: 2}}))
+
+	records, err := storage.ListRecords(context.Background(), 1, 1, "")
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, int64(2), records[0].ID)
+
+	records, err = storage.ListRecords(context.Background(), 0, 0, repository.OrderDesc)
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, int64(3), records[0].ID)
+
+	records, err = storage.ListRecords(context.Background(), 5, 1, "")
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	_, err = storage.ListRecords(context.Background(), 0, 0, "newest")
+	assert.ErrorIs(t, err, repository.ErrInvalidPage)
+}
+
+func TestForEachRecordOutlivesQueryTimeout(t *testing.T) {
+	storage, _ := newTestStorage(t)
+	storage.queryTimeout = 50 * time.Millisecond
+
+	assert.NoError(t, storage.SaveRecords(context.Background(), []*domain.Record{{ID: 1}, {ID: 2}, {ID: 3}}))
+
+	calls := 0
+	err := storage.ForEachRecord(context.Background(), func(rec *domain.Record) error {
+		calls++
+		time.Sleep(40 * time.Millisecond)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestUpdateRecord(t *testing.T) {
+	storage, clk := newTestStorage(t)
+
+	assert.NoError(t, storage.SaveRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "available"}}))
+
+	clk.Advance(time.Minute)
+
+	err := storage.UpdateRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "not available"}})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, storage.UpdateRecord(context.Background(), &domain.Record{ID: 2}), repository.ErrRecordNotFound)
+	assert.Equal(t, int64(1), storage.Len())
+
+	rec, err := storage.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "not available", rec.Links["a.com"])
+	assert.True(t, rec.CreatedAt.Equal(testNow))
+	assert.True(t, rec.UpdatedAt.Equal(testNow.Add(time.Minute)))
+}
+
+func TestDeleteRecord(t *testing.T) {
+	storage, _ := newTestStorage(t)
+
+	assert.NoError(t, storage.SaveRecords(context.Background(), []*domain.Record{{ID: 1}, {ID: 2}}))
+
+	assert.NoError(t, storage.DeleteRecord(context.Background(), 2))
+	assert.ErrorIs(t, storage.DeleteRecord(context.Background(), 2), repository.ErrRecordNotFound)
+	assert.Equal(t, int64(1), storage.Len())
+
+	_, err := storage.GetRecord(context.Background(), 2)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	// The events keep the ID of the deleted record taken.
+	assert.Equal(t, int64(2), storage.LoadLastLinksNum(context.Background()))
+}
@@ -0,0 +1,912 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+// StorageType selects this backend with STORAGE_TYPE.
+const StorageType = "redis"
+
+func init() {
+	repository.Register(StorageType, repository.FactoryFor(func(cfg *Config, logger *zap.Logger) (*Storage, error) {
+		return New(cfg, clock.Real{}, logger)
+	}))
+}
+
+type Config struct {
+	Addr      string `env:"REDIS_ADDR"`
+	Password  string `env:"REDIS_PASSWORD"`
+	DB        int    `env:"REDIS_DB" env-default:"0"`
+	KeyPrefix string `env:"REDIS_KEY_PREFIX" env-default:"link-service:"`
+	// RecordTTL is the expiry of records saved without their own TTL. Zero
+	// keeps them forever.
+	RecordTTL time.Duration `env:"REDIS_RECORD_TTL" env-default:"0"`
+	// TempRecordTTL is the expiry of the temp records, refreshed on every
+	// temp save. Zero keeps them until they are cleared.
+	TempRecordTTL time.Duration `env:"REDIS_TEMP_RECORD_TTL" env-default:"0"`
+	QueryTimeout  time.Duration `env:"REDIS_QUERY_TIMEOUT" env-default:"5s"`
+}
+
+const (
+	defaultQueryTimeout = 5 * time.Second
+	// scanBatchSize is the number of records read per round trip when
+	// iterating over all records.
+	scanBatchSize = 500
+	// maxTxRetries is the number of times an upsert or delete is retried
+	// when another client changes the records it reads.
+	maxTxRetries = 3
+)
+
+// Validate reports every missing or invalid field of the config.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Addr == "" {
+		errs = append(errs, errors.New("REDIS_ADDR is required"))
+	}
+
+	if c.DB < 0 {
+		errs = append(errs, errors.New("REDIS_DB must not be negative"))
+	}
+
+	if c.RecordTTL < 0 {
+		errs = append(errs, errors.New("REDIS_RECORD_TTL must not be negative"))
+	}
+
+	if c.TempRecordTTL < 0 {
+		errs = append(errs, errors.New("REDIS_TEMP_RECORD_TTL must not be negative"))
+	}
+
+	if c.QueryTimeout < 0 {
+		errs = append(errs, errors.New("REDIS_QUERY_TIMEOUT must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// setMax stores ARGV[1] in KEYS[1] if it is greater than the stored number.
+var setMax = goredis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+if tonumber(ARGV[1]) > current then
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+return 0`)
+
+// Storage keeps every record as a JSON string under its own key, so Redis
+// expires records one by one. A sorted set keeps the IDs in order; the IDs of
+// expired records are dropped from it when they are next read. The highest
+// ID ever saved is kept separately so IDs are not reused after expiry.
+type Storage struct {
+	client        *goredis.Client
+	prefix        string
+	recordTTL     time.Duration
+	tempRecordTTL time.Duration
+	queryTimeout  time.Duration
+	clock         clock.Clock
+	logger        *zap.Logger
+}
+
+func New(cfg *Config, clk clock.Clock, logger *zap.Logger) (*Storage, error) {
+	queryTimeout := cfg.QueryTimeout
+	if queryTimeout == 0 {
+		queryTimeout = defaultQueryTimeout
+	}
+
+	client := goredis.NewClient(&goredis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	err := client.Ping(ctx).Err()
+	if err != nil {
+		client.Close()
+		logger.Error("failed to connect to redis", zap.String("addr", cfg.Addr), zap.Error(err))
+		return nil, fmt.Errorf("failed to connect to redis: %s: %w", cfg.Addr, err)
+	}
+
+	logger.Info("redis connected", zap.String("addr", cfg.Addr), zap.Int("db", cfg.DB))
+
+	return &Storage{
+		client:        client,
+		prefix:        cfg.KeyPrefix,
+		recordTTL:     cfg.RecordTTL,
+		tempRecordTTL: cfg.TempRecordTTL,
+		queryTimeout:  queryTimeout,
+		clock:         clk,
+		logger:        logger,
+	}, nil
+}
+
+// Close closes the client.
+func (s *Storage) Close() error {
+	return s.client.Close()
+}
+
+func (s *Storage) context(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, s.queryTimeout)
+}
+
+func (s *Storage) recordKey(id int64) string {
+	return s.prefix + "record:" + strconv.FormatInt(id, 10)
+}
+
+func (s *Storage) eventsKey(id int64) string {
+	return s.prefix + "events:" + strconv.FormatInt(id, 10)
+}
+
+func (s *Storage) idsKey() string {
+	return s.prefix + "record_ids"
+}
+
+func (s *Storage) lastIDKey() string {
+	return s.prefix + "last_id"
+}
+
+func (s *Storage) tempKey() string {
+	return s.prefix + "temp_records"
+}
+
+// ttl returns the expiry of the record.
+func (s *Storage) ttl(rec *domain.Record) time.Duration {
+	if rec.TTL > 0 {
+		return rec.TTL
+	}
+
+	return s.recordTTL
+}
+
+// SaveRecord stores the record under its ID with its TTL. Missing timestamps
+// are set from the storage clock.
+func (s *Storage) SaveRecord(ctx context.Context, record *domain.Record) error {
+	return s.saveRecords(ctx, []*domain.Record{record}, "save record")
+}
+
+// SaveRecords stores the records in a single MULTI/EXEC transaction.
+func (s *Storage) SaveRecords(ctx context.Context, records []*domain.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	return s.saveRecords(ctx, records, "save records")
+}
+
+func (s *Storage) saveRecords(ctx context.Context, records []*domain.Record, op string) error {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	now := s.clock.Now().UTC()
+
+	_, err := s.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for _, record := range records {
+			if record.CreatedAt.IsZero() {
+				record.CreatedAt = now
+			}
+
+			if record.UpdatedAt.IsZero() {
+				record.UpdatedAt = record.CreatedAt
+			}
+
+			err := s.putRecord(ctx, pipe, record, domain.EventTypeCreated)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("failed to "+op, zap.Error(err))
+		return fmt.Errorf("failed to %s: %w", op, err)
+	}
+
+	s.logger.Info("successfully wrote records", zap.Int("count", len(records)))
+	return nil
+}
+
+// SaveTempRecord appends the record to the temp list and refreshes the expiry
+// of the list.
+func (s *Storage) SaveTempRecord(ctx context.Context, record *domain.Record) error {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		s.logger.Error("failed to marshal record", zap.Error(err))
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.RPush(ctx, s.tempKey(), data)
+		if s.tempRecordTTL > 0 {
+			pipe.Expire(ctx, s.tempKey(), s.tempRecordTTL)
+		}
+
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("failed to save temp record", zap.Int64("id", record.ID), zap.Error(err))
+		return fmt.Errorf("failed to save temp record: %w", err)
+	}
+
+	s.logger.Info("successfully wrote temp record")
+	return nil
+}
+
+// UpsertRecords inserts or replaces the batch in a single transaction. The
+// transaction is retried if another client changes one of the records while
+// it runs. If the batch contains the same ID several times, the last one
+// wins.
+func (s *Storage) UpsertRecords(ctx context.Context, records []*domain.Record) (int, int, error) {
+	batch := make(map[int64]*domain.Record, len(records))
+	order := make([]int64, 0, len(records))
+	for _, rec := range records {
+		if _, ok := batch[rec.ID]; !ok {
+			order = append(order, rec.ID)
+		}
+		batch[rec.ID] = rec
+	}
+
+	if len(order) == 0 {
+		return 0, 0, nil
+	}
+
+	keys := make([]string, 0, len(order))
+	for _, id := range order {
+		keys = append(keys, s.recordKey(id))
+	}
+
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	var inserted, updated int
+	var err error
+
+	for range maxTxRetries {
+		inserted, updated, err = s.upsert(ctx, batch, order, keys)
+		if !errors.Is(err, goredis.TxFailedErr) {
+			break
+		}
+	}
+	if err != nil {
+		s.logger.Error("failed to upsert records", zap.Error(err))
+		return 0, 0, fmt.Errorf("failed to upsert records: %w", err)
+	}
+
+	s.logger.Info("successfully upserted records", zap.Int("inserted", inserted), zap.Int("updated", updated))
+	return inserted, updated, nil
+}
+
+func (s *Storage) upsert(ctx context.Context, batch map[int64]*domain.Record, order []int64, keys []string) (int, int, error) {
+	now := s.clock.Now().UTC()
+	inserted, updated := 0, 0
+
+	err := s.client.Watch(ctx, func(tx *goredis.Tx) error {
+		values, err := tx.MGet(ctx, keys...).Result()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			for i, id := range order {
+				rec := batch[id]
+
+				eventType := domain.EventTypeUpdated
+
+				stored, err := decodeRecord(values[i])
+				switch {
+				case errors.Is(err, repository.ErrRecordNotFound):
+					if rec.CreatedAt.IsZero() {
+						rec.CreatedAt = now
+					}
+					if rec.UpdatedAt.IsZero() {
+						rec.UpdatedAt = rec.CreatedAt
+					}
+					eventType = domain.EventTypeCreated
+					inserted++
+
+				case err != nil:
+					return fmt.Errorf("record with ID %d: %w", id, err)
+
+				default:
+					if rec.CreatedAt.IsZero() {
+						rec.CreatedAt = stored.CreatedAt
+					}
+					rec.UpdatedAt = now
+					updated++
+				}
+
+				err = s.putRecord(ctx, pipe, rec, eventType)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		return err
+	}, keys...)
+
+	return inserted, updated, err
+}
+
+// UpdateRecord replaces the stored record and stores its updated event in a
+// single transaction, retried like UpsertRecords. A zero CreatedAt keeps the
+// stored creation time. It fails with repository.ErrRecordNotFound if the
+// record is not stored.
+func (s *Storage) UpdateRecord(ctx context.Context, record *domain.Record) error {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	key := s.recordKey(record.ID)
+
+	var err error
+	for range maxTxRetries {
+		err = s.client.Watch(ctx, func(tx *goredis.Tx) error {
+			values, err := tx.MGet(ctx, key).Result()
+			if err != nil {
+				return err
+			}
+
+			stored, err := decodeRecord(values[0])
+			if err != nil {
+				return fmt.Errorf("record with ID %d: %w", record.ID, err)
+			}
+
+			if record.CreatedAt.IsZero() {
+				record.CreatedAt = stored.CreatedAt
+			}
+			record.UpdatedAt = s.clock.Now().UTC()
+
+			_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+				return s.putRecord(ctx, pipe, record, domain.EventTypeUpdated)
+			})
+
+			return err
+		}, key)
+		if !errors.Is(err, goredis.TxFailedErr) {
+			break
+		}
+	}
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return err
+	}
+	if err != nil {
+		s.logger.Error("failed to update record", zap.Int64("id", record.ID), zap.Error(err))
+		return fmt.Errorf("failed to update record: %w", err)
+	}
+
+	s.logger.Info("successfully updated record", zap.Int64("id", record.ID))
+	return nil
+}
+
+// DeleteRecord removes the record and its ID and stores the deleted event in
+// a single transaction, retried like UpsertRecords. The events keep their
+// expiry. It fails with repository.ErrRecordNotFound if the record is not
+// stored.
+func (s *Storage) DeleteRecord(ctx context.Context, id int64) error {
+	event, err := json.Marshal(domain.RecordEvent{
+		RecordID:   id,
+		EventType:  domain.EventTypeDeleted,
+		OccurredAt: s.clock.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	key := s.recordKey(id)
+
+	for range maxTxRetries {
+		err = s.client.Watch(ctx, func(tx *goredis.Tx) error {
+			n, err := tx.Exists(ctx, key).Result()
+			if err != nil {
+				return err
+			}
+
+			if n == 0 {
+				return fmt.Errorf("record with ID %d: %w", id, repository.ErrRecordNotFound)
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+				pipe.Del(ctx, key)
+				pipe.ZRem(ctx, s.idsKey(), id)
+				pipe.RPush(ctx, s.eventsKey(id), event)
+				return nil
+			})
+
+			return err
+		}, key)
+		if !errors.Is(err, goredis.TxFailedErr) {
+			break
+		}
+	}
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return err
+	}
+	if err != nil {
+		s.logger.Error("failed to delete record", zap.Int64("id", id), zap.Error(err))
+		return fmt.Errorf("failed to delete record: %w", err)
+	}
+
+	s.logger.Info("successfully deleted record", zap.Int64("id", id))
+	return nil
+}
+
+// LoadTempRecords returns the temp records in insertion order, keeping one
+// record per ID: the most recently updated one and, on ties, the last one.
+func (s *Storage) LoadTempRecords(ctx context.Context) ([]domain.Record, error) {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	values, err := s.client.LRange(ctx, s.tempKey(), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load temp records: %w", err)
+	}
+
+	var records []domain.Record
+	positions := make(map[int64]int)
+
+	for _, value := range values {
+		var rec domain.Record
+		err = json.Unmarshal([]byte(value), &rec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode temp record: %w", err)
+		}
+
+		pos, ok := positions[rec.ID]
+		if !ok {
+			positions[rec.ID] = len(records)
+			records = append(records, rec)
+			continue
+		}
+
+		if !rec.UpdatedAt.Before(records[pos].UpdatedAt) {
+			records[pos] = rec
+		}
+	}
+
+	return records, nil
+}
+
+func (s *Storage) GetRecord(ctx context.Context, id int64) (*domain.Record, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	value, err := s.client.Get(ctx, s.recordKey(id)).Result()
+	if errors.Is(err, goredis.Nil) {
+		return nil, fmt.Errorf("record with ID %d: %w", id, repository.ErrRecordNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("record with ID %d: %w", id, err)
+	}
+
+	rec, err := decodeRecord(value)
+	if err != nil {
+		return nil, fmt.Errorf("record with ID %d: %w", id, err)
+	}
+
+	return rec, nil
+}
+
+// GetRecords reads the records with the given IDs with a single MGET.
+func (s *Storage) GetRecords(ctx context.Context, ids []int64) (map[int64]*domain.Record, error) {
+	records := make(map[int64]*domain.Record, len(ids))
+	if len(ids) == 0 {
+		return records, nil
+	}
+
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	found, err := s.getRecords(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get records: %w", err)
+	}
+
+	for _, rec := range found {
+		records[rec.ID] = rec
+	}
+
+	return records, nil
+}
+
+// GetAllRecords returns all records matching the filter options ordered by ID.
+func (s *Storage) GetAllRecords(ctx context.Context, opts ...repository.FilterOption) ([]domain.Record, error) {
+	filter := repository.NewFilter(opts...)
+	records := make([]domain.Record, 0)
+
+	err := s.ForEachRecord(ctx, func(rec *domain.Record) error {
+		if filter.Match(rec) {
+			records = append(records, *rec)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func (s *Storage) GetRecordsBySource(ctx context.Context, source string) ([]domain.Record, error) {
+	return s.GetAllRecords(ctx, repository.WithSource(source))
+}
+
+// GetRecordsSince returns the records created or updated at or after since,
+// ordered by UpdatedAt.
+func (s *Storage) GetRecordsSince(ctx context.Context, since time.Time) ([]domain.Record, error) {
+	records := make([]domain.Record, 0)
+
+	err := s.ForEachRecord(ctx, func(rec *domain.Record) error {
+		if rec.ModifiedSince(since) {
+			records = append(records, *rec)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	repository.SortByUpdatedAt(records)
+	return records, nil
+}
+
+func (s *Storage) FindRecordsByTag(ctx context.Context, tag string) ([]domain.Record, error) {
+	return s.GetAllRecords(ctx, repository.WithTag(tag))
+}
+
+// SearchRecords returns the records matching the query ordered by ID.
+func (s *Storage) SearchRecords(ctx context.Context, query repository.SearchQuery) ([]domain.Record, error) {
+	records := make([]domain.Record, 0)
+
+	err := s.ForEachRecord(ctx, func(rec *domain.Record) error {
+		if query.Match(rec) {
+			records = append(records, *rec)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// ForEachRecord calls fn for every record ordered by ID. The records are read
+// in batches, so records saved meanwhile may or may not be seen.
+func (s *Storage) ForEachRecord(ctx context.Context, fn func(rec *domain.Record) error) error {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	for start := int64(0); ; start += scanBatchSize {
+		members, err := s.client.ZRange(ctx, s.idsKey(), start, start+scanBatchSize-1).Result()
+		if err != nil {
+			return fmt.Errorf("failed to list record ids: %w", err)
+		}
+
+		ids, err := parseIDs(members)
+		if err != nil {
+			return err
+		}
+
+		records, err := s.getRecords(ctx, ids)
+		if err != nil {
+			return fmt.Errorf("failed to get records: %w", err)
+		}
+
+		for _, rec := range records {
+			err = fn(rec)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(members) < scanBatchSize {
+			return nil
+		}
+
+		// Dropping expired IDs shifts the following ones down.
+		start -= int64(len(ids) - len(records))
+	}
+}
+
+// ListRecords reads a page of the ID set by rank and then the records of the
+// page. IDs of expired records found on the page are dropped and the page is
+// filled up from the following ones, while expired records before offset
+// still count towards it until they are read.
+func (s *Storage) ListRecords(ctx context.Context, offset, limit int, order string) ([]domain.Record, error) {
+	err := repository.ValidatePage(offset, limit, order)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	zrange := s.client.ZRange
+	if order == repository.OrderDesc {
+		zrange = s.client.ZRevRange
+	}
+
+	records := make([]domain.Record, 0)
+
+	for start := int64(offset); limit == 0 || len(records) < limit; {
+		count := int64(scanBatchSize)
+		if limit > 0 {
+			count = min(count, int64(limit-len(records)))
+		}
+
+		members, err := zrange(ctx, s.idsKey(), start, start+count-1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list record ids: %w", err)
+		}
+
+		ids, err := parseIDs(members)
+		if err != nil {
+			return nil, err
+		}
+
+		found, err := s.getRecords(ctx, ids)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get records: %w", err)
+		}
+
+		for _, rec := range found {
+			records = append(records, *rec)
+		}
+
+		if int64(len(members)) < count {
+			break
+		}
+
+		// Dropping expired IDs shifts the following ones down.
+		start += int64(len(found))
+	}
+
+	return records, nil
+}
+
+func (s *Storage) ListRecordIDs(ctx context.Context) ([]int64, error) {
+	ids := make([]int64, 0)
+
+	err := s.ForEachRecord(ctx, func(rec *domain.Record) error {
+		ids = append(ids, rec.ID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// ClearTempFile removes the temp records.
+func (s *Storage) ClearTempFile(ctx context.Context) error {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	err := s.client.Del(ctx, s.tempKey()).Err()
+	if err != nil {
+		s.logger.Error("failed to clear temp records", zap.Error(err))
+		return fmt.Errorf("failed to clear temp records: %w", err)
+	}
+
+	return nil
+}
+
+// LoadLastLinksNum returns the highest ID ever saved, including the IDs of
+// expired records.
+func (s *Storage) LoadLastLinksNum(ctx context.Context) int64 {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	last, err := s.client.Get(ctx, s.lastIDKey()).Int64()
+	if errors.Is(err, goredis.Nil) {
+		return 0
+	}
+	if err != nil {
+		s.logger.Error("failed to load last links num", zap.Error(err))
+		return 0
+	}
+
+	return last
+}
+
+// Len returns the size of the ID set, which still counts expired records
+// that were not read since they expired.
+func (s *Storage) Len() int64 {
+	ctx, cancel := s.context(context.Background())
+	defer cancel()
+
+	n, err := s.client.ZCard(ctx, s.idsKey()).Result()
+	if err != nil {
+		s.logger.Warn("failed to count records", zap.Error(err))
+		return 0
+	}
+
+	return n
+}
+
+// SaveRecordEvent stores the event. A missing OccurredAt is set from the
+// storage clock.
+func (s *Storage) SaveRecordEvent(ctx context.Context, event *domain.RecordEvent) error {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = s.clock.Now().UTC()
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	err = s.client.RPush(ctx, s.eventsKey(event.RecordID), data).Err()
+	if err != nil {
+		s.logger.Error("failed to save record event", zap.Int64("id", event.RecordID), zap.Error(err))
+		return fmt.Errorf("failed to save record event: %w", err)
+	}
+
+	return nil
+}
+
+// GetRecordEvents returns the events of the record in the order they were
+// stored.
+func (s *Storage) GetRecordEvents(ctx context.Context, id int64) ([]domain.RecordEvent, error) {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	values, err := s.client.LRange(ctx, s.eventsKey(id), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get record events: %w", err)
+	}
+
+	events := make([]domain.RecordEvent, 0, len(values))
+	for _, value := range values {
+		var event domain.RecordEvent
+		err = json.Unmarshal([]byte(value), &event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// putRecord queues the writes of the record and its event. The events of the
+// record expire together with it.
+func (s *Storage) putRecord(ctx context.Context, pipe goredis.Pipeliner, rec *domain.Record, eventType string) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record %d: %w", rec.ID, err)
+	}
+
+	event, err := json.Marshal(domain.RecordEvent{
+		RecordID:   rec.ID,
+		EventType:  eventType,
+		OccurredAt: s.clock.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	ttl := s.ttl(rec)
+	eventsKey := s.eventsKey(rec.ID)
+
+	pipe.Set(ctx, s.recordKey(rec.ID), data, ttl)
+	pipe.ZAdd(ctx, s.idsKey(), goredis.Z{Score: float64(rec.ID), Member: rec.ID})
+	setMax.Eval(ctx, pipe, []string{s.lastIDKey()}, rec.ID)
+	pipe.RPush(ctx, eventsKey, event)
+	if ttl > 0 {
+		pipe.Expire(ctx, eventsKey, ttl)
+	} else {
+		pipe.Persist(ctx, eventsKey)
+	}
+
+	return nil
+}
+
+// getRecords reads the records with the given IDs and drops the IDs of
+// expired records from the ID set.
+func (s *Storage) getRecords(ctx context.Context, ids []int64) ([]*domain.Record, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, s.recordKey(id))
+	}
+
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*domain.Record, 0, len(ids))
+	var expired []any
+
+	for i, value := range values {
+		rec, err := decodeRecord(value)
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			expired = append(expired, ids[i])
+			continue
+		}
+		if err != nil {
+			s.logger.Warn("skipping corrupted record", zap.Int64("id", ids[i]), zap.Error(err))
+			continue
+		}
+
+		records = append(records, rec)
+	}
+
+	if len(expired) > 0 {
+		err = s.client.ZRem(ctx, s.idsKey(), expired...).Err()
+		if err != nil {
+			s.logger.Warn("failed to drop expired record ids", zap.Error(err))
+		}
+	}
+
+	return records, nil
+}
+
+// decodeRecord decodes a value returned by MGET or GET. A nil value means the
+// key does not exist.
+func decodeRecord(value any) (*domain.Record, error) {
+	var data string
+	switch v := value.(type) {
+	case nil:
+		return nil, repository.ErrRecordNotFound
+	case string:
+		data = v
+	default:
+		return nil, fmt.Errorf("unexpected value type %T", value)
+	}
+
+	var rec domain.Record
+	err := json.Unmarshal([]byte(data), &rec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode record: %w", err)
+	}
+
+	return &rec, nil
+}
+
+func parseIDs(members []string) ([]int64, error) {
+	ids := make([]int64, 0, len(members))
+	for _, member := range members {
+		id, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid record id %q: %w", member, err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
@@ -1,12 +1,106 @@
 package repository
 
-import "link-service/internal/domain"
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
 
+	"link-service/internal/domain"
+)
+
+var (
+	ErrRecordNotFound  = errors.New("record not found")
+	ErrTempFileFull    = errors.New("temp file is full")
+	ErrReadOnlyStorage = errors.New("storage is read-only")
+	ErrInvalidPage     = errors.New("invalid page")
+	ErrInvalidBackup   = errors.New("invalid backup")
+)
+
+// EventLog keeps the lifecycle events of records.
+type EventLog interface {
+	SaveRecordEvent(ctx context.Context, event *domain.RecordEvent) error
+	// GetRecordEvents returns the events of the record in the order they
+	// occurred.
+	GetRecordEvents(ctx context.Context, id int64) ([]domain.RecordEvent, error)
+}
+
+// Repository stores the records. Every method but Len takes a context that
+// cancels the storage calls or bounds them with a deadline; how far a call is
+// interrupted depends on the storage.
 type Repository interface {
-	SaveRecord(record *domain.Record) error
-	SaveTempRecord(record *domain.Record) error
-	LoadTempRecords() ([]domain.Record, error)
-	GetRecord(id int64) (*domain.Record, error)
-	ClearTempFile() error
-	LoadLastLinksNum() int64
+	EventLog
+
+	SaveRecord(ctx context.Context, record *domain.Record) error
+	SaveRecords(ctx context.Context, records []*domain.Record) error
+	SaveTempRecord(ctx context.Context, record *domain.Record) error
+	UpsertRecords(ctx context.Context, records []*domain.Record) (inserted int, updated int, err error)
+	// UpdateRecord replaces the stored record with the same ID. A zero
+	// CreatedAt keeps the stored creation time and UpdatedAt is set by the
+	// storage. It fails with ErrRecordNotFound if the record is not stored.
+	UpdateRecord(ctx context.Context, record *domain.Record) error
+	// DeleteRecord removes the record, keeping its events. It fails with
+	// ErrRecordNotFound if the record is not stored.
+	DeleteRecord(ctx context.Context, id int64) error
+	LoadTempRecords(ctx context.Context) ([]domain.Record, error)
+	GetRecord(ctx context.Context, id int64) (*domain.Record, error)
+	// GetRecords returns the stored records with the given IDs keyed by ID
+	// in a single pass over the storage. Missing IDs are absent from the
+	// map. The records may be modified and written back with UpsertRecords.
+	GetRecords(ctx context.Context, ids []int64) (map[int64]*domain.Record, error)
+	GetAllRecords(ctx context.Context, opts ...FilterOption) ([]domain.Record, error)
+	GetRecordsBySource(ctx context.Context, source string) ([]domain.Record, error)
+	// GetRecordsSince returns the records created or updated at or after
+	// since, ordered by UpdatedAt.
+	GetRecordsSince(ctx context.Context, since time.Time) ([]domain.Record, error)
+	FindRecordsByTag(ctx context.Context, tag string) ([]domain.Record, error)
+	// SearchRecords returns the records matching the query, see
+	// SearchQuery.Match, ordered by ID.
+	SearchRecords(ctx context.Context, query SearchQuery) ([]domain.Record, error)
+	ForEachRecord(ctx context.Context, fn func(rec *domain.Record) error) error
+	ListRecordIDs(ctx context.Context) ([]int64, error)
+	// ListRecords returns a page of the records ordered by ID, OrderAsc if
+	// order is empty. A zero limit returns all records after offset. It
+	// fails with ErrInvalidPage, see ValidatePage.
+	ListRecords(ctx context.Context, offset, limit int, order string) ([]domain.Record, error)
+	ClearTempFile(ctx context.Context) error
+	LoadLastLinksNum(ctx context.Context) int64
+	// Len returns the number of records in O(1). The count may be
+	// approximate; 0 means it is unknown.
+	Len() int64
+}
+
+// SortByUpdatedAt orders the records by UpdatedAt, keeping the storage order
+// of records updated at the same time.
+func SortByUpdatedAt(records []domain.Record) {
+	slices.SortStableFunc(records, func(a, b domain.Record) int {
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	})
+}
+
+// Wrapper is a Repository decorating another one, e.g. a cache in front of
+// a storage.
+type Wrapper interface {
+	Unwrap() Repository
+}
+
+// As returns the first repository of the chain of repo and the repositories
+// it wraps that is a T, so optional capabilities of a storage, e.g. Compact,
+// are found behind its decorators.
+func As[T any](repo Repository) (T, bool) {
+	for repo != nil {
+		if t, ok := repo.(T); ok {
+			return t, true
+		}
+
+		wrapper, ok := repo.(Wrapper)
+		if !ok {
+			break
+		}
+
+		repo = wrapper.Unwrap()
+	}
+
+	var zero T
+	return zero, false
 }
@@ -0,0 +1,457 @@
+// Package repositorytest checks that a repository.Repository implementation
+// keeps the contract the service relies on, so a new storage backend can be
+// verified against the same tests as the built-in ones.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+// concurrentWriters is the number of goroutines of the concurrency tests.
+const concurrentWriters = 8
+
+// RunSuite runs the contract tests against the repositories returned by
+// newRepository, which is called once per test and must return an empty
+// repository. It may skip the test, e.g. when the backend is not reachable,
+// and register the cleanup of the repository with t.Cleanup.
+func RunSuite(t *testing.T, newRepository func(t *testing.T) repository.Repository) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo repository.Repository)
+	}{
+		{name: "SaveAndGetRecord", fn: testSaveAndGetRecord},
+		{name: "SaveRecords", fn: testSaveRecords},
+		{name: "UpsertRecords", fn: testUpsertRecords},
+		{name: "UpdateRecord", fn: testUpdateRecord},
+		{name: "DeleteRecord", fn: testDeleteRecord},
+		{name: "GetRecords", fn: testGetRecords},
+		{name: "ListRecordIDs", fn: testListRecordIDs},
+		{name: "ListRecords", fn: testListRecords},
+		{name: "Queries", fn: testQueries},
+		{name: "SearchRecords", fn: testSearchRecords},
+		{name: "ForEachRecord", fn: testForEachRecord},
+		{name: "TempRecords", fn: testTempRecords},
+		{name: "Events", fn: testEvents},
+		{name: "ConcurrentWrites", fn: testConcurrentWrites},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepository(t))
+		})
+	}
+}
+
+// record returns a record with a link per URL, all available.
+func record(id int64, urls ...string) *domain.Record {
+	rec := &domain.Record{ID: id, Links: make(map[string]string, len(urls))}
+	for _, url := range urls {
+		rec.Links[url] = "available"
+	}
+
+	return rec
+}
+
+func ids(records []domain.Record) []int64 {
+	ids := make([]int64, 0, len(records))
+	for _, rec := range records {
+		ids = append(ids, rec.ID)
+	}
+
+	return ids
+}
+
+func testSaveAndGetRecord(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	assert.Zero(t, repo.LoadLastLinksNum(ctx))
+
+	_, err := repo.GetRecord(ctx, 1)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	rec := record(2, "a.com", "b.com")
+	rec.Source = domain.SourceAPI
+	rec.Tags = []string{"prod"}
+	require.NoError(t, repo.SaveRecord(ctx, rec))
+
+	got, err := repo.GetRecord(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), got.ID)
+	assert.Equal(t, rec.Links, got.Links)
+	assert.Equal(t, domain.SourceAPI, got.Source)
+	assert.Equal(t, []string{"prod"}, got.Tags)
+	assert.False(t, got.CreatedAt.IsZero(), "CreatedAt is set on save")
+	assert.False(t, got.UpdatedAt.IsZero(), "UpdatedAt is set on save")
+
+	require.NoError(t, repo.SaveRecord(ctx, record(1, "c.com")))
+	assert.Equal(t, int64(2), repo.LoadLastLinksNum(ctx))
+
+	if n := repo.Len(); n != 0 {
+		assert.Equal(t, int64(2), n)
+	}
+}
+
+func testSaveRecords(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	require.NoError(t, repo.SaveRecords(ctx, nil))
+	require.NoError(t, repo.SaveRecords(ctx, []*domain.Record{record(1, "a.com"), record(2, "b.com"), record(3, "c.com")}))
+
+	for id := int64(1); id <= 3; id++ {
+		_, err := repo.GetRecord(ctx, id)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, int64(3), repo.LoadLastLinksNum(ctx))
+}
+
+func testUpsertRecords(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	require.NoError(t, repo.SaveRecord(ctx, record(1, "a.com")))
+
+	updated := record(1, "b.com")
+	inserted, changed, err := repo.UpsertRecords(ctx, []*domain.Record{updated, record(2, "c.com")})
+	require.NoError(t, err)
+	assert.Equal(t, 1, inserted)
+	assert.Equal(t, 1, changed)
+
+	got, err := repo.GetRecord(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, updated.Links, got.Links)
+
+	_, err = repo.GetRecord(ctx, 2)
+	assert.NoError(t, err)
+}
+
+func testUpdateRecord(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	err := repo.UpdateRecord(ctx, record(1, "a.com"))
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	require.NoError(t, repo.SaveRecord(ctx, record(1, "a.com")))
+
+	stored, err := repo.GetRecord(ctx, 1)
+	require.NoError(t, err)
+
+	update := record(1, "a.com")
+	update.Links["a.com"] = "not available"
+	require.NoError(t, repo.UpdateRecord(ctx, update))
+
+	got, err := repo.GetRecord(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "not available", got.Links["a.com"])
+	assert.True(t, stored.CreatedAt.Equal(got.CreatedAt), "a zero CreatedAt keeps the stored one")
+	assert.False(t, got.UpdatedAt.Before(stored.UpdatedAt), "UpdatedAt does not go back")
+
+	recordIDs, err := repo.ListRecordIDs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, recordIDs, "an update does not add a record")
+}
+
+func testDeleteRecord(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	err := repo.DeleteRecord(ctx, 1)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	require.NoError(t, repo.SaveRecords(ctx, []*domain.Record{record(1, "a.com"), record(2, "b.com")}))
+	require.NoError(t, repo.DeleteRecord(ctx, 1))
+
+	_, err = repo.GetRecord(ctx, 1)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	err = repo.DeleteRecord(ctx, 1)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	err = repo.UpdateRecord(ctx, record(1, "a.com"))
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	recordIDs, err := repo.ListRecordIDs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2}, recordIDs)
+
+	all, err := repo.GetAllRecords(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2}, ids(all))
+}
+
+func testGetRecords(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	require.NoError(t, repo.SaveRecords(ctx, []*domain.Record{record(1, "a.com"), record(2, "b.com"), record(3, "c.com")}))
+
+	records, err := repo.GetRecords(ctx, []int64{1, 3, 4})
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Contains(t, records, int64(1))
+	assert.Contains(t, records, int64(3))
+	assert.NotContains(t, records, int64(4))
+
+	records, err = repo.GetRecords(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func testListRecordIDs(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	recordIDs, err := repo.ListRecordIDs(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, recordIDs)
+
+	for _, id := range []int64{3, 1, 2} {
+		require.NoError(t, repo.SaveRecord(ctx, record(id, "a.com")))
+	}
+
+	recordIDs, err = repo.ListRecordIDs(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{1, 2, 3}, recordIDs)
+}
+
+func testListRecords(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	for _, id := range []int64{4, 1, 3, 2, 5} {
+		require.NoError(t, repo.SaveRecord(ctx, record(id, "a.com")))
+	}
+
+	page, err := repo.ListRecords(ctx, 0, 0, "")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, ids(page))
+
+	page, err = repo.ListRecords(ctx, 1, 2, repository.OrderAsc)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2, 3}, ids(page))
+
+	page, err = repo.ListRecords(ctx, 0, 2, repository.OrderDesc)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{5, 4}, ids(page))
+
+	page, err = repo.ListRecords(ctx, 10, 2, "")
+	require.NoError(t, err)
+	assert.Empty(t, page)
+
+	_, err = repo.ListRecords(ctx, -1, 0, "")
+	assert.ErrorIs(t, err, repository.ErrInvalidPage)
+
+	_, err = repo.ListRecords(ctx, 0, 0, "sideways")
+	assert.ErrorIs(t, err, repository.ErrInvalidPage)
+}
+
+func testQueries(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	before := time.Now().Add(-time.Hour)
+
+	api := record(1, "a.com")
+	api.Source = domain.SourceAPI
+	api.Tags = []string{"prod"}
+
+	imported := record(2, "a.com", "b.com", "c.com")
+	imported.Source = domain.SourceImport
+	imported.Tags = []string{"staging"}
+
+	require.NoError(t, repo.SaveRecords(ctx, []*domain.Record{api, imported}))
+
+	records, err := repo.GetRecordsBySource(ctx, domain.SourceImport)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2}, ids(records))
+
+	records, err = repo.FindRecordsByTag(ctx, "prod")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, ids(records))
+
+	records, err = repo.GetAllRecords(ctx, repository.WithMinLinks(2))
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2}, ids(records))
+
+	records, err = repo.GetAllRecords(ctx, repository.WithTag("staging"), repository.WithSource(domain.SourceAPI))
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	// The storages may run on a fake clock, so only a bound far in the past
+	// or the future is certain.
+	records, err = repo.GetRecordsSince(ctx, before.AddDate(-100, 0, 0))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{1, 2}, ids(records))
+
+	records, err = repo.GetRecordsSince(ctx, before.AddDate(100, 0, 0))
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func testSearchRecords(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := jan.AddDate(0, 1, 0)
+	mar := jan.AddDate(0, 2, 0)
+
+	first := record(1, "https://a.com/x")
+	first.Links["b.com"] = "not available"
+	first.CreatedAt = jan
+
+	second := &domain.Record{ID: 2, Links: map[string]string{"a.com/y": "not available"}, CreatedAt: feb}
+
+	third := record(3, "c.com")
+	third.CreatedAt = mar
+
+	require.NoError(t, repo.SaveRecords(ctx, []*domain.Record{third, first, second}))
+
+	tests := []struct {
+		name  string
+		query repository.SearchQuery
+		want  []int64
+	}{
+		{name: "all", query: repository.SearchQuery{}, want: []int64{1, 2, 3}},
+		{name: "url", query: repository.SearchQuery{URL: "a.com"}, want: []int64{1, 2}},
+		{name: "status", query: repository.SearchQuery{Status: "not available"}, want: []int64{1, 2}},
+		{name: "url and status of one link", query: repository.SearchQuery{URL: "a.com", Status: "not available"}, want: []int64{2}},
+		{name: "from", query: repository.SearchQuery{From: feb}, want: []int64{2, 3}},
+		{name: "to", query: repository.SearchQuery{To: feb}, want: []int64{1}},
+		{name: "range", query: repository.SearchQuery{From: jan.AddDate(0, 0, 1), To: mar}, want: []int64{2}},
+		{name: "no match", query: repository.SearchQuery{URL: "d.com"}, want: []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := repo.SearchRecords(ctx, tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ids(records))
+		})
+	}
+}
+
+func testForEachRecord(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	require.NoError(t, repo.SaveRecords(ctx, []*domain.Record{record(1, "a.com"), record(2, "b.com"), record(3, "c.com")}))
+
+	var visited []int64
+	err := repo.ForEachRecord(ctx, func(rec *domain.Record) error {
+		visited = append(visited, rec.ID)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{1, 2, 3}, visited)
+
+	errStop := errors.New("stop")
+	calls := 0
+	err = repo.ForEachRecord(ctx, func(rec *domain.Record) error {
+		calls++
+		return errStop
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, 1, calls, "iteration stops at the first error")
+}
+
+func testTempRecords(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	records, err := repo.LoadTempRecords(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	require.NoError(t, repo.SaveTempRecord(ctx, record(1, "a.com")))
+	require.NoError(t, repo.SaveTempRecord(ctx, record(2, "b.com")))
+
+	records, err = repo.LoadTempRecords(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{1, 2}, ids(records))
+
+	_, err = repo.GetRecord(ctx, 1)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound, "temp records are not stored records")
+
+	require.NoError(t, repo.ClearTempFile(ctx))
+
+	records, err = repo.LoadTempRecords(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func testEvents(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	// The storages may log events of their own for the stored records, so
+	// the events belong to a record that is never saved.
+	const id = 42
+
+	events, err := repo.GetRecordEvents(ctx, id)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	at := time.Date(2025, 11, 30, 12, 0, 0, 0, time.UTC)
+	for i, eventType := range []string{domain.EventTypeCreated, domain.EventTypeChecked, domain.EventTypeDeleted} {
+		err = repo.SaveRecordEvent(ctx, &domain.RecordEvent{
+			RecordID:   id,
+			EventType:  eventType,
+			OccurredAt: at.Add(time.Duration(i) * time.Minute),
+		})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, repo.SaveRecordEvent(ctx, &domain.RecordEvent{RecordID: id + 1, EventType: domain.EventTypeCreated, OccurredAt: at}))
+
+	events, err = repo.GetRecordEvents(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	for i, eventType := range []string{domain.EventTypeCreated, domain.EventTypeChecked, domain.EventTypeDeleted} {
+		assert.Equal(t, int64(id), events[i].RecordID)
+		assert.Equal(t, eventType, events[i].EventType)
+		assert.True(t, events[i].OccurredAt.Equal(at.Add(time.Duration(i)*time.Minute)))
+	}
+}
+
+func testConcurrentWrites(t *testing.T, repo repository.Repository) {
+	ctx := context.Background()
+
+	const perWriter = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, concurrentWriters)
+	for w := range concurrentWriters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range perWriter {
+				id := int64(w*perWriter + i + 1)
+				err := repo.SaveRecord(ctx, record(id, fmt.Sprintf("%d.com", id)))
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				_, err = repo.GetRecord(ctx, id)
+				if err != nil {
+					errs <- fmt.Errorf("failed to read record %d back: %w", id, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	recordIDs, err := repo.ListRecordIDs(ctx)
+	require.NoError(t, err)
+	assert.Len(t, recordIDs, concurrentWriters*perWriter)
+	assert.Equal(t, int64(concurrentWriters*perWriter), repo.LoadLastLinksNum(ctx))
+}
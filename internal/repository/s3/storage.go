@@ -0,0 +1,856 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+// StorageType selects this backend with STORAGE_TYPE.
+const StorageType = "s3"
+
+func init() {
+	repository.Register(StorageType, repository.FactoryFor(func(cfg *Config, logger *zap.Logger) (*Storage, error) {
+		return New(cfg, clock.Real{}, logger)
+	}))
+}
+
+type Config struct {
+	// Endpoint is the host and optional port of the S3 API, without scheme,
+	// e.g. "s3.amazonaws.com" or "minio:9000".
+	Endpoint        string `env:"S3_ENDPOINT"`
+	Bucket          string `env:"S3_BUCKET"`
+	AccessKeyID     string `env:"S3_ACCESS_KEY_ID"`
+	SecretAccessKey string `env:"S3_SECRET_ACCESS_KEY"`
+	Region          string `env:"S3_REGION" env-default:"us-east-1"`
+	UseSSL          bool   `env:"S3_USE_SSL" env-default:"true"`
+	KeyPrefix       string `env:"S3_KEY_PREFIX"`
+	// RequestTimeout bounds each request to the bucket, not a whole
+	// operation made of several requests.
+	RequestTimeout time.Duration `env:"S3_REQUEST_TIMEOUT" env-default:"10s"`
+	// WriteConcurrency is the number of objects written at a time by a
+	// batch.
+	WriteConcurrency int `env:"S3_WRITE_CONCURRENCY" env-default:"8"`
+}
+
+const (
+	defaultRequestTimeout   = 10 * time.Second
+	defaultWriteConcurrency = 8
+)
+
+// Validate reports every missing or invalid field of the config.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Endpoint == "" {
+		errs = append(errs, errors.New("S3_ENDPOINT is required"))
+	} else if strings.Contains(c.Endpoint, "://") {
+		errs = append(errs, errors.New("S3_ENDPOINT must not contain a scheme, use S3_USE_SSL instead"))
+	}
+
+	if c.Bucket == "" {
+		errs = append(errs, errors.New("S3_BUCKET is required"))
+	}
+
+	if (c.AccessKeyID == "") != (c.SecretAccessKey == "") {
+		errs = append(errs, errors.New("S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY must be set together"))
+	}
+
+	if c.RequestTimeout < 0 {
+		errs = append(errs, errors.New("S3_REQUEST_TIMEOUT must not be negative"))
+	}
+
+	if c.WriteConcurrency < 0 {
+		errs = append(errs, errors.New("S3_WRITE_CONCURRENCY must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// manifest is the object listing the stored record IDs, so that iterating
+// and counting records does not list the bucket. LastID is the highest ID
+// ever stored, which outlives the deletion of the record.
+type manifest struct {
+	IDs    []int64 `json:"ids"`
+	LastID int64   `json:"last_id,omitempty"`
+}
+
+// Storage keeps one JSON object per record and a manifest of the stored IDs
+// in an S3-compatible bucket. Records are written before the manifest, so a
+// failed write leaves at most an unreferenced object behind. Writes are
+// serialized in the process; only one instance may write to a key prefix.
+type Storage struct {
+	client           *minio.Client
+	bucket           string
+	prefix           string
+	requestTimeout   time.Duration
+	writeConcurrency int
+
+	// mu guards ids and lastID and serializes the read-modify-write of the
+	// manifest, the temp records and the events.
+	mu     sync.RWMutex
+	ids    map[int64]struct{}
+	lastID int64
+
+	clock  clock.Clock
+	logger *zap.Logger
+}
+
+func New(cfg *Config, clk clock.Clock, logger *zap.Logger) (*Storage, error) {
+	requestTimeout := cfg.RequestTimeout
+	if requestTimeout == 0 {
+		requestTimeout = defaultRequestTimeout
+	}
+
+	writeConcurrency := cfg.WriteConcurrency
+	if writeConcurrency == 0 {
+		writeConcurrency = defaultWriteConcurrency
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		logger.Error("failed to create s3 client", zap.String("endpoint", cfg.Endpoint), zap.Error(err))
+		return nil, fmt.Errorf("failed to create s3 client: %s: %w", cfg.Endpoint, err)
+	}
+
+	s := &Storage{
+		client:           client,
+		bucket:           cfg.Bucket,
+		prefix:           cfg.KeyPrefix,
+		requestTimeout:   requestTimeout,
+		writeConcurrency: writeConcurrency,
+		ids:              make(map[int64]struct{}),
+		clock:            clk,
+		logger:           logger,
+	}
+
+	ctx, cancel := s.context(context.Background())
+	defer cancel()
+
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err == nil && !exists {
+		err = errors.New("bucket does not exist")
+	}
+	if err != nil {
+		logger.Error("failed to open bucket", zap.String("bucket", cfg.Bucket), zap.Error(err))
+		return nil, fmt.Errorf("failed to open bucket: %s: %w", cfg.Bucket, err)
+	}
+
+	var m manifest
+	err = s.getObject(ctx, s.manifestKey(), &m)
+	if err != nil && !errors.Is(err, repository.ErrRecordNotFound) {
+		logger.Error("failed to load manifest", zap.String("bucket", cfg.Bucket), zap.Error(err))
+		return nil, fmt.Errorf("failed to load manifest: %s: %w", cfg.Bucket, err)
+	}
+
+	for _, id := range m.IDs {
+		s.ids[id] = struct{}{}
+	}
+	s.lastID = m.LastID
+
+	logger.Info("s3 bucket opened", zap.String("bucket", cfg.Bucket), zap.Int("records", len(s.ids)))
+
+	return s, nil
+}
+
+func (s *Storage) context(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, s.requestTimeout)
+}
+
+func (s *Storage) recordKey(id int64) string {
+	return fmt.Sprintf("%srecords/%020d.json", s.prefix, id)
+}
+
+func (s *Storage) eventsKey(id int64) string {
+	return fmt.Sprintf("%sevents/%020d.json", s.prefix, id)
+}
+
+func (s *Storage) manifestKey() string {
+	return s.prefix + "manifest.json"
+}
+
+func (s *Storage) tempKey() string {
+	return s.prefix + "temp_records.json"
+}
+
+// SaveRecord stores the record under its ID. Missing timestamps are set from
+// the storage clock.
+func (s *Storage) SaveRecord(ctx context.Context, record *domain.Record) error {
+	return s.saveRecords(ctx, []*domain.Record{record}, "save record")
+}
+
+// SaveRecords stores the records one object each and then updates the
+// manifest once.
+func (s *Storage) SaveRecords(ctx context.Context, records []*domain.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	return s.saveRecords(ctx, records, "save records")
+}
+
+func (s *Storage) saveRecords(ctx context.Context, records []*domain.Record, op string) error {
+	now := s.clock.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range records {
+		if record.CreatedAt.IsZero() {
+			record.CreatedAt = now
+		}
+
+		if record.UpdatedAt.IsZero() {
+			record.UpdatedAt = record.CreatedAt
+		}
+	}
+
+	err := s.putRecords(ctx, records, func(*domain.Record) string { return domain.EventTypeCreated })
+	if err != nil {
+		s.logger.Error("failed to "+op, zap.Error(err))
+		return fmt.Errorf("failed to %s: %w", op, err)
+	}
+
+	s.logger.Info("successfully wrote records", zap.Int("count", len(records)))
+	return nil
+}
+
+// SaveTempRecord appends the record to the temp records object.
+func (s *Storage) SaveTempRecord(ctx context.Context, record *domain.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []domain.Record
+	err := s.getObject(ctx, s.tempKey(), &records)
+	if err != nil && !errors.Is(err, repository.ErrRecordNotFound) {
+		s.logger.Error("failed to load temp records", zap.Error(err))
+		return fmt.Errorf("failed to save temp record: %w", err)
+	}
+
+	err = s.putObject(ctx, s.tempKey(), append(records, *record))
+	if err != nil {
+		s.logger.Error("failed to save temp record", zap.Int64("id", record.ID), zap.Error(err))
+		return fmt.Errorf("failed to save temp record: %w", err)
+	}
+
+	s.logger.Info("successfully wrote temp record")
+	return nil
+}
+
+// UpsertRecords inserts or replaces the batch. If the batch contains the
+// same ID several times, the last one wins.
+func (s *Storage) UpsertRecords(ctx context.Context, records []*domain.Record) (int, int, error) {
+	batch := make(map[int64]*domain.Record, len(records))
+	order := make([]int64, 0, len(records))
+	for _, rec := range records {
+		if _, ok := batch[rec.ID]; !ok {
+			order = append(order, rec.ID)
+		}
+		batch[rec.ID] = rec
+	}
+
+	now := s.clock.Now().UTC()
+	upserts := make([]*domain.Record, 0, len(order))
+	for _, id := range order {
+		upserts = append(upserts, batch[id])
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The stored records are read concurrently; each goroutine only touches
+	// its own record and eventTypes is written under eventMu.
+	var eventMu sync.Mutex
+	eventTypes := make(map[int64]string, len(order))
+
+	err := s.forEach(ctx, order, func(ctx context.Context, id int64) error {
+		rec := batch[id]
+
+		var stored domain.Record
+		err := s.getObject(ctx, s.recordKey(id), &stored)
+		eventType := domain.EventTypeUpdated
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			if rec.CreatedAt.IsZero() {
+				rec.CreatedAt = now
+			}
+			if rec.UpdatedAt.IsZero() {
+				rec.UpdatedAt = rec.CreatedAt
+			}
+			eventType = domain.EventTypeCreated
+
+		case err != nil:
+			return fmt.Errorf("record with ID %d: %w", id, err)
+
+		default:
+			if rec.CreatedAt.IsZero() {
+				rec.CreatedAt = stored.CreatedAt
+			}
+			rec.UpdatedAt = now
+		}
+
+		eventMu.Lock()
+		eventTypes[id] = eventType
+		eventMu.Unlock()
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("failed to upsert records", zap.Error(err))
+		return 0, 0, fmt.Errorf("failed to upsert records: %w", err)
+	}
+
+	inserted, updated := 0, 0
+	for _, eventType := range eventTypes {
+		if eventType == domain.EventTypeCreated {
+			inserted++
+		} else {
+			updated++
+		}
+	}
+
+	err = s.putRecords(ctx, upserts, func(rec *domain.Record) string { return eventTypes[rec.ID] })
+	if err != nil {
+		s.logger.Error("failed to upsert records", zap.Error(err))
+		return 0, 0, fmt.Errorf("failed to upsert records: %w", err)
+	}
+
+	s.logger.Info("successfully upserted records", zap.Int("inserted", inserted), zap.Int("updated", updated))
+	return inserted, updated, nil
+}
+
+// UpdateRecord replaces the record object and appends the updated event. A
+// zero CreatedAt keeps the stored creation time. It fails with
+// repository.ErrRecordNotFound if the ID is not in the manifest.
+func (s *Storage) UpdateRecord(ctx context.Context, record *domain.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.ids[record.ID]; !ok {
+		return fmt.Errorf("record with ID %d: %w", record.ID, repository.ErrRecordNotFound)
+	}
+
+	var stored domain.Record
+	err := s.getObject(ctx, s.recordKey(record.ID), &stored)
+	if err != nil {
+		s.logger.Error("failed to update record", zap.Int64("id", record.ID), zap.Error(err))
+		return fmt.Errorf("failed to update record: record with ID %d: %w", record.ID, err)
+	}
+
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = stored.CreatedAt
+	}
+	record.UpdatedAt = s.clock.Now().UTC()
+
+	err = s.putRecords(ctx, []*domain.Record{record}, func(*domain.Record) string { return domain.EventTypeUpdated })
+	if err != nil {
+		s.logger.Error("failed to update record", zap.Int64("id", record.ID), zap.Error(err))
+		return fmt.Errorf("failed to update record: %w", err)
+	}
+
+	s.logger.Info("successfully updated record", zap.Int64("id", record.ID))
+	return nil
+}
+
+// DeleteRecord drops the ID from the manifest, then removes the record
+// object and appends the deleted event. A failure after the manifest write
+// leaves an unreferenced object behind like a failed save. It fails with
+// repository.ErrRecordNotFound if the ID is not in the manifest.
+func (s *Storage) DeleteRecord(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.ids[id]; !ok {
+		return fmt.Errorf("record with ID %d: %w", id, repository.ErrRecordNotFound)
+	}
+
+	s.lastID = s.lastLinksNum()
+	delete(s.ids, id)
+
+	err := s.putManifest(ctx)
+	if err != nil {
+		s.ids[id] = struct{}{}
+		s.logger.Error("failed to delete record", zap.Int64("id", id), zap.Error(err))
+		return fmt.Errorf("failed to delete record: %w", err)
+	}
+
+	err = s.removeObject(ctx, s.recordKey(id))
+	if err != nil {
+		s.logger.Warn("failed to remove deleted record", zap.Int64("id", id), zap.Error(err))
+	}
+
+	err = s.appendEvent(ctx, domain.RecordEvent{
+		RecordID:   id,
+		EventType:  domain.EventTypeDeleted,
+		OccurredAt: s.clock.Now().UTC(),
+	})
+	if err != nil {
+		s.logger.Warn("failed to save record event", zap.Int64("id", id), zap.Error(err))
+	}
+
+	s.logger.Info("successfully deleted record", zap.Int64("id", id))
+	return nil
+}
+
+// LoadTempRecords returns the temp records in insertion order, keeping one
+// record per ID: the most recently updated one and, on ties, the last one.
+func (s *Storage) LoadTempRecords(ctx context.Context) ([]domain.Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var stored []domain.Record
+	err := s.getObject(ctx, s.tempKey(), &stored)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load temp records: %w", err)
+	}
+
+	var records []domain.Record
+	positions := make(map[int64]int)
+
+	for _, rec := range stored {
+		pos, ok := positions[rec.ID]
+		if !ok {
+			positions[rec.ID] = len(records)
+			records = append(records, rec)
+			continue
+		}
+
+		if !rec.UpdatedAt.Before(records[pos].UpdatedAt) {
+			records[pos] = rec
+		}
+	}
+
+	return records, nil
+}
+
+func (s *Storage) GetRecord(ctx context.Context, id int64) (*domain.Record, error) {
+	var rec domain.Record
+	err := s.getObject(ctx, s.recordKey(id), &rec)
+	if err != nil {
+		return nil, fmt.Errorf("record with ID %d: %w", id, err)
+	}
+
+	return &rec, nil
+}
+
+// GetRecords reads the records with the given IDs. IDs missing from the
+// manifest are skipped without a request.
+func (s *Storage) GetRecords(ctx context.Context, ids []int64) (map[int64]*domain.Record, error) {
+	records := make(map[int64]*domain.Record, len(ids))
+
+	for _, id := range ids {
+		if !s.has(id) {
+			continue
+		}
+
+		rec, err := s.GetRecord(ctx, id)
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get records: %w", err)
+		}
+
+		records[id] = rec
+	}
+
+	return records, nil
+}
+
+// GetAllRecords returns all records matching the filter options ordered by ID.
+func (s *Storage) GetAllRecords(ctx context.Context, opts ...repository.FilterOption) ([]domain.Record, error) {
+	filter := repository.NewFilter(opts...)
+	records := make([]domain.Record, 0)
+
+	err := s.ForEachRecord(ctx, func(rec *domain.Record) error {
+		if filter.Match(rec) {
+			records = append(records, *rec)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func (s *Storage) GetRecordsBySource(ctx context.Context, source string) ([]domain.Record, error) {
+	return s.GetAllRecords(ctx, repository.WithSource(source))
+}
+
+// GetRecordsSince returns the records created or updated at or after since,
+// ordered by UpdatedAt.
+func (s *Storage) GetRecordsSince(ctx context.Context, since time.Time) ([]domain.Record, error) {
+	records := make([]domain.Record, 0)
+
+	err := s.ForEachRecord(ctx, func(rec *domain.Record) error {
+		if rec.ModifiedSince(since) {
+			records = append(records, *rec)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	repository.SortByUpdatedAt(records)
+	return records, nil
+}
+
+func (s *Storage) FindRecordsByTag(ctx context.Context, tag string) ([]domain.Record, error) {
+	return s.GetAllRecords(ctx, repository.WithTag(tag))
+}
+
+// SearchRecords returns the records matching the query ordered by ID.
+func (s *Storage) SearchRecords(ctx context.Context, query repository.SearchQuery) ([]domain.Record, error) {
+	records := make([]domain.Record, 0)
+
+	err := s.ForEachRecord(ctx, func(rec *domain.Record) error {
+		if query.Match(rec) {
+			records = append(records, *rec)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// ForEachRecord calls fn for every record in the manifest ordered by ID,
+// reading one object per record.
+func (s *Storage) ForEachRecord(ctx context.Context, fn func(rec *domain.Record) error) error {
+	ids, err := s.ListRecordIDs(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		rec, err := s.GetRecord(ctx, id)
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			s.logger.Warn("skipping record missing from bucket", zap.Int64("id", id))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		err = fn(rec)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListRecords picks a page of the IDs in the manifest and reads their record
+// objects.
+func (s *Storage) ListRecords(ctx context.Context, offset, limit int, order string) ([]domain.Record, error) {
+	err := repository.ValidatePage(offset, limit, order)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := s.ListRecordIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	page := repository.PageIDs(ids, offset, limit, order)
+	records := make([]domain.Record, 0, len(page))
+	for _, id := range page {
+		rec, err := s.GetRecord(ctx, id)
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list records: %w", err)
+		}
+
+		records = append(records, *rec)
+	}
+
+	return records, nil
+}
+
+func (s *Storage) ListRecordIDs(ctx context.Context) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Sorted(maps.Keys(s.ids)), nil
+}
+
+// ClearTempFile removes the temp records object.
+func (s *Storage) ClearTempFile(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.removeObject(ctx, s.tempKey())
+	if err != nil {
+		s.logger.Error("failed to clear temp records", zap.Error(err))
+		return fmt.Errorf("failed to clear temp records: %w", err)
+	}
+
+	return nil
+}
+
+// LoadLastLinksNum returns the highest ID in the manifest, including the IDs
+// of deleted records.
+func (s *Storage) LoadLastLinksNum(ctx context.Context) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.lastLinksNum()
+}
+
+// lastLinksNum implements LoadLastLinksNum. s.mu must be held.
+func (s *Storage) lastLinksNum() int64 {
+	last := s.lastID
+	for id := range s.ids {
+		last = max(last, id)
+	}
+
+	return last
+}
+
+// Len returns the number of IDs in the manifest.
+func (s *Storage) Len() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return int64(len(s.ids))
+}
+
+// SaveRecordEvent appends the event to the events object of the record. A
+// missing OccurredAt is set from the storage clock.
+func (s *Storage) SaveRecordEvent(ctx context.Context, event *domain.RecordEvent) error {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = s.clock.Now().UTC()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.appendEvent(ctx, *event)
+	if err != nil {
+		s.logger.Error("failed to save record event", zap.Int64("id", event.RecordID), zap.Error(err))
+		return fmt.Errorf("failed to save record event: %w", err)
+	}
+
+	return nil
+}
+
+// GetRecordEvents returns the events of the record in the order they were
+// stored.
+func (s *Storage) GetRecordEvents(ctx context.Context, id int64) ([]domain.RecordEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]domain.RecordEvent, 0)
+	err := s.getObject(ctx, s.eventsKey(id), &events)
+	if err != nil && !errors.Is(err, repository.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get record events: %w", err)
+	}
+
+	return events, nil
+}
+
+func (s *Storage) has(id int64) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.ids[id]
+	return ok
+}
+
+// putRecords writes the records with their events and then the manifest if
+// new IDs were added. The objects of different IDs are written concurrently,
+// and the events object of an ID is written once with all the events of the
+// batch for it. An ID above every ID stored so far has no events object yet,
+// so its events are written without reading it first; events saved with
+// SaveRecordEvent for an ID that was never stored are replaced. The IDs are
+// only added to the manifest if every write succeeded. s.mu must be held.
+func (s *Storage) putRecords(ctx context.Context, records []*domain.Record, eventType func(*domain.Record) string) error {
+	now := s.clock.Now().UTC()
+	latest := make(map[int64]*domain.Record, len(records))
+	events := make(map[int64][]domain.RecordEvent, len(records))
+	order := make([]int64, 0, len(records))
+
+	for _, rec := range records {
+		if _, ok := latest[rec.ID]; !ok {
+			order = append(order, rec.ID)
+		}
+		latest[rec.ID] = rec
+		events[rec.ID] = append(events[rec.ID], domain.RecordEvent{
+			RecordID:   rec.ID,
+			EventType:  eventType(rec),
+			OccurredAt: now,
+		})
+	}
+
+	known := s.lastLinksNum()
+
+	err := s.forEach(ctx, order, func(ctx context.Context, id int64) error {
+		err := s.putObject(ctx, s.recordKey(id), latest[id])
+		if err != nil {
+			return fmt.Errorf("record with ID %d: %w", id, err)
+		}
+
+		if id > known {
+			err = s.putObject(ctx, s.eventsKey(id), events[id])
+		} else {
+			err = s.appendEvents(ctx, id, events[id]...)
+		}
+		if err != nil {
+			return fmt.Errorf("record with ID %d: %w", id, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	added := false
+	for _, id := range order {
+		if _, ok := s.ids[id]; !ok {
+			s.ids[id] = struct{}{}
+			added = true
+		}
+	}
+
+	if !added {
+		return nil
+	}
+
+	return s.putManifest(ctx)
+}
+
+// forEach calls fn for every ID, at most writeConcurrency at a time, and
+// returns the first error. The context passed to fn is canceled once a call
+// fails.
+func (s *Storage) forEach(ctx context.Context, ids []int64, fn func(ctx context.Context, id int64) error) error {
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(s.writeConcurrency)
+
+	for _, id := range ids {
+		g.Go(func() error {
+			return fn(ctx, id)
+		})
+	}
+
+	return g.Wait()
+}
+
+// putManifest writes the manifest from the in-memory IDs. s.mu must be held.
+func (s *Storage) putManifest(ctx context.Context) error {
+	err := s.putObject(ctx, s.manifestKey(), manifest{IDs: slices.Sorted(maps.Keys(s.ids)), LastID: s.lastID})
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	return nil
+}
+
+// appendEvent rewrites the events object of the record with the event
+// appended. s.mu must be held.
+func (s *Storage) appendEvent(ctx context.Context, event domain.RecordEvent) error {
+	return s.appendEvents(ctx, event.RecordID, event)
+}
+
+// appendEvents rewrites the events object of the record with the events
+// appended. s.mu must be held.
+func (s *Storage) appendEvents(ctx context.Context, id int64, events ...domain.RecordEvent) error {
+	var stored []domain.RecordEvent
+	err := s.getObject(ctx, s.eventsKey(id), &stored)
+	if err != nil && !errors.Is(err, repository.ErrRecordNotFound) {
+		return err
+	}
+
+	return s.putObject(ctx, s.eventsKey(id), append(stored, events...))
+}
+
+// putObject, getObject and removeObject each bound their request by
+// requestTimeout.
+func (s *Storage) putObject(ctx context.Context, key string, v any) error {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", key, err)
+	}
+
+	_, err = s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// getObject decodes the object into v. A missing object is
+// repository.ErrRecordNotFound.
+func (s *Storage) getObject(ctx context.Context, key string, v any) error {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get %s: %w", key, err)
+	}
+	defer obj.Close()
+
+	err = json.NewDecoder(obj).Decode(v)
+	if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+		return repository.ErrRecordNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) removeObject(ctx context.Context, key string) error {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to remove %s: %w", key, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,508 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+	"link-service/internal/repository/repositorytest"
+)
+
+var testNow = time.Date(2025, 11, 30, 12, 0, 0, 0, time.UTC)
+
+const testBucket = "links"
+
+// fakeS3 serves the object requests the storage makes against a single
+// path-style bucket kept in memory. Every request is delayed by delay and
+// the GETs are counted per key.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	gets    map[string]int
+
+	delay       time.Duration
+	inFlight    atomic.Int32
+	maxInFlight atomic.Int32
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: make(map[string][]byte), gets: make(map[string]int)}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != testBucket {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	n := f.inFlight.Add(1)
+	defer f.inFlight.Add(-1)
+	for {
+		highest := f.maxInFlight.Load()
+		if n <= highest || f.maxInFlight.CompareAndSwap(highest, n) {
+			break
+		}
+	}
+	time.Sleep(f.delay)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case key == "":
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == http.MethodPut:
+		data, err := readBody(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		f.objects[key] = data
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == http.MethodGet:
+		f.gets[key]++
+		data, ok := f.objects[key]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`)
+			return
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Last-Modified", testNow.Format(http.TimeFormat))
+		w.Header().Set("ETag", `"etag"`)
+		w.Write(data)
+
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// readBody reads the request body, decoding the aws-chunked encoding used by
+// streaming signatures over plain HTTP.
+func readBody(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var data bytes.Buffer
+	reader := bufio.NewReader(r.Body)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		if size == 0 {
+			return data.Bytes(), nil
+		}
+
+		_, err = io.CopyN(&data, reader, size)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = reader.Discard(2)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func newTestStorage(t *testing.T) (*Storage, *fakeS3, *clock.Fake) {
+	t.Helper()
+
+	fake := newFakeS3()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	clk := clock.NewFake(testNow)
+	storage, err := New(testConfig(server), clk, zap.NewNop())
+	assert.NoError(t, err)
+
+	return storage, fake, clk
+}
+
+func TestConformance(t *testing.T) {
+	repositorytest.RunSuite(t, func(t *testing.T) repository.Repository {
+		storage, _, _ := newTestStorage(t)
+		return storage
+	})
+}
+
+func testConfig(server *httptest.Server) *Config {
+	return &Config{
+		Endpoint:        strings.TrimPrefix(server.URL, "http://"),
+		Bucket:          testBucket,
+		AccessKeyID:     "access",
+		SecretAccessKey: "secret",
+		Region:          "us-east-1",
+		KeyPrefix:       "links/",
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Config{Endpoint: "https://s3.amazonaws.com", AccessKeyID: "access", WriteConcurrency: -1}
+
+	err := cfg.Validate()
+	assert.ErrorContains(t, err, "S3_ENDPOINT must not contain a scheme")
+	assert.ErrorContains(t, err, "S3_BUCKET")
+	assert.ErrorContains(t, err, "S3_SECRET_ACCESS_KEY")
+	assert.ErrorContains(t, err, "S3_WRITE_CONCURRENCY")
+
+	cfg = Config{Endpoint: "s3.amazonaws.com", Bucket: testBucket}
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestNewMissingBucket(t *testing.T) {
+	server := httptest.NewServer(newFakeS3())
+	defer server.Close()
+
+	cfg := testConfig(server)
+	cfg.Bucket = "missing"
+
+	_, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.ErrorContains(t, err, "failed to open bucket")
+}
+
+func TestSaveAndGetRecord(t *testing.T) {
+	storage, fake, _ := newTestStorage(t)
+
+	err := storage.SaveRecord(context.Background(), &domain.Record{ID: 2, Links: map[string]string{"a.com": "available"}, Tags: []string{"prod"}})
+	assert.NoError(t, err)
+
+	assert.Contains(t, fake.objects, "links/records/00000000000000000002.json")
+	assert.JSONEq(t, `{"ids":[2]}`, string(fake.objects["links/manifest.json"]))
+
+	rec, err := storage.GetRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a.com": "available"}, rec.Links)
+	assert.Equal(t, []string{"prod"}, rec.Tags)
+	assert.Equal(t, testNow, rec.CreatedAt)
+	assert.Equal(t, testNow, rec.UpdatedAt)
+
+	_, err = storage.GetRecord(context.Background(), 3)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+}
+
+func TestNewLoadsManifest(t *testing.T) {
+	fake := newFakeS3()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	storage, err := New(testConfig(server), clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	for _, id := range []int64{3, 256, 10} {
+		err = storage.SaveRecord(context.Background(), &domain.Record{ID: id})
+		assert.NoError(t, err)
+	}
+
+	// A new instance, e.g. after a container restart, sees the same records.
+	storage, err = New(testConfig(server), clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	assert.Equal(t, int64(256), storage.LoadLastLinksNum(context.Background()))
+	assert.Equal(t, int64(3), storage.Len())
+
+	ids, err := storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3, 10, 256}, ids)
+}
+
+func TestUpsertRecords(t *testing.T) {
+	storage, _, clk := newTestStorage(t)
+
+	err := storage.SaveRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "available"}})
+	assert.NoError(t, err)
+
+	clk.Advance(time.Hour)
+
+	inserted, updated, err := storage.UpsertRecords(context.Background(), []*domain.Record{
+		{ID: 1, Links: map[string]string{"a.com": "not available"}},
+		{ID: 2, Links: map[string]string{"b.com": "available"}},
+		{ID: 2, Links: map[string]string{"c.com": "available"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, inserted)
+	assert.Equal(t, 1, updated)
+	assert.Equal(t, int64(2), storage.Len())
+
+	rec, err := storage.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "not available", rec.Links["a.com"])
+	assert.Equal(t, testNow, rec.CreatedAt)
+	assert.Equal(t, testNow.Add(time.Hour), rec.UpdatedAt)
+
+	rec, err = storage.GetRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"c.com": "available"}, rec.Links)
+
+	records, err := storage.GetRecordsSince(context.Background(), testNow.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+}
+
+func TestTempRecords(t *testing.T) {
+	storage, fake, _ := newTestStorage(t)
+
+	for _, rec := range []*domain.Record{
+		{ID: 1, Links: map[string]string{"a.com": "available"}, UpdatedAt: testNow},
+		{ID: 2, Links: map[string]string{"b.com": "available"}},
+		{ID: 1, Links: map[string]string{"a.com": "not available"}, UpdatedAt: testNow.Add(time.Minute)},
+	} {
+		err := storage.SaveTempRecord(context.Background(), rec)
+		assert.NoError(t, err)
+	}
+
+	records, err := storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, int64(1), records[0].ID)
+	assert.Equal(t, "not available", records[0].Links["a.com"])
+
+	err = storage.ClearTempFile(context.Background())
+	assert.NoError(t, err)
+	assert.NotContains(t, fake.objects, "links/temp_records.json")
+
+	records, err = storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestRecordEvents(t *testing.T) {
+	storage, _, clk := newTestStorage(t)
+
+	err := storage.SaveRecord(context.Background(), &domain.Record{ID: 1})
+	assert.NoError(t, err)
+
+	clk.Advance(time.Minute)
+
+	_, _, err = storage.UpsertRecords(context.Background(), []*domain.Record{{ID: 1}})
+	assert.NoError(t, err)
+
+	events, err := storage.GetRecordEvents(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.RecordEvent{
+		{RecordID: 1, EventType: domain.EventTypeCreated, OccurredAt: testNow},
+		{RecordID: 1, EventType: domain.EventTypeUpdated, OccurredAt: testNow.Add(time.Minute)},
+	}, events)
+
+	events, err = storage.GetRecordEvents(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestSaveRecordsBatch(t *testing.T) {
+	storage, fake, _ := newTestStorage(t)
+	ctx := context.Background()
+
+	assert.NoError(t, storage.SaveRecord(ctx, &domain.Record{ID: 1}))
+
+	fake.mu.Lock()
+	clear(fake.gets)
+	fake.mu.Unlock()
+	fake.delay = 10 * time.Millisecond
+	fake.maxInFlight.Store(0)
+
+	records := []*domain.Record{{ID: 1}}
+	for id := int64(2); id <= 20; id++ {
+		records = append(records, &domain.Record{ID: id})
+	}
+	records = append(records, &domain.Record{ID: 2})
+	assert.NoError(t, storage.SaveRecords(ctx, records))
+	assert.Equal(t, int64(20), storage.Len())
+
+	// The PUTs run concurrently up to the default limit.
+	assert.Greater(t, fake.maxInFlight.Load(), int32(1))
+	assert.LessOrEqual(t, fake.maxInFlight.Load(), int32(defaultWriteConcurrency))
+
+	// Only the events of the already stored ID are read, once.
+	assert.Equal(t, map[string]int{storage.eventsKey(1): 1}, fake.gets)
+
+	for id, count := range map[int64]int{1: 2, 2: 2, 20: 1} {
+		events, err := storage.GetRecordEvents(ctx, id)
+		assert.NoError(t, err)
+		assert.Len(t, events, count)
+	}
+}
+
+func TestRequestTimeoutPerRequest(t *testing.T) {
+	fake := newFakeS3()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	cfg := testConfig(server)
+	cfg.RequestTimeout = 100 * time.Millisecond
+	cfg.WriteConcurrency = 1
+	storage, err := New(cfg, clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	// Each request fits the timeout, the whole batch does not.
+	fake.delay = 20 * time.Millisecond
+	records := make([]*domain.Record, 0, 10)
+	for id := int64(1); id <= 10; id++ {
+		records = append(records, &domain.Record{ID: id})
+	}
+	assert.NoError(t, storage.SaveRecords(context.Background(), records))
+	assert.Equal(t, int64(10), storage.Len())
+}
+
+func TestQueries(t *testing.T) {
+	storage, _, _ := newTestStorage(t)
+
+	err := storage.SaveRecords(context.Background(), []*domain.Record{
+		{ID: 3, Links: map[string]string{}, Source: domain.SourceAPI, Tags: []string{"prod", "eu"}},
+		{ID: 1, Links: map[string]string{"a.com": "available"}, Source: domain.SourceImport},
+		{ID: 2, Links: map[string]string{}, Source: domain.SourceAPI, Tags: []string{"eu"}},
+	})
+	assert.NoError(t, err)
+
+	records, err := storage.GetRecordsBySource(context.Background(), domain.SourceAPI)
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	records, err = storage.FindRecordsByTag(context.Background(), "prod")
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, int64(3), records[0].ID)
+
+	records, err = storage.GetAllRecords(context.Background(), repository.WithMinLinks(1))
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	found, err := storage.GetRecords(context.Background(), []int64{1, 4})
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+	assert.Contains(t, found, int64(1))
+}
+
+func TestListRecords(t *testing.T) {
+	storage, _, _ := newTestStorage(t)
+
+	assert.NoError(t, storage.SaveRecords(context.Background(), []*domain.Record{{ID: 3}, {ID: 1}, {ID: 2}}))
+
+	records, err := storage.ListRecords(context.Background(), 1, 1, "")
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, int64(2), records[0].ID)
+
+	records, err = storage.ListRecords(context.Background(), 0, 0, repository.OrderDesc)
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, int64(3), records[0].ID)
+
+	records, err = storage.ListRecords(context.Background(), 5, 1, "")
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	_, err = storage.ListRecords(context.Background(), 0, 0, "newest")
+	assert.ErrorIs(t, err, repository.ErrInvalidPage)
+}
+
+func TestUpdateRecord(t *testing.T) {
+	storage, _, clk := newTestStorage(t)
+
+	assert.NoError(t, storage.SaveRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "available"}}))
+
+	clk.Advance(time.Minute)
+
+	err := storage.UpdateRecord(context.Background(), &domain.Record{ID: 1, Links: map[string]string{"a.com": "not available"}})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, storage.UpdateRecord(context.Background(), &domain.Record{ID: 2}), repository.ErrRecordNotFound)
+	assert.Equal(t, int64(1), storage.Len())
+
+	rec, err := storage.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "not available", rec.Links["a.com"])
+	assert.True(t, rec.CreatedAt.Equal(testNow))
+	assert.True(t, rec.UpdatedAt.Equal(testNow.Add(time.Minute)))
+
+	events, err := storage.GetRecordEvents(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.EventTypeUpdated, events[len(events)-1].EventType)
+}
+
+func TestDeleteRecord(t *testing.T) {
+	fake := newFakeS3()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	storage, err := New(testConfig(server), clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+
+	assert.NoError(t, storage.SaveRecords(context.Background(), []*domain.Record{{ID: 1}, {ID: 2}}))
+
+	assert.NoError(t, storage.DeleteRecord(context.Background(), 2))
+	assert.ErrorIs(t, storage.DeleteRecord(context.Background(), 2), repository.ErrRecordNotFound)
+	assert.Equal(t, int64(1), storage.Len())
+
+	_, err = storage.GetRecord(context.Background(), 2)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+
+	events, err := storage.GetRecordEvents(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.EventTypeDeleted, events[len(events)-1].EventType)
+
+	// The manifest keeps the ID of the deleted record taken.
+	storage, err = New(testConfig(server), clock.NewFake(testNow), zap.NewNop())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), storage.LoadLastLinksNum(context.Background()))
+	assert.Equal(t, int64(1), storage.Len())
+}
+
+func TestReadsStopOnCancel(t *testing.T) {
+	storage, _, _ := newTestStorage(t)
+	ctx := context.Background()
+
+	for id := int64(1); id <= 3; id++ {
+		assert.NoError(t, storage.SaveRecord(ctx, &domain.Record{ID: id, Links: map[string]string{"a.com": "available"}}))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	calls := 0
+	err := storage.ForEachRecord(ctx, func(rec *domain.Record) error {
+		calls++
+		cancel()
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls)
+
+	_, err = storage.GetRecords(ctx, []int64{1, 2, 3})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = storage.ListRecords(ctx, 0, 10, repository.OrderAsc)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"link-service/internal/domain"
+)
+
+// SearchQuery selects the records returned by SearchRecords. Zero values
+// disable a condition.
+type SearchQuery struct {
+	// URL is a substring of a link of the record.
+	URL string
+	// Status is the status of a link of the record. Together with URL both
+	// must hold for the same link.
+	Status string
+	// From and To bound CreatedAt to [From, To). Records without CreatedAt
+	// do not match a bound.
+	From time.Time
+	To   time.Time
+}
+
+// Match reports whether the record passes the query.
+func (q SearchQuery) Match(rec *domain.Record) bool {
+	if !q.From.IsZero() && (rec.CreatedAt.IsZero() || rec.CreatedAt.Before(q.From)) {
+		return false
+	}
+
+	if !q.To.IsZero() && (rec.CreatedAt.IsZero() || !rec.CreatedAt.Before(q.To)) {
+		return false
+	}
+
+	if q.URL == "" && q.Status == "" {
+		return true
+	}
+
+	for link, status := range rec.Links {
+		if strings.Contains(link, q.URL) && (q.Status == "" || status == q.Status) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"time"
+
+	"link-service/internal/domain"
+)
+
+// Sharded is a Repository that partitions the records across shards by
+// ID % len(shards), so writes of different shards do not wait for each
+// other. A record, its temp record and its events live in the same shard.
+// Reads across all records merge the shards: lists are ordered by ID and
+// GetRecordsSince by UpdatedAt. The shard count must not change once records
+// are stored; cmd/migrate copies them to a storage with another count.
+type Sharded struct {
+	shards []Repository
+}
+
+func NewSharded(shards []Repository) *Sharded {
+	return &Sharded{shards: shards}
+}
+
+// shard returns the shard of the record ID.
+func (s *Sharded) shard(id int64) Repository {
+	return s.shards[s.ShardOf(id)]
+}
+
+// Close closes every shard that can be closed.
+func (s *Sharded) Close() error {
+	var errs []error
+	for _, shard := range s.shards {
+		if closer, ok := shard.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *Sharded) SaveRecord(ctx context.Context, record *domain.Record) error {
+	return s.shard(record.ID).SaveRecord(ctx, record)
+}
+
+// SaveRecords saves the records of every shard with a call per shard. It is
+// not atomic across shards: on failure the shards before the failing one
+// keep their records.
+func (s *Sharded) SaveRecords(ctx context.Context, records []*domain.Record) error {
+	for i, batch := range s.split(records) {
+		if len(batch) == 0 {
+			continue
+		}
+
+		err := s.shards[i].SaveRecords(ctx, batch)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Sharded) SaveTempRecord(ctx context.Context, record *domain.Record) error {
+	return s.shard(record.ID).SaveTempRecord(ctx, record)
+}
+
+// UpsertRecords upserts the records of every shard with a call per shard and
+// sums the counts. Like SaveRecords it is not atomic across shards.
+func (s *Sharded) UpsertRecords(ctx context.Context, records []*domain.Record) (int, int, error) {
+	inserted, updated := 0, 0
+	for i, batch := range s.split(records) {
+		if len(batch) == 0 {
+			continue
+		}
+
+		ins, upd, err := s.shards[i].UpsertRecords(ctx, batch)
+		inserted += ins
+		updated += upd
+		if err != nil {
+			return inserted, updated, err
+		}
+	}
+
+	return inserted, updated, nil
+}
+
+func (s *Sharded) UpdateRecord(ctx context.Context, record *domain.Record) error {
+	return s.shard(record.ID).UpdateRecord(ctx, record)
+}
+
+func (s *Sharded) DeleteRecord(ctx context.Context, id int64) error {
+	return s.shard(id).DeleteRecord(ctx, id)
+}
+
+func (s *Sharded) LoadTempRecords(ctx context.Context) ([]domain.Record, error) {
+	var records []domain.Record
+	for _, shard := range s.shards {
+		temp, err := shard.LoadTempRecords(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, temp...)
+	}
+
+	return records, nil
+}
+
+func (s *Sharded) GetRecord(ctx context.Context, id int64) (*domain.Record, error) {
+	return s.shard(id).GetRecord(ctx, id)
+}
+
+func (s *Sharded) GetRecords(ctx context.Context, ids []int64) (map[int64]*domain.Record, error) {
+	byShard := make([][]int64, len(s.shards))
+	for _, id := range ids {
+		i := s.ShardOf(id)
+		byShard[i] = append(byShard[i], id)
+	}
+
+	records := make(map[int64]*domain.Record, len(ids))
+	for i, shardIDs := range byShard {
+		if len(shardIDs) == 0 {
+			continue
+		}
+
+		found, err := s.shards[i].GetRecords(ctx, shardIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		for id, rec := range found {
+			records[id] = rec
+		}
+	}
+
+	return records, nil
+}
+
+func (s *Sharded) GetAllRecords(ctx context.Context, opts ...FilterOption) ([]domain.Record, error) {
+	return s.merge(func(shard Repository) ([]domain.Record, error) {
+		return shard.GetAllRecords(ctx, opts...)
+	})
+}
+
+func (s *Sharded) GetRecordsBySource(ctx context.Context, source string) ([]domain.Record, error) {
+	return s.merge(func(shard Repository) ([]domain.Record, error) {
+		return shard.GetRecordsBySource(ctx, source)
+	})
+}
+
+func (s *Sharded) GetRecordsSince(ctx context.Context, since time.Time) ([]domain.Record, error) {
+	records, err := s.merge(func(shard Repository) ([]domain.Record, error) {
+		return shard.GetRecordsSince(ctx, since)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	SortByUpdatedAt(records)
+	return records, nil
+}
+
+func (s *Sharded) FindRecordsByTag(ctx context.Context, tag string) ([]domain.Record, error) {
+	return s.merge(func(shard Repository) ([]domain.Record, error) {
+		return shard.FindRecordsByTag(ctx, tag)
+	})
+}
+
+func (s *Sharded) SearchRecords(ctx context.Context, query SearchQuery) ([]domain.Record, error) {
+	return s.merge(func(shard Repository) ([]domain.Record, error) {
+		return shard.SearchRecords(ctx, query)
+	})
+}
+
+// ForEachRecord visits the records shard by shard.
+func (s *Sharded) ForEachRecord(ctx context.Context, fn func(rec *domain.Record) error) error {
+	for _, shard := range s.shards {
+		err := shard.ForEachRecord(ctx, fn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Sharded) ListRecordIDs(ctx context.Context) ([]int64, error) {
+	var ids []int64
+	for _, shard := range s.shards {
+		shardIDs, err := shard.ListRecordIDs(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, shardIDs...)
+	}
+
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// ListRecords pages the IDs of all shards and reads the records of the page.
+func (s *Sharded) ListRecords(ctx context.Context, offset, limit int, order string) ([]domain.Record, error) {
+	err := ValidatePage(offset, limit, order)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := s.ListRecordIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids = PageIDs(ids, offset, limit, order)
+
+	found, err := s.GetRecords(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]domain.Record, 0, len(ids))
+	for _, id := range ids {
+		if rec, ok := found[id]; ok {
+			records = append(records, *rec)
+		}
+	}
+
+	return records, nil
+}
+
+func (s *Sharded) ClearTempFile(ctx context.Context) error {
+	for _, shard := range s.shards {
+		err := shard.ClearTempFile(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Sharded) LoadLastLinksNum(ctx context.Context) int64 {
+	var last int64
+	for _, shard := range s.shards {
+		last = max(last, shard.LoadLastLinksNum(ctx))
+	}
+
+	return last
+}
+
+// Len sums the counts of the shards.
+func (s *Sharded) Len() int64 {
+	var n int64
+	for _, shard := range s.shards {
+		n += shard.Len()
+	}
+
+	return n
+}
+
+func (s *Sharded) SaveRecordEvent(ctx context.Context, event *domain.RecordEvent) error {
+	return s.shard(event.RecordID).SaveRecordEvent(ctx, event)
+}
+
+func (s *Sharded) GetRecordEvents(ctx context.Context, id int64) ([]domain.RecordEvent, error) {
+	return s.shard(id).GetRecordEvents(ctx, id)
+}
+
+// ShardOf returns the index of the shard of the record ID.
+func (s *Sharded) ShardOf(id int64) int {
+	n := int64(len(s.shards))
+	return int((id%n + n) % n)
+}
+
+// split groups the records by the index of their shard.
+func (s *Sharded) split(records []*domain.Record) [][]*domain.Record {
+	byShard := make([][]*domain.Record, len(s.shards))
+	for _, rec := range records {
+		i := s.ShardOf(rec.ID)
+		byShard[i] = append(byShard[i], rec)
+	}
+
+	return byShard
+}
+
+// merge concatenates the records read from every shard ordered by ID.
+func (s *Sharded) merge(read func(shard Repository) ([]domain.Record, error)) ([]domain.Record, error) {
+	var records []domain.Record
+	for _, shard := range s.shards {
+		shardRecords, err := read(shard)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, shardRecords...)
+	}
+
+	slices.SortStableFunc(records, func(a, b domain.Record) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return records, nil
+}
@@ -0,0 +1,68 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+	"link-service/internal/repository/inmemory"
+	"link-service/internal/repository/repositorytest"
+)
+
+func newTestSharded(t *testing.T, n int) (*repository.Sharded, []*inmemory.Storage) {
+	t.Helper()
+
+	shards := make([]repository.Repository, 0, n)
+	storages := make([]*inmemory.Storage, 0, n)
+	for range n {
+		storage := newTestStorage(t, clock.NewFake(testNow))
+		shards = append(shards, storage)
+		storages = append(storages, storage)
+	}
+
+	return repository.NewSharded(shards), storages
+}
+
+func TestShardedConformance(t *testing.T) {
+	repositorytest.RunSuite(t, func(t *testing.T) repository.Repository {
+		sharded, _ := newTestSharded(t, 3)
+		return sharded
+	})
+}
+
+func TestShardedRouting(t *testing.T) {
+	sharded, shards := newTestSharded(t, 3)
+
+	records := make([]*domain.Record, 0, 6)
+	for id := int64(1); id <= 6; id++ {
+		records = append(records, &domain.Record{ID: id, Links: map[string]string{"a.com": "available"}})
+	}
+
+	err := sharded.SaveRecords(context.Background(), records)
+	assert.NoError(t, err)
+
+	err = sharded.SaveRecordEvent(context.Background(), &domain.RecordEvent{RecordID: 4, EventType: domain.EventTypeChecked})
+	assert.NoError(t, err)
+
+	for i, want := range [][]int64{{3, 6}, {1, 4}, {2, 5}} {
+		ids, err := shards[i].ListRecordIDs(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, want, ids)
+	}
+
+	events, err := shards[1].GetRecordEvents(context.Background(), 4)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	assert.Equal(t, int64(6), sharded.Len())
+	assert.Equal(t, int64(6), sharded.LoadLastLinksNum(context.Background()))
+
+	page, err := sharded.ListRecords(context.Background(), 1, 3, repository.OrderDesc)
+	assert.NoError(t, err)
+	assert.Len(t, page, 3)
+	assert.Equal(t, []int64{5, 4, 3}, []int64{page[0].ID, page[1].ID, page[2].ID})
+}
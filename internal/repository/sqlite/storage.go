@@ -0,0 +1,816 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	_ "modernc.org/sqlite"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+// StorageType selects this backend with STORAGE_TYPE.
+const StorageType = "sqlite"
+
+func init() {
+	repository.Register(StorageType, repository.FactoryFor(func(cfg *Config, logger *zap.Logger) (*Storage, error) {
+		return New(cfg, clock.Real{}, logger)
+	}))
+}
+
+type Config struct {
+	Path        string        `env:"SQLITE_PATH"`
+	BusyTimeout time.Duration `env:"SQLITE_BUSY_TIMEOUT" env-default:"5s"`
+}
+
+const defaultBusyTimeout = 5 * time.Second
+
+// Validate reports every missing or invalid field of the config.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Path == "" {
+		errs = append(errs, errors.New("SQLITE_PATH is required"))
+	}
+
+	if c.BusyTimeout < 0 {
+		errs = append(errs, errors.New("SQLITE_BUSY_TIMEOUT must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// migrations are applied in order on startup. The number of applied
+// migrations is kept in PRAGMA user_version, so new migrations are only ever
+// appended.
+var migrations = []string{
+	`CREATE TABLE records (
+		id         INTEGER PRIMARY KEY,
+		links      TEXT NOT NULL,
+		source     TEXT NOT NULL DEFAULT '',
+		tags       TEXT NOT NULL DEFAULT '[]',
+		created_at INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL
+	);
+	CREATE INDEX records_updated_at_idx ON records (updated_at);
+	CREATE INDEX records_source_idx ON records (source);
+
+	CREATE TABLE temp_records (
+		seq  INTEGER PRIMARY KEY AUTOINCREMENT,
+		data TEXT NOT NULL
+	);
+
+	CREATE TABLE record_events (
+		seq         INTEGER PRIMARY KEY AUTOINCREMENT,
+		record_id   INTEGER NOT NULL,
+		event_type  TEXT NOT NULL,
+		occurred_at INTEGER NOT NULL,
+		detail      TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX record_events_record_id_idx ON record_events (record_id, seq);`,
+}
+
+const recordColumns = `id, links, source, tags, created_at, updated_at`
+
+// Storage keeps records in an embedded SQLite database in WAL mode, so reads
+// do not wait for writes. Timestamps are stored as Unix nanoseconds and links
+// and tags as JSON.
+type Storage struct {
+	db *sql.DB
+	// records is the number of rows in the records table, see Len.
+	records atomic.Int64
+	clock   clock.Clock
+	logger  *zap.Logger
+}
+
+func New(cfg *Config, clk clock.Clock, logger *zap.Logger) (*Storage, error) {
+	err := os.MkdirAll(filepath.Dir(cfg.Path), 0755)
+	if err != nil {
+		logger.Error("failed to create dir", zap.String("path", cfg.Path), zap.Error(err))
+		return nil, fmt.Errorf("failed to create dir: %s: %w", filepath.Dir(cfg.Path), err)
+	}
+
+	busyTimeout := cfg.BusyTimeout
+	if busyTimeout == 0 {
+		busyTimeout = defaultBusyTimeout
+	}
+
+	pragmas := url.Values{}
+	pragmas.Add("_pragma", "journal_mode(WAL)")
+	pragmas.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	pragmas.Add("_pragma", "synchronous(NORMAL)")
+	pragmas.Add("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+cfg.Path+"?"+pragmas.Encode())
+	if err != nil {
+		logger.Error("failed to open db", zap.String("path", cfg.Path), zap.Error(err))
+		return nil, fmt.Errorf("failed to open db: %s: %w", cfg.Path, err)
+	}
+
+	err = migrate(db, logger)
+	if err != nil {
+		db.Close()
+		logger.Error("failed to migrate db", zap.String("path", cfg.Path), zap.Error(err))
+		return nil, fmt.Errorf("failed to migrate db: %s: %w", cfg.Path, err)
+	}
+
+	var records int64
+	err = db.QueryRow(`SELECT count(*) FROM records`).Scan(&records)
+	if err != nil {
+		db.Close()
+		logger.Error("failed to count records", zap.String("path", cfg.Path), zap.Error(err))
+		return nil, fmt.Errorf("failed to count records: %s: %w", cfg.Path, err)
+	}
+
+	logger.Info("sqlite db opened", zap.String("path", cfg.Path))
+
+	storage := &Storage{
+		db:     db,
+		clock:  clk,
+		logger: logger,
+	}
+	storage.records.Store(records)
+
+	return storage, nil
+}
+
+// migrate applies the migrations the database has not seen yet, each in its
+// own transaction.
+func migrate(db *sql.DB, logger *zap.Logger) error {
+	var version int
+	err := db.QueryRow(`PRAGMA user_version`).Scan(&version)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		err = withTx(context.Background(), db, func(tx *sql.Tx) error {
+			_, err := tx.Exec(migrations[i])
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, i+1))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %d: %w", i+1, err)
+		}
+
+		logger.Info("applied migration", zap.Int("version", i+1))
+	}
+
+	return nil
+}
+
+// Close closes the database.
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+// SaveRecord stores the record under its ID. Missing timestamps are set from
+// the storage clock.
+func (s *Storage) SaveRecord(ctx context.Context, record *domain.Record) error {
+	return s.saveRecords(ctx, []*domain.Record{record}, "save record")
+}
+
+// SaveRecords stores the records in a single transaction.
+func (s *Storage) SaveRecords(ctx context.Context, records []*domain.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	return s.saveRecords(ctx, records, "save records")
+}
+
+func (s *Storage) saveRecords(ctx context.Context, records []*domain.Record, op string) error {
+	now := s.clock.Now().UTC()
+	var added int64
+
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		for _, record := range records {
+			if record.CreatedAt.IsZero() {
+				record.CreatedAt = now
+			}
+
+			if record.UpdatedAt.IsZero() {
+				record.UpdatedAt = record.CreatedAt
+			}
+
+			inserted, err := putRecord(ctx, tx, record)
+			if err != nil {
+				return err
+			}
+			if inserted {
+				added++
+			}
+
+			err = s.putEvent(ctx, tx, record.ID, domain.EventTypeCreated)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("failed to "+op, zap.Error(err))
+		return fmt.Errorf("failed to %s: %w", op, err)
+	}
+
+	s.records.Add(added)
+
+	s.logger.Info("successfully wrote records", zap.Int("count", len(records)))
+	return nil
+}
+
+func (s *Storage) SaveTempRecord(ctx context.Context, record *domain.Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		s.logger.Error("failed to marshal record", zap.Error(err))
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO temp_records (data) VALUES (?)`, string(data))
+	if err != nil {
+		s.logger.Error("failed to save temp record", zap.Int64("id", record.ID), zap.Error(err))
+		return fmt.Errorf("failed to save temp record: %w", err)
+	}
+
+	s.logger.Info("successfully wrote temp record")
+	return nil
+}
+
+// UpsertRecords inserts or replaces the batch in a single transaction.
+// If the batch contains the same ID several times, the last one wins.
+func (s *Storage) UpsertRecords(ctx context.Context, records []*domain.Record) (int, int, error) {
+	batch := make(map[int64]*domain.Record, len(records))
+	order := make([]int64, 0, len(records))
+	for _, rec := range records {
+		if _, ok := batch[rec.ID]; !ok {
+			order = append(order, rec.ID)
+		}
+		batch[rec.ID] = rec
+	}
+
+	now := s.clock.Now().UTC()
+	inserted, updated := 0, 0
+
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		for _, id := range order {
+			rec := batch[id]
+
+			eventType := domain.EventTypeUpdated
+
+			var storedCreatedAt int64
+			err := tx.QueryRowContext(ctx, `SELECT created_at FROM records WHERE id = ?`, id).Scan(&storedCreatedAt)
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				if rec.CreatedAt.IsZero() {
+					rec.CreatedAt = now
+				}
+				if rec.UpdatedAt.IsZero() {
+					rec.UpdatedAt = rec.CreatedAt
+				}
+				eventType = domain.EventTypeCreated
+				inserted++
+
+			case err != nil:
+				return err
+
+			default:
+				if rec.CreatedAt.IsZero() {
+					rec.CreatedAt = fromNanos(storedCreatedAt)
+				}
+				rec.UpdatedAt = now
+				updated++
+			}
+
+			_, err = putRecord(ctx, tx, rec)
+			if err != nil {
+				return err
+			}
+
+			err = s.putEvent(ctx, tx, id, eventType)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("failed to upsert records", zap.Error(err))
+		return 0, 0, fmt.Errorf("failed to upsert records: %w", err)
+	}
+
+	s.records.Add(int64(inserted))
+
+	s.logger.Info("successfully upserted records", zap.Int("inserted", inserted), zap.Int("updated", updated))
+	return inserted, updated, nil
+}
+
+// UpdateRecord replaces the stored record and stores its updated event in
+// the same transaction. A zero CreatedAt keeps the stored creation time. It
+// fails with repository.ErrRecordNotFound if the record is not stored.
+func (s *Storage) UpdateRecord(ctx context.Context, record *domain.Record) error {
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var storedCreatedAt int64
+		err := tx.QueryRowContext(ctx, `SELECT created_at FROM records WHERE id = ?`, record.ID).Scan(&storedCreatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("record with ID %d: %w", record.ID, repository.ErrRecordNotFound)
+		}
+		if err != nil {
+			return err
+		}
+
+		if record.CreatedAt.IsZero() {
+			record.CreatedAt = fromNanos(storedCreatedAt)
+		}
+		record.UpdatedAt = s.clock.Now().UTC()
+
+		_, err = putRecord(ctx, tx, record)
+		if err != nil {
+			return err
+		}
+
+		return s.putEvent(ctx, tx, record.ID, domain.EventTypeUpdated)
+	})
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return err
+	}
+	if err != nil {
+		s.logger.Error("failed to update record", zap.Int64("id", record.ID), zap.Error(err))
+		return fmt.Errorf("failed to update record: %w", err)
+	}
+
+	s.logger.Info("successfully updated record", zap.Int64("id", record.ID))
+	return nil
+}
+
+// DeleteRecord removes the record and stores its deleted event in the same
+// transaction. It fails with repository.ErrRecordNotFound if the record is
+// not stored.
+func (s *Storage) DeleteRecord(ctx context.Context, id int64) error {
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM records WHERE id = ?`, id)
+		if err != nil {
+			return err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if n == 0 {
+			return fmt.Errorf("record with ID %d: %w", id, repository.ErrRecordNotFound)
+		}
+
+		return s.putEvent(ctx, tx, id, domain.EventTypeDeleted)
+	})
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return err
+	}
+	if err != nil {
+		s.logger.Error("failed to delete record", zap.Int64("id", id), zap.Error(err))
+		return fmt.Errorf("failed to delete record: %w", err)
+	}
+
+	s.records.Add(-1)
+
+	s.logger.Info("successfully deleted record", zap.Int64("id", id))
+	return nil
+}
+
+// LoadTempRecords returns the temp records in insertion order, keeping one
+// record per ID: the most recently updated one and, on ties, the last one.
+func (s *Storage) LoadTempRecords(ctx context.Context) ([]domain.Record, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT data FROM temp_records ORDER BY seq`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load temp records: %w", err)
+	}
+	defer rows.Close()
+
+	var records []domain.Record
+	positions := make(map[int64]int)
+
+	for rows.Next() {
+		var data string
+		err = rows.Scan(&data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load temp records: %w", err)
+		}
+
+		var rec domain.Record
+		err = json.Unmarshal([]byte(data), &rec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode temp record: %w", err)
+		}
+
+		pos, ok := positions[rec.ID]
+		if !ok {
+			positions[rec.ID] = len(records)
+			records = append(records, rec)
+			continue
+		}
+
+		if !rec.UpdatedAt.Before(records[pos].UpdatedAt) {
+			records[pos] = rec
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load temp records: %w", err)
+	}
+
+	return records, nil
+}
+
+func (s *Storage) GetRecord(ctx context.Context, id int64) (*domain.Record, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+recordColumns+` FROM records WHERE id = ?`, id)
+
+	rec, err := scanRecord(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("record with ID %d: %w", id, repository.ErrRecordNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("record with ID %d: %w", id, err)
+	}
+
+	return rec, nil
+}
+
+// GetRecords reads the records with the given IDs in a single read transaction.
+func (s *Storage) GetRecords(ctx context.Context, ids []int64) (map[int64]*domain.Record, error) {
+	records := make(map[int64]*domain.Record, len(ids))
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get records: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		rec, err := scanRecord(tx.QueryRowContext(ctx, `SELECT `+recordColumns+` FROM records WHERE id = ?`, id))
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("record with ID %d: %w", id, err)
+		}
+
+		records[id] = rec
+	}
+
+	return records, nil
+}
+
+// GetAllRecords returns all records matching the filter options ordered by ID.
+func (s *Storage) GetAllRecords(ctx context.Context, opts ...repository.FilterOption) ([]domain.Record, error) {
+	filter := repository.NewFilter(opts...)
+	records := make([]domain.Record, 0)
+
+	err := s.ForEachRecord(ctx, func(rec *domain.Record) error {
+		if filter.Match(rec) {
+			records = append(records, *rec)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func (s *Storage) GetRecordsBySource(ctx context.Context, source string) ([]domain.Record, error) {
+	return s.listRecords(ctx, `SELECT `+recordColumns+` FROM records WHERE source = ? ORDER BY id`, source)
+}
+
+// GetRecordsSince returns the records created or updated at or after since,
+// ordered by UpdatedAt.
+func (s *Storage) GetRecordsSince(ctx context.Context, since time.Time) ([]domain.Record, error) {
+	return s.listRecords(ctx, `SELECT `+recordColumns+` FROM records
+		WHERE created_at >= ?1 OR updated_at >= ?1
+		ORDER BY updated_at, id`, since.UnixNano())
+}
+
+func (s *Storage) FindRecordsByTag(ctx context.Context, tag string) ([]domain.Record, error) {
+	return s.listRecords(ctx, `SELECT `+recordColumns+` FROM records
+		WHERE EXISTS (SELECT 1 FROM json_each(records.tags) WHERE json_each.value = ?)
+		ORDER BY id`, tag)
+}
+
+// SearchRecords returns the records matching the query ordered by ID. The
+// links are matched with json_each, so every record is read.
+func (s *Storage) SearchRecords(ctx context.Context, query repository.SearchQuery) ([]domain.Record, error) {
+	// Zero is no bound.
+	var from, to int64
+	if !query.From.IsZero() {
+		from = query.From.UnixNano()
+	}
+	if !query.To.IsZero() {
+		to = query.To.UnixNano()
+	}
+
+	return s.listRecords(ctx, `SELECT `+recordColumns+` FROM records
+		WHERE (?1 = '' AND ?2 = '' OR EXISTS (
+				SELECT 1 FROM json_each(records.links)
+				WHERE instr(json_each.key, ?1) > 0 AND (?2 = '' OR json_each.value = ?2)))
+			AND (?3 = 0 OR created_at >= ?3)
+			AND (?4 = 0 OR created_at < ?4)
+		ORDER BY id`, query.URL, query.Status, from, to)
+}
+
+// ForEachRecord calls fn for every record ordered by ID while the rows are
+// read. fn must not write to the storage.
+func (s *Storage) ForEachRecord(ctx context.Context, fn func(rec *domain.Record) error) error {
+	return s.queryRecords(ctx, fn, `SELECT `+recordColumns+` FROM records ORDER BY id`)
+}
+
+// ListRecords returns a page of the records ordered by ID with LIMIT and
+// OFFSET.
+func (s *Storage) ListRecords(ctx context.Context, offset, limit int, order string) ([]domain.Record, error) {
+	err := repository.ValidatePage(offset, limit, order)
+	if err != nil {
+		return nil, err
+	}
+
+	direction := "ASC"
+	if order == repository.OrderDesc {
+		direction = "DESC"
+	}
+
+	// A negative limit is no limit in SQLite.
+	if limit == 0 {
+		limit = -1
+	}
+
+	return s.listRecords(ctx, `SELECT `+recordColumns+` FROM records ORDER BY id `+direction+` LIMIT ? OFFSET ?`, limit, offset)
+}
+
+func (s *Storage) ListRecordIDs(ctx context.Context) ([]int64, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM records ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list record ids: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list record ids: %w", err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list record ids: %w", err)
+	}
+
+	return ids, nil
+}
+
+// ClearTempFile removes every temp record.
+func (s *Storage) ClearTempFile(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM temp_records`)
+	if err != nil {
+		s.logger.Error("failed to clear temp records", zap.Error(err))
+		return fmt.Errorf("failed to clear temp records: %w", err)
+	}
+
+	return nil
+}
+
+// LoadLastLinksNum returns the highest stored ID. Deleted records keep their
+// events, which count as well so that their IDs are not handed out again.
+func (s *Storage) LoadLastLinksNum(ctx context.Context) int64 {
+	var last int64
+	err := s.db.QueryRowContext(ctx, `SELECT MAX(
+		(SELECT COALESCE(MAX(id), 0) FROM records),
+		(SELECT COALESCE(MAX(record_id), 0) FROM record_events))`).Scan(&last)
+	if err != nil {
+		s.logger.Error("failed to load last links num", zap.Error(err))
+		return 0
+	}
+
+	return last
+}
+
+// Len returns the number of records without querying the database.
+func (s *Storage) Len() int64 {
+	return s.records.Load()
+}
+
+// SaveRecordEvent stores the event. A missing OccurredAt is set from the
+// storage clock.
+func (s *Storage) SaveRecordEvent(ctx context.Context, event *domain.RecordEvent) error {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = s.clock.Now().UTC()
+	}
+
+	_, err := s.db.ExecContext(ctx, `INSERT INTO record_events (record_id, event_type, occurred_at, detail)
+		VALUES (?, ?, ?, ?)`, event.RecordID, event.EventType, event.OccurredAt.UnixNano(), event.Detail)
+	if err != nil {
+		s.logger.Error("failed to save record event", zap.Int64("id", event.RecordID), zap.Error(err))
+		return fmt.Errorf("failed to save record event: %w", err)
+	}
+
+	return nil
+}
+
+// GetRecordEvents returns the events of the record in the order they were
+// stored.
+func (s *Storage) GetRecordEvents(ctx context.Context, id int64) ([]domain.RecordEvent, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT record_id, event_type, occurred_at, detail
+		FROM record_events WHERE record_id = ? ORDER BY seq`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get record events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]domain.RecordEvent, 0)
+	for rows.Next() {
+		var (
+			event      domain.RecordEvent
+			occurredAt int64
+		)
+		err = rows.Scan(&event.RecordID, &event.EventType, &occurredAt, &event.Detail)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get record events: %w", err)
+		}
+
+		event.OccurredAt = fromNanos(occurredAt)
+		events = append(events, event)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get record events: %w", err)
+	}
+
+	return events, nil
+}
+
+func (s *Storage) putEvent(ctx context.Context, tx *sql.Tx, id int64, eventType string) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO record_events (record_id, event_type, occurred_at) VALUES (?, ?, ?)`,
+		id, eventType, s.clock.Now().UnixNano())
+	return err
+}
+
+// putRecord inserts the record or replaces the stored one and reports
+// whether the row was inserted.
+func putRecord(ctx context.Context, tx *sql.Tx, rec *domain.Record) (bool, error) {
+	links := rec.Links
+	if links == nil {
+		links = map[string]string{}
+	}
+
+	linksData, err := json.Marshal(links)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal links of record %d: %w", rec.ID, err)
+	}
+
+	tags := rec.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	tagsData, err := json.Marshal(tags)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal tags of record %d: %w", rec.ID, err)
+	}
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM records WHERE id = ?)`, rec.ID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to put record %d: %w", rec.ID, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO records (`+recordColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+		rec.ID, string(linksData), rec.Source, string(tagsData), rec.CreatedAt.UnixNano(), rec.UpdatedAt.UnixNano())
+	if err != nil {
+		return false, fmt.Errorf("failed to put record %d: %w", rec.ID, err)
+	}
+
+	return !exists, nil
+}
+
+// listRecords returns the records selected by the query.
+func (s *Storage) listRecords(ctx context.Context, query string, args ...any) ([]domain.Record, error) {
+	records := make([]domain.Record, 0)
+
+	err := s.queryRecords(ctx, func(rec *domain.Record) error {
+		records = append(records, *rec)
+		return nil
+	}, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// queryRecords calls fn for every record selected by the query.
+func (s *Storage) queryRecords(ctx context.Context, fn func(rec *domain.Record) error, query string, args ...any) error {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query records: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rec, err := scanRecord(rows)
+		if err != nil {
+			s.logger.Warn("skipping corrupted record", zap.Error(err))
+			continue
+		}
+
+		err = fn(rec)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("failed to query records: %w", err)
+	}
+
+	return nil
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRecord(row scanner) (*domain.Record, error) {
+	var (
+		rec                  domain.Record
+		links, tags          string
+		createdAt, updatedAt int64
+	)
+
+	err := row.Scan(&rec.ID, &links, &rec.Source, &tags, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal([]byte(links), &rec.Links)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode links of record %d: %w", rec.ID, err)
+	}
+
+	err = json.Unmarshal([]byte(tags), &rec.Tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode tags of record %d: %w", rec.ID, err)
+	}
+
+	if len(rec.Tags) == 0 {
+		rec.Tags = nil
+	}
+
+	rec.CreatedAt = fromNanos(createdAt)
+	rec.UpdatedAt = fromNanos(updatedAt)
+
+	return &rec, nil
+}
+
+// withTx runs fn in a transaction that is committed if fn succeeds.
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func fromNanos(nanos int64) time.Time {
+	return time.Unix(0, nanos).UTC()
+}
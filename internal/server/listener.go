@@ -0,0 +1,112 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+const socketFileMode os.FileMode = 0660
+
+// Listen opens the listener the server is served on: a Unix domain socket
+// when UnixSocketPath is set, TCP on Host:Port otherwise. The socket file is
+// removed when the listener is closed, which http.Server.Shutdown does.
+func Listen(cfg *Config) (net.Listener, error) {
+	if cfg.UnixSocketPath == "" {
+		addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen: %s: %w", addr, err)
+		}
+
+		return ln, nil
+	}
+
+	return listenUnix(cfg.UnixSocketPath, cfg.SocketGroup)
+}
+
+func listenUnix(path, group string) (net.Listener, error) {
+	err := removeStaleSocket(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %s: %w", path, err)
+	}
+
+	err = os.Chmod(path, socketFileMode)
+	if err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to chmod socket: %s: %w", path, err)
+	}
+
+	if group != "" {
+		err = chownGroup(path, group)
+		if err != nil {
+			ln.Close()
+			return nil, err
+		}
+	}
+
+	return ln, nil
+}
+
+// removeStaleSocket removes a socket left behind by a process that did not
+// shut down gracefully. A socket still accepting connections belongs to a
+// running instance and any other kind of file is not a socket, both are left
+// alone.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat socket: %s: %w", path, err)
+	}
+
+	if info.Mode().Type() != fs.ModeSocket {
+		return fmt.Errorf("socket path exists and is not a socket: %s", path)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("socket is in use by another process: %s", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("failed to dial socket: %s: %w", path, err)
+	}
+
+	err = os.Remove(path)
+	if err != nil {
+		return fmt.Errorf("failed to remove stale socket: %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func chownGroup(path, group string) error {
+	grp, err := user.LookupGroup(group)
+	if err != nil {
+		return fmt.Errorf("failed to look up socket group: %s: %w", group, err)
+	}
+
+	gid, err := strconv.Atoi(grp.Gid)
+	if err != nil {
+		return fmt.Errorf("failed to parse gid of group: %s: %w", group, err)
+	}
+
+	err = os.Chown(path, -1, gid)
+	if err != nil {
+		return fmt.Errorf("failed to chown socket: %s: %w", path, err)
+	}
+
+	return nil
+}
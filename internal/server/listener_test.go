@@ -0,0 +1,69 @@
+package server
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "link-service.sock")
+
+	ln, err := Listen(&Config{UnixSocketPath: path})
+	assert.NoError(t, err)
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, fs.ModeSocket, info.Mode().Type())
+	assert.Equal(t, socketFileMode, info.Mode().Perm())
+
+	conn, err := net.Dial("unix", path)
+	assert.NoError(t, err)
+	conn.Close()
+
+	assert.NoError(t, ln.Close())
+
+	_, err = os.Stat(path)
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+}
+
+func TestListenUnixSocketPathTaken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "link-service.sock")
+	assert.NoError(t, os.WriteFile(path, nil, 0644))
+
+	_, err := Listen(&Config{UnixSocketPath: path})
+	assert.ErrorContains(t, err, "not a socket")
+}
+
+func TestListenUnixSocketStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "link-service.sock")
+
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	assert.NoError(t, err)
+	stale.SetUnlinkOnClose(false)
+	assert.NoError(t, stale.Close())
+
+	ln, err := Listen(&Config{UnixSocketPath: path})
+	assert.NoError(t, err)
+	assert.NoError(t, ln.Close())
+}
+
+func TestListenUnixSocketInUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "link-service.sock")
+
+	ln, err := Listen(&Config{UnixSocketPath: path})
+	assert.NoError(t, err)
+	defer ln.Close()
+
+	_, err = Listen(&Config{UnixSocketPath: path})
+	assert.ErrorContains(t, err, "in use")
+
+	conn, err := net.Dial("unix", path)
+	assert.NoError(t, err)
+	conn.Close()
+}
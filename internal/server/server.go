@@ -2,30 +2,147 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 
 	"link-service/internal/handler"
 	"link-service/internal/logger"
+	"link-service/internal/report/pdf"
 	"link-service/internal/repository"
 	"link-service/internal/service"
 )
 
 type Config struct {
-	Host            string        `env:"HTTP_HOST" env-required:"true"`
-	Port            int           `env:"HTTP_PORT" env-required:"true"`
-	Timeout         time.Duration `env:"HTTP_OPERATION_TIMEOUT" env-required:"true"`
-	ShutdownTimeout time.Duration `env:"HTTP_SHUTDOWN_TIMEOUT" env-required:"true"`
+	Host            string        `env:"HTTP_HOST"`
+	Port            int           `env:"HTTP_PORT"`
+	Timeout         time.Duration `env:"HTTP_OPERATION_TIMEOUT"`
+	ShutdownTimeout time.Duration `env:"HTTP_SHUTDOWN_TIMEOUT"`
+	MaxReportBytes  int64         `env:"HTTP_MAX_REPORT_BYTES" env-default:"0"`
+	ReportFontPath  string        `env:"REPORT_FONT_PATH"`
+	// ReportTitle and ReportLogoPath brand the header of the PDF report. An
+	// empty title uses pdf.DefaultTitle, an empty logo path leaves it out.
+	ReportTitle    string `env:"REPORT_TITLE"`
+	ReportLogoPath string `env:"REPORT_LOGO_PATH"`
+	// MaxPDFRecords limits the number of records of a PDF report, 0 means no
+	// limit. The PDF is built in memory before it is sent, even when streamed.
+	MaxPDFRecords int `env:"REPORT_MAX_PDF_RECORDS" env-default:"5000"`
+	// ReportTemplateDir holds report.html and the templates it includes to
+	// brand the HTML report. Empty uses the built-in template.
+	ReportTemplateDir string `env:"REPORT_TEMPLATE_DIR"`
+	// BasePath prefixes every route when the service runs behind a reverse
+	// proxy under a sub path, e.g. "/link-service". The matching nginx
+	// location keeps the prefix when proxying:
+	//
+	//	location /link-service/ {
+	//	    proxy_pass http://127.0.0.1:8080;
+	//	}
+	BasePath string `env:"HTTP_BASE_PATH"`
+	// UnixSocketPath makes the server listen on a Unix domain socket instead
+	// of Host:Port, e.g. when nginx runs on the same host.
+	UnixSocketPath string `env:"HTTP_UNIX_SOCKET_PATH"`
+	// SocketGroup is the group that owns the socket file. Empty keeps the
+	// group of the process.
+	SocketGroup string `env:"HTTP_SOCKET_GROUP"`
+	// WSAllowedOrigins are the browser origins besides the server's own that
+	// may open /ws, e.g. "https://dashboard.example.com". Clients sending no
+	// Origin are always accepted.
+	WSAllowedOrigins []string `env:"HTTP_WS_ALLOWED_ORIGINS" env-separator:","`
+}
+
+// Validate reports every missing or invalid field of the config.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.UnixSocketPath == "" {
+		if c.Host == "" {
+			errs = append(errs, errors.New("HTTP_HOST is required"))
+		}
+
+		if c.Port <= 0 || c.Port > 65535 {
+			errs = append(errs, errors.New("HTTP_PORT is required and must be between 1 and 65535"))
+		}
+	}
+
+	if c.SocketGroup != "" && c.UnixSocketPath == "" {
+		errs = append(errs, errors.New("HTTP_SOCKET_GROUP requires HTTP_UNIX_SOCKET_PATH"))
+	}
+
+	if c.Timeout <= 0 {
+		errs = append(errs, errors.New("HTTP_OPERATION_TIMEOUT is required and must be positive"))
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("HTTP_SHUTDOWN_TIMEOUT is required and must be positive"))
+	}
+
+	if c.MaxReportBytes < 0 {
+		errs = append(errs, errors.New("HTTP_MAX_REPORT_BYTES must not be negative"))
+	}
+
+	if c.MaxPDFRecords < 0 {
+		errs = append(errs, errors.New("REPORT_MAX_PDF_RECORDS must not be negative"))
+	}
+
+	if c.BasePath != "" && !strings.HasPrefix(c.BasePath, "/") {
+		errs = append(errs, fmt.Errorf("HTTP_BASE_PATH must start with /, got %q", c.BasePath))
+	}
+
+	return errors.Join(errs...)
 }
 
 func New(ctx context.Context, srv *service.Service, cfgLogger *logger.Config, cfgServer *Config, log *zap.Logger, repo repository.Repository) http.Server {
 	addr := fmt.Sprintf("%s:%d", cfgServer.Host, cfgServer.Port)
 
+	if cfgServer.ReportFontPath != "" {
+		err := pdf.ValidateFont(cfgServer.ReportFontPath)
+		if err != nil {
+			log.Warn("report font is unusable, reports will use the built-in font",
+				zap.String("font_path", cfgServer.ReportFontPath),
+				zap.Error(err),
+			)
+		}
+	}
+
+	if cfgServer.ReportLogoPath != "" {
+		err := pdf.ValidateLogo(cfgServer.ReportLogoPath)
+		if err != nil {
+			log.Warn("report logo is unusable, reports will have no logo",
+				zap.String("logo_path", cfgServer.ReportLogoPath),
+				zap.Error(err),
+			)
+		}
+	}
+
+	reportOpts := handler.ReportOptions{
+		MaxBytes:      cfgServer.MaxReportBytes,
+		MaxPDFRecords: cfgServer.MaxPDFRecords,
+		PDF: pdf.Options{
+			Title:    cfgServer.ReportTitle,
+			LogoPath: cfgServer.ReportLogoPath,
+			FontPath: cfgServer.ReportFontPath,
+		},
+	}
+
+	if cfgServer.ReportTemplateDir != "" {
+		tmpl, err := handler.LoadReportTemplate(cfgServer.ReportTemplateDir)
+		if err != nil {
+			log.Warn("report template is unusable, html reports will use the built-in template",
+				zap.String("template_dir", cfgServer.ReportTemplateDir),
+				zap.Error(err),
+			)
+		}
+		reportOpts.HTMLTemplate = tmpl
+	}
+
 	router := chi.NewRouter()
 
 	router.Use(middleware.RequestID)
@@ -34,11 +151,59 @@ func New(ctx context.Context, srv *service.Service, cfgLogger *logger.Config, cf
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.URLFormat)
 
+	router.Handle("/metrics", promhttp.Handler())
+	router.Get("/health", handler.Health(srv, log))
+	router.Handle("/ws", handler.RecordUpdates(ctx, srv, cfgServer.WSAllowedOrigins, log))
+
 	router.Post("/links", handler.ProcessLinks(ctx, srv, cfgServer.Timeout, log))
-	router.Get("/links", handler.GetLinks(repo, log))
+	router.Get("/links", handler.GetLinks(repo, reportOpts, log))
+	// Browsers cannot send a body with GET, so HTML forms post the report
+	// request instead.
+	router.Post("/links/report", handler.GetLinks(repo, reportOpts, log))
+	router.Post("/links/async", handler.SubmitLinksAsync(ctx, srv, log))
+	router.Get("/jobs/{id}", handler.GetJob(srv, log))
+	router.Get("/jobs/{id}/events", handler.JobEvents(srv, log))
+	// v2 reports missing records with 404 and 207 Multi-Status instead of
+	// silently leaving them out of the report.
+	multiStatusOpts := reportOpts
+	multiStatusOpts.MultiStatus = true
+	router.Get("/v2/links", handler.GetLinks(repo, multiStatusOpts, log))
+	router.Get("/records", handler.GetAllRecords(repo, log))
+	router.Get("/records/ids", handler.ListRecordIDs(repo, log))
+	router.Get("/records/search", handler.SearchRecords(repo, log))
+	router.Get("/records/export", handler.ExportCSV(repo, log))
+	router.Get("/records/{id}", handler.GetRecord(repo, log))
+	router.Delete("/records/{id}", handler.DeleteRecord(srv, log))
+	router.Patch("/records/{id}", handler.RecheckRecord(srv, cfgServer.Timeout, log))
+	router.Get("/records/{id}/events", handler.GetRecordEvents(repo, log))
+	router.Get("/export/array", handler.ExportJSONArray(repo, log))
+	// The optional capabilities belong to the storage, which may sit
+	// behind a cache.
+	if exporter, ok := repository.As[io.WriterTo](repo); ok {
+		router.Get("/export/json", handler.ExportNDJSON(exporter, log))
+	}
+	router.Post("/import/array", handler.ImportJSONArray(srv, log))
+	if reindexer, ok := repository.As[handler.Reindexer](repo); ok {
+		router.Post("/admin/reindex", handler.Reindex(reindexer, log))
+	}
+	if compactor, ok := repository.As[handler.Compactor](repo); ok {
+		router.Post("/admin/compact", handler.Compact(compactor, log))
+	}
+	if backupRestorer, ok := repository.As[handler.BackupRestorer](repo); ok {
+		purger, _ := repository.As[handler.Purger](repo)
+		router.Post("/admin/backup", handler.Backup(backupRestorer, log))
+		router.Post("/admin/restore", handler.Restore(srv, backupRestorer, purger, log))
+	}
+
+	var h http.Handler = router
+	if basePath := strings.TrimRight(cfgServer.BasePath, "/"); basePath != "" {
+		root := chi.NewRouter()
+		root.Mount(basePath, router)
+		h = root
+	}
 
 	return http.Server{
 		Addr:    addr,
-		Handler: router,
+		Handler: h,
 	}
 }
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/logger"
+	filesystem "link-service/internal/repository/file_system"
+	"link-service/internal/service"
+)
+
+func TestBasePath(t *testing.T) {
+	tests := []struct {
+		name       string
+		basePath   string
+		path       string
+		wantStatus int
+	}{
+		{
+			name:       "no base path",
+			basePath:   "",
+			path:       "/health",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "prefixed",
+			basePath:   "/link-service",
+			path:       "/link-service/health",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "trailing slash",
+			basePath:   "/link-service/",
+			path:       "/link-service/records/ids",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "file extension",
+			basePath:   "/link-service",
+			path:       "/link-service/records/export.csv",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "missing prefix",
+			basePath:   "/link-service",
+			path:       "/health",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := filesystem.NewMockStorage()
+			srv := service.New(repo, &service.Config{PingTimeout: time.Second}, clock.Real{}, zap.NewNop())
+			serv := New(context.Background(), srv, &logger.Config{}, &Config{BasePath: tt.basePath}, zap.NewNop(), repo)
+
+			rec := httptest.NewRecorder()
+			serv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestLinksReportForm(t *testing.T) {
+	repo := filesystem.NewMockStorage()
+	srv := service.New(repo, &service.Config{PingTimeout: time.Second}, clock.Real{}, zap.NewNop())
+	serv := New(context.Background(), srv, &logger.Config{}, &Config{}, zap.NewNop(), repo)
+
+	req := httptest.NewRequest(http.MethodPost, "/links/report", strings.NewReader("links_list=1%2C2"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+
+	serv.Handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+}
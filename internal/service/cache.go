@@ -0,0 +1,69 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"link-service/internal/clock"
+)
+
+type cacheEntry struct {
+	status    string
+	checkedAt time.Time
+}
+
+// linkCache keeps recent link statuses keyed by normalized link.
+// A nil *linkCache is a valid disabled cache.
+type linkCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	clock   clock.Clock
+	entries map[string]cacheEntry
+}
+
+func newLinkCache(ttl time.Duration, clk clock.Clock) *linkCache {
+	if ttl <= 0 {
+		return nil
+	}
+
+	return &linkCache{
+		ttl:     ttl,
+		clock:   clk,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+func (c *linkCache) get(link string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[link]
+	if !ok {
+		return "", false
+	}
+
+	if c.clock.Now().Sub(entry.checkedAt) >= c.ttl {
+		delete(c.entries, link)
+		return "", false
+	}
+
+	return entry.status, true
+}
+
+func (c *linkCache) set(link string, status string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[link] = cacheEntry{
+		status:    status,
+		checkedAt: c.clock.Now(),
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	filesystem "link-service/internal/repository/file_system"
+)
+
+func TestCheckLinkCache(t *testing.T) {
+	tests := []struct {
+		name         string
+		cacheTTL     time.Duration
+		wantRequests int64
+	}{
+		{
+			name:         "cache enabled",
+			cacheTTL:     time.Minute,
+			wantRequests: 1,
+		},
+		{
+			name:         "cache disabled",
+			cacheTTL:     0,
+			wantRequests: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			srv := New(filesystem.NewMockStorage(), &Config{
+				PingTimeout: time.Second,
+				CacheTTL:    tt.cacheTTL,
+			}, clock.Real{}, zap.NewNop())
+
+			assert.Equal(t, domain.StatusAvailable, srv.checkLink(server.URL))
+			assert.Equal(t, domain.StatusAvailable, srv.checkLink(server.URL+"/"))
+			assert.Equal(t, tt.wantRequests, requests.Load())
+		})
+	}
+}
+
+func TestCheckLinkCacheExpiry(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	clk := clock.NewFake(time.Date(2025, 11, 30, 12, 0, 0, 0, time.UTC))
+	srv := New(filesystem.NewMockStorage(), &Config{
+		PingTimeout: time.Second,
+		CacheTTL:    time.Minute,
+	}, clk, zap.NewNop())
+
+	srv.checkLink(server.URL)
+	clk.Advance(59 * time.Second)
+	srv.checkLink(server.URL)
+	assert.Equal(t, int64(1), requests.Load())
+
+	clk.Advance(time.Second)
+	srv.checkLink(server.URL)
+	assert.Equal(t, int64(2), requests.Load())
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	filesystem "link-service/internal/repository/file_system"
+)
+
+func TestCheckMethod(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		checkMethod string
+		wantStatus  string
+		wantMethod  string
+	}{
+		{
+			name:        "head",
+			checkMethod: CheckMethodHead,
+			wantStatus:  domain.StatusNotAvailable,
+			wantMethod:  http.MethodHead,
+		},
+		{
+			name:        "get",
+			checkMethod: CheckMethodGet,
+			wantStatus:  domain.StatusAvailable,
+			wantMethod:  http.MethodGet,
+		},
+		{
+			name:        "auto",
+			checkMethod: CheckMethodAuto,
+			wantStatus:  domain.StatusAvailable,
+			wantMethod:  http.MethodGet,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(filesystem.NewMockStorage(), &Config{
+				PingTimeout: time.Second,
+				CheckMethod: tt.checkMethod,
+			}, clock.Real{}, zap.NewNop())
+
+			assert.Equal(t, tt.wantStatus, srv.checkLink(server.URL))
+
+			u, err := normalizeLink(server.URL)
+			assert.NoError(t, err)
+
+			_, method, err := srv.ping(u)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantMethod, method)
+		})
+	}
+}
+
+func TestCheckMethodAutoHeadTimeout(t *testing.T) {
+	var gets atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			time.Sleep(200 * time.Millisecond)
+			return
+		}
+
+		gets.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	srv := New(filesystem.NewMockStorage(), &Config{
+		PingTimeout: 50 * time.Millisecond,
+		CheckMethod: CheckMethodAuto,
+	}, clock.Real{}, zap.NewNop())
+
+	u, err := normalizeLink(server.URL)
+	assert.NoError(t, err)
+
+	_, method, err := srv.ping(u)
+	assert.Error(t, err)
+	assert.Equal(t, http.MethodHead, method)
+	assert.Equal(t, int32(0), gets.Load())
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/metrics"
+)
+
+const defaultHealthCheckInterval = time.Minute
+
+// health holds the settings and the last results of the health checks.
+type health struct {
+	maxRecordAge time.Duration
+	interval     time.Duration
+	staleRecords atomic.Int64
+}
+
+// RunHealthChecks checks for stale records right away and then on every
+// interval tick until ctx is done.
+func (s *Service) RunHealthChecks(ctx context.Context) {
+	interval := s.health.interval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := s.CheckStaleRecords(ctx)
+		if err != nil {
+			s.logger.Error("failed to check stale records", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckStaleRecords counts the records that have not been updated for longer
+// than the configured maximum age, warns about them and remembers the count
+// for StaleRecords. Records without timestamps are not counted since their
+// age is unknown.
+func (s *Service) CheckStaleRecords(ctx context.Context) (int, error) {
+	if s.health.maxRecordAge <= 0 {
+		return 0, nil
+	}
+
+	threshold := s.clock.Now().Add(-s.health.maxRecordAge)
+	stale := 0
+
+	err := s.repository.ForEachRecord(ctx, func(rec *domain.Record) error {
+		updatedAt := rec.UpdatedAt
+		if updatedAt.IsZero() {
+			updatedAt = rec.CreatedAt
+		}
+
+		if !updatedAt.IsZero() && updatedAt.Before(threshold) {
+			stale++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.health.staleRecords.Store(int64(stale))
+
+	if stale > 0 {
+		metrics.StaleRecordsTotal.Add(float64(stale))
+		s.logger.Warn("records have stale link statuses",
+			zap.Int("stale_records", stale),
+			zap.Duration("max_record_age", s.health.maxRecordAge),
+		)
+	}
+
+	return stale, nil
+}
+
+// StaleRecords returns the number of stale records found by the last check.
+func (s *Service) StaleRecords() int64 {
+	return s.health.staleRecords.Load()
+}
+
+// RecordCount returns the approximate number of stored records.
+func (s *Service) RecordCount() int64 {
+	return s.repository.Len()
+}
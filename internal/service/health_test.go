@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	filesystem "link-service/internal/repository/file_system"
+)
+
+func TestCheckStaleRecords(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 11, 30, 12, 0, 0, 0, time.UTC))
+	storage, err := filesystem.New(&filesystem.Config{
+		DirPath:      t.TempDir(),
+		FileName:     "data.json",
+		TempFileName: "temp.json",
+	}, clk, zap.NewNop())
+	assert.NoError(t, err)
+
+	for _, id := range []int64{1, 2} {
+		err = storage.SaveRecord(context.Background(), &domain.Record{ID: id, Links: map[string]string{"a.com": domain.StatusAvailable}})
+		assert.NoError(t, err)
+	}
+
+	clk.Advance(25 * time.Hour)
+
+	err = storage.SaveRecord(context.Background(), &domain.Record{ID: 3, Links: map[string]string{"a.com": domain.StatusAvailable}})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		maxRecordAge time.Duration
+		want         int
+	}{
+		{
+			name:         "disabled",
+			maxRecordAge: 0,
+			want:         0,
+		},
+		{
+			name:         "two stale",
+			maxRecordAge: 24 * time.Hour,
+			want:         2,
+		},
+		{
+			name:         "none stale",
+			maxRecordAge: 48 * time.Hour,
+			want:         0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(storage, &Config{PingTimeout: time.Second, MaxRecordAgeBeforeWarn: tt.maxRecordAge}, clk, zap.NewNop())
+
+			stale, err := srv.CheckStaleRecords(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, stale)
+			assert.Equal(t, int64(tt.want), srv.StaleRecords())
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	filesystem "link-service/internal/repository/file_system"
+)
+
+// batchRecorder records the size of every SaveRecords call.
+type batchRecorder struct {
+	*filesystem.MockStorage
+	batches []int
+	ids     []int64
+}
+
+func (br *batchRecorder) SaveRecords(ctx context.Context, records []*domain.Record) error {
+	br.batches = append(br.batches, len(records))
+	for _, rec := range records {
+		br.ids = append(br.ids, rec.ID)
+	}
+
+	return nil
+}
+
+func jsonArray(n int) string {
+	records := make([]string, 0, n)
+	for id := 1; id <= n; id++ {
+		records = append(records, fmt.Sprintf(`{"links":{"https://example-%d.com":"available"},"links_num":%d}`, id, id))
+	}
+
+	return "[" + strings.Join(records, ",") + "]"
+}
+
+func TestImportJSONArrayBatches(t *testing.T) {
+	repo := &batchRecorder{MockStorage: filesystem.NewMockStorage()}
+	srv := New(repo, &Config{PingTimeout: time.Second, ImportBatchSize: 3}, clock.Real{}, zap.NewNop())
+
+	imported, err := srv.ImportJSONArray(context.Background(), strings.NewReader(jsonArray(7)))
+	assert.NoError(t, err)
+	assert.Equal(t, 7, imported)
+	assert.Equal(t, []int{3, 3, 1}, repo.batches)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7}, repo.ids)
+	assert.Equal(t, int64(7), srv.counter)
+}
+
+func TestImportJSONArrayPartialBatchOnError(t *testing.T) {
+	repo := &batchRecorder{MockStorage: filesystem.NewMockStorage()}
+	srv := New(repo, &Config{PingTimeout: time.Second, ImportBatchSize: 2}, clock.Real{}, zap.NewNop())
+
+	body := strings.TrimSuffix(jsonArray(3), "]") + `,{"links_num":"four"}]`
+
+	imported, err := srv.ImportJSONArray(context.Background(), strings.NewReader(body))
+	assert.Error(t, err)
+	assert.Equal(t, 2, imported)
+	assert.Equal(t, []int{2}, repo.batches)
+}
+
+func BenchmarkImportJSONArray(b *testing.B) {
+	const records = 2000
+	body := jsonArray(records)
+
+	for _, batchSize := range []int{1, 500} {
+		b.Run(fmt.Sprintf("batch=%d", batchSize), func(b *testing.B) {
+			for b.Loop() {
+				b.StopTimer()
+				storage, err := filesystem.New(&filesystem.Config{
+					DirPath:      b.TempDir(),
+					FileName:     "data.json",
+					TempFileName: "temp.json",
+				}, clock.Real{}, zap.NewNop())
+				if err != nil {
+					b.Fatal(err)
+				}
+				srv := New(storage, &Config{PingTimeout: time.Second, ImportBatchSize: batchSize}, clock.Real{}, zap.NewNop())
+				b.StartTimer()
+
+				_, err = srv.ImportJSONArray(context.Background(), strings.NewReader(body))
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+)
+
+const (
+	JobStatusPending = "pending"
+	JobStatusRunning = "running"
+	JobStatusDone    = "done"
+	JobStatusFailed  = "failed"
+
+	defaultJobTTL = time.Hour
+)
+
+var ErrJobNotFound = errors.New("job not found")
+
+// Job is an asynchronous check of a list of links. Its record is set once
+// the links are checked and the record is saved, or once the links are saved
+// as a temp record because the application is stopping.
+type Job struct {
+	ID         string         `json:"id"`
+	Status     string         `json:"status"`
+	Total      int            `json:"total"`
+	Checked    int            `json:"checked"`
+	Record     *domain.Record `json:"record,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	FinishedAt time.Time      `json:"finished_at,omitzero"`
+}
+
+// LinkUpdate is the status of a link of a job, sent as soon as the link is
+// checked.
+type LinkUpdate struct {
+	Link    string `json:"link"`
+	Status  string `json:"status"`
+	Checked int    `json:"checked"`
+	Total   int    `json:"total"`
+}
+
+// jobState is a job with the updates of its links. changed is closed and
+// replaced on every change to wake up the watchers.
+type jobState struct {
+	job     Job
+	updates []LinkUpdate
+	changed chan struct{}
+}
+
+// jobs keeps the jobs in memory. Finished jobs are dropped ttl after they
+// finish, and all jobs are lost on restart.
+type jobs struct {
+	mu      sync.Mutex
+	byID    map[string]*jobState
+	ttl     time.Duration
+	running sync.WaitGroup
+}
+
+func newJobs(ttl time.Duration) *jobs {
+	if ttl <= 0 {
+		ttl = defaultJobTTL
+	}
+
+	return &jobs{
+		byID: make(map[string]*jobState),
+		ttl:  ttl,
+	}
+}
+
+// SubmitJob starts checking the links in the background and returns the
+// pending job at once. The job runs until it is done or ctx, the server
+// context, is done, in which case it fails with ErrAppStopped and its links
+// are saved as a temp record, like in Process, so ProcessTempRecords checks
+// them on the next start under the ID of the job record.
+func (s *Service) SubmitJob(ctx context.Context, links []string, tags []string) (*Job, error) {
+	if ctx.Err() != nil {
+		return nil, ErrAppStopped
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	state := &jobState{
+		job: Job{
+			ID:        id,
+			Status:    JobStatusPending,
+			Total:     len(links),
+			CreatedAt: now,
+		},
+		changed: make(chan struct{}),
+	}
+
+	s.jobs.mu.Lock()
+	s.jobs.prune(now)
+	s.jobs.byID[id] = state
+	snapshot := state.job
+	s.jobs.mu.Unlock()
+
+	s.jobs.running.Add(1)
+	go func() {
+		defer s.jobs.running.Done()
+		s.runJob(ctx, id, links, tags)
+	}()
+
+	s.logger.Info("job submitted", zap.String("job_id", id), zap.Int("links", len(links)))
+	return &snapshot, nil
+}
+
+// GetJob returns a copy of the job with the ID.
+func (s *Service) GetJob(id string) (*Job, error) {
+	s.jobs.mu.Lock()
+	defer s.jobs.mu.Unlock()
+
+	state, ok := s.jobs.byID[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	snapshot := state.job
+	return &snapshot, nil
+}
+
+// WatchJob calls send with every link update of the job, the past ones
+// first, until the job finishes, and then returns the finished job. It stops
+// early with the error of send or of ctx.
+func (s *Service) WatchJob(ctx context.Context, id string, send func(update LinkUpdate) error) (*Job, error) {
+	sent := 0
+	for {
+		s.jobs.mu.Lock()
+		state, ok := s.jobs.byID[id]
+		if !ok {
+			s.jobs.mu.Unlock()
+			return nil, ErrJobNotFound
+		}
+
+		updates := state.updates[sent:]
+		snapshot := state.job
+		changed := state.changed
+		s.jobs.mu.Unlock()
+
+		for _, update := range updates {
+			err := send(update)
+			if err != nil {
+				return nil, err
+			}
+		}
+		sent += len(updates)
+
+		if !snapshot.FinishedAt.IsZero() {
+			return &snapshot, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// WaitJobs blocks until the running jobs return. Call it after the server
+// context is done and before the storage is closed.
+func (s *Service) WaitJobs() {
+	s.jobs.running.Wait()
+}
+
+func (s *Service) runJob(ctx context.Context, id string, links []string, tags []string) {
+	s.updateJob(id, func(state *jobState) {
+		state.job.Status = JobStatusRunning
+	})
+
+	rec := s.newRecord(tags)
+	err := s.checkAndSave(ctx, rec, links, func(link, status string) {
+		s.updateJob(id, func(state *jobState) {
+			state.job.Checked++
+			state.updates = append(state.updates, LinkUpdate{
+				Link:    link,
+				Status:  status,
+				Checked: state.job.Checked,
+				Total:   state.job.Total,
+			})
+		})
+	})
+
+	if err != nil && ctx.Err() != nil {
+		err = s.saveJobTempRecord(ctx, rec, links)
+	}
+
+	now := s.clock.Now()
+	if err != nil {
+		s.logger.Error("job failed", zap.String("job_id", id), zap.Error(err))
+		s.updateJob(id, func(state *jobState) {
+			state.job.Status = JobStatusFailed
+			state.job.Error = err.Error()
+			state.job.FinishedAt = now
+			if errors.Is(err, ErrAppStopped) {
+				state.job.Record = rec
+			}
+		})
+		return
+	}
+
+	s.logger.Info("job done", zap.String("job_id", id), zap.Object("record", rec))
+	s.updateJob(id, func(state *jobState) {
+		state.job.Status = JobStatusDone
+		state.job.Record = rec
+		state.job.FinishedAt = now
+	})
+}
+
+// saveJobTempRecord saves the links of a job stopped by the server context as
+// a temp record, the links not checked yet with the unknown status. It
+// returns ErrAppStopped once the record is saved.
+func (s *Service) saveJobTempRecord(ctx context.Context, rec *domain.Record, links []string) error {
+	for _, link := range links {
+		if _, ok := rec.Links[link]; !ok {
+			rec.Links[link] = domain.StatusUnknown
+		}
+	}
+
+	// The server context is done, but the storage stays open until the jobs
+	// return, see WaitJobs.
+	err := s.repository.SaveTempRecord(context.WithoutCancel(ctx), rec)
+	if err != nil {
+		s.logger.Error("failed to save temp record", zap.Object("record", rec), zap.Error(err))
+		return fmt.Errorf("failed to save temp record: %w", err)
+	}
+
+	return ErrAppStopped
+}
+
+// updateJob changes the job with fn and wakes up its watchers.
+func (s *Service) updateJob(id string, fn func(state *jobState)) {
+	s.jobs.mu.Lock()
+	defer s.jobs.mu.Unlock()
+
+	state, ok := s.jobs.byID[id]
+	if !ok {
+		return
+	}
+
+	fn(state)
+	close(state.changed)
+	state.changed = make(chan struct{})
+}
+
+// prune drops the jobs that finished longer than ttl ago. The caller must
+// hold the lock.
+func (j *jobs) prune(now time.Time) {
+	for id, state := range j.byID {
+		if !state.job.FinishedAt.IsZero() && now.Sub(state.job.FinishedAt) > j.ttl {
+			delete(j.byID, id)
+		}
+	}
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository/inmemory"
+)
+
+func TestSubmitJob(t *testing.T) {
+	okServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer okServer.Close()
+
+	clk := clock.NewFake(time.Date(2025, 11, 30, 12, 0, 0, 0, time.UTC))
+	repo, err := inmemory.New(&inmemory.Config{}, clk, zap.NewNop())
+	assert.NoError(t, err)
+
+	srv := New(repo, &Config{PingTimeout: time.Second, JobTTL: time.Minute}, clk, zap.NewNop())
+
+	job, err := srv.SubmitJob(context.Background(), []string{okServer.URL, "invalid link %"}, []string{"prod"})
+	assert.NoError(t, err)
+	assert.Equal(t, JobStatusPending, job.Status)
+	assert.Equal(t, 2, job.Total)
+
+	srv.WaitJobs()
+
+	done, err := srv.GetJob(job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, JobStatusDone, done.Status)
+	assert.Equal(t, 2, done.Checked)
+	assert.Equal(t, int64(1), done.Record.ID)
+	assert.Equal(t, map[string]string{okServer.URL: domain.StatusAvailable, "invalid link %": domain.StatusNotAvailable}, done.Record.Links)
+
+	stored, err := repo.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prod"}, stored.Tags)
+
+	_, err = srv.GetJob("unknown")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	// Finished jobs are dropped on the next submit after their TTL.
+	clk.Advance(2 * time.Minute)
+
+	_, err = srv.SubmitJob(context.Background(), []string{okServer.URL}, nil)
+	assert.NoError(t, err)
+	srv.WaitJobs()
+
+	_, err = srv.GetJob(job.ID)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestSubmitJobStopped(t *testing.T) {
+	repo, err := inmemory.New(&inmemory.Config{}, clock.Real{}, zap.NewNop())
+	assert.NoError(t, err)
+
+	srv := New(repo, &Config{PingTimeout: time.Second}, clock.Real{}, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = srv.SubmitJob(ctx, []string{"a.com"}, nil)
+	assert.ErrorIs(t, err, ErrAppStopped)
+}
+
+func TestSubmitJobStoppedWhileRunning(t *testing.T) {
+	// The second link answers only after the server context is done, so the
+	// job stops before the third link.
+	release := make(chan struct{})
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/b" {
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slowServer.Close()
+
+	repo, err := inmemory.New(&inmemory.Config{}, clock.Real{}, zap.NewNop())
+	assert.NoError(t, err)
+
+	srv := New(repo, &Config{PingTimeout: 5 * time.Second, CheckMethod: CheckMethodHead}, clock.Real{}, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	links := []string{slowServer.URL + "/a", slowServer.URL + "/b", slowServer.URL + "/c"}
+	job, err := srv.SubmitJob(ctx, links, []string{"prod"})
+	assert.NoError(t, err)
+
+	_, err = srv.WatchJob(context.Background(), job.ID, func(update LinkUpdate) error {
+		if update.Checked == 1 {
+			cancel()
+			close(release)
+		}
+
+		return nil
+	})
+	assert.NoError(t, err)
+	srv.WaitJobs()
+
+	failed, err := srv.GetJob(job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, JobStatusFailed, failed.Status)
+	assert.Equal(t, ErrAppStopped.Error(), failed.Error)
+	assert.Equal(t, int64(1), failed.Record.ID)
+
+	// The links are kept for ProcessTempRecords under the ID of the job.
+	temp, err := repo.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, temp, 1)
+	assert.Equal(t, int64(1), temp[0].ID)
+	assert.Equal(t, []string{"prod"}, temp[0].Tags)
+	assert.Equal(t, map[string]string{
+		links[0]: domain.StatusAvailable,
+		links[1]: domain.StatusAvailable,
+		links[2]: domain.StatusUnknown,
+	}, temp[0].Links)
+
+	// The ID stays taken.
+	rec, err := srv.Process(context.Background(), context.Background(), []string{slowServer.URL + "/a"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), rec.ID)
+}
+
+func TestWatchJob(t *testing.T) {
+	// The second link answers only after the first update was watched, so
+	// the updates are sent while the job is running.
+	release := make(chan struct{})
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/b" {
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slowServer.Close()
+
+	repo, err := inmemory.New(&inmemory.Config{}, clock.Real{}, zap.NewNop())
+	assert.NoError(t, err)
+
+	srv := New(repo, &Config{PingTimeout: 5 * time.Second, CheckMethod: CheckMethodHead}, clock.Real{}, zap.NewNop())
+
+	job, err := srv.SubmitJob(context.Background(), []string{slowServer.URL + "/a", slowServer.URL + "/b"}, nil)
+	assert.NoError(t, err)
+
+	var updates []LinkUpdate
+	done, err := srv.WatchJob(context.Background(), job.ID, func(update LinkUpdate) error {
+		updates = append(updates, update)
+		if len(updates) == 1 {
+			close(release)
+		}
+
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, JobStatusDone, done.Status)
+	assert.Equal(t, []LinkUpdate{
+		{Link: slowServer.URL + "/a", Status: domain.StatusAvailable, Checked: 1, Total: 2},
+		{Link: slowServer.URL + "/b", Status: domain.StatusAvailable, Checked: 2, Total: 2},
+	}, updates)
+
+	_, err = srv.WatchJob(context.Background(), "unknown", func(LinkUpdate) error { return nil })
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
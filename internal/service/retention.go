@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+)
+
+const defaultRetentionInterval = time.Hour
+
+// retention holds the settings of the retention job.
+type retention struct {
+	window   time.Duration
+	interval time.Duration
+}
+
+// RunRetention prunes expired records right away and then on every interval
+// tick until ctx is done. It returns at once if retention is disabled.
+func (s *Service) RunRetention(ctx context.Context) {
+	if s.retention.window <= 0 {
+		return
+	}
+
+	interval := s.retention.interval
+	if interval <= 0 {
+		interval = defaultRetentionInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := s.PruneRecords(ctx)
+		if err != nil {
+			s.logger.Error("failed to prune records", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PruneRecords deletes the records created longer ago than the retention
+// window and returns how many were deleted. Records without CreatedAt are
+// kept since their age is unknown. Storages that delete with tombstones,
+// like the filesystem one, free the space on their next compaction.
+func (s *Service) PruneRecords(ctx context.Context) (int, error) {
+	if s.retention.window <= 0 {
+		return 0, nil
+	}
+
+	threshold := s.clock.Now().Add(-s.retention.window)
+
+	var expired []int64
+	err := s.repository.ForEachRecord(ctx, func(rec *domain.Record) error {
+		if !rec.CreatedAt.IsZero() && rec.CreatedAt.Before(threshold) {
+			expired = append(expired, rec.ID)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	for _, id := range expired {
+		err = s.DeleteRecord(ctx, id)
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			s.logger.Error("failed to delete expired record", zap.Int64("id", id), zap.Error(err))
+			return pruned, err
+		}
+
+		pruned++
+	}
+
+	if pruned > 0 {
+		s.logger.Info("pruned expired records",
+			zap.Int("pruned", pruned),
+			zap.Duration("retention", s.retention.window),
+		)
+	}
+
+	return pruned, nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	filesystem "link-service/internal/repository/file_system"
+)
+
+func TestPruneRecords(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 11, 30, 12, 0, 0, 0, time.UTC))
+	storage, err := filesystem.New(&filesystem.Config{
+		DirPath:      t.TempDir(),
+		FileName:     "data.json",
+		TempFileName: "temp.json",
+	}, clk, zap.NewNop())
+	assert.NoError(t, err)
+
+	for _, id := range []int64{1, 2} {
+		err = storage.SaveRecord(context.Background(), &domain.Record{ID: id, Links: map[string]string{"a.com": domain.StatusAvailable}})
+		assert.NoError(t, err)
+	}
+
+	clk.Advance(3 * 24 * time.Hour)
+
+	err = storage.SaveRecord(context.Background(), &domain.Record{ID: 3, Links: map[string]string{"a.com": domain.StatusAvailable}})
+	assert.NoError(t, err)
+
+	clk.Advance(24 * time.Hour)
+
+	srv := New(storage, &Config{PingTimeout: time.Second}, clk, zap.NewNop())
+	pruned, err := srv.PruneRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, pruned)
+
+	srv = New(storage, &Config{PingTimeout: time.Second, RetentionDays: 2}, clk, zap.NewNop())
+	pruned, err = srv.PruneRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, pruned)
+
+	ids, err := storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3}, ids)
+
+	pruned, err = srv.PruneRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, pruned)
+}
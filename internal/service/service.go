@@ -2,26 +2,34 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"slices"
 	"strings"
 	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
 
+	"link-service/internal/clock"
 	"link-service/internal/domain"
 	"link-service/internal/repository"
 )
 
 const (
-	statusAvailable    = "available"
-	statusNotAvailable = "not available"
-	statusUnknown      = "unknown"
-
 	httpsPrefix = "https://"
 	httpPrefix  = "http://"
+
+	CheckMethodHead = "head"
+	CheckMethodGet  = "get"
+	CheckMethodAuto = "auto"
+
+	defaultImportBatchSize = 500
 )
 
 var (
@@ -29,45 +37,139 @@ var (
 )
 
 type Config struct {
-	PingTimeout time.Duration `env:"SERVICE_PING_TIMEOUT" env-required:"true"`
+	PingTimeout  time.Duration `env:"SERVICE_PING_TIMEOUT"`
+	TimeoutRules TimeoutRules  `env:"SERVICE_TIMEOUT_RULES"`
+	CacheTTL     time.Duration `env:"SERVICE_CACHE_TTL" env-default:"0s"`
+	CheckMethod  string        `env:"SERVICE_CHECK_METHOD" env-default:"auto"`
+	// ImportBatchSize is the number of imported records saved per storage
+	// call. Zero uses defaultImportBatchSize.
+	ImportBatchSize int `env:"SERVICE_IMPORT_BATCH_SIZE" env-default:"500"`
+	// MaxRecordAgeBeforeWarn is the age after which a record that has not
+	// been updated counts as stale. Zero disables the stale records check.
+	MaxRecordAgeBeforeWarn time.Duration `env:"SERVICE_MAX_RECORD_AGE_BEFORE_WARN" env-default:"24h"`
+	HealthCheckInterval    time.Duration `env:"SERVICE_HEALTH_CHECK_INTERVAL" env-default:"1m"`
+	// RetentionDays is the age in days after which records are deleted by
+	// RunRetention. Zero keeps records forever.
+	RetentionDays     int           `env:"SERVICE_RETENTION_DAYS" env-default:"0"`
+	RetentionInterval time.Duration `env:"SERVICE_RETENTION_INTERVAL" env-default:"1h"`
+	// JobTTL is how long a finished job submitted with SubmitJob can be
+	// polled. Zero uses defaultJobTTL.
+	JobTTL time.Duration `env:"SERVICE_JOB_TTL" env-default:"1h"`
+}
+
+// Validate reports every missing or invalid field of the config.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.PingTimeout <= 0 {
+		errs = append(errs, errors.New("SERVICE_PING_TIMEOUT is required and must be positive"))
+	}
+
+	if c.CacheTTL < 0 {
+		errs = append(errs, errors.New("SERVICE_CACHE_TTL must not be negative"))
+	}
+
+	if c.ImportBatchSize < 0 {
+		errs = append(errs, errors.New("SERVICE_IMPORT_BATCH_SIZE must not be negative"))
+	}
+
+	if c.MaxRecordAgeBeforeWarn < 0 {
+		errs = append(errs, errors.New("SERVICE_MAX_RECORD_AGE_BEFORE_WARN must not be negative"))
+	}
+
+	if c.HealthCheckInterval < 0 {
+		errs = append(errs, errors.New("SERVICE_HEALTH_CHECK_INTERVAL must not be negative"))
+	}
+
+	if c.RetentionDays < 0 {
+		errs = append(errs, errors.New("SERVICE_RETENTION_DAYS must not be negative"))
+	}
+
+	if c.RetentionInterval < 0 {
+		errs = append(errs, errors.New("SERVICE_RETENTION_INTERVAL must not be negative"))
+	}
+
+	if c.JobTTL < 0 {
+		errs = append(errs, errors.New("SERVICE_JOB_TTL must not be negative"))
+	}
+
+	switch c.CheckMethod {
+	case "", CheckMethodHead, CheckMethodGet, CheckMethodAuto:
+	default:
+		errs = append(errs, fmt.Errorf("SERVICE_CHECK_METHOD must be head, get or auto, got %q", c.CheckMethod))
+	}
+
+	return errors.Join(errs...)
 }
 
 type Service struct {
-	counter    int64
-	repository repository.Repository
-	httpClient *http.Client
-	logger     *zap.Logger
+	counter      int64
+	repository   repository.Repository
+	httpClient   *http.Client
+	pingTimeout  time.Duration
+	timeoutRules TimeoutRules
+	checkMethod  string
+	cache        *linkCache
+	importBatch  int
+	health       health
+	retention    retention
+	jobs         *jobs
+	updates      updates
+	clock        clock.Clock
+	logger       *zap.Logger
 }
 
-func New(repo repository.Repository, cfg *Config, logger *zap.Logger) *Service {
-	lastLinksNum := repo.LoadLastLinksNum()
+func New(repo repository.Repository, cfg *Config, clk clock.Clock, logger *zap.Logger) *Service {
+	lastLinksNum := repo.LoadLastLinksNum(context.Background())
+
+	importBatch := cfg.ImportBatchSize
+	if importBatch <= 0 {
+		importBatch = defaultImportBatchSize
+	}
 
 	return &Service{
 		repository: repo,
 		counter:    lastLinksNum,
 		httpClient: &http.Client{
-			Timeout: cfg.PingTimeout,
+			Transport: &http.Transport{
+				Proxy:       http.ProxyFromEnvironment,
+				DialContext: dialContext,
+			},
 		},
+		pingTimeout:  cfg.PingTimeout,
+		timeoutRules: cfg.TimeoutRules,
+		checkMethod:  cfg.CheckMethod,
+		cache:        newLinkCache(cfg.CacheTTL, clk),
+		importBatch:  importBatch,
+		health: health{
+			maxRecordAge: cfg.MaxRecordAgeBeforeWarn,
+			interval:     cfg.HealthCheckInterval,
+		},
+		retention: retention{
+			window:   time.Duration(cfg.RetentionDays) * 24 * time.Hour,
+			interval: cfg.RetentionInterval,
+		},
+		jobs:   newJobs(cfg.JobTTL),
+		clock:  clk,
 		logger: logger,
 	}
 }
 
-func (s *Service) Process(serverCtx context.Context, requestCtx context.Context, links []string) (*domain.Record, error) {
-	s.incCounter()
-	rec := &domain.Record{
-		Links: make(map[string]string),
-		ID:    s.counter,
-	}
+func (s *Service) Process(serverCtx context.Context, requestCtx context.Context, links []string, tags []string) (*domain.Record, error) {
+	rec := s.newRecord(tags)
 
 	select {
 	case <-serverCtx.Done():
 		for _, link := range links {
-			rec.Links[link] = statusUnknown
+			rec.Links[link] = domain.StatusUnknown
 		}
 
-		err := s.repository.SaveTempRecord(rec)
+		err := s.repository.SaveTempRecord(requestCtx, rec)
+		if errors.Is(err, repository.ErrTempFileFull) {
+			s.logger.Error("temp storage is full, dropping record", zap.Object("record", rec), zap.Error(err))
+			return nil, fmt.Errorf("failed to save temp record: %w", err)
+		}
 		if err != nil {
-			s.decCounter()
 			s.logger.Error("failed to save temp record", zap.Error(err))
 			return nil, fmt.Errorf("failed to save temp record: %w", err)
 		}
@@ -77,67 +179,139 @@ func (s *Service) Process(serverCtx context.Context, requestCtx context.Context,
 	default:
 	}
 
+	err := s.checkAndSave(requestCtx, rec, links, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("success process record", zap.Object("record", rec))
+	return rec, nil
+}
+
+// newRecord takes the next ID for a record submitted through the API.
+func (s *Service) newRecord(tags []string) *domain.Record {
+	return &domain.Record{
+		Links:  make(map[string]string),
+		ID:     s.incCounter(),
+		Source: domain.SourceAPI,
+		Tags:   normalizeTags(tags),
+	}
+}
+
+// checkAndSave checks the links of rec, calling progress with the status of
+// each link if it is not nil, and saves the record. On failure the ID of rec
+// is left unused: giving it back could hand out an ID taken since by a
+// concurrent record.
+func (s *Service) checkAndSave(ctx context.Context, rec *domain.Record, links []string, progress func(link, status string)) error {
 	for _, link := range links {
 		select {
-		case <-requestCtx.Done():
-			s.decCounter()
-			s.logger.Info(requestCtx.Err().Error(), zap.String("link", link))
-			return nil, requestCtx.Err()
+		case <-ctx.Done():
+			s.logger.Info(ctx.Err().Error(), zap.String("link", link))
+			return ctx.Err()
 
 		default:
 		}
 
-		statusCode, err := s.ping(link)
-		if err != nil || statusCode != http.StatusOK {
-			s.logger.Warn("failed to ping link", zap.String("link", link), zap.Error(err))
-			rec.Links[link] = statusNotAvailable
-		} else {
-			rec.Links[link] = statusAvailable
+		rec.Links[link] = s.checkLink(link)
+		if progress != nil {
+			progress(link, rec.Links[link])
 		}
 	}
 
-	err := s.repository.SaveRecord(rec)
+	err := s.repository.SaveRecord(ctx, rec)
 	if err != nil {
-		s.decCounter()
-		s.logger.Error("failed to save record", zap.Error(err))
-		return nil, fmt.Errorf("failed to save record: %w", err)
+		s.logger.Error("failed to save record", zap.Object("record", rec), zap.Error(err))
+		return fmt.Errorf("failed to save record: %w", err)
 	}
 
-	s.logger.Info("success process record")
-	return rec, nil
+	s.recordChecked(ctx, rec)
+	s.publish(domain.EventTypeCreated, rec)
+	return nil
+}
+
+// TempRecordPromoter is a storage that stores the processed temp records and
+// clears the temp records in one crash-safe step.
+type TempRecordPromoter interface {
+	PromoteTempRecords(ctx context.Context, records []*domain.Record) (int, error)
 }
 
-func (s *Service) ProcessTempRecords() error {
-	records, err := s.repository.LoadTempRecords()
+// ProcessTempRecords checks the links of records saved while the application
+// was stopping and moves them to the main storage under the IDs the clients
+// were given. Records already present in the main storage with the same links
+// are skipped, so the flush can be safely repeated after a crash. The temp
+// records are kept if a processed record cannot be stored, and a storage
+// that is a TempRecordPromoter also keeps them until the processed ones are
+// durably stored.
+func (s *Service) ProcessTempRecords(ctx context.Context) error {
+	records, err := s.repository.LoadTempRecords(ctx)
 	if err != nil {
 		s.logger.Error("failed to load temp records", zap.Error(err))
 		return fmt.Errorf("failed to load temp records: %w", err)
 	}
 
+	if len(records) == 0 {
+		s.logger.Info("no temp records to process")
+		return nil
+	}
+
+	processed := make([]*domain.Record, 0, len(records))
 	for _, tempRec := range records {
-		s.incCounter()
+		id, flushed, err := s.resolveTempRecordID(ctx, &tempRec)
+		if err != nil {
+			s.logger.Error("failed to check temp record", zap.Int64("id", tempRec.ID), zap.Error(err))
+			return fmt.Errorf("failed to check temp record: %w", err)
+		}
+
+		if flushed {
+			s.logger.Info("temp record already flushed", zap.Object("record", &tempRec))
+			continue
+		}
+
 		rec := &domain.Record{
-			ID:    s.counter,
-			Links: make(map[string]string),
+			ID:     id,
+			Links:  make(map[string]string),
+			Source: domain.SourceAPI,
+			Tags:   tempRec.Tags,
 		}
 
 		for link := range tempRec.Links {
-			statusCode, err := s.ping(link)
-			if err != nil || statusCode != http.StatusOK {
-				rec.Links[link] = statusNotAvailable
-			} else {
-				rec.Links[link] = statusAvailable
-			}
+			rec.Links[link] = s.checkLink(link)
 		}
 
-		err = s.repository.SaveRecord(rec)
+		processed = append(processed, rec)
+	}
+
+	promoter, ok := repository.As[TempRecordPromoter](s.repository)
+	if ok {
+		promoted, err := promoter.PromoteTempRecords(ctx, processed)
 		if err != nil {
-			s.logger.Error("failed to save processed temp record", zap.Int64("id", rec.ID), zap.Error(err))
-			continue
+			s.logger.Error("failed to promote temp records", zap.Error(err))
+			return fmt.Errorf("failed to promote temp records: %w", err)
+		}
+
+		for _, rec := range processed {
+			s.recordChecked(ctx, rec)
+			s.publish(domain.EventTypeCreated, rec)
 		}
+
+		s.logger.Info("successfully processed temp records", zap.Int("promoted", promoted))
+		return nil
 	}
 
-	err = s.repository.ClearTempFile()
+	// The temp records are kept if a record cannot be saved. The records
+	// saved so far are skipped as flushed when they are processed again.
+	for _, rec := range processed {
+		err = s.repository.SaveRecord(ctx, rec)
+		if err != nil {
+			s.logger.Error("failed to save processed temp record", zap.Object("record", rec), zap.Error(err))
+			return fmt.Errorf("failed to save processed temp record: %w", err)
+		}
+
+		s.recordChecked(ctx, rec)
+		s.publish(domain.EventTypeCreated, rec)
+	}
+
+	err = s.repository.ClearTempFile(ctx)
 	if err != nil {
 		s.logger.Error("failed to clear temp file", zap.Error(err))
 		return fmt.Errorf("failed to clear temp file: %w", err)
@@ -147,28 +321,298 @@ func (s *Service) ProcessTempRecords() error {
 	return nil
 }
 
-func (s *Service) ping(link string) (int, error) {
+// StatusChange is the previous and the new status of a rechecked link.
+type StatusChange struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// RecheckRecord checks the links of a stored record again and updates the
+// record with the new statuses. It returns the updated record and the links
+// whose status changed. It fails with repository.ErrRecordNotFound if the
+// record is not stored and stops with the context error once ctx is done.
+func (s *Service) RecheckRecord(ctx context.Context, id int64) (*domain.Record, map[string]StatusChange, error) {
+	rec, err := s.repository.GetRecord(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changed := make(map[string]StatusChange)
+	for link, old := range rec.Links {
+		err = ctx.Err()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		status := s.checkLink(link)
+		if status != old {
+			changed[link] = StatusChange{Old: old, New: status}
+		}
+
+		rec.Links[link] = status
+	}
+
+	err = s.repository.UpdateRecord(ctx, rec)
+	if err != nil {
+		s.logger.Error("failed to update record", zap.Object("record", rec), zap.Error(err))
+		return nil, nil, fmt.Errorf("failed to update record: %w", err)
+	}
+
+	s.recordChecked(ctx, rec)
+	s.publish(domain.EventTypeUpdated, rec)
+
+	s.logger.Info("success recheck record", zap.Object("record", rec), zap.Int("changed", len(changed)))
+	return rec, changed, nil
+}
+
+// DeleteRecord deletes the record with the ID. It fails with
+// repository.ErrRecordNotFound if the record is not stored.
+func (s *Service) DeleteRecord(ctx context.Context, id int64) error {
+	err := s.repository.DeleteRecord(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	s.publish(domain.EventTypeDeleted, &domain.Record{ID: id})
+	return nil
+}
+
+// recordChecked logs a checked event with the link statuses of the saved
+// record. The record is already stored, so a failure is only logged.
+func (s *Service) recordChecked(ctx context.Context, rec *domain.Record) {
+	err := s.repository.SaveRecordEvent(ctx, &domain.RecordEvent{
+		RecordID:  rec.ID,
+		EventType: domain.EventTypeChecked,
+		Detail:    domain.StatusesDetail(rec.Links),
+	})
+	if err != nil {
+		s.logger.Warn("failed to save checked event", zap.Int64("id", rec.ID), zap.Error(err))
+	}
+}
+
+// resolveTempRecordID returns the ID to save the temp record under, or
+// flushed=true if the main storage already holds it. The temp record keeps
+// its ID unless another record took it, in which case a new ID is assigned.
+func (s *Service) resolveTempRecordID(ctx context.Context, tempRec *domain.Record) (int64, bool, error) {
+	if tempRec.ID <= 0 {
+		return s.incCounter(), false, nil
+	}
+
+	stored, err := s.repository.GetRecord(ctx, tempRec.ID)
+	switch {
+	case errors.Is(err, repository.ErrRecordNotFound):
+		s.advanceCounter(tempRec.ID)
+		return tempRec.ID, false, nil
+
+	case err != nil:
+		return 0, false, err
+
+	case linksChecksum(stored.Links) == linksChecksum(tempRec.Links):
+		s.advanceCounter(tempRec.ID)
+		return tempRec.ID, true, nil
+
+	default:
+		id := s.incCounter()
+		s.logger.Warn("temp record id is taken, assigning a new one",
+			zap.Int64("id", tempRec.ID),
+			zap.Int64("new_id", id),
+		)
+		return id, false, nil
+	}
+}
+
+// normalizeTags trims tags and drops empty and repeated ones.
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || slices.Contains(normalized, tag) {
+			continue
+		}
+
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
+
+// linksChecksum hashes the set of links of a record, ignoring their statuses.
+func linksChecksum(links map[string]string) string {
+	keys := make([]string, 0, len(links))
+	for link := range links {
+		keys = append(keys, link)
+	}
+	slices.Sort(keys)
+
+	sum := sha256.Sum256([]byte(strings.Join(keys, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkLink returns the status of the link, reusing a cached status
+// while it is fresh.
+func (s *Service) checkLink(link string) string {
+	u, err := normalizeLink(link)
+	if err != nil {
+		s.logger.Warn("failed to parse link", zap.String("link", link), zap.Error(err))
+		return domain.StatusNotAvailable
+	}
+
+	key := u.String()
+	if status, ok := s.cache.get(key); ok {
+		return status
+	}
+
+	status := domain.StatusAvailable
+	statusCode, method, err := s.ping(u)
+	if err != nil || statusCode != http.StatusOK {
+		s.logger.Warn("failed to ping link", zap.String("link", link), zap.String("method", method), zap.Error(err))
+		status = domain.StatusNotAvailable
+	} else {
+		s.logger.Debug("link checked", zap.String("link", link), zap.String("method", method))
+	}
+
+	s.cache.set(key, status)
+
+	return status
+}
+
+// normalizeLink adds the default scheme and lowercases the scheme and host,
+// so that equivalent links share a cache entry.
+func normalizeLink(link string) (*url.URL, error) {
 	if !strings.HasPrefix(link, httpPrefix) && !strings.HasPrefix(link, httpsPrefix) {
 		link = httpsPrefix + link
 	}
 
-	resp, err := s.httpClient.Head(link)
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	if u.Path == "/" {
+		u.Path = ""
+	}
+
+	return u, nil
+}
+
+// ImportJSONArray saves the records of a JSON array keeping their IDs and moves
+// the counter past the highest imported ID. Records without a source are
+// marked as imported. Records are saved in batches of the configured size and
+// the returned count only includes saved batches.
+func (s *Service) ImportJSONArray(ctx context.Context, r io.Reader) (int, error) {
+	imported := 0
+	batch := make([]*domain.Record, 0, s.importBatch)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		err := s.repository.SaveRecords(ctx, batch)
+		if err != nil {
+			return fmt.Errorf("failed to save records: %w", err)
+		}
+
+		for _, rec := range batch {
+			s.advanceCounter(rec.ID)
+			s.publish(domain.EventTypeCreated, rec)
+		}
+
+		imported += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	_, err := repository.ReadJSONArray(r, func(rec *domain.Record) error {
+		if rec.Source == "" {
+			rec.Source = domain.SourceImport
+		}
+
+		batch = append(batch, rec)
+		if len(batch) < s.importBatch {
+			return nil
+		}
+
+		return flush()
+	})
 	if err == nil {
-		return resp.StatusCode, nil
+		err = flush()
+	}
+	if err != nil {
+		s.logger.Error("failed to import records", zap.Int("imported", imported), zap.Error(err))
+		return imported, fmt.Errorf("failed to import records: %w", err)
 	}
 
-	resp, err = s.httpClient.Get(link)
+	s.logger.Info("successfully imported records", zap.Int("imported", imported))
+	return imported, nil
+}
+
+// ping requests the link with the configured check method and returns the
+// status code together with the HTTP method that determined it. In auto mode
+// a HEAD request falls back to GET only when the server answers that it does
+// not support HEAD, with 405 or 501. A failed HEAD request fails the ping.
+func (s *Service) ping(u *url.URL) (int, string, error) {
+	if s.checkMethod != CheckMethodGet {
+		statusCode, err := s.doRequest(u, http.MethodHead)
+		if err != nil {
+			return 0, http.MethodHead, fmt.Errorf("failed to ping link: %w", err)
+		}
+
+		if s.checkMethod == CheckMethodHead || (statusCode != http.StatusMethodNotAllowed && statusCode != http.StatusNotImplemented) {
+			return statusCode, http.MethodHead, nil
+		}
+	}
+
+	statusCode, err := s.doRequest(u, http.MethodGet)
+	if err != nil {
+		return 0, http.MethodGet, fmt.Errorf("failed to ping link: %w", err)
+	}
+
+	return statusCode, http.MethodGet, nil
+}
+
+// doRequest requests the link with the method under the timeouts of its
+// host, so a GET after a HEAD gets the full timeout again.
+func (s *Service) doRequest(u *url.URL, method string) (int, error) {
+	connectTimeout, timeout := s.timeoutsFor(u)
+
+	ctx, cancel := context.WithTimeout(withConnectTimeout(context.Background(), connectTimeout), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		return 0, fmt.Errorf("failed to ping link: %w", err)
+		return 0, err
 	}
+	defer resp.Body.Close()
 
 	return resp.StatusCode, nil
 }
 
-func (s *Service) incCounter() {
-	atomic.AddInt64(&s.counter, 1)
+// incCounter takes the next ID and returns it.
+func (s *Service) incCounter() int64 {
+	return atomic.AddInt64(&s.counter, 1)
+}
+
+// SyncCounter moves the ID counter to the last stored ID unless it is
+// already past it, e.g. after the storage was restored from a backup.
+func (s *Service) SyncCounter(ctx context.Context) {
+	s.advanceCounter(s.repository.LoadLastLinksNum(ctx))
 }
 
-func (s *Service) decCounter() {
-	atomic.AddInt64(&s.counter, -1)
+// advanceCounter moves the counter to id unless it is already past it.
+func (s *Service) advanceCounter(id int64) {
+	for {
+		current := atomic.LoadInt64(&s.counter)
+		if id <= current || atomic.CompareAndSwapInt64(&s.counter, current, id) {
+			return
+		}
+	}
 }
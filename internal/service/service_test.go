@@ -2,17 +2,31 @@ package service
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 
+	"link-service/internal/clock"
 	"link-service/internal/domain"
+	"link-service/internal/repository"
 	filesystem "link-service/internal/repository/file_system"
 )
 
 func TestProcess(t *testing.T) {
+	okServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer okServer.Close()
+
+	notFoundServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer notFoundServer.Close()
+
 	tests := []struct {
 		name       string
 		serverCtx  context.Context
@@ -26,15 +40,16 @@ func TestProcess(t *testing.T) {
 			serverCtx:  context.Background(),
 			requestCtx: context.Background(),
 			links: []string{
-				"google.com",
-				"yandex.ru",
+				okServer.URL,
+				okServer.URL + "/path",
 			},
 			wantRec: &domain.Record{
 				Links: map[string]string{
-					"google.com": statusAvailable,
-					"yandex.ru":  statusAvailable,
+					okServer.URL:           domain.StatusAvailable,
+					okServer.URL + "/path": domain.StatusAvailable,
 				},
-				ID: 1,
+				ID:     1,
+				Source: domain.SourceAPI,
 			},
 			wantErr: nil,
 		},
@@ -43,15 +58,16 @@ func TestProcess(t *testing.T) {
 			serverCtx:  context.Background(),
 			requestCtx: context.Background(),
 			links: []string{
-				"12dqf4wgf4.com",
-				"yandex.ru",
+				notFoundServer.URL,
+				okServer.URL,
 			},
 			wantRec: &domain.Record{
 				Links: map[string]string{
-					"12dqf4wgf4.com": statusNotAvailable,
-					"yandex.ru":      statusAvailable,
+					notFoundServer.URL: domain.StatusNotAvailable,
+					okServer.URL:       domain.StatusAvailable,
 				},
-				ID: 1,
+				ID:     1,
+				Source: domain.SourceAPI,
 			},
 			wantErr: nil,
 		},
@@ -69,10 +85,11 @@ func TestProcess(t *testing.T) {
 			},
 			wantRec: &domain.Record{
 				Links: map[string]string{
-					"google.com": statusUnknown,
-					"yandex.ru":  statusUnknown,
+					"google.com": domain.StatusUnknown,
+					"yandex.ru":  domain.StatusUnknown,
 				},
-				ID: 1,
+				ID:     1,
+				Source: domain.SourceAPI,
 			},
 			wantErr: ErrAppStopped,
 		},
@@ -95,11 +112,70 @@ func TestProcess(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			srv := New(filesystem.NewMockStorage(), 30*time.Second, zap.NewNop())
+			srv := New(filesystem.NewMockStorage(), &Config{PingTimeout: 30 * time.Second}, clock.Real{}, zap.NewNop())
 
-			gotRec, err := srv.Process(tt.serverCtx, tt.requestCtx, tt.links)
+			gotRec, err := srv.Process(tt.serverCtx, tt.requestCtx, tt.links, nil)
 			assert.Equal(t, tt.wantErr, err)
 			assert.Equal(t, tt.wantRec, gotRec)
 		})
 	}
 }
+
+func TestProcessTags(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	storage, err := filesystem.New(&filesystem.Config{
+		DirPath:      t.TempDir(),
+		FileName:     "data.json",
+		TempFileName: "temp.json",
+	}, clock.Real{}, zap.NewNop())
+	assert.NoError(t, err)
+
+	srv := New(storage, &Config{PingTimeout: time.Second}, clock.Real{}, zap.NewNop())
+
+	rec, err := srv.Process(context.Background(), context.Background(), []string{server.URL}, []string{" prod ", "docs", "prod", ""})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prod", "docs"}, rec.Tags)
+
+	stored, err := storage.GetRecord(context.Background(), rec.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prod", "docs"}, stored.Tags)
+}
+
+func TestRecheckRecord(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	storage, err := filesystem.New(&filesystem.Config{
+		DirPath:      t.TempDir(),
+		FileName:     "data.json",
+		TempFileName: "temp.json",
+	}, clock.Real{}, zap.NewNop())
+	assert.NoError(t, err)
+
+	err = storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{server.URL: domain.StatusNotAvailable}, ID: 1})
+	assert.NoError(t, err)
+
+	srv := New(storage, &Config{PingTimeout: time.Second}, clock.Real{}, zap.NewNop())
+
+	rec, changed, err := srv.RecheckRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.StatusAvailable, rec.Links[server.URL])
+	assert.Equal(t, map[string]StatusChange{server.URL: {Old: domain.StatusNotAvailable, New: domain.StatusAvailable}}, changed)
+
+	stored, err := storage.GetRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.StatusAvailable, stored.Links[server.URL])
+
+	_, changed, err = srv.RecheckRecord(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Empty(t, changed)
+
+	_, _, err = srv.RecheckRecord(context.Background(), 2)
+	assert.ErrorIs(t, err, repository.ErrRecordNotFound)
+}
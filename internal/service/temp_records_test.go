@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository"
+	filesystem "link-service/internal/repository/file_system"
+	"link-service/internal/repository/inmemory"
+)
+
+func TestProcessTempRecordsIdempotent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	storage, err := filesystem.New(&filesystem.Config{
+		DirPath:      t.TempDir(),
+		FileName:     "data.json",
+		TempFileName: "temp.json",
+	}, clock.Real{}, zap.NewNop())
+	assert.NoError(t, err)
+
+	err = storage.SaveRecord(context.Background(), &domain.Record{Links: map[string]string{server.URL + "/1": domain.StatusAvailable}, ID: 1})
+	assert.NoError(t, err)
+
+	tempRecords := []*domain.Record{
+		{Links: map[string]string{server.URL + "/2": domain.StatusUnknown, server.URL + "/3": domain.StatusUnknown}, ID: 2},
+		{Links: map[string]string{server.URL + "/4": domain.StatusUnknown}, ID: 3},
+	}
+	for _, rec := range tempRecords {
+		err = storage.SaveTempRecord(context.Background(), rec)
+		assert.NoError(t, err)
+	}
+
+	// Simulate a crash after the first temp record reached the main file
+	// but before the temp file was cleared.
+	err = storage.SaveRecord(context.Background(), &domain.Record{
+		Links: map[string]string{server.URL + "/2": domain.StatusAvailable, server.URL + "/3": domain.StatusAvailable},
+		ID:    2,
+	})
+	assert.NoError(t, err)
+
+	srv := New(storage, &Config{PingTimeout: time.Second}, clock.Real{}, zap.NewNop())
+	err = srv.ProcessTempRecords(context.Background())
+	assert.NoError(t, err)
+
+	ids, err := storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+
+	rec, err := storage.GetRecord(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{server.URL + "/4": domain.StatusAvailable}, rec.Links)
+
+	tempRecs, err := storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, tempRecs)
+
+	// Running the flush again must not change anything either.
+	err = srv.ProcessTempRecords(context.Background())
+	assert.NoError(t, err)
+
+	ids, err = storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+	assert.Equal(t, int64(3), srv.counter)
+}
+
+// crashingPromoter stores the first processed record and fails, like a
+// process killed part way through the promotion of the temp records.
+type crashingPromoter struct {
+	*filesystem.Storage
+}
+
+func (cp *crashingPromoter) PromoteTempRecords(ctx context.Context, records []*domain.Record) (int, error) {
+	err := cp.SaveRecord(ctx, records[0])
+	if err != nil {
+		return 0, err
+	}
+
+	return 0, errors.New("crashed")
+}
+
+func TestProcessTempRecordsPromoteCrash(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	storage, err := filesystem.New(&filesystem.Config{
+		DirPath:      t.TempDir(),
+		FileName:     "data.json",
+		TempFileName: "temp.json",
+	}, clock.Real{}, zap.NewNop())
+	assert.NoError(t, err)
+
+	for _, rec := range []*domain.Record{
+		{Links: map[string]string{server.URL + "/1": domain.StatusUnknown}, ID: 1},
+		{Links: map[string]string{server.URL + "/2": domain.StatusUnknown}, ID: 2},
+	} {
+		assert.NoError(t, storage.SaveTempRecord(context.Background(), rec))
+	}
+
+	srv := New(&crashingPromoter{Storage: storage}, &Config{PingTimeout: time.Second}, clock.Real{}, zap.NewNop())
+	err = srv.ProcessTempRecords(context.Background())
+	assert.ErrorContains(t, err, "crashed")
+
+	// The temp records outlive the failed promotion.
+	tempRecs, err := storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, tempRecs, 2)
+
+	srv = New(storage, &Config{PingTimeout: time.Second}, clock.Real{}, zap.NewNop())
+	err = srv.ProcessTempRecords(context.Background())
+	assert.NoError(t, err)
+
+	ids, err := storage.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, ids)
+	assert.Equal(t, int64(2), storage.Len())
+
+	rec, err := storage.GetRecord(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{server.URL + "/2": domain.StatusAvailable}, rec.Links)
+
+	tempRecs, err = storage.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, tempRecs)
+}
+
+// failingSaver fails to save the record with the ID. Embedding the interface
+// hides the optional capabilities, so the service takes its fallback path.
+type failingSaver struct {
+	repository.Repository
+	id int64
+}
+
+func (fs *failingSaver) SaveRecord(ctx context.Context, rec *domain.Record) error {
+	if rec.ID == fs.id {
+		return errors.New("disk full")
+	}
+
+	return fs.Repository.SaveRecord(ctx, rec)
+}
+
+func TestProcessTempRecordsSaveFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	repo, err := inmemory.New(&inmemory.Config{}, clock.Real{}, zap.NewNop())
+	assert.NoError(t, err)
+
+	for _, rec := range []*domain.Record{
+		{Links: map[string]string{server.URL + "/1": domain.StatusUnknown}, ID: 1},
+		{Links: map[string]string{server.URL + "/2": domain.StatusUnknown}, ID: 2},
+	} {
+		assert.NoError(t, repo.SaveTempRecord(context.Background(), rec))
+	}
+
+	srv := New(&failingSaver{Repository: repo, id: 2}, &Config{PingTimeout: time.Second}, clock.Real{}, zap.NewNop())
+	err = srv.ProcessTempRecords(context.Background())
+	assert.ErrorContains(t, err, "disk full")
+
+	// The temp records outlive the failed save.
+	tempRecs, err := repo.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, tempRecs, 2)
+
+	srv = New(repo, &Config{PingTimeout: time.Second}, clock.Real{}, zap.NewNop())
+	err = srv.ProcessTempRecords(context.Background())
+	assert.NoError(t, err)
+
+	ids, err := repo.ListRecordIDs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, ids)
+
+	tempRecs, err = repo.LoadTempRecords(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, tempRecs)
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TimeoutRule overrides the ping timeouts for links matching its scheme and host.
+// An empty Scheme matches any scheme, a Host starting with "*." matches the
+// domain and all of its subdomains, and an empty Host matches any host.
+type TimeoutRule struct {
+	Scheme         string
+	Host           string
+	ConnectTimeout time.Duration
+	Timeout        time.Duration
+}
+
+// TimeoutRules is a list of timeout rules read from a single env variable in the
+// form "[scheme://]host=connect/overall,...", e.g.
+// "https://*.corp.local=5s/1m,http://10.0.0.1=1s/10s". The first matching rule wins.
+type TimeoutRules []TimeoutRule
+
+func (tr *TimeoutRules) SetValue(s string) error {
+	*tr = nil
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		rule, err := parseTimeoutRule(item)
+		if err != nil {
+			return err
+		}
+
+		*tr = append(*tr, rule)
+	}
+
+	return nil
+}
+
+func parseTimeoutRule(s string) (TimeoutRule, error) {
+	var rule TimeoutRule
+
+	pattern, timeouts, ok := strings.Cut(s, "=")
+	if !ok {
+		return rule, fmt.Errorf("invalid timeout rule %q: missing '='", s)
+	}
+
+	scheme, host, ok := strings.Cut(pattern, "://")
+	if !ok {
+		scheme, host = "", pattern
+	}
+
+	connect, overall, ok := strings.Cut(timeouts, "/")
+	if !ok {
+		return rule, fmt.Errorf("invalid timeout rule %q: timeouts must be connect/overall", s)
+	}
+
+	connectTimeout, err := time.ParseDuration(connect)
+	if err != nil {
+		return rule, fmt.Errorf("invalid connect timeout in rule %q: %w", s, err)
+	}
+
+	timeout, err := time.ParseDuration(overall)
+	if err != nil {
+		return rule, fmt.Errorf("invalid timeout in rule %q: %w", s, err)
+	}
+
+	return TimeoutRule{
+		Scheme:         strings.ToLower(scheme),
+		Host:           strings.ToLower(host),
+		ConnectTimeout: connectTimeout,
+		Timeout:        timeout,
+	}, nil
+}
+
+func (r TimeoutRule) match(u *url.URL) bool {
+	if r.Scheme != "" && r.Scheme != strings.ToLower(u.Scheme) {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+
+	switch {
+	case r.Host == "" || r.Host == "*":
+		return true
+
+	case strings.HasPrefix(r.Host, "*."):
+		domain := r.Host[2:]
+		return host == domain || strings.HasSuffix(host, "."+domain)
+
+	default:
+		return host == r.Host
+	}
+}
+
+// timeoutsFor returns the connect and overall timeouts for the link, falling
+// back to the default ping timeout when no rule matches.
+func (s *Service) timeoutsFor(u *url.URL) (time.Duration, time.Duration) {
+	for _, rule := range s.timeoutRules {
+		if rule.match(u) {
+			return rule.ConnectTimeout, rule.Timeout
+		}
+	}
+
+	return s.pingTimeout, s.pingTimeout
+}
+
+type connectTimeoutKey struct{}
+
+func withConnectTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, connectTimeoutKey{}, timeout)
+}
+
+// dialContext applies the connect timeout carried by the request context.
+func dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := net.Dialer{}
+	if timeout, ok := ctx.Value(connectTimeoutKey{}).(time.Duration); ok && timeout > 0 {
+		dialer.Timeout = timeout
+	}
+
+	return dialer.DialContext(ctx, network, addr)
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	filesystem "link-service/internal/repository/file_system"
+)
+
+func TestTimeoutRulesSetValue(t *testing.T) {
+	var rules TimeoutRules
+	err := rules.SetValue("https://*.corp.local=5s/1m, 10.0.0.1=1s/10s")
+	assert.NoError(t, err)
+	assert.Equal(t, TimeoutRules{
+		{Scheme: "https", Host: "*.corp.local", ConnectTimeout: 5 * time.Second, Timeout: time.Minute},
+		{Scheme: "", Host: "10.0.0.1", ConnectTimeout: time.Second, Timeout: 10 * time.Second},
+	}, rules)
+
+	err = rules.SetValue("corp.local=5s")
+	assert.Error(t, err)
+}
+
+func TestTimeoutsFor(t *testing.T) {
+	srv := New(filesystem.NewMockStorage(), &Config{
+		PingTimeout: time.Second,
+		TimeoutRules: TimeoutRules{
+			{Scheme: "https", Host: "*.corp.local", ConnectTimeout: 5 * time.Second, Timeout: time.Minute},
+			{Host: "10.0.0.1", ConnectTimeout: 2 * time.Second, Timeout: 20 * time.Second},
+		},
+	}, clock.Real{}, zap.NewNop())
+
+	tests := []struct {
+		name        string
+		link        string
+		wantConnect time.Duration
+		wantTimeout time.Duration
+	}{
+		{
+			name:        "subdomain of internal host",
+			link:        "https://wiki.corp.local/page",
+			wantConnect: 5 * time.Second,
+			wantTimeout: time.Minute,
+		},
+		{
+			name:        "internal domain itself",
+			link:        "https://corp.local",
+			wantConnect: 5 * time.Second,
+			wantTimeout: time.Minute,
+		},
+		{
+			name:        "scheme mismatch",
+			link:        "http://wiki.corp.local",
+			wantConnect: time.Second,
+			wantTimeout: time.Second,
+		},
+		{
+			name:        "any scheme",
+			link:        "http://10.0.0.1:8080",
+			wantConnect: 2 * time.Second,
+			wantTimeout: 20 * time.Second,
+		},
+		{
+			name:        "external host",
+			link:        "https://google.com",
+			wantConnect: time.Second,
+			wantTimeout: time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.link)
+			assert.NoError(t, err)
+
+			connect, timeout := srv.timeoutsFor(u)
+			assert.Equal(t, tt.wantConnect, connect)
+			assert.Equal(t, tt.wantTimeout, timeout)
+		})
+	}
+}
+
+func TestPingTimeoutRule(t *testing.T) {
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slowServer.Close()
+
+	tests := []struct {
+		name       string
+		rules      TimeoutRules
+		wantStatus string
+	}{
+		{
+			name:       "default timeout",
+			wantStatus: domain.StatusNotAvailable,
+		},
+		{
+			name: "internal host rule",
+			rules: TimeoutRules{
+				{Host: "127.0.0.1", ConnectTimeout: time.Second, Timeout: 5 * time.Second},
+			},
+			wantStatus: domain.StatusAvailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(filesystem.NewMockStorage(), &Config{
+				PingTimeout:  50 * time.Millisecond,
+				TimeoutRules: tt.rules,
+			}, clock.Real{}, zap.NewNop())
+
+			assert.Equal(t, tt.wantStatus, srv.checkLink(slowServer.URL))
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+
+	"link-service/internal/domain"
+)
+
+// updatesBuffer is the number of updates a subscriber may fall behind
+// before further updates are dropped for it.
+const updatesBuffer = 64
+
+// RecordUpdate is a record created, updated or deleted through the service.
+// Type is domain.EventTypeCreated, domain.EventTypeUpdated or
+// domain.EventTypeDeleted; a deleted record only carries its ID. Subscribers
+// share the record and must not modify it.
+type RecordUpdate struct {
+	Type   string         `json:"type"`
+	Record *domain.Record `json:"record"`
+}
+
+// updates fans out record updates to the subscribers.
+type updates struct {
+	mu   sync.Mutex
+	subs map[chan RecordUpdate]struct{}
+}
+
+// SubscribeUpdates returns a channel of the records created or updated from
+// now on and a function that ends the subscription. A subscriber that does
+// not keep up misses updates rather than slowing the service down.
+func (s *Service) SubscribeUpdates() (<-chan RecordUpdate, func()) {
+	ch := make(chan RecordUpdate, updatesBuffer)
+
+	s.updates.mu.Lock()
+	if s.updates.subs == nil {
+		s.updates.subs = make(map[chan RecordUpdate]struct{})
+	}
+	s.updates.subs[ch] = struct{}{}
+	s.updates.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			s.updates.mu.Lock()
+			delete(s.updates.subs, ch)
+			s.updates.mu.Unlock()
+		})
+	}
+
+	return ch, cancel
+}
+
+// publish sends the update of the record to every subscriber.
+func (s *Service) publish(eventType string, rec *domain.Record) {
+	s.updates.mu.Lock()
+	defer s.updates.mu.Unlock()
+
+	for ch := range s.updates.subs {
+		select {
+		case ch <- RecordUpdate{Type: eventType, Record: rec}:
+		default:
+			s.logger.Warn("subscriber is behind, dropping record update", zap.Int64("id", rec.ID))
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"link-service/internal/clock"
+	"link-service/internal/domain"
+	"link-service/internal/repository/inmemory"
+)
+
+func TestSubscribeUpdates(t *testing.T) {
+	okServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer okServer.Close()
+
+	repo, err := inmemory.New(&inmemory.Config{}, clock.Real{}, zap.NewNop())
+	assert.NoError(t, err)
+
+	srv := New(repo, &Config{PingTimeout: time.Second}, clock.Real{}, zap.NewNop())
+
+	updates, cancel := srv.SubscribeUpdates()
+
+	rec, err := srv.Process(context.Background(), context.Background(), []string{okServer.URL}, nil)
+	assert.NoError(t, err)
+
+	_, _, err = srv.RecheckRecord(context.Background(), rec.ID)
+	assert.NoError(t, err)
+
+	_, err = srv.ImportJSONArray(context.Background(), strings.NewReader(`[{"links_num":10,"links":{"a.com":"available"}}]`))
+	assert.NoError(t, err)
+
+	for _, want := range []struct {
+		eventType string
+		id        int64
+	}{
+		{eventType: domain.EventTypeCreated, id: rec.ID},
+		{eventType: domain.EventTypeUpdated, id: rec.ID},
+		{eventType: domain.EventTypeCreated, id: 10},
+	} {
+		update := <-updates
+		assert.Equal(t, want.eventType, update.Type)
+		assert.Equal(t, want.id, update.Record.ID)
+	}
+
+	cancel()
+
+	_, err = srv.Process(context.Background(), context.Background(), []string{okServer.URL}, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, updates)
+}